@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/justinottesen/japaya/internal/core"
+	"github.com/justinottesen/japaya/internal/python"
+)
+
+// benchResult holds one bench run's measurements; the same shape is saved
+// as the baseline file.
+type benchResult struct {
+	ParserMBPerSec float64 `json:"parser_mb_per_sec"`
+	EvalRTTMicros  float64 `json:"eval_rtt_us"`
+	FilesPerSec    float64 `json:"files_per_sec"`
+}
+
+// runBench implements `japaya bench`: micro-benchmarks for the three costs
+// that dominate a run — parsing, worker round trips, and end-to-end tree
+// translation — against a given tree or a synthetic corpus. With -save the
+// results become a baseline; with -baseline they are compared against one,
+// so a change's effect on throughput shows up as a percentage.
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("japaya bench", flag.ExitOnError)
+
+	var inPath string
+	var pythonCmd string
+	var pythonDir string
+	var files int
+	var rounds int
+	var baselinePath string
+	var savePath string
+
+	fs.StringVar(&inPath, "in", "", "tree to benchmark against (default: a synthetic corpus)")
+	fs.StringVar(&pythonCmd, "python", "", "python executable (default: python3/python)")
+	fs.StringVar(&pythonDir, "python-dir", "", "directory added to Python module search path for snippets (optional)")
+	fs.IntVar(&files, "files", 50, "synthetic corpus size when -in is not given")
+	fs.IntVar(&rounds, "rounds", 200, "worker round trips to time")
+	fs.StringVar(&baselinePath, "baseline", "", "baseline JSON to compare against (optional)")
+	fs.StringVar(&savePath, "save", "", "write this run's results as a baseline JSON (optional)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: japaya bench [-in <dir>] [-files <n>] [-rounds <n>] [-baseline <json>] [-save <json>] [-python <python>] [-python-dir <dir>]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	if err := validatePythonDir(pythonDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if inPath == "" {
+		dir, err := os.MkdirTemp("", "japaya-bench-*")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer os.RemoveAll(dir)
+		if err := writeSyntheticCorpus(dir, files); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		inPath = dir
+	}
+
+	res, err := runBenchmarks(inPath, pythonCmd, pythonDir, rounds)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var baseline *benchResult
+	if baselinePath != "" {
+		data, err := os.ReadFile(baselinePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		baseline = &benchResult{}
+		if err := json.Unmarshal(data, baseline); err != nil {
+			fmt.Fprintf(os.Stderr, "parse baseline %q: %v\n", baselinePath, err)
+			return 1
+		}
+	}
+
+	printMetric("parser throughput", res.ParserMBPerSec, "MB/s", baselineOf(baseline, func(b *benchResult) float64 { return b.ParserMBPerSec }))
+	printMetric("worker round-trip", res.EvalRTTMicros, "us", baselineOf(baseline, func(b *benchResult) float64 { return b.EvalRTTMicros }))
+	printMetric("end-to-end", res.FilesPerSec, "files/s", baselineOf(baseline, func(b *benchResult) float64 { return b.FilesPerSec }))
+
+	if savePath != "" {
+		data, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if err := os.WriteFile(savePath, append(data, '\n'), 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Fprintln(os.Stderr, "japaya bench: baseline saved to", savePath)
+	}
+	return 0
+}
+
+// baselineOf plucks one metric out of an optional baseline; 0 means "no
+// baseline" (no real measurement is ever exactly zero).
+func baselineOf(b *benchResult, metric func(*benchResult) float64) float64 {
+	if b == nil {
+		return 0
+	}
+	return metric(b)
+}
+
+// printMetric prints one measurement line, with the baseline value and the
+// percentage change when a baseline is present.
+func printMetric(name string, value float64, unit string, baseline float64) {
+	if baseline == 0 {
+		fmt.Printf("%-18s %10.1f %s\n", name+":", value, unit)
+		return
+	}
+	delta := (value - baseline) / baseline * 100
+	fmt.Printf("%-18s %10.1f %s  (baseline %.1f, %+.1f%%)\n", name+":", value, unit, baseline, delta)
+}
+
+// runBenchmarks takes the three measurements against the tree at inPath.
+func runBenchmarks(inPath, pythonCmd, pythonDir string, rounds int) (benchResult, error) {
+	var res benchResult
+
+	// Collect the corpus once; parser throughput re-parses it from memory so
+	// disk reads don't pollute the number.
+	var sources [][]byte
+	probe := &core.Translator{}
+	err := filepath.WalkDir(inPath, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil || d.IsDir() || !probe.ShouldTranslatePath(path) {
+			return walkErr
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, data)
+		return nil
+	})
+	if err != nil {
+		return res, err
+	}
+	if len(sources) == 0 {
+		return res, fmt.Errorf("no translatable files under %q", inPath)
+	}
+
+	// Parser throughput: re-parse the corpus until enough time has elapsed
+	// for a stable rate.
+	var parsedBytes int64
+	start := time.Now()
+	for time.Since(start) < 300*time.Millisecond {
+		for _, src := range sources {
+			if _, err := core.ParseBytes(src); err != nil {
+				return res, fmt.Errorf("parse corpus: %w", err)
+			}
+			parsedBytes += int64(len(src))
+		}
+	}
+	res.ParserMBPerSec = float64(parsedBytes) / 1e6 / time.Since(start).Seconds()
+
+	py, err := python.NewEvaluator(pythonCmd, pythonDir)
+	if err != nil {
+		return res, err
+	}
+	defer py.Close()
+
+	ctx := context.Background()
+
+	// Worker round-trip latency: trivial statements, so the number is the
+	// protocol floor rather than snippet cost. One warm-up eval absorbs
+	// interpreter startup.
+	if _, err := py.Eval(ctx, core.RegionTypePythonStatement, []byte(`"warm"`)); err != nil {
+		return res, err
+	}
+	start = time.Now()
+	for i := 0; i < rounds; i++ {
+		if _, err := py.Eval(ctx, core.RegionTypePythonStatement, []byte(`"x"`)); err != nil {
+			return res, err
+		}
+	}
+	res.EvalRTTMicros = float64(time.Since(start).Microseconds()) / float64(rounds)
+
+	// End-to-end files/second: a full tree translation into a scratch dir.
+	outRoot, err := os.MkdirTemp("", "japaya-bench-out-*")
+	if err != nil {
+		return res, err
+	}
+	defer os.RemoveAll(outRoot)
+	tr := &core.Translator{Py: py}
+	start = time.Now()
+	if err := tr.TranslateTree(ctx, inPath, filepath.Join(outRoot, "out")); err != nil {
+		return res, err
+	}
+	res.FilesPerSec = float64(len(sources)) / time.Since(start).Seconds()
+
+	return res, nil
+}
+
+// writeSyntheticCorpus fills dir with templates shaped like typical japaya
+// input: mostly java with a few statement regions and a block.
+func writeSyntheticCorpus(dir string, files int) error {
+	for i := 0; i < files; i++ {
+		contents := fmt.Sprintf(`public class Gen%d {
+  int a = `+"`%d + 1`"+`;
+  int b = `+"`%d * 2`"+`;
+  String s = "plain java line to keep the parser honest";
+}
+`, i, i, i)
+		name := filepath.Join(dir, fmt.Sprintf("Gen%d.japaya", i))
+		if err := os.WriteFile(name, []byte(contents), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
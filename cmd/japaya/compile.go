@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/justinottesen/japaya/internal/core"
+)
+
+// runCompile invokes javac on the translated output and rewrites its
+// diagnostics to point at the original inputs via the source maps collected
+// during translation. Returns a process exit code.
+func runCompile(maps *core.SourceMaps, outPath, javacCmd, classpath, release string) int {
+	if javacCmd == "" {
+		javacCmd = "javac"
+	}
+
+	files, err := collectJavaFiles(outPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "nothing to compile under", outPath)
+		return 0
+	}
+
+	args := []string{"-proc:none"}
+	if classpath != "" {
+		args = append(args, "-classpath", classpath)
+	}
+	if release != "" {
+		args = append(args, "--release", release)
+	}
+	args = append(args, files...)
+
+	cmd := exec.Command(javacCmd, args...)
+	var stderr bytes.Buffer
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	for _, line := range strings.Split(strings.TrimRight(stderr.String(), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		fmt.Fprintln(os.Stderr, rewriteJavacLine(line, maps))
+	}
+
+	if runErr != nil {
+		var exitErr *exec.ExitError
+		if errors.As(runErr, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintf(os.Stderr, "run %s: %v\n", javacCmd, runErr)
+		return 1
+	}
+	return 0
+}
+
+// collectJavaFiles lists the .java files in the output: either a single file
+// or everything under an output directory.
+func collectJavaFiles(outPath string) ([]string, error) {
+	info, err := os.Stat(outPath)
+	if err != nil {
+		return nil, fmt.Errorf("stat output %q: %w", outPath, err)
+	}
+	if !info.IsDir() {
+		if strings.EqualFold(filepath.Ext(outPath), ".java") {
+			return []string{outPath}, nil
+		}
+		return nil, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(outPath, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".java") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// rewriteJavacLine maps a "path:line: kind: message" diagnostic back to the
+// input file and line that produced the generated code. Lines that don't
+// look like a diagnostic for a mapped file pass through unchanged.
+func rewriteJavacLine(line string, maps *core.SourceMaps) string {
+	if maps == nil {
+		return line
+	}
+
+	// javac prints "<path>:<line>: <rest>"; the path may itself contain
+	// colons on some platforms, so scan for the first ":<digits>:" split.
+	rest := line
+	for {
+		i := strings.Index(rest, ":")
+		if i < 0 {
+			return line
+		}
+		pathEnd := len(line) - len(rest) + i
+		numStart := pathEnd + 1
+		numEnd := numStart
+		for numEnd < len(line) && line[numEnd] >= '0' && line[numEnd] <= '9' {
+			numEnd++
+		}
+		if numEnd > numStart && numEnd < len(line) && line[numEnd] == ':' {
+			outFile := line[:pathEnd]
+			if sm := maps.Lookup(outFile); sm != nil {
+				outLine, _ := strconv.Atoi(line[numStart:numEnd])
+				// javac lines are 1-based; the maps are 0-based.
+				inLine := sm.InputLine(uint(outLine-1)) + 1
+				return fmt.Sprintf("%s:%d:%s (generated at %s:%d)",
+					sm.InPath, inLine, line[numEnd+1:], outFile, outLine)
+			}
+			return line
+		}
+		rest = rest[i+1:]
+	}
+}
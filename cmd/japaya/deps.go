@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/justinottesen/japaya/internal/deps"
+)
+
+// runDeps implements `japaya deps sync`: it reads the [deps] section of
+// japaya.toml, fetches each declared template library into the managed
+// directory, and records the resolved revisions in the lockfile next to the
+// config. Point -python-dir at the managed directory to make the libraries
+// importable from snippets.
+func runDeps(args []string) int {
+	fs := flag.NewFlagSet("japaya deps sync", flag.ExitOnError)
+
+	var configPath string
+	var depsDir string
+
+	fs.StringVar(&configPath, "config", "japaya.toml", "config file with the [deps] section")
+	fs.StringVar(&depsDir, "dir", filepath.Join(".japaya", "deps"), "directory dependencies are fetched into")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: japaya deps sync [-config <toml>] [-dir <dir>]")
+		fs.PrintDefaults()
+	}
+
+	if len(args) < 1 || args[0] != "sync" {
+		fs.Usage()
+		return 2
+	}
+	_ = fs.Parse(args[1:])
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := os.ReadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "no %s found; nothing to sync\n", configPath)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ds, err := deps.ParseConfig(data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	lockPath := filepath.Join(filepath.Dir(configPath), deps.LockFileName)
+	if err := deps.Sync(ds, depsDir, lockPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "japaya deps: %d dependencies in %s\n", len(ds), depsDir)
+	return 0
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/justinottesen/japaya/internal/core"
+	"github.com/justinottesen/japaya/internal/python"
+)
+
+// runEval implements `japaya eval`: run a single snippet through the same
+// worker/prelude configuration as a build and print the result to stdout.
+//
+// With -e, the argument is evaluated as a python statement region.
+// Without -e, a python block is read from stdin and executed.
+func runEval(args []string) int {
+	fs := flag.NewFlagSet("japaya eval", flag.ExitOnError)
+
+	var expr string
+	var pythonCmd string
+	var pythonDir string
+
+	fs.StringVar(&expr, "e", "", "expression to evaluate as a statement region (default: read a block from stdin)")
+	fs.StringVar(&pythonCmd, "python", "", "python executable (default: python3/python)")
+	fs.StringVar(&pythonDir, "python-dir", "", "directory added to Python module search path for snippets (optional)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: japaya eval [-e <expr>] [-python <python>] [-python-dir <dir>]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if err := validatePythonDir(pythonDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	mode := core.RegionTypePythonStatement
+	code := []byte(expr)
+	if expr == "" {
+		// No -e: execute a block read from stdin.
+		var err error
+		code, err = io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed reading stdin:", err)
+			return 1
+		}
+		mode = core.RegionTypePythonBlock
+	}
+
+	py, err := python.NewEvaluator(pythonCmd, pythonDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer func() {
+		if err := py.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "warning: failed to close python worker:", err)
+		}
+	}()
+
+	out, err := py.Eval(context.Background(), mode, code)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	os.Stdout.Write(out)
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		fmt.Println()
+	}
+	return 0
+}
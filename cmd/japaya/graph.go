@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/justinottesen/japaya/internal/core"
+)
+
+// runGraph implements `japaya graph`: a static dependency graph of a
+// template tree. Templates are linked to the top-level python modules their
+// snippets import, and python-dir modules are linked to the sibling modules
+// they import, so large hierarchies can be visualized with standard DOT
+// tooling. Nothing is evaluated; imports are read straight from the source.
+func runGraph(args []string) int {
+	fs := flag.NewFlagSet("japaya graph", flag.ExitOnError)
+
+	var inPath string
+	var pythonDir string
+	var asJSON bool
+	var dollarInterp bool
+
+	fs.StringVar(&inPath, "in", ".", "template tree to scan")
+	fs.StringVar(&pythonDir, "python-dir", "", "directory of python helper modules to include in the graph")
+	fs.BoolVar(&asJSON, "json", false, "emit JSON instead of DOT")
+	fs.BoolVar(&dollarInterp, "dollar-interp", false, "also recognize ${expr} interpolation alongside backtick regions")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: japaya graph [-in <dir>] [-python-dir <dir>] [-json] [-dollar-interp]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	g, err := buildGraph(inPath, pythonDir, core.ParseOptions{DollarInterp: dollarInterp})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if asJSON {
+		if err := g.writeJSON(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	} else {
+		g.writeDOT(os.Stdout)
+	}
+	return 0
+}
+
+// depGraph maps templates and python-dir modules to the modules they import.
+// Both maps hold sorted, deduplicated module names.
+type depGraph struct {
+	Templates map[string][]string `json:"templates"`
+	Modules   map[string][]string `json:"modules"`
+}
+
+// buildGraph scans the template tree and python-dir and records the import
+// edges found in each.
+func buildGraph(inPath, pythonDir string, opts core.ParseOptions) (*depGraph, error) {
+	g := &depGraph{
+		Templates: make(map[string][]string),
+		Modules:   make(map[string][]string),
+	}
+
+	probe := &core.Translator{}
+	err := filepath.WalkDir(inPath, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if name == ".git" || name == "node_modules" || name == "bin" || name == "dist" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !probe.ShouldTranslatePath(path) {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		unit, err := core.ParseBytesWithOptions(data, opts)
+		if err != nil {
+			return fmt.Errorf("parse %q: %w", path, err)
+		}
+
+		var imports []string
+		for _, r := range unit.Regions {
+			if r.Type != core.RegionTypePythonStatement && r.Type != core.RegionTypePythonBlock {
+				continue
+			}
+			imports = append(imports, scanPythonImports(r.Data)...)
+		}
+		g.Templates[path] = sortedUnique(imports)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if pythonDir != "" {
+		entries, err := os.ReadDir(pythonDir)
+		if err != nil {
+			return nil, fmt.Errorf("python-dir: %w", err)
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".py") {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(pythonDir, e.Name()))
+			if err != nil {
+				return nil, err
+			}
+			name := strings.TrimSuffix(e.Name(), ".py")
+			g.Modules[name] = sortedUnique(scanPythonImports(data))
+		}
+	}
+	return g, nil
+}
+
+// scanPythonImports extracts the top-level module names referenced by
+// `import a, b` and `from a.b import c` statements in a python snippet. It is
+// a line-based scan, not a real parser, which matches how the worker reports
+// imports: good enough for graphing, no evaluation required.
+func scanPythonImports(code []byte) []string {
+	var mods []string
+	sc := bufio.NewScanner(bytes.NewReader(code))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(line, "import "):
+			for _, part := range strings.Split(line[len("import "):], ",") {
+				mods = appendModule(mods, part)
+			}
+		case strings.HasPrefix(line, "from "):
+			rest := line[len("from "):]
+			if i := strings.Index(rest, " import "); i >= 0 {
+				mods = appendModule(mods, rest[:i])
+			}
+		}
+	}
+	return mods
+}
+
+// appendModule reduces one import clause to its top-level module name and
+// appends it: "os.path as p" becomes "os".
+func appendModule(mods []string, clause string) []string {
+	clause = strings.TrimSpace(clause)
+	if i := strings.IndexAny(clause, " ."); i >= 0 {
+		clause = clause[:i]
+	}
+	if clause == "" || clause == "." {
+		return mods
+	}
+	return append(mods, clause)
+}
+
+// sortedUnique sorts mods and drops duplicates.
+func sortedUnique(mods []string) []string {
+	sort.Strings(mods)
+	out := mods[:0]
+	for i, m := range mods {
+		if i == 0 || m != mods[i-1] {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// writeDOT renders the graph in Graphviz DOT form. Templates are boxes,
+// modules ellipses; output is sorted so it diffs cleanly.
+func (g *depGraph) writeDOT(w *os.File) {
+	fmt.Fprintln(w, "digraph japaya {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [shape=box];")
+	for _, path := range sortedKeys(g.Templates) {
+		fmt.Fprintf(w, "  %q;\n", path)
+		for _, mod := range g.Templates[path] {
+			fmt.Fprintf(w, "  %q -> %q;\n", path, mod)
+		}
+	}
+	for _, name := range sortedKeys(g.Modules) {
+		fmt.Fprintf(w, "  %q [shape=ellipse];\n", name)
+		for _, mod := range g.Modules[name] {
+			fmt.Fprintf(w, "  %q -> %q;\n", name, mod)
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// writeJSON renders the graph as indented JSON.
+func (g *depGraph) writeJSON(w *os.File) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// sortedKeys returns m's keys in sorted order.
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/justinottesen/japaya/internal/core"
+	"github.com/justinottesen/japaya/internal/python"
+)
+
+// runHook implements `japaya hook -staged`: a pre-commit guard that checks
+// only the files a commit touches. Paths arrive as arguments or, with none
+// given, one per line on stdin — both shapes git hook frameworks produce.
+// Templates under -in are re-translated and compared against the committed
+// outputs under -out; any difference fails the hook so stale generated code
+// never lands. Paths outside -in or not translatable are ignored, and the
+// python worker only starts when there is something to check.
+func runHook(args []string) int {
+	fs := flag.NewFlagSet("japaya hook", flag.ExitOnError)
+
+	var staged bool
+	var inRoot string
+	var outRoot string
+	var pythonCmd string
+	var pythonDir string
+
+	fs.BoolVar(&staged, "staged", false, "check the staged files listed on stdin or as arguments")
+	fs.StringVar(&inRoot, "in", ".", "template root the checked paths live under")
+	fs.StringVar(&outRoot, "out", ".", "output root holding the committed generated files")
+	fs.StringVar(&pythonCmd, "python", "", "python executable (default: python3/python)")
+	fs.StringVar(&pythonDir, "python-dir", "", "directory added to Python module search path for snippets (optional)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: japaya hook -staged [-in <dir>] [-out <dir>] [-python <python>] [-python-dir <dir>] [path ...]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if !staged {
+		fs.Usage()
+		return 2
+	}
+	if err := validatePythonDir(pythonDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		sc := bufio.NewScanner(os.Stdin)
+		for sc.Scan() {
+			if line := strings.TrimSpace(sc.Text()); line != "" {
+				paths = append(paths, line)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	inRoot = filepath.Clean(inRoot)
+	probe := &core.Translator{}
+	type check struct{ in, out string }
+	var checks []check
+	for _, path := range paths {
+		rel, err := filepath.Rel(inRoot, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if !probe.ShouldTranslatePath(path) {
+			continue
+		}
+		checks = append(checks, check{in: path, out: filepath.Join(outRoot, probe.OutputRelPath(rel))})
+	}
+	if len(checks) == 0 {
+		fmt.Fprintln(os.Stderr, "japaya hook: no templates among the staged files")
+		return 0
+	}
+
+	py, err := python.NewEvaluator(pythonCmd, pythonDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer func() {
+		if err := py.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "warning: failed to close python worker:", err)
+		}
+	}()
+
+	tr := &core.Translator{Py: py, Verify: true}
+	ctx := context.Background()
+
+	var stale []string
+	for _, c := range checks {
+		err := tr.TranslateFile(ctx, c.in, c.out)
+		if err == nil {
+			continue
+		}
+		var ve *core.VerifyError
+		if errors.As(err, &ve) {
+			stale = append(stale, c.out)
+			continue
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if len(stale) > 0 {
+		for _, out := range stale {
+			fmt.Fprintf(os.Stderr, "japaya hook: %s is stale; re-run japaya -in %s -out %s and stage the result\n", out, inRoot, outRoot)
+		}
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "japaya hook: %d generated file(s) up to date\n", len(checks))
+	return 0
+}
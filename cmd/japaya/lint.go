@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/justinottesen/japaya/internal/core"
+)
+
+// runLint implements `japaya lint`: statically check templates for common
+// mistakes without evaluating any python. Findings print as compiler-style
+// diagnostics with a rule ID; a `japaya:ignore [rule...]` comment on or above
+// a region suppresses them, and -baseline suppresses findings recorded with
+// -write-baseline. Exits nonzero when anything is found.
+func runLint(args []string) int {
+	fs := flag.NewFlagSet("japaya lint", flag.ExitOnError)
+
+	var inPath string
+	var baselinePath string
+	var writeBaselinePath string
+	fs.StringVar(&inPath, "in", "", "input file or directory to lint")
+	fs.StringVar(&baselinePath, "baseline", "", "suppress findings recorded in this baseline file; new findings still fail")
+	fs.StringVar(&writeBaselinePath, "write-baseline", "", "record all findings to a baseline file for -baseline and exit successfully")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: japaya lint -in <input> [-baseline <file>] [-write-baseline <file>]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if inPath == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var baseline *core.Baseline
+	if baselinePath != "" {
+		b, err := core.LoadBaseline(baselinePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		baseline = b
+	}
+
+	paths, err := lintablePaths(inPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var findings []core.Warning
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		warnings, err := core.LintBytes(data, path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return 1
+		}
+		findings = append(findings, warnings...)
+	}
+
+	if writeBaselinePath != "" {
+		if err := core.WriteBaseline(writeBaselinePath, findings); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "recorded %d finding(s) to %s\n", len(findings), writeBaselinePath)
+		return 0
+	}
+
+	findings = baseline.Filter(findings)
+	for _, w := range findings {
+		fmt.Fprintln(os.Stderr, w)
+	}
+	if len(findings) > 0 {
+		fmt.Fprintf(os.Stderr, "%d finding(s)\n", len(findings))
+		return 1
+	}
+	return 0
+}
+
+// lintablePaths expands a lint target into concrete files: the path itself,
+// or every translatable file under a directory.
+func lintablePaths(inPath string) ([]string, error) {
+	info, err := os.Stat(inPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{inPath}, nil
+	}
+
+	tr := &core.Translator{} // default language decides what gets linted
+	var paths []string
+	err = filepath.WalkDir(inPath, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if name == ".git" || name == "node_modules" || name == "bin" || name == "dist" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if tr.ShouldTranslatePath(path) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
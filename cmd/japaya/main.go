@@ -2,25 +2,181 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/justinottesen/japaya/internal/core"
+	"github.com/justinottesen/japaya/internal/jshell"
+	"github.com/justinottesen/japaya/internal/otel"
 	"github.com/justinottesen/japaya/internal/python"
+	"github.com/justinottesen/japaya/internal/remote"
+	"github.com/justinottesen/japaya/internal/shell"
 )
 
 func main() {
+	// Subcommands; the bare flag form below remains the default build mode.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "eval":
+			os.Exit(runEval(os.Args[2:]))
+		case "repl":
+			os.Exit(runRepl(os.Args[2:]))
+		case "test":
+			os.Exit(runTest(os.Args[2:]))
+		case "lint":
+			os.Exit(runLint(os.Args[2:]))
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		case "serve":
+			os.Exit(runServe(os.Args[2:]))
+		case "deps":
+			os.Exit(runDeps(os.Args[2:]))
+		case "bench":
+			os.Exit(runBench(os.Args[2:]))
+		case "hook":
+			os.Exit(runHook(os.Args[2:]))
+		case "report":
+			os.Exit(runReport(os.Args[2:]))
+		case "graph":
+			os.Exit(runGraph(os.Args[2:]))
+		case "warm":
+			os.Exit(runWarm(os.Args[2:]))
+		case "version":
+			os.Exit(runVersion(os.Args[2:]))
+		}
+	}
+
 	var inPath string
 	var outPath string
+	var resourcesOut string
+	var manageImports bool
+	var packageRoot string
 	var pythonCmd string
 	var pythonDir string
+	var eventsSpec string
+	var jshellCmd string
+	var allowShell bool
+	var verify bool
+	var watch bool
+	var watchDebounce time.Duration
+	var fileTimeout time.Duration
+	var totalTimeout time.Duration
+	var annotateGenerated bool
+	var generatedFQN string
+	var headerFile string
+	var templateExts string
+	var outExt string
+	var passthroughExts string
+	var headerComment string
+	var werror bool
+	var baselinePath string
+	var writeBaselinePath string
+	var failAtEnd bool
+	var checkBalance bool
+	var warnStale bool
+	var checkClassName bool
+	var checkDeterminism bool
+	var expandDepth int
+	var includeDepth int
+	var dollarInterp bool
+	var maxFileSize int
+	var maxRegionSize int
+	var maxRegions int
+	var snippetEnv string
+	var transformSpec string
+	var chomp bool
+	var align bool
+	var seed int64
+	var tracePython string
+	var traceTruncate int
+	var summaryJSON string
+	var otelEndpoint string
+	var metricsAddr string
+	var evalRetries int
+	var retryBackoff time.Duration
+	var sandboxFS bool
+	var sandboxWriteDir string
+	var engine string
+	var preload string
+	var remoteCert string
+	var remoteKey string
+	var remoteCA string
+	var compile bool
+	var javacCmd string
+	var classpath string
+	var release string
 
 	flag.StringVar(&inPath, "in", "", "input file path")
 	flag.StringVar(&outPath, "out", "", "output file path")
+	flag.StringVar(&resourcesOut, "resources-out", "", "root directory for resources snippets emit via japaya.emit_resource (empty discards them with a warning)")
+	flag.BoolVar(&manageImports, "manage-imports", false, "insert imports snippets require via japaya.require_import into each generated file's import block")
+	flag.StringVar(&packageRoot, "package-root", "", "source root to infer each file's java package from; exposed to snippets as japaya.package")
 	flag.StringVar(&pythonCmd, "python", "", "python executable (default: python3/python)")
 	flag.StringVar(&pythonDir, "python-dir", "", "directory added to Python module search path for snippets (optional)")
+	flag.StringVar(&eventsSpec, "events-ndjson", "", "emit NDJSON progress events to the given file descriptor number or file path (optional)")
+	flag.StringVar(&jshellCmd, "jshell", "", "jshell executable for `java!` regions (default: jshell)")
+	flag.BoolVar(&allowShell, "allow-shell", false, "allow `sh!` regions to run arbitrary shell commands")
+	flag.BoolVar(&verify, "verify", false, "fail if outputs differ from a fresh translation, without writing anything")
+	flag.BoolVar(&watch, "watch", false, "stay running and re-translate when inputs or python-dir helpers change")
+	flag.DurationVar(&watchDebounce, "watch-debounce", 500*time.Millisecond, "how long edits must settle before a watch rebuild")
+	flag.DurationVar(&fileTimeout, "file-timeout", 0, "maximum time to translate any single file (0 disables)")
+	flag.DurationVar(&totalTimeout, "total-timeout", 0, "maximum time for the whole run (0 disables)")
+	flag.BoolVar(&annotateGenerated, "annotate-generated", false, "insert a @Generated annotation above top-level types in generated files")
+	flag.StringVar(&generatedFQN, "generated-annotation", "javax.annotation.processing.Generated", "fully qualified annotation name used by -annotate-generated")
+	flag.StringVar(&headerFile, "header-file", "", "file whose contents are prepended to each generated file; {{year}} and {{file}} are expanded")
+	flag.StringVar(&templateExts, "template-ext", ".japaya", "comma-separated template extensions rewritten to -out-ext in tree mode")
+	flag.StringVar(&outExt, "out-ext", ".java", "output extension replacing a template extension")
+	flag.StringVar(&passthroughExts, "passthrough-ext", ".java", "comma-separated extensions translated in place without renaming")
+	flag.StringVar(&headerComment, "header-comment", "", "line-comment token wrapped around -header-file lines (e.g. \"#\" for YAML)")
+	flag.BoolVar(&werror, "Werror", false, "treat warnings as errors")
+	flag.StringVar(&baselinePath, "baseline", "", "suppress warnings recorded in this baseline file; new warnings still print and still trip -Werror")
+	flag.StringVar(&writeBaselinePath, "write-baseline", "", "record this run's warnings to a baseline file for -baseline instead of printing or failing on them")
+	flag.BoolVar(&failAtEnd, "fail-at-end", false, "keep evaluating a file after a region error and report every error at once")
+	flag.BoolVar(&checkBalance, "check-balance", false, "warn when a generated file's brackets don't balance")
+	flag.BoolVar(&warnStale, "warn-stale", false, "warn about files in the output tree this run did not produce, without deleting them")
+	flag.BoolVar(&checkClassName, "check-class-name", false, "fail when a generated file's public type name doesn't match its file name")
+	flag.BoolVar(&checkDeterminism, "check-determinism", false, "evaluate each file twice and fail if the outputs differ")
+	flag.IntVar(&expandDepth, "expand-depth", 1, "re-expand regions found in translated output up to this many passes")
+	flag.IntVar(&includeDepth, "max-include-depth", 0, "cap on !include nesting depth (0 = the default of 16)")
+	flag.BoolVar(&dollarInterp, "dollar-interp", false, "also recognize ${expr} interpolation alongside backtick regions")
+	flag.IntVar(&maxFileSize, "max-file-size", 0, "reject input files larger than this many bytes (0 = no limit)")
+	flag.IntVar(&maxRegionSize, "max-region-size", 0, "reject regions larger than this many bytes (0 = no limit)")
+	flag.IntVar(&maxRegions, "max-regions", 0, "reject files with more than this many regions (0 = no limit)")
+	flag.StringVar(&snippetEnv, "snippet-env", "", "comma-separated KEY=VALUE pairs added to snippets' environment")
+	flag.StringVar(&transformSpec, "transform", "", "semicolon-separated java rewrites applied during translation: strip-lines:MARKER and replace:OLD=NEW")
+	flag.BoolVar(&chomp, "chomp", false, "strip the single trailing newline of block output; regions override with chomp/no-chomp annotations")
+	flag.BoolVar(&align, "align", false, "pad statement results to their region's template width so later columns line up; regions override with align/no-align annotations")
+	flag.Int64Var(&seed, "seed", 0, "deterministic seed exposed to snippets as japaya.seed for reproducible builds")
+	flag.StringVar(&summaryJSON, "summary-json", "", "also write the end-of-run summary as JSON to this file (tree runs only)")
+	flag.StringVar(&tracePython, "trace-python", "", "append every python worker request/response to this debug trace file")
+	flag.IntVar(&traceTruncate, "trace-truncate", 0, "truncate traced snippets and outputs to this many bytes (0 uses a default)")
+	flag.StringVar(&otelEndpoint, "otel-endpoint", "", "export OTel spans to this OTLP/HTTP collector base URL (e.g. http://localhost:4318)")
+	flag.StringVar(&metricsAddr, "metrics-addr", "", "serve Prometheus metrics on this address's /metrics endpoint (most useful with -watch)")
+	flag.IntVar(&evalRetries, "eval-retries", 0, "restart the python worker and retry an evaluation this many times after worker crashes or I/O errors")
+	flag.DurationVar(&retryBackoff, "eval-retry-backoff", 100*time.Millisecond, "wait before the first -eval-retries retry, doubling for each later one")
+	flag.BoolVar(&sandboxFS, "sandbox-fs", false, "confine snippet filesystem access to the input root and python-dir (reads) and a scratch dir (writes)")
+	flag.StringVar(&sandboxWriteDir, "sandbox-write-dir", "", "scratch directory -sandbox-fs snippets may write to (default: a fresh temp dir)")
+	flag.StringVar(&engine, "engine", "worker", "python engine: \"worker\" (subprocess), \"fork\" (workers forked from a pre-imported parent; POSIX only), \"docker:IMAGE\" (worker inside a container image) or \"remote:ADDR\" (japaya serve on another host)")
+	flag.StringVar(&preload, "preload", "", "comma-separated python modules the fork engine imports once in the parent, so forked workers skip their import cost (e.g. jinja2,pandas)")
+	flag.StringVar(&remoteCert, "remote-cert", "", "PEM client certificate for -engine remote:ADDR mutual TLS")
+	flag.StringVar(&remoteKey, "remote-key", "", "PEM private key for -remote-cert")
+	flag.StringVar(&remoteCA, "remote-ca", "", "PEM CA bundle the remote server's certificate must chain to")
+	flag.BoolVar(&compile, "compile", false, "run javac on the output after translation, mapping diagnostics to inputs")
+	flag.StringVar(&javacCmd, "javac", "", "javac executable for -compile (default: javac)")
+	flag.StringVar(&classpath, "classpath", "", "classpath passed to javac with -compile")
+	flag.StringVar(&release, "release", "", "--release passed to javac with -compile")
 	flag.Parse()
 
 	if inPath == "" || outPath == "" {
@@ -28,33 +184,305 @@ func main() {
 		os.Exit(2)
 	}
 
-	if pythonDir != "" {
-		info, err := os.Stat(pythonDir)
+	if err := validatePythonDir(pythonDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var baseline *core.Baseline
+	if baselinePath != "" {
+		b, err := core.LoadBaseline(baselinePath)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "invalid -python-dir %q: %v\n", pythonDir, err)
-			os.Exit(2)
-		}
-		if !info.IsDir() {
-			fmt.Fprintf(os.Stderr, "invalid -python-dir %q: not a directory\n", pythonDir)
+			fmt.Fprintln(os.Stderr, err)
 			os.Exit(2)
 		}
+		baseline = b
 	}
 
+	events, closeEvents, err := openEventSink(eventsSpec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -events-ndjson %q: %v\n", eventsSpec, err)
+		os.Exit(2)
+	}
+	defer closeEvents()
+
 	ctx := context.Background()
 
-	// Create the python evaluator (long-lived worker).
-	py, err := python.NewEvaluator(pythonCmd, pythonDir)
+	// Every run gets a managed scratch directory, exposed to snippets as
+	// japaya.tmpdir and removed when the run ends, so templates that need
+	// intermediate files have a sanctioned, leak-free place to write.
+	scratchDir, err := os.MkdirTemp("", "japaya-tmp-*")
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, "create scratch dir:", err)
 		os.Exit(1)
 	}
+	defer os.RemoveAll(scratchDir)
+
+	var sandbox *python.SandboxConfig
+	if sandboxFS {
+		inRoot, err := filepath.Abs(inPath)
+		if err == nil {
+			if info, statErr := os.Stat(inRoot); statErr == nil && !info.IsDir() {
+				inRoot = filepath.Dir(inRoot)
+			}
+		}
+		// The run's scratch dir doubles as the sandbox write dir unless one
+		// was named explicitly, keeping japaya.tmpdir writable under -sandbox-fs.
+		writeDir := sandboxWriteDir
+		if writeDir == "" {
+			writeDir = scratchDir
+		} else {
+			scratchDir = writeDir
+		}
+		sandbox = &python.SandboxConfig{ReadDirs: []string{inRoot}, WriteDir: writeDir}
+	}
+
+	// Create the python evaluator: a long-lived local worker, or a
+	// connection to a `japaya serve` host. py stays nil for the remote
+	// engine, whose worker lives on the other end.
+	var py *python.Evaluator
+	var pyEval core.PythonEvaluator
+	if addr, ok := strings.CutPrefix(engine, "remote:"); ok {
+		if sandboxFS {
+			fmt.Fprintln(os.Stderr, "-sandbox-fs is not supported with the remote engine; sandboxing is the server's job")
+			os.Exit(2)
+		}
+		var cfg *tls.Config
+		if remoteCert != "" || remoteKey != "" || remoteCA != "" {
+			cfg, err = remote.MTLSConfig(remoteCert, remoteKey, remoteCA, false)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+		}
+		client, err := remote.Dial(addr, cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer client.Close()
+		pyEval = client
+	} else {
+		if image, ok := strings.CutPrefix(engine, "docker:"); ok {
+			if sandboxFS {
+				fmt.Fprintln(os.Stderr, "-sandbox-fs is not supported with the docker engine; the container is the sandbox")
+				os.Exit(2)
+			}
+			py, err = python.NewDockerEvaluator(image, pythonCmd, pythonDir)
+		} else if engine == "fork" {
+			if sandboxFS {
+				fmt.Fprintln(os.Stderr, "-sandbox-fs is not supported with the fork engine; forked workers share the parent's view of the filesystem")
+				os.Exit(2)
+			}
+			var mods []string
+			for _, mod := range strings.Split(preload, ",") {
+				if mod = strings.TrimSpace(mod); mod != "" {
+					mods = append(mods, mod)
+				}
+			}
+			py, err = python.NewForkServerEvaluator(pythonCmd, pythonDir, mods)
+		} else if engine == "worker" || engine == "" {
+			py, err = python.NewSandboxedEvaluator(pythonCmd, pythonDir, sandbox)
+		} else {
+			fmt.Fprintf(os.Stderr, "invalid -engine %q (expected \"worker\", \"fork\", \"docker:IMAGE\" or \"remote:ADDR\")\n", engine)
+			os.Exit(2)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer func() {
+			if err := py.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, "warning: failed to close python worker:", err)
+			}
+		}()
+		pyEval = py
+	}
+
+	if evalRetries > 0 {
+		if py == nil {
+			fmt.Fprintln(os.Stderr, "-eval-retries is not supported with the remote engine")
+			os.Exit(2)
+		}
+		py.SetRetryPolicy(evalRetries, retryBackoff)
+	}
+
+	if tracePython != "" {
+		if py == nil {
+			fmt.Fprintln(os.Stderr, "-trace-python is not supported with the remote engine")
+			os.Exit(2)
+		}
+		f, err := os.OpenFile(tracePython, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -trace-python %q: %v\n", tracePython, err)
+			os.Exit(2)
+		}
+		defer f.Close()
+		py.SetTrace(f, traceTruncate)
+	}
+
+	var metrics *buildMetrics
+	if metricsAddr != "" {
+		metrics = &buildMetrics{}
+		events = &metricsSink{m: metrics, next: events}
+		stopMetrics, err := serveMetrics(metricsAddr, metrics, py)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -metrics-addr %q: %v\n", metricsAddr, err)
+			os.Exit(2)
+		}
+		defer stopMetrics()
+	}
+
+	// JShell starts lazily, so this is free unless `java!` regions are used.
+	js := jshell.NewEvaluator(jshellCmd)
 	defer func() {
-		if err := py.Close(); err != nil {
-			fmt.Fprintln(os.Stderr, "warning: failed to close python worker:", err)
+		if err := js.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "warning: failed to close jshell worker:", err)
 		}
 	}()
 
-	if err := core.TranslatePath(ctx, inPath, outPath, py); err != nil {
+	tr := &core.Translator{Py: pyEval, JShell: js, Events: events}
+	if otelEndpoint != "" {
+		exp := otel.New(otelEndpoint, "japaya")
+		defer func() {
+			if err := exp.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, "warning: failed to export OTel spans:", err)
+			}
+		}()
+		tr.Tracer = exp
+	}
+	if allowShell {
+		tr.Shell = shell.NewEvaluator()
+	}
+	tr.Verify = verify
+	tr.CheckDeterminism = checkDeterminism
+	tr.ChompBlocks = chomp
+	tr.AlignStatements = align
+	tr.FileTimeout = fileTimeout
+	tr.TotalTimeout = totalTimeout
+	tr.MaxExpandDepth = expandDepth
+	tr.MaxIncludeDepth = includeDepth
+	tr.ResourcesDir = resourcesOut
+	tr.ManageImports = manageImports
+	tr.PackageRoot = packageRoot
+	tr.ParseOptions.DollarInterp = dollarInterp
+	tr.ParseOptions.Limits = core.ParseLimits{
+		MaxFileSize:   maxFileSize,
+		MaxRegionSize: maxRegionSize,
+		MaxRegions:    maxRegions,
+	}
+	if transformSpec != "" {
+		chain, err := core.ParseTransformChain(transformSpec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -transform %q: %v\n", transformSpec, err)
+			os.Exit(2)
+		}
+		tr.Transforms = chain
+	}
+	if snippetEnv != "" {
+		env, err := parseEnvList(snippetEnv)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -snippet-env %q: %v\n", snippetEnv, err)
+			os.Exit(2)
+		}
+		tr.SnippetEnv = env
+	}
+	// Reproducible-build plumbing: SOURCE_DATE_EPOCH pins tool-generated
+	// timestamps and, like -seed, is exposed to snippets (japaya.epoch,
+	// japaya.seed).
+	if v := os.Getenv("SOURCE_DATE_EPOCH"); v != "" {
+		epoch, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid SOURCE_DATE_EPOCH %q: %v\n", v, err)
+			os.Exit(2)
+		}
+		tr.Epoch = epoch
+		addSnippetEnv(tr, "JAPAYA_EPOCH", strconv.FormatInt(epoch, 10))
+	}
+	seedSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			seedSet = true
+		}
+	})
+	if seedSet {
+		addSnippetEnv(tr, "JAPAYA_SEED", strconv.FormatInt(seed, 10))
+	}
+	addSnippetEnv(tr, "JAPAYA_TMPDIR", scratchDir)
+	if compile {
+		tr.SourceMaps = core.NewSourceMaps()
+	}
+	if annotateGenerated {
+		tr.GeneratedAnnotation = generatedFQN
+	}
+	if headerFile != "" {
+		header, err := os.ReadFile(headerFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -header-file %q: %v\n", headerFile, err)
+			os.Exit(2)
+		}
+		tr.Header = string(header)
+	}
+	if lang := languageFromFlags(templateExts, outExt, passthroughExts, headerComment); lang != nil {
+		tr.Language = lang
+	}
+	// Regions annotated #!cache hold run-level constants, evaluated once for
+	// the whole run; the prelude hash keys the cache so results recorded
+	// under old helper code can never satisfy a lookup after an edit.
+	tr.EvalCache = core.NewEvalCache(hashPythonDir(pythonDir))
+
+	if watch {
+		if verify {
+			fmt.Fprintln(os.Stderr, "-watch cannot be combined with -verify")
+			os.Exit(2)
+		}
+		if strings.HasPrefix(outPath, "tar:") {
+			fmt.Fprintln(os.Stderr, "-watch cannot be combined with a tar: output")
+			os.Exit(2)
+		}
+		// Rebuilds reuse each unchanged region's output from the previous
+		// translation, so an edit re-runs only the snippets it touched.
+		tr.RegionCache = core.NewRegionOutputCache()
+		watchCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		code := runWatch(watchCtx, tr, inPath, outPath, pythonDir, watchDebounce, metrics)
+		stop()
+		if code != 0 {
+			os.Exit(code)
+		}
+		return
+	}
+
+	tr.Warnings = core.NewWarnings()
+	if failAtEnd {
+		tr.Policy.RecoverableErrors = core.FailAtEnd
+	}
+	tr.CheckBalance = checkBalance
+	tr.WarnStale = warnStale
+	tr.CheckClassName = checkClassName
+
+	// Tree runs get end-of-run accounting; single-file and verify runs
+	// don't, since most of the categories only make sense for trees.
+	if info, statErr := os.Stat(inPath); statErr == nil && info.IsDir() && !verify {
+		tr.Summary = core.NewRunSummary()
+	}
+
+	err = tr.TranslatePath(ctx, inPath, outPath)
+	reportSummary(tr.Summary, summaryJSON)
+	if err != nil {
+		printWarnings(baseline.Filter(tr.Warnings.All()))
+		var ve *core.VerifyError
+		if errors.As(err, &ve) {
+			for _, m := range ve.Mismatches {
+				existing, readErr := os.ReadFile(m.OutPath)
+				if readErr != nil {
+					fmt.Fprintf(os.Stderr, "--- %s: missing from output\n", m.OutPath)
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "--- %s\n%s", m.OutPath, diffLines(existing, m.Got))
+			}
+			fmt.Fprintf(os.Stderr, "%d output file(s) out of date\n", len(ve.Mismatches))
+			os.Exit(1)
+		}
 		var te *core.TranslationError
 		if errors.As(err, &te) {
 			// print something like: file:line:col: message
@@ -65,4 +493,176 @@ func main() {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+
+	if writeBaselinePath != "" {
+		all := tr.Warnings.All()
+		if err := core.WriteBaseline(writeBaselinePath, all); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "recorded %d warning(s) to %s\n", len(all), writeBaselinePath)
+	} else if n := printWarnings(baseline.Filter(tr.Warnings.All())); n > 0 && werror {
+		fmt.Fprintf(os.Stderr, "%d warning(s) treated as errors (-Werror)\n", n)
+		os.Exit(1)
+	}
+
+	if compile {
+		if code := runCompile(tr.SourceMaps, outPath, javacCmd, classpath, release); code != 0 {
+			os.Exit(code)
+		}
+	}
+}
+
+// hashPythonDir fingerprints the python sources cached run-level constants
+// may depend on: every .py file's path and contents under dir. An empty or
+// unset dir hashes to the empty string.
+func hashPythonDir(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	h := sha256.New()
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".py" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", path, len(data))
+		h.Write(data)
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// addSnippetEnv adds one entry to the translator's snippet environment,
+// allocating the map on first use.
+func addSnippetEnv(tr *core.Translator, key, value string) {
+	if tr.SnippetEnv == nil {
+		tr.SnippetEnv = make(map[string]string)
+	}
+	tr.SnippetEnv[key] = value
+}
+
+// reportSummary prints the end-of-run summary to stderr and writes the JSON
+// form when a path was given. A nil summary (single-file or verify runs)
+// reports nothing.
+func reportSummary(sum *core.RunSummary, jsonPath string) {
+	if sum == nil {
+		return
+	}
+	rep := sum.Report()
+	fmt.Fprintln(os.Stderr, rep)
+	if jsonPath != "" {
+		data, err := json.MarshalIndent(rep, "", "  ")
+		if err == nil {
+			err = os.WriteFile(jsonPath, append(data, '\n'), 0o644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to write -summary-json %q: %v\n", jsonPath, err)
+		}
+	}
+}
+
+// printWarnings writes warnings to stderr and returns how many there were.
+func printWarnings(warnings []core.Warning) int {
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, w)
+	}
+	return len(warnings)
+}
+
+// languageFromFlags builds a core.Language from the extension and comment
+// flags, or nil when they all match the Java defaults so the core default
+// applies unchanged.
+func languageFromFlags(templateExts, outExt, passthroughExts, headerComment string) *core.Language {
+	if templateExts == ".japaya" && outExt == ".java" && passthroughExts == ".java" && headerComment == "" {
+		return nil
+	}
+	return &core.Language{
+		Name:            strings.TrimPrefix(outExt, "."),
+		TemplateExts:    splitExtList(templateExts),
+		OutputExt:       normalizeExt(outExt),
+		PassthroughExts: splitExtList(passthroughExts),
+		LineComment:     headerComment,
+	}
+}
+
+// parseEnvList parses a comma-separated KEY=VALUE list into a map.
+func parseEnvList(s string) (map[string]string, error) {
+	env := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("entry %q is not KEY=VALUE", pair)
+		}
+		env[key] = val
+	}
+	return env, nil
+}
+
+// splitExtList parses a comma-separated extension list, dropping empties.
+func splitExtList(s string) []string {
+	var exts []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			exts = append(exts, normalizeExt(e))
+		}
+	}
+	return exts
+}
+
+// normalizeExt lowercases an extension and ensures the leading dot.
+func normalizeExt(e string) string {
+	e = strings.ToLower(e)
+	if !strings.HasPrefix(e, ".") {
+		e = "." + e
+	}
+	return e
+}
+
+// openEventSink interprets an -events-ndjson argument. A decimal number is
+// treated as an inherited file descriptor (e.g. 3 for a pipe set up by an
+// IDE); anything else is a file path that gets created/truncated. Returns a
+// nil sink when spec is empty.
+func openEventSink(spec string) (sink core.EventSink, closer func(), err error) {
+	closer = func() {}
+	if spec == "" {
+		return nil, closer, nil
+	}
+
+	if fd, convErr := strconv.ParseUint(spec, 10, 32); convErr == nil {
+		f := os.NewFile(uintptr(fd), "events-ndjson")
+		if f == nil {
+			return nil, closer, fmt.Errorf("invalid file descriptor %d", fd)
+		}
+		return core.NewNDJSONSink(f), func() { _ = f.Close() }, nil
+	}
+
+	f, err := os.Create(spec)
+	if err != nil {
+		return nil, closer, err
+	}
+	return core.NewNDJSONSink(f), func() { _ = f.Close() }, nil
+}
+
+// validatePythonDir checks that a -python-dir argument, if given, exists and
+// is a directory.
+func validatePythonDir(pythonDir string) error {
+	if pythonDir == "" {
+		return nil
+	}
+	info, err := os.Stat(pythonDir)
+	if err != nil {
+		return fmt.Errorf("invalid -python-dir %q: %v", pythonDir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("invalid -python-dir %q: not a directory", pythonDir)
+	}
+	return nil
 }
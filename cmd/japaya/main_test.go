@@ -1,11 +1,16 @@
 package main
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"encoding/json"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -499,3 +504,774 @@ func TestJapaya_Prelude_PyDirInitProvidesNames(t *testing.T) {
 		t.Fatalf("unexpected output:\n--- want ---\n%q\n--- got ---\n%q", want, string(got))
 	}
 }
+
+func TestJapaya_Eval_Statement(t *testing.T) {
+	t.Parallel()
+
+	pythonCmd, ok := findPython()
+	if !ok {
+		t.Error("python not found in PATH")
+	}
+
+	res := runJapaya(t, []string{"eval", "-e", "1+2", "-python", pythonCmd})
+
+	if res.exitCode != 0 {
+		t.Fatalf("expected success (0), got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+	if res.stdout != "3\n" {
+		t.Fatalf("unexpected stdout: %q", res.stdout)
+	}
+}
+
+func TestJapaya_ForeachDirective_ExpandsRows(t *testing.T) {
+	t.Parallel()
+
+	pythonCmd, ok := findPython()
+	if !ok {
+		t.Error("python not found in PATH")
+	}
+
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "in.japaya")
+	outPath := filepath.Join(dir, "out.java")
+
+	mustWrite(t, filepath.Join(dir, "rows.csv"), "name,value\nFoo,1\nBar,2\n")
+	mustWrite(t, inPath, strings.Join([]string{
+		"class A {",
+		"```!foreach rows.csv",
+		"  int get{name}() {{ return {value}; }}",
+		"```}",
+		"",
+	}, "\n"))
+
+	res := runJapaya(t, []string{
+		"-in", inPath,
+		"-out", outPath,
+		"-python", pythonCmd,
+	})
+
+	if res.exitCode != 0 {
+		t.Fatalf("expected success (0), got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+
+	got, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+
+	want := strings.Join([]string{
+		"class A {",
+		"  int getFoo() { return 1; }",
+		"  int getBar() { return 2; }",
+		"}",
+		"",
+	}, "\n")
+
+	if string(got) != want {
+		t.Fatalf("unexpected output:\n--- want ---\n%q\n--- got ---\n%q", want, string(got))
+	}
+}
+
+func TestJapaya_TestCommand_SnapshotCompareAndUpdate(t *testing.T) {
+	t.Parallel()
+
+	pythonCmd, ok := findPython()
+	if !ok {
+		t.Error("python not found in PATH")
+	}
+
+	dir := t.TempDir()
+	inRoot := filepath.Join(dir, "src")
+	expected := filepath.Join(dir, "testdata", "expected")
+
+	mustWrite(t, filepath.Join(inRoot, "A.japaya"), "int x = `1+2`;\n")
+
+	// No snapshots yet: the run should fail and mention the missing file.
+	res := runJapaya(t, []string{"test", "-in", inRoot, "-expected", expected, "-python", pythonCmd})
+	if res.exitCode != 1 {
+		t.Fatalf("expected exit 1 without snapshots, got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+
+	// -update writes the snapshots; a following run passes.
+	res = runJapaya(t, []string{"test", "-in", inRoot, "-expected", expected, "-update", "-python", pythonCmd})
+	if res.exitCode != 0 {
+		t.Fatalf("expected success for -update, got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+	mustExist(t, filepath.Join(expected, "A.java"))
+
+	res = runJapaya(t, []string{"test", "-in", inRoot, "-expected", expected, "-python", pythonCmd})
+	if res.exitCode != 0 {
+		t.Fatalf("expected success after -update, got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+
+	// Change the template; the comparison should now fail with a diff.
+	mustWrite(t, filepath.Join(inRoot, "A.japaya"), "int x = `2+2`;\n")
+	res = runJapaya(t, []string{"test", "-in", inRoot, "-expected", expected, "-python", pythonCmd})
+	if res.exitCode != 1 {
+		t.Fatalf("expected exit 1 on changed output, got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+	if !strings.Contains(res.stderr, "A.java") {
+		t.Fatalf("expected diff to mention A.java; got:\n%s", res.stderr)
+	}
+}
+
+func TestJapaya_Verify_DetectsStaleOutputs(t *testing.T) {
+	t.Parallel()
+
+	pythonCmd, ok := findPython()
+	if !ok {
+		t.Error("python not found in PATH")
+	}
+
+	inRoot := t.TempDir()
+	outRoot := filepath.Join(t.TempDir(), "out")
+
+	mustWrite(t, filepath.Join(inRoot, "A.japaya"), "int x = `1+2`;\n")
+
+	// First build writes the output; verify should then pass.
+	res := runJapaya(t, []string{"-in", inRoot, "-out", outRoot, "-python", pythonCmd})
+	if res.exitCode != 0 {
+		t.Fatalf("expected build success, got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+	res = runJapaya(t, []string{"-in", inRoot, "-out", outRoot, "-python", pythonCmd, "-verify"})
+	if res.exitCode != 0 {
+		t.Fatalf("expected verify success, got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+
+	// Make the committed output stale; verify must fail without touching it.
+	stale := "int x = 999;\n"
+	mustWrite(t, filepath.Join(outRoot, "A.java"), stale)
+	res = runJapaya(t, []string{"-in", inRoot, "-out", outRoot, "-python", pythonCmd, "-verify"})
+	if res.exitCode == 0 {
+		t.Fatalf("expected verify failure, got 0")
+	}
+	if !strings.Contains(res.stderr, "A.java") {
+		t.Fatalf("expected diff to mention A.java; got:\n%s", res.stderr)
+	}
+
+	got, err := os.ReadFile(filepath.Join(outRoot, "A.java"))
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if string(got) != stale {
+		t.Fatalf("verify mode must not rewrite outputs; got %q", string(got))
+	}
+}
+
+func TestJapaya_TreeMode_ArchiveOutput(t *testing.T) {
+	t.Parallel()
+
+	pythonCmd, ok := findPython()
+	if !ok {
+		t.Error("python not found in PATH")
+	}
+
+	inRoot := t.TempDir()
+	outJar := filepath.Join(t.TempDir(), "out.src.jar")
+
+	mustWrite(t, filepath.Join(inRoot, "A.japaya"), "int x = `1+2`;\n")
+	mustWrite(t, filepath.Join(inRoot, "sub", "B.java"), "public class B {}\n")
+
+	res := runJapaya(t, []string{"-in", inRoot, "-out", outJar, "-python", pythonCmd})
+	if res.exitCode != 0 {
+		t.Fatalf("expected success (0), got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+
+	zr, err := zip.OpenReader(outJar)
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	defer zr.Close()
+
+	found := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open entry %q: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read entry %q: %v", f.Name, err)
+		}
+		found[f.Name] = string(data)
+	}
+
+	if got, want := found["A.java"], "int x = 3;\n"; got != want {
+		t.Fatalf("A.java mismatch: got %q, want %q", got, want)
+	}
+	if _, ok := found["sub/B.java"]; !ok {
+		t.Fatalf("missing sub/B.java in archive; entries: %v", found)
+	}
+}
+
+func TestJapaya_TreeMode_SummaryAndJSON(t *testing.T) {
+	t.Parallel()
+
+	pythonCmd, ok := findPython()
+	if !ok {
+		t.Error("python not found in PATH")
+	}
+
+	inRoot := t.TempDir()
+	outRoot := filepath.Join(t.TempDir(), "out")
+	summaryPath := filepath.Join(t.TempDir(), "summary.json")
+
+	mustWrite(t, filepath.Join(inRoot, "A.japaya"), "int x = `1+2`;\n")
+	mustWrite(t, filepath.Join(inRoot, "B.java"), "public class B {}\n")
+	mustWrite(t, filepath.Join(inRoot, "README.md"), "ignore\n")
+
+	res := runJapaya(t, []string{"-in", inRoot, "-out", outRoot, "-python", pythonCmd, "-summary-json", summaryPath})
+	if res.exitCode != 0 {
+		t.Fatalf("expected success (0), got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+	if !strings.Contains(res.stderr, "1 translated") || !strings.Contains(res.stderr, "1 copied") || !strings.Contains(res.stderr, "1 skipped") {
+		t.Fatalf("expected summary line on stderr; got:\n%s", res.stderr)
+	}
+
+	data, err := os.ReadFile(summaryPath)
+	if err != nil {
+		t.Fatalf("read summary json: %v", err)
+	}
+	var rep struct {
+		Translated int `json:"translated"`
+		Copied     int `json:"copied"`
+		Skipped    int `json:"skipped"`
+	}
+	if err := json.Unmarshal(data, &rep); err != nil {
+		t.Fatalf("unmarshal summary json %q: %v", data, err)
+	}
+	if rep.Translated != 1 || rep.Copied != 1 || rep.Skipped != 1 {
+		t.Fatalf("unexpected summary json: %s", data)
+	}
+
+	// Rebuilding with nothing changed reports everything unchanged.
+	res = runJapaya(t, []string{"-in", inRoot, "-out", outRoot, "-python", pythonCmd})
+	if res.exitCode != 0 {
+		t.Fatalf("expected success (0), got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+	if !strings.Contains(res.stderr, "2 unchanged") {
+		t.Fatalf("expected unchanged count on rebuild; got:\n%s", res.stderr)
+	}
+}
+
+func TestJapaya_TreeMode_TarToStdout(t *testing.T) {
+	t.Parallel()
+
+	pythonCmd, ok := findPython()
+	if !ok {
+		t.Error("python not found in PATH")
+	}
+
+	inRoot := t.TempDir()
+	mustWrite(t, filepath.Join(inRoot, "A.japaya"), "int x = `1+2`;\n")
+	mustWrite(t, filepath.Join(inRoot, "sub", "B.java"), "public class B {}\n")
+	mustWrite(t, filepath.Join(inRoot, "README.md"), "ignore\n")
+
+	res := runJapaya(t, []string{"-in", inRoot, "-out", "tar:-", "-python", pythonCmd})
+	if res.exitCode != 0 {
+		t.Fatalf("expected success (0), got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+
+	found := map[string]string{}
+	tr := tar.NewReader(strings.NewReader(res.stdout))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read tar stream: %v", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("read tar entry %q: %v", hdr.Name, err)
+		}
+		found[hdr.Name] = string(data)
+	}
+
+	if got, want := found["A.java"], "int x = 3;\n"; got != want {
+		t.Fatalf("A.java mismatch: got %q, want %q", got, want)
+	}
+	if _, ok := found["sub/B.java"]; !ok {
+		t.Fatalf("missing sub/B.java in tar stream; entries: %v", found)
+	}
+	if _, ok := found["README.md"]; ok {
+		t.Fatalf("did not expect README.md in tar stream")
+	}
+}
+
+func TestJapaya_TreeMode_TarToFile(t *testing.T) {
+	t.Parallel()
+
+	pythonCmd, ok := findPython()
+	if !ok {
+		t.Error("python not found in PATH")
+	}
+
+	inRoot := t.TempDir()
+	outTar := filepath.Join(t.TempDir(), "out.tar")
+	mustWrite(t, filepath.Join(inRoot, "A.japaya"), "int x = `1+2`;\n")
+
+	res := runJapaya(t, []string{"-in", inRoot, "-out", "tar:" + outTar, "-python", pythonCmd})
+	if res.exitCode != 0 {
+		t.Fatalf("expected success (0), got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+
+	f, err := os.Open(outTar)
+	if err != nil {
+		t.Fatalf("open tar: %v", err)
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatalf("read tar: %v", err)
+	}
+	if hdr.Name != "A.java" {
+		t.Fatalf("first entry = %q, want A.java", hdr.Name)
+	}
+}
+
+func TestJapaya_ArchiveInput_TranslatesEntries(t *testing.T) {
+	t.Parallel()
+
+	pythonCmd, ok := findPython()
+	if !ok {
+		t.Error("python not found in PATH")
+	}
+
+	dir := t.TempDir()
+	inZip := filepath.Join(dir, "templates.zip")
+	outRoot := filepath.Join(dir, "out")
+
+	// Build a small archive of templates.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"A.japaya":    "int x = `1+2`;\n",
+		"sub/B.java":  "public class B {}\n",
+		"ignored.txt": "ignore\n",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("create entry: %v", err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("write entry: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close archive: %v", err)
+	}
+	mustWrite(t, inZip, buf.String())
+
+	res := runJapaya(t, []string{"-in", inZip, "-out", outRoot, "-python", pythonCmd})
+	if res.exitCode != 0 {
+		t.Fatalf("expected success (0), got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+
+	got, err := os.ReadFile(filepath.Join(outRoot, "A.java"))
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if string(got) != "int x = 3;\n" {
+		t.Fatalf("unexpected A.java: %q", string(got))
+	}
+	mustExist(t, filepath.Join(outRoot, "sub", "B.java"))
+	mustNotExist(t, filepath.Join(outRoot, "ignored.txt"))
+}
+
+func TestJapaya_AssertRegions_CheckWithoutOutput(t *testing.T) {
+	t.Parallel()
+
+	pythonCmd, ok := findPython()
+	if !ok {
+		t.Error("python not found in PATH")
+	}
+
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "in.java")
+	outPath := filepath.Join(dir, "out.java")
+
+	mustWrite(t, inPath, strings.Join([]string{
+		"class A {",
+		"  ```!assert",
+		"assert 1 + 1 == 2",
+		"  ```",
+		"  int x = `1+2`;",
+		"}",
+		"",
+	}, "\n"))
+
+	res := runJapaya(t, []string{"-in", inPath, "-out", outPath, "-python", pythonCmd})
+	if res.exitCode != 0 {
+		t.Fatalf("expected success (0), got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+	got, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if strings.Contains(string(got), "assert") {
+		t.Fatalf("assert block leaked into output:\n%s", got)
+	}
+	if !strings.Contains(string(got), "int x = 3;") {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+
+	// A failing check stops the build and points at the region.
+	mustWrite(t, inPath, strings.Join([]string{
+		"class A {",
+		"  ```!assert",
+		"assert False, 'schema has no columns'",
+		"  ```",
+		"}",
+		"",
+	}, "\n"))
+	res = runJapaya(t, []string{"-in", inPath, "-out", outPath, "-python", pythonCmd})
+	if res.exitCode != 1 {
+		t.Fatalf("expected exit 1 for a failing assert, got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+	if !strings.Contains(res.stderr, "in.java:2") {
+		t.Fatalf("expected the error to point at the region; got:\n%s", res.stderr)
+	}
+	if !strings.Contains(res.stderr, "schema has no columns") {
+		t.Fatalf("expected the assertion message; got:\n%s", res.stderr)
+	}
+}
+
+func TestJapaya_CheckDeterminism_FlagsUnstableTemplates(t *testing.T) {
+	t.Parallel()
+
+	pythonCmd, ok := findPython()
+	if !ok {
+		t.Error("python not found in PATH")
+	}
+
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "in.java")
+	outPath := filepath.Join(dir, "out.java")
+
+	// Stable template passes.
+	mustWrite(t, inPath, "int x = `1+2`;\n")
+	res := runJapaya(t, []string{"-in", inPath, "-out", outPath, "-python", pythonCmd, "-check-determinism"})
+	if res.exitCode != 0 {
+		t.Fatalf("expected success (0), got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+
+	// A template that leans on randomness differs between evaluations.
+	mustWrite(t, inPath, "String id = \"`__import__('random').random()`\";\n")
+	res = runJapaya(t, []string{"-in", inPath, "-out", outPath, "-python", pythonCmd, "-check-determinism"})
+	if res.exitCode != 1 {
+		t.Fatalf("expected exit 1 for a nondeterministic template, got %d\nstderr:\n%s", res.exitCode, res.stderr)
+	}
+	if !strings.Contains(res.stderr, "differs between two evaluations") {
+		t.Fatalf("expected a determinism error; got:\n%s", res.stderr)
+	}
+}
+
+func TestCompile_MapsJavacDiagnosticsToInput(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("javac"); err != nil {
+		t.Skip("javac not found on PATH")
+	}
+	py, ok := findPython()
+	if !ok {
+		t.Skip("python not found on PATH")
+	}
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "Main.japaya")
+	out := filepath.Join(dir, "Main.java")
+
+	// The snippet generates a statement with a missing semicolon, so javac
+	// reports an error on a generated line that only the source map can tie
+	// back to the snippet.
+	mustWrite(t, in, "class Main {\n  void f() {\n```\nprint('int broken')\n```\n  }\n}\n")
+
+	res := runJapaya(t, []string{"-in", in, "-out", out, "-python", py, "-compile"})
+	if res.exitCode == 0 {
+		t.Fatalf("expected javac failure, got success; stderr:\n%s", res.stderr)
+	}
+	if !strings.Contains(res.stderr, in+":") {
+		t.Fatalf("expected diagnostics pointing at %s; got:\n%s", in, res.stderr)
+	}
+	if !strings.Contains(res.stderr, "(generated at ") {
+		t.Fatalf("expected generated-location note; got:\n%s", res.stderr)
+	}
+}
+
+func TestJapaya_Bench_SyntheticCorpusAndBaseline(t *testing.T) {
+	t.Parallel()
+
+	pythonCmd, ok := findPython()
+	if !ok {
+		t.Skip("python not found in PATH; skipping")
+	}
+
+	baseline := filepath.Join(t.TempDir(), "baseline.json")
+	res := runJapaya(t, []string{"bench", "-files", "3", "-rounds", "3",
+		"-save", baseline, "-python", pythonCmd})
+	if res.exitCode != 0 {
+		t.Fatalf("bench exit=%d stderr=%s", res.exitCode, res.stderr)
+	}
+	for _, want := range []string{"parser throughput", "worker round-trip", "files/s"} {
+		if !strings.Contains(res.stdout, want) {
+			t.Errorf("stdout %q should mention %q", res.stdout, want)
+		}
+	}
+	mustExist(t, baseline)
+
+	var saved map[string]float64
+	data, err := os.ReadFile(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("baseline is not JSON: %v", err)
+	}
+	if saved["files_per_sec"] <= 0 {
+		t.Errorf("baseline files_per_sec = %v, want > 0", saved["files_per_sec"])
+	}
+
+	res = runJapaya(t, []string{"bench", "-files", "3", "-rounds", "3",
+		"-baseline", baseline, "-python", pythonCmd})
+	if res.exitCode != 0 {
+		t.Fatalf("bench with baseline exit=%d stderr=%s", res.exitCode, res.stderr)
+	}
+	if !strings.Contains(res.stdout, "baseline") || !strings.Contains(res.stdout, "%") {
+		t.Errorf("stdout %q should compare against the baseline", res.stdout)
+	}
+}
+
+// runJapayaStdin is runJapaya with input piped to the binary's stdin.
+func runJapayaStdin(t *testing.T, args []string, stdin string) japayaResult {
+	t.Helper()
+
+	bin := buildJapayaBinary(t)
+	cmd := exec.Command(bin, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	exitCode := 0
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			exitCode = ee.ExitCode()
+		} else {
+			exitCode = 127
+		}
+	}
+	return japayaResult{exitCode: exitCode, stdout: stdout.String(), stderr: stderr.String()}
+}
+
+func TestJapaya_Hook_StagedChecksOnlyListedTemplates(t *testing.T) {
+	t.Parallel()
+
+	pythonCmd, ok := findPython()
+	if !ok {
+		t.Skip("python not found in PATH; skipping")
+	}
+
+	inRoot := t.TempDir()
+	outRoot := filepath.Join(t.TempDir(), "out")
+	tmplPath := filepath.Join(inRoot, "A.japaya")
+	mustWrite(t, tmplPath, "int x = `1+2`;\n")
+	mustWrite(t, filepath.Join(inRoot, "README.md"), "docs\n")
+
+	res := runJapaya(t, []string{"-in", inRoot, "-out", outRoot, "-python", pythonCmd})
+	if res.exitCode != 0 {
+		t.Fatalf("translate exit=%d stderr=%s", res.exitCode, res.stderr)
+	}
+
+	// Paths as arguments, outputs current: the hook passes.
+	res = runJapaya(t, []string{"hook", "-staged", "-in", inRoot, "-out", outRoot,
+		"-python", pythonCmd, tmplPath})
+	if res.exitCode != 0 {
+		t.Fatalf("hook on fresh outputs exit=%d stderr=%s", res.exitCode, res.stderr)
+	}
+	if !strings.Contains(res.stderr, "up to date") {
+		t.Errorf("stderr %q should report up-to-date outputs", res.stderr)
+	}
+
+	// Only non-templates staged: nothing to check.
+	res = runJapaya(t, []string{"hook", "-staged", "-in", inRoot, "-out", outRoot,
+		"-python", pythonCmd, filepath.Join(inRoot, "README.md")})
+	if res.exitCode != 0 || !strings.Contains(res.stderr, "no templates") {
+		t.Errorf("hook with no templates: exit=%d stderr=%s", res.exitCode, res.stderr)
+	}
+
+	// Edit the template; paths on stdin as git hooks provide them.
+	mustWrite(t, tmplPath, "int x = `2+3`;\n")
+	res = runJapayaStdin(t, []string{"hook", "-staged", "-in", inRoot, "-out", outRoot,
+		"-python", pythonCmd}, tmplPath+"\n")
+	if res.exitCode != 1 {
+		t.Fatalf("hook on stale output exit=%d, want 1; stderr=%s", res.exitCode, res.stderr)
+	}
+	if !strings.Contains(res.stderr, "stale") {
+		t.Errorf("stderr %q should flag the stale output", res.stderr)
+	}
+}
+
+func TestJapaya_Report_TreeStatistics(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	mustWrite(t, filepath.Join(dir, "Mixed.japaya"),
+		"int a = `one`;\n```\nemit(\"two\")\n```\n")
+	mustWrite(t, filepath.Join(dir, "Plain.japaya"), "class Plain {}\n")
+	mustWrite(t, filepath.Join(dir, "README.md"), "not a template\n")
+
+	res := runJapaya(t, []string{"report", "-in", dir, "-top", "1"})
+	if res.exitCode != 0 {
+		t.Fatalf("exit = %d, stderr = %q", res.exitCode, res.stderr)
+	}
+	if !strings.Contains(res.stdout, "total: 2 file(s), 1 statement region(s), 1 block region(s)") {
+		t.Errorf("stdout %q missing the aggregate line", res.stdout)
+	}
+	if !strings.Contains(res.stdout, "largest snippets:") ||
+		!strings.Contains(res.stdout, "Mixed.japaya:2") {
+		t.Errorf("stdout %q should list the block as the largest snippet", res.stdout)
+	}
+	if !strings.Contains(res.stdout, "Plain.japaya") ||
+		!strings.Contains(res.stdout, "no python regions") {
+		t.Errorf("stdout %q should flag the python-free file", res.stdout)
+	}
+}
+
+func TestJapaya_Graph_DOTAndJSON(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	pyDir := filepath.Join(dir, "py")
+	mustWrite(t, filepath.Join(dir, "App.japaya"),
+		"```\nimport helper, os.path\nemit(helper.greet())\n```\n")
+	mustWrite(t, filepath.Join(pyDir, "helper.py"),
+		"from textwrap import dedent\n\ndef greet():\n    return \"hi\"\n")
+
+	res := runJapaya(t, []string{"graph", "-in", dir, "-python-dir", pyDir})
+	if res.exitCode != 0 {
+		t.Fatalf("exit = %d, stderr = %q", res.exitCode, res.stderr)
+	}
+	for _, want := range []string{
+		"digraph japaya {",
+		`-> "helper";`,
+		`-> "os";`,
+		`"helper" -> "textwrap";`,
+	} {
+		if !strings.Contains(res.stdout, want) {
+			t.Errorf("DOT output %q missing %q", res.stdout, want)
+		}
+	}
+
+	res = runJapaya(t, []string{"graph", "-in", dir, "-python-dir", pyDir, "-json"})
+	if res.exitCode != 0 {
+		t.Fatalf("json exit = %d, stderr = %q", res.exitCode, res.stderr)
+	}
+	var g struct {
+		Templates map[string][]string `json:"templates"`
+		Modules   map[string][]string `json:"modules"`
+	}
+	if err := json.Unmarshal([]byte(res.stdout), &g); err != nil {
+		t.Fatalf("json output: %v", err)
+	}
+	tmpl := filepath.Join(dir, "App.japaya")
+	if got := g.Templates[tmpl]; !slices.Equal(got, []string{"helper", "os"}) {
+		t.Errorf("template imports = %v, want [helper os]", got)
+	}
+	if got := g.Modules["helper"]; !slices.Equal(got, []string{"textwrap"}) {
+		t.Errorf("module imports = %v, want [textwrap]", got)
+	}
+}
+
+func TestJapaya_TmpdirScratchProvidedAndCleanedUp(t *testing.T) {
+	t.Parallel()
+
+	pythonCmd, ok := findPython()
+	if !ok {
+		t.Skip("python not found in PATH")
+	}
+
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "in.java")
+	outPath := filepath.Join(dir, "out.java")
+	mustWrite(t, inPath,
+		"```import os\np = os.path.join(japaya.tmpdir, 'x.tmp')\nopen(p, 'w').write('tmp')\nprint(p, end='')```\n")
+
+	res := runJapaya(t, []string{"-in", inPath, "-out", outPath, "-python", pythonCmd})
+	if res.exitCode != 0 {
+		t.Fatalf("exit = %d, stderr = %q", res.exitCode, res.stderr)
+	}
+	out, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	scratch := strings.TrimSpace(string(out))
+	if scratch == "" || scratch == "None" {
+		t.Fatalf("japaya.tmpdir spliced as %q, want a real path", scratch)
+	}
+	// The scratch dir (and the file the snippet wrote there) is gone once
+	// the run ends.
+	if _, err := os.Stat(filepath.Dir(scratch)); !os.IsNotExist(err) {
+		t.Errorf("scratch dir %q still exists after the run (stat err: %v)", filepath.Dir(scratch), err)
+	}
+}
+
+func TestJapaya_Lint_BaselineSuppressesRecordedFindings(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	baselinePath := filepath.Join(dir, "japaya-baseline.json")
+	// A block that never prints: lint's classic finding.
+	mustWrite(t, filepath.Join(dir, "Old.japaya"), "class Old {\n```x = 1```\n}\n")
+
+	res := runJapaya(t, []string{"lint", "-in", dir, "-write-baseline", baselinePath})
+	if res.exitCode != 0 {
+		t.Fatalf("write-baseline exit = %d, stderr = %q", res.exitCode, res.stderr)
+	}
+
+	// With the baseline loaded the legacy finding is accepted.
+	res = runJapaya(t, []string{"lint", "-in", dir, "-baseline", baselinePath})
+	if res.exitCode != 0 {
+		t.Fatalf("baselined lint exit = %d, stderr = %q", res.exitCode, res.stderr)
+	}
+
+	// A new finding in a new file still fails, and only it is reported.
+	mustWrite(t, filepath.Join(dir, "New.japaya"), "class New {\n```y = 2```\n}\n")
+	res = runJapaya(t, []string{"lint", "-in", dir, "-baseline", baselinePath})
+	if res.exitCode != 1 {
+		t.Fatalf("lint with new finding exit = %d, stderr = %q", res.exitCode, res.stderr)
+	}
+	if !strings.Contains(res.stderr, "New.japaya") || strings.Contains(res.stderr, "Old.japaya") {
+		t.Errorf("stderr = %q, want only the new finding reported", res.stderr)
+	}
+}
+
+func TestJapaya_Warm_EvaluatesWithoutWritingOutputs(t *testing.T) {
+	t.Parallel()
+
+	pythonCmd, ok := findPython()
+	if !ok {
+		t.Skip("python not found in PATH")
+	}
+
+	inRoot := t.TempDir()
+	mustWrite(t, filepath.Join(inRoot, "A.japaya"), "class A { int x = `1+2`; }\n")
+
+	res := runJapaya(t, []string{"warm", "-in", inRoot, "-python", pythonCmd})
+	if res.exitCode != 0 {
+		t.Fatalf("exit = %d, stderr = %q", res.exitCode, res.stderr)
+	}
+	if !strings.Contains(res.stderr, "evaluated 1 file(s)") {
+		t.Errorf("stderr = %q, want the evaluated-file count", res.stderr)
+	}
+	// Warming leaves no outputs behind, in the tree or next to it.
+	if _, err := os.Stat(filepath.Join(inRoot, "A.java")); !os.IsNotExist(err) {
+		t.Errorf("A.java exists after warm (stat err: %v)", err)
+	}
+}
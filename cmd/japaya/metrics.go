@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/justinottesen/japaya/internal/core"
+	"github.com/justinottesen/japaya/internal/python"
+)
+
+// buildMetrics counts run-level translation activity for the -metrics-addr
+// endpoint. The zero value is ready; all fields are safe for concurrent use.
+type buildMetrics struct {
+	translations atomic.Int64 // files translated (file_done events)
+	errors       atomic.Int64 // translation errors
+
+	// Watch-mode rebuild cache accounting: a "hit" is a watched file whose
+	// output survived a rebuild cycle untouched, a "miss" is one that had to
+	// be regenerated. hits/(hits+misses) is the cache hit rate.
+	rebuildHits   atomic.Int64
+	rebuildMisses atomic.Int64
+}
+
+// recordRebuild notes one watch-mode rebuild cycle that regenerated rebuilt
+// of the watched translatable files.
+func (m *buildMetrics) recordRebuild(rebuilt, watched int) {
+	m.rebuildMisses.Add(int64(rebuilt))
+	if kept := watched - rebuilt; kept > 0 {
+		m.rebuildHits.Add(int64(kept))
+	}
+}
+
+// write emits the counters in Prometheus text exposition format.
+func (m *buildMetrics) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP japaya_translations_total Files translated.\n")
+	fmt.Fprintf(w, "# TYPE japaya_translations_total counter\n")
+	fmt.Fprintf(w, "japaya_translations_total %d\n", m.translations.Load())
+	fmt.Fprintf(w, "# HELP japaya_translation_errors_total Translations that failed.\n")
+	fmt.Fprintf(w, "# TYPE japaya_translation_errors_total counter\n")
+	fmt.Fprintf(w, "japaya_translation_errors_total %d\n", m.errors.Load())
+	fmt.Fprintf(w, "# HELP japaya_rebuild_cache_hits_total Watched files whose outputs were kept across rebuilds.\n")
+	fmt.Fprintf(w, "# TYPE japaya_rebuild_cache_hits_total counter\n")
+	fmt.Fprintf(w, "japaya_rebuild_cache_hits_total %d\n", m.rebuildHits.Load())
+	fmt.Fprintf(w, "# HELP japaya_rebuild_cache_misses_total Watched files regenerated by rebuilds.\n")
+	fmt.Fprintf(w, "# TYPE japaya_rebuild_cache_misses_total counter\n")
+	fmt.Fprintf(w, "japaya_rebuild_cache_misses_total %d\n", m.rebuildMisses.Load())
+}
+
+// metricsSink tees translation events into the counters before forwarding
+// them to the configured sink, if any.
+type metricsSink struct {
+	m    *buildMetrics
+	next core.EventSink
+}
+
+func (s *metricsSink) Emit(e core.Event) {
+	switch e.Type {
+	case "file_done":
+		s.m.translations.Add(1)
+	case "error":
+		s.m.errors.Add(1)
+	}
+	if s.next != nil {
+		s.next.Emit(e)
+	}
+}
+
+// metricsHandler serves the combined run and worker counters; worker metrics
+// are skipped when py is nil.
+func metricsHandler(m *buildMetrics, py *python.Evaluator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.write(w)
+		if py != nil {
+			py.Metrics().WritePrometheus(w)
+		}
+	})
+}
+
+// serveMetrics exposes /metrics on addr for the life of the run, returning a
+// func that stops the server. Mainly useful with -watch, where the process
+// sticks around long enough to scrape.
+func serveMetrics(addr string, m *buildMetrics, py *python.Evaluator) (func(), error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler(m, py))
+	srv := &http.Server{Handler: mux}
+	go func() { _ = srv.Serve(ln) }()
+	return func() { _ = srv.Close() }, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/justinottesen/japaya/internal/core"
+)
+
+func TestMetricsSink_CountsAndForwards(t *testing.T) {
+	t.Parallel()
+
+	m := &buildMetrics{}
+	var forwarded []core.Event
+	sink := &metricsSink{m: m, next: eventFunc(func(e core.Event) { forwarded = append(forwarded, e) })}
+
+	sink.Emit(core.Event{Type: "file_started", Path: "A.japaya"})
+	sink.Emit(core.Event{Type: "file_done", Path: "A.japaya"})
+	sink.Emit(core.Event{Type: "error", Path: "B.japaya"})
+
+	if got := m.translations.Load(); got != 1 {
+		t.Errorf("translations = %d, want 1", got)
+	}
+	if got := m.errors.Load(); got != 1 {
+		t.Errorf("errors = %d, want 1", got)
+	}
+	if len(forwarded) != 3 {
+		t.Errorf("forwarded %d events, want all 3", len(forwarded))
+	}
+}
+
+func TestMetricsSink_NilNextIsFine(t *testing.T) {
+	t.Parallel()
+
+	sink := &metricsSink{m: &buildMetrics{}}
+	sink.Emit(core.Event{Type: "file_done"})
+}
+
+func TestMetricsHandler_ServesPrometheusText(t *testing.T) {
+	t.Parallel()
+
+	m := &buildMetrics{}
+	m.translations.Add(2)
+	m.recordRebuild(1, 4)
+
+	srv := httptest.NewServer(metricsHandler(m, nil))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain exposition format", ct)
+	}
+	for _, want := range []string{
+		"japaya_translations_total 2",
+		"japaya_translation_errors_total 0",
+		"japaya_rebuild_cache_hits_total 3",
+		"japaya_rebuild_cache_misses_total 1",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics output missing %q:\n%s", want, body)
+		}
+	}
+}
+
+// eventFunc adapts a func to core.EventSink for tests.
+type eventFunc func(core.Event)
+
+func (f eventFunc) Emit(e core.Event) { f(e) }
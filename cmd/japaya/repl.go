@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/justinottesen/japaya/internal/python"
+)
+
+// runRepl implements `japaya repl`: an interactive prompt against a worker
+// started with the configured python-dir/prelude. All inputs share a
+// persistent namespace, so helpers can be developed incrementally before
+// being embedded in templates.
+func runRepl(args []string) int {
+	fs := flag.NewFlagSet("japaya repl", flag.ExitOnError)
+
+	var pythonCmd string
+	var pythonDir string
+
+	fs.StringVar(&pythonCmd, "python", "", "python executable (default: python3/python)")
+	fs.StringVar(&pythonDir, "python-dir", "", "directory added to Python module search path for snippets (optional)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: japaya repl [-python <python>] [-python-dir <dir>]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if err := validatePythonDir(pythonDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	py, err := python.NewEvaluator(pythonCmd, pythonDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer func() {
+		if err := py.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "warning: failed to close python worker:", err)
+		}
+	}()
+
+	ctx := context.Background()
+	scanner := bufio.NewScanner(os.Stdin)
+
+	fmt.Print(">>> ")
+	for scanner.Scan() {
+		input := scanner.Text()
+
+		// A line ending in ':' starts a multi-line input (like the standard
+		// Python REPL); keep reading until a blank line.
+		if strings.HasSuffix(strings.TrimSpace(input), ":") {
+			var lines []string
+			lines = append(lines, input)
+			for {
+				fmt.Print("... ")
+				if !scanner.Scan() {
+					break
+				}
+				line := scanner.Text()
+				if strings.TrimSpace(line) == "" {
+					break
+				}
+				lines = append(lines, line)
+			}
+			input = strings.Join(lines, "\n")
+		}
+
+		if strings.TrimSpace(input) != "" {
+			out, err := py.Repl(ctx, []byte(input))
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			} else {
+				os.Stdout.Write(out)
+			}
+		}
+
+		fmt.Print(">>> ")
+	}
+	fmt.Println()
+
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, "failed reading stdin:", err)
+		return 1
+	}
+	return 0
+}
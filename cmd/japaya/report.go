@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/justinottesen/japaya/internal/core"
+)
+
+// runReport implements `japaya report`: a read-only scan of a template tree
+// that prints per-file and aggregate region statistics — how much python a
+// tree really contains, where the largest snippets live, and which files
+// have no python at all (candidates to rename back to plain .java).
+func runReport(args []string) int {
+	fs := flag.NewFlagSet("japaya report", flag.ExitOnError)
+
+	var inPath string
+	var top int
+	var dollarInterp bool
+
+	fs.StringVar(&inPath, "in", ".", "template tree to scan")
+	fs.IntVar(&top, "top", 5, "how many of the largest snippets to list")
+	fs.BoolVar(&dollarInterp, "dollar-interp", false, "also recognize ${expr} interpolation alongside backtick regions")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: japaya report [-in <dir>] [-top <n>] [-dollar-interp]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	rep, err := buildReport(inPath, core.ParseOptions{DollarInterp: dollarInterp})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	rep.print(os.Stdout, top)
+	return 0
+}
+
+// fileStats is one file's region tally.
+type fileStats struct {
+	path        string
+	java        int
+	statements  int
+	blocks      int
+	pythonBytes int
+}
+
+// snippetStat locates one snippet for the largest-snippets list.
+type snippetStat struct {
+	path string
+	line uint // 1-based for display
+	size int
+}
+
+// treeReport aggregates a whole tree's statistics.
+type treeReport struct {
+	files    []fileStats
+	snippets []snippetStat
+	noPython []string
+}
+
+// buildReport parses every translatable file under inPath and tallies its
+// regions. No evaluator is involved; the scan is read-only.
+func buildReport(inPath string, opts core.ParseOptions) (*treeReport, error) {
+	rep := &treeReport{}
+	probe := &core.Translator{}
+	err := filepath.WalkDir(inPath, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if name == ".git" || name == "node_modules" || name == "bin" || name == "dist" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !probe.ShouldTranslatePath(path) {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		unit, err := core.ParseBytesWithOptions(data, opts)
+		if err != nil {
+			return fmt.Errorf("parse %q: %w", path, err)
+		}
+
+		st := fileStats{path: path}
+		for _, r := range unit.Regions {
+			switch r.Type {
+			case core.RegionTypeJava:
+				st.java++
+				continue
+			case core.RegionTypePythonStatement:
+				st.statements++
+			case core.RegionTypePythonBlock:
+				st.blocks++
+			default:
+				st.statements++
+			}
+			st.pythonBytes += len(r.Data)
+			rep.snippets = append(rep.snippets, snippetStat{path: path, line: r.Start.Line + 1, size: len(r.Data)})
+		}
+		rep.files = append(rep.files, st)
+		if st.statements == 0 && st.blocks == 0 {
+			rep.noPython = append(rep.noPython, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(rep.snippets, func(i, j int) bool { return rep.snippets[i].size > rep.snippets[j].size })
+	return rep, nil
+}
+
+// print renders the report: a per-file table, totals, the largest snippets
+// and the python-free files.
+func (rep *treeReport) print(w *os.File, top int) {
+	tw := tabwriter.NewWriter(w, 2, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tJAVA\tSTMTS\tBLOCKS\tPY-BYTES")
+	var totalStmts, totalBlocks, totalBytes int
+	for _, st := range rep.files {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", st.path, st.java, st.statements, st.blocks, st.pythonBytes)
+		totalStmts += st.statements
+		totalBlocks += st.blocks
+		totalBytes += st.pythonBytes
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\ntotal: %d file(s), %d statement region(s), %d block region(s), %d python byte(s)\n",
+		len(rep.files), totalStmts, totalBlocks, totalBytes)
+
+	if top > len(rep.snippets) {
+		top = len(rep.snippets)
+	}
+	if top > 0 {
+		fmt.Fprintln(w, "\nlargest snippets:")
+		for _, sn := range rep.snippets[:top] {
+			fmt.Fprintf(w, "  %s:%d (%d bytes)\n", sn.path, sn.line, sn.size)
+		}
+	}
+	if len(rep.noPython) > 0 {
+		fmt.Fprintln(w, "\nfiles with no python regions (rename to plain source?):")
+		for _, path := range rep.noPython {
+			fmt.Fprintf(w, "  %s\n", path)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/justinottesen/japaya/internal/python"
+	"github.com/justinottesen/japaya/internal/remote"
+)
+
+// runServe implements `japaya serve`: a long-running evaluator service that
+// accepts remote eval requests over TCP, so build agents that forbid a local
+// interpreter can use `-engine remote:ADDR` against a managed host instead.
+// With -cert/-key/-ca the listener requires mutual TLS; without them it
+// speaks plain TCP, which is only sensible on a trusted network.
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("japaya serve", flag.ExitOnError)
+
+	var listen string
+	var pythonCmd string
+	var pythonDir string
+	var certFile string
+	var keyFile string
+	var caFile string
+
+	fs.StringVar(&listen, "listen", "127.0.0.1:7633", "address to listen on")
+	fs.StringVar(&pythonCmd, "python", "", "python executable (default: python3/python)")
+	fs.StringVar(&pythonDir, "python-dir", "", "directory added to Python module search path for snippets (optional)")
+	fs.StringVar(&certFile, "cert", "", "PEM server certificate; enables mutual TLS together with -key and -ca")
+	fs.StringVar(&keyFile, "key", "", "PEM private key for -cert")
+	fs.StringVar(&caFile, "ca", "", "PEM CA bundle that client certificates must chain to")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: japaya serve [-listen <addr>] [-cert <pem> -key <pem> -ca <pem>] [-python <python>] [-python-dir <dir>]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	tlsFlags := 0
+	for _, f := range []string{certFile, keyFile, caFile} {
+		if f != "" {
+			tlsFlags++
+		}
+	}
+	if tlsFlags != 0 && tlsFlags != 3 {
+		fmt.Fprintln(os.Stderr, "-cert, -key and -ca must be given together")
+		return 2
+	}
+
+	if err := validatePythonDir(pythonDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	py, err := python.NewEvaluator(pythonCmd, pythonDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer func() {
+		if err := py.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "warning: failed to close python worker:", err)
+		}
+	}()
+
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if tlsFlags == 3 {
+		cfg, err := remote.MTLSConfig(certFile, keyFile, caFile, true)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		ln = tls.NewListener(ln, cfg)
+		fmt.Fprintln(os.Stderr, "japaya serve: listening with mTLS on", ln.Addr())
+	} else {
+		fmt.Fprintln(os.Stderr, "japaya serve: listening WITHOUT TLS on", ln.Addr())
+	}
+
+	if err := remote.NewServer(py).Serve(ln); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
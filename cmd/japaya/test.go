@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/justinottesen/japaya/internal/core"
+	"github.com/justinottesen/japaya/internal/jshell"
+	"github.com/justinottesen/japaya/internal/python"
+)
+
+// runTest implements `japaya test`: translate inputs into a temp dir and
+// compare against checked-in expected outputs, so template regressions show
+// up as failures. -update regenerates the snapshots instead of comparing.
+func runTest(args []string) int {
+	fs := flag.NewFlagSet("japaya test", flag.ExitOnError)
+
+	var inPath string
+	var expectedDir string
+	var update bool
+	var pythonCmd string
+	var pythonDir string
+	var jshellCmd string
+
+	fs.StringVar(&inPath, "in", "", "input file or directory to translate")
+	fs.StringVar(&expectedDir, "expected", filepath.Join("testdata", "expected"), "directory of expected outputs")
+	fs.BoolVar(&update, "update", false, "write translated outputs into the expected directory instead of comparing")
+	fs.StringVar(&pythonCmd, "python", "", "python executable (default: python3/python)")
+	fs.StringVar(&pythonDir, "python-dir", "", "directory added to Python module search path for snippets (optional)")
+	fs.StringVar(&jshellCmd, "jshell", "", "jshell executable for `java!` regions (default: jshell)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: japaya test -in <input> [-expected <dir>] [-update] [-python <python>] [-python-dir <dir>]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if inPath == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if err := validatePythonDir(pythonDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	py, err := python.NewEvaluator(pythonCmd, pythonDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer func() {
+		if err := py.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "warning: failed to close python worker:", err)
+		}
+	}()
+
+	js := jshell.NewEvaluator(jshellCmd)
+	defer func() { _ = js.Close() }()
+
+	tr := &core.Translator{Py: py, JShell: js}
+
+	tmpDir, err := os.MkdirTemp("", "japaya-test-*")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(tmpDir)
+
+	outRoot := filepath.Join(tmpDir, "out")
+	if err := tr.TranslatePath(context.Background(), inPath, outRoot); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if update {
+		if err := copyTree(outRoot, expectedDir); err != nil {
+			fmt.Fprintln(os.Stderr, "failed updating snapshots:", err)
+			return 1
+		}
+		fmt.Println("updated", expectedDir)
+		return 0
+	}
+
+	diffs, err := compareTrees(expectedDir, outRoot)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(diffs) > 0 {
+		for _, d := range diffs {
+			fmt.Fprint(os.Stderr, d)
+		}
+		fmt.Fprintf(os.Stderr, "%d file(s) differ (run with -update to regenerate snapshots)\n", len(diffs))
+		return 1
+	}
+
+	fmt.Println("ok")
+	return 0
+}
+
+// listFiles returns the relative paths of all regular files under root.
+func listFiles(root string) (map[string]bool, error) {
+	files := map[string]bool{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files[rel] = true
+		return nil
+	})
+	return files, err
+}
+
+// compareTrees diffs the translated tree against the expected tree, returning
+// one printable diff per differing file.
+func compareTrees(expectedRoot, gotRoot string) ([]string, error) {
+	expected, err := listFiles(expectedRoot)
+	if err != nil {
+		return nil, fmt.Errorf("reading expected outputs %q: %w", expectedRoot, err)
+	}
+	got, err := listFiles(gotRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	all := map[string]bool{}
+	for f := range expected {
+		all[f] = true
+	}
+	for f := range got {
+		all[f] = true
+	}
+
+	var names []string
+	for f := range all {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+
+	var diffs []string
+	for _, name := range names {
+		switch {
+		case !expected[name]:
+			diffs = append(diffs, fmt.Sprintf("--- %s: unexpected output file\n", name))
+		case !got[name]:
+			diffs = append(diffs, fmt.Sprintf("--- %s: missing from output\n", name))
+		default:
+			wantBytes, err := os.ReadFile(filepath.Join(expectedRoot, name))
+			if err != nil {
+				return nil, err
+			}
+			gotBytes, err := os.ReadFile(filepath.Join(gotRoot, name))
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(wantBytes, gotBytes) {
+				diffs = append(diffs, fmt.Sprintf("--- %s\n%s", name, diffLines(wantBytes, gotBytes)))
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// diffLines renders a simple line-by-line diff (-want / +got). Not a minimal
+// diff, but enough to see what changed in generated code.
+func diffLines(want, got []byte) string {
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(string(got), "\n")
+
+	const maxShown = 20
+	var b strings.Builder
+	shown := 0
+
+	n := len(wantLines)
+	if len(gotLines) > n {
+		n = len(gotLines)
+	}
+	for i := 0; i < n && shown < maxShown; i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w == g {
+			continue
+		}
+		if i < len(wantLines) {
+			fmt.Fprintf(&b, "%d: -%s\n", i+1, w)
+		}
+		if i < len(gotLines) {
+			fmt.Fprintf(&b, "%d: +%s\n", i+1, g)
+		}
+		shown++
+	}
+	if shown == maxShown {
+		b.WriteString("...\n")
+	}
+	return b.String()
+}
+
+// copyTree mirrors the files under src into dst, creating directories as
+// needed. Existing files in dst that are absent from src are left alone.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0o644)
+	})
+}
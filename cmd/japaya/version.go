@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/justinottesen/japaya/internal/python"
+	"github.com/justinottesen/japaya/internal/version"
+)
+
+// runVersion implements `japaya version`: print the module version, git
+// commit, Go version and worker protocol version.
+func runVersion(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "usage: japaya version")
+		return 2
+	}
+
+	v, commit, date := version.Fields()
+	if commit == "" {
+		commit = "unknown"
+	}
+
+	fmt.Printf("japaya %s\n", v)
+	fmt.Printf("  commit:          %s\n", commit)
+	if date != "" {
+		fmt.Printf("  built:           %s\n", date)
+	}
+	fmt.Printf("  go:              %s\n", runtime.Version())
+	fmt.Printf("  worker protocol: %d\n", python.ProtocolVersion)
+	return 0
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/justinottesen/japaya/internal/core"
+	"github.com/justinottesen/japaya/internal/python"
+)
+
+// runWarm implements `japaya warm`: translate a tree with the outputs
+// discarded, purely for the caches it leaves behind. Starting the worker
+// byte-compiles python-dir into the persistent bytecode cache, and
+// evaluating every snippet compiles anything compileall missed, so a CI
+// cache-restore step that runs warm leaves the build that follows almost
+// entirely cache hits. Nothing is written anywhere the build reads.
+func runWarm(args []string) int {
+	fs := flag.NewFlagSet("japaya warm", flag.ExitOnError)
+
+	var inPath string
+	var pythonCmd string
+	var pythonDir string
+	fs.StringVar(&inPath, "in", "", "input file or directory to warm")
+	fs.StringVar(&pythonCmd, "python", "", "python executable (default: python3/python)")
+	fs.StringVar(&pythonDir, "python-dir", "", "directory added to Python module search path for snippets (optional)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: japaya warm -in <input> [-python <python>] [-python-dir <dir>]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if inPath == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	if err := validatePythonDir(pythonDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	py, err := python.NewEvaluator(pythonCmd, pythonDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer func() {
+		if err := py.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "warning: failed to close python worker:", err)
+		}
+	}()
+
+	// Outputs land in a scratch directory that is removed before we return;
+	// warming must never overwrite what a real build produced.
+	outDir, err := os.MkdirTemp("", "japaya-warm-*")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(outDir)
+
+	tr := &core.Translator{Py: py}
+	if info, statErr := os.Stat(inPath); statErr == nil && info.IsDir() {
+		tr.Summary = core.NewRunSummary()
+	}
+	if err := tr.TranslatePath(context.Background(), inPath, outDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if tr.Summary != nil {
+		fmt.Fprintf(os.Stderr, "japaya warm: evaluated %d file(s) in %s\n", tr.Summary.Report().Translated, inPath)
+	} else {
+		fmt.Fprintf(os.Stderr, "japaya warm: evaluated %s\n", inPath)
+	}
+	return 0
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/justinottesen/japaya/internal/core"
+)
+
+// How often watch mode polls for modifications. Polling keeps us free of
+// platform-specific notification APIs at the cost of a little latency.
+const watchPollInterval = 200 * time.Millisecond
+
+// runWatch re-translates on changes until ctx is cancelled. Edits are
+// debounced so a save storm triggers one rebuild. Input file edits rebuild
+// only the affected outputs. Python-dir helper edits rebuild only the files
+// whose snippets imported the edited module, as reported by the worker via
+// the import manifest; editing the prelude (__init__.py) or a module no file
+// is known to import falls back to a full rebuild. metrics, when non-nil,
+// records each rebuild cycle's cache hits and misses.
+func runWatch(ctx context.Context, tr *core.Translator, inPath, outPath, pythonDir string, debounce time.Duration, metrics *buildMetrics) int {
+	inInfo, err := os.Stat(inPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "stat input %q: %v\n", inPath, err)
+		return 1
+	}
+	treeMode := inInfo.IsDir()
+
+	// Record which helper modules each file imports so python-dir edits can
+	// rebuild selectively.
+	if tr.Manifest == nil {
+		tr.Manifest = core.NewManifest()
+	}
+
+	// Initial full build; failures are reported but watching continues, since
+	// the next edit may fix them.
+	if err := tr.TranslatePath(ctx, inPath, outPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	prev, err := scanWatched(tr, inPath, pythonDir, treeMode)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Fprintln(os.Stderr, "watching", inPath)
+
+	var pending map[string]bool
+	var lastChange time.Time
+
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-ticker.C:
+		}
+
+		cur, err := scanWatched(tr, inPath, pythonDir, treeMode)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+
+		changed := diffWatched(prev, cur)
+		prev = cur
+		if len(changed) > 0 {
+			if pending == nil {
+				pending = map[string]bool{}
+			}
+			for p := range changed {
+				pending[p] = true
+			}
+			lastChange = time.Now()
+		}
+
+		// Debounce: rebuild once edits have settled.
+		if pending == nil || time.Since(lastChange) < debounce {
+			continue
+		}
+
+		rebuildAll := false
+		fileSet := map[string]bool{}
+		var changedModules []string
+		for p := range pending {
+			if pythonDir != "" && strings.HasPrefix(p, filepath.Clean(pythonDir)+string(filepath.Separator)) {
+				mod := pythonDirModule(pythonDir, p)
+				if mod == "" {
+					// The prelude (__init__.py) seeds every snippet's
+					// namespace, so nothing short of a full rebuild is safe —
+					// and no cached region output either.
+					rebuildAll = true
+					if tr.RegionCache != nil {
+						tr.RegionCache.Clear()
+					}
+					if tr.EvalCache != nil {
+						tr.EvalCache.Clear()
+					}
+					continue
+				}
+				changedModules = append(changedModules, mod)
+				continue
+			}
+			fileSet[p] = true
+		}
+		pending = nil
+
+		// Edited helpers are cached in the long-lived worker; drop them so
+		// rebuilt files see the new code. Cached region outputs may have come
+		// from the old helper code, so they go too.
+		if len(changedModules) > 0 {
+			if tr.RegionCache != nil {
+				tr.RegionCache.Clear()
+			}
+			if tr.EvalCache != nil {
+				tr.EvalCache.Clear()
+			}
+			if inv, ok := tr.Py.(core.ModuleInvalidator); ok {
+				if err := inv.Invalidate(ctx, changedModules); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			}
+			for _, mod := range changedModules {
+				affected := tr.Manifest.FilesImporting(mod)
+				if len(affected) == 0 {
+					// No file is known to import it directly; it may still be
+					// pulled in transitively, so rebuild everything.
+					rebuildAll = true
+					break
+				}
+				for _, p := range affected {
+					fileSet[p] = true
+				}
+			}
+		}
+
+		var files []string
+		for p := range fileSet {
+			files = append(files, p)
+		}
+
+		if rebuildAll || !treeMode {
+			if metrics != nil {
+				n := countTranslatable(tr, prev)
+				metrics.recordRebuild(n, n)
+			}
+			fmt.Fprintln(os.Stderr, "rebuilding", inPath)
+			if err := tr.TranslatePath(ctx, inPath, outPath); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			continue
+		}
+
+		if metrics != nil {
+			metrics.recordRebuild(len(files), countTranslatable(tr, prev))
+		}
+		for _, p := range files {
+			rel, err := filepath.Rel(inPath, p)
+			if err != nil {
+				continue
+			}
+			target := filepath.Join(outPath, tr.OutputRelPath(rel))
+			fmt.Fprintln(os.Stderr, "rebuilding", p)
+			if err := tr.TranslateFile(ctx, p, target); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	}
+}
+
+// countTranslatable counts the watched files that are translation inputs
+// (as opposed to python-dir helpers), the denominator for rebuild cache
+// accounting.
+func countTranslatable(tr *core.Translator, mtimes map[string]time.Time) int {
+	n := 0
+	for p := range mtimes {
+		if tr.ShouldTranslatePath(p) {
+			n++
+		}
+	}
+	return n
+}
+
+// pythonDirModule maps a changed python-dir file to the top-level module
+// name snippets would import it as (the first path segment, minus .py for
+// plain modules). Returns "" for the prelude __init__.py, which has no
+// importable name of its own.
+func pythonDirModule(pythonDir, path string) string {
+	rel, err := filepath.Rel(pythonDir, path)
+	if err != nil {
+		return ""
+	}
+	top := rel
+	if i := strings.IndexRune(rel, filepath.Separator); i >= 0 {
+		top = rel[:i] // a package directory; the dir name is the module
+		return top
+	}
+	top = strings.TrimSuffix(top, ".py")
+	if top == "__init__" {
+		return ""
+	}
+	return top
+}
+
+// scanWatched collects mtimes for every watched file: translatable inputs
+// plus python-dir helper modules.
+func scanWatched(tr *core.Translator, inPath, pythonDir string, treeMode bool) (map[string]time.Time, error) {
+	mtimes := map[string]time.Time{}
+
+	record := func(path string) {
+		if info, err := os.Stat(path); err == nil {
+			mtimes[path] = info.ModTime()
+		}
+	}
+
+	if treeMode {
+		err := filepath.WalkDir(inPath, func(path string, d fs.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				return nil // transient: file removed mid-walk
+			}
+			if d.IsDir() {
+				name := d.Name()
+				if name == ".git" || name == "node_modules" || name == "bin" || name == "dist" {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if tr.ShouldTranslatePath(path) {
+				record(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		record(inPath)
+	}
+
+	if pythonDir != "" {
+		err := filepath.WalkDir(pythonDir, func(path string, d fs.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				return nil
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".py") {
+				record(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return mtimes, nil
+}
+
+// diffWatched returns the set of paths added, removed, or modified.
+func diffWatched(prev, cur map[string]time.Time) map[string]bool {
+	changed := map[string]bool{}
+	for p, mt := range cur {
+		if old, ok := prev[p]; !ok || !old.Equal(mt) {
+			changed[p] = true
+		}
+	}
+	for p := range prev {
+		if _, ok := cur[p]; !ok {
+			changed[p] = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// shouldAlign decides whether a statement's result is fitted to its region's
+// width: the region's align / no-align annotation wins, otherwise
+// AlignStatements applies.
+func (t *Translator) shouldAlign(opts RegionOptions) bool {
+	if _, ok := opts.Raw["align"]; ok {
+		return true
+	}
+	if _, ok := opts.Raw["no-align"]; ok {
+		return false
+	}
+	return t.AlignStatements
+}
+
+// alignStatement pads or trims a statement's result to the width the raw
+// region (backticks included) occupied in the template, so java text after
+// the splice keeps its column — handy for table-like constant declarations.
+// Only single-line regions with single-line results are adjusted. A result
+// too wide for its slot is spliced untouched with an align-overflow warning:
+// truncating generated code would corrupt it.
+func (t *Translator) alignStatement(path string, r Region, translated []byte) []byte {
+	if r.RawStart.Line != r.RawEnd.Line || bytes.IndexByte(translated, '\n') >= 0 {
+		return translated
+	}
+	slot := r.RawEndOff - r.RawStartOff
+	if len(translated) > slot {
+		// Trailing padding in the result is expendable; content is not.
+		if trimmed := bytes.TrimRight(translated, " "); len(trimmed) <= slot {
+			translated = trimmed
+		} else {
+			t.warn(path, r, "align-overflow", fmt.Sprintf(
+				"result is %d bytes wide but the region spans %d; alignment not preserved", len(trimmed), slot))
+			return translated
+		}
+	}
+	for len(translated) < slot {
+		translated = append(translated, ' ')
+	}
+	return translated
+}
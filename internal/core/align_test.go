@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// litEval returns a fixed literal for every statement, whatever its code.
+func litEval(lit string) fakePythonEvaluator {
+	return fakePythonEvaluator{eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		return []byte(lit), nil
+	}}
+}
+
+func TestTranslateUnit_AlignPadsResultToRegionWidth(t *testing.T) {
+	t.Parallel()
+
+	// The raw region `value_a` spans 9 bytes; a 2-byte result gets padded
+	// so the trailing comment column is preserved.
+	in := "int A = `value_a`; // doc\n"
+	unit, err := ParseBytes([]byte(in))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	tr := &Translator{Py: litEval("42"), AlignStatements: true}
+	out, err := tr.TranslateUnit(t.Context(), unit)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	want := "int A = 42       ; // doc\n"
+	if string(out) != want {
+		t.Errorf("output:\n got: %q\nwant: %q", out, want)
+	}
+}
+
+func TestTranslateUnit_AlignOverflowWarnsAndKeepsResult(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("int A = `v`; // doc\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	tr := &Translator{
+		Py:              litEval("123456"),
+		AlignStatements: true,
+		Warnings:        NewWarnings(),
+	}
+	out, err := tr.TranslateUnit(t.Context(), unit)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if string(out) != "int A = 123456; // doc\n" {
+		t.Errorf("output = %q, want the result spliced untruncated", out)
+	}
+
+	warnings := tr.Warnings.All()
+	if len(warnings) != 1 || warnings[0].Rule != "align-overflow" {
+		t.Fatalf("warnings = %+v, want one align-overflow", warnings)
+	}
+	if !strings.Contains(warnings[0].Message, "alignment not preserved") {
+		t.Errorf("warning message = %q", warnings[0].Message)
+	}
+}
+
+func TestTranslateUnit_AlignAnnotationsOverrideGlobal(t *testing.T) {
+	t.Parallel()
+
+	// Annotation turns alignment on for one region without the global flag.
+	unit, err := ParseBytes([]byte("int A = `#!align value_long`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	out, err := TranslateUnit(t.Context(), unit, litEval("7"))
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if string(out) != "int A = 7                   ;\n" {
+		t.Errorf("align-annotated output = %q", out)
+	}
+
+	// no-align opts a region out of the global mode.
+	unit, err = ParseBytes([]byte("int A = `#!no-align v`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	tr := &Translator{Py: litEval("7"), AlignStatements: true}
+	out, err = tr.TranslateUnit(t.Context(), unit)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if string(out) != "int A = 7;\n" {
+		t.Errorf("no-align output = %q", out)
+	}
+}
+
+func TestTranslateUnit_AlignSkipsMultilineResults(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("int A = `v`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	tr := &Translator{Py: litEval("1 +\n2"), AlignStatements: true}
+	out, err := tr.TranslateUnit(t.Context(), unit)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if string(out) != "int A = 1 +\n2;\n" {
+		t.Errorf("multiline result output = %q", out)
+	}
+}
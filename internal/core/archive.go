@@ -0,0 +1,213 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// isArchivePath reports whether an output path selects archive mode.
+func isArchivePath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".zip", ".jar":
+		return true
+	default:
+		return false
+	}
+}
+
+// TranslateArchive translates a zip/jar of templates without extracting it,
+// walking the archive through an fs.FS. outPath may be a directory or
+// another archive.
+func (t *Translator) TranslateArchive(ctx context.Context, archivePath, outPath string) error {
+	if t.Verify {
+		return fmt.Errorf("verify mode is not supported with archive input %q", archivePath)
+	}
+
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("open archive %q: %w", archivePath, err)
+	}
+	defer zr.Close()
+
+	label := func(name string) string { return archivePath + "!" + name }
+
+	if isArchivePath(outPath) {
+		return writeArchiveAtomically(outPath, func(zw *zip.Writer) error {
+			return t.translateFS(ctx, &zr.Reader, zipSink{zw: zw}, label)
+		})
+	}
+
+	if err := os.MkdirAll(outPath, 0o755); err != nil {
+		return fmt.Errorf("mkdir output dir %q: %w", outPath, err)
+	}
+	return t.translateFS(ctx, &zr.Reader, DirSink{Root: outPath}, label)
+}
+
+// walkTranslatableFS is walkTranslatableFiles for an fs.FS: it visits each
+// translatable file with its slash-separated name and extension-rewritten
+// output name, applying the same junk-dir and extension filters.
+func (t *Translator) walkTranslatableFS(fsys fs.FS, fn func(name, outRel string) error) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			base := d.Name()
+			if base == ".git" || base == "node_modules" || base == "bin" || base == "dist" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !t.language().translates(name) {
+			return nil
+		}
+		return fn(name, filepath.ToSlash(t.language().outputRelPath(filepath.FromSlash(name))))
+	})
+}
+
+// writeArchiveAtomically creates a zip at path via temp file + rename,
+// handing the open writer to fill.
+func writeArchiveAtomically(path string, fill func(*zip.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".japaya-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+	}()
+
+	zw := zip.NewWriter(tmp)
+	if err := fill(zw); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
+
+// TranslateTreeToArchive walks inRoot like TranslateTree but writes the
+// translated files into a zip/jar archive at archivePath instead of a
+// directory tree. The archive is written atomically (temp file + rename).
+func (t *Translator) TranslateTreeToArchive(ctx context.Context, inRoot, archivePath string) error {
+	if t.Verify {
+		return fmt.Errorf("verify mode is not supported with archive output %q", archivePath)
+	}
+
+	inRoot = filepath.Clean(inRoot)
+
+	return writeArchiveAtomically(archivePath, func(zw *zip.Writer) error {
+		return t.translateTreeToSink(ctx, inRoot, zipSink{zw: zw}, func(name string) string {
+			return archivePath + "!" + name
+		})
+	})
+}
+
+// translateTreeToSink walks inRoot like TranslateTree but hands each
+// translated file to sink, qualifying output names in events via outLabel.
+// Entry names use forward slashes regardless of platform. Shared by the
+// zip and tar output modes.
+func (t *Translator) translateTreeToSink(ctx context.Context, inRoot string, sink OutputSink, outLabel func(string) string) error {
+	return t.walkTranslatableFiles(inRoot, func(path, relPath string) error {
+		t.emit(Event{Type: "file_started", Path: path})
+		started := time.Now()
+
+		fileCtx, cancel := t.fileContext(ctx)
+		defer cancel()
+		outBytes, _, err := t.translateFileBytes(fileCtx, path)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(relPath)
+		if err := sink.WriteFile(name, outBytes); err != nil {
+			return err
+		}
+
+		t.emit(Event{
+			Type:       "file_done",
+			Path:       path,
+			OutPath:    outLabel(name),
+			DurationMS: float64(time.Since(started)) / float64(time.Millisecond),
+			Imports:    t.takeImports(path),
+		})
+		return nil
+	})
+}
+
+// tarOutDest splits a "tar:DEST" output path; DEST "-" selects stdout.
+func tarOutDest(path string) (string, bool) {
+	return strings.CutPrefix(path, "tar:")
+}
+
+// TranslateTreeToTar walks inRoot like TranslateTree but streams the
+// translated files as a tar archive to w, so pipelines like
+// `japaya -in src -out tar:- | docker build -` work without a writable
+// workspace. spec qualifies entry names in events, like the zip path does.
+func (t *Translator) TranslateTreeToTar(ctx context.Context, inRoot string, w io.Writer, spec string) error {
+	if t.Verify {
+		return fmt.Errorf("verify mode is not supported with tar output")
+	}
+
+	inRoot = filepath.Clean(inRoot)
+	tw := tar.NewWriter(w)
+	err := t.translateTreeToSink(ctx, inRoot, tarSink{tw: tw, modTime: t.epochTime()}, func(name string) string {
+		return spec + "!" + name
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// translateTreeToTarDest routes a "tar:" output: "-" streams straight to
+// stdout; anything else is a tar file written via temp + rename like zip
+// archives.
+func (t *Translator) translateTreeToTarDest(ctx context.Context, inRoot, dest string) error {
+	if dest == "-" {
+		return t.TranslateTreeToTar(ctx, inRoot, os.Stdout, "tar:-")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".japaya-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+	}()
+
+	if err := t.TranslateTreeToTar(ctx, inRoot, tmp, dest); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, dest)
+}
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"bytes"
+	"context"
+)
+
+// Marks a python block as an assertion:
+//
+//	```!assert
+//	assert len(columns) > 0, "schema has no columns"
+//	```
+//
+// The body runs for its checks only — a raising check fails the build with
+// the region's position, like any other python error — and contributes no
+// output, so templates can sanity-check the data they were given without
+// disturbing the generated file.
+const assertPrefix = "!assert"
+
+// isAssertBlock reports whether a python block region carries an !assert
+// directive.
+func isAssertBlock(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte(assertPrefix))
+}
+
+// evalAssert runs an !assert block's checks, discarding whatever they print.
+// Checks may start on the directive line itself or on the lines below it.
+func (t *Translator) evalAssert(ctx context.Context, data []byte) ([]byte, error) {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	body := bytes.TrimPrefix(trimmed, []byte(assertPrefix))
+	if _, err := t.Py.Eval(ctx, RegionTypePythonBlock, body); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
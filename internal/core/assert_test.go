@@ -0,0 +1,90 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTranslateUnit_AssertBlockContributesNoOutput(t *testing.T) {
+	t.Parallel()
+
+	var got string
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, regionType RegionType, code []byte) ([]byte, error) {
+			got = string(code)
+			return []byte("should be discarded"), nil
+		},
+	}
+
+	unit := &TranslationUnit{
+		Regions: []Region{
+			{Type: RegionTypeJava, Data: []byte("class A {")},
+			{Type: RegionTypePythonBlock, Data: []byte("!assert\nassert 1 == 1\n")},
+			{Type: RegionTypeJava, Data: []byte("}\n")},
+		},
+	}
+	out, err := TranslateUnit(context.Background(), unit, py)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if want := "class A {}\n"; string(out) != want {
+		t.Fatalf("output = %q, want %q", out, want)
+	}
+	if !strings.Contains(got, "assert 1 == 1") || strings.Contains(got, "!assert") {
+		t.Fatalf("evaluator received %q; want the body without the directive", got)
+	}
+}
+
+func TestTranslateUnit_AssertBlockFailureCarriesPosition(t *testing.T) {
+	t.Parallel()
+
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, regionType RegionType, code []byte) ([]byte, error) {
+			return nil, errors.New("AssertionError: schema has no columns")
+		},
+	}
+
+	r := Region{
+		Type:  RegionTypePythonBlock,
+		Data:  []byte("!assert\nassert False\n"),
+		Start: Position{Line: 4, Column: 2},
+	}
+	unit := &TranslationUnit{Regions: []Region{r}}
+	_, err := TranslateUnit(context.Background(), unit, py)
+	if err == nil {
+		t.Fatal("expected a failing assert block to fail the unit")
+	}
+	var te *TranslationError
+	if !errors.As(err, &te) {
+		t.Fatalf("error %v should be a TranslationError", err)
+	}
+	if te.Region.Start != r.Start {
+		t.Fatalf("error position = %+v, want %+v", te.Region.Start, r.Start)
+	}
+	if !strings.Contains(te.Error(), "AssertionError") {
+		t.Fatalf("error %q should carry the assertion message", te)
+	}
+}
+
+func TestTranslateUnit_AssertBlockSkipsEmptyOutputWarning(t *testing.T) {
+	t.Parallel()
+
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, regionType RegionType, code []byte) ([]byte, error) {
+			return nil, nil
+		},
+	}
+
+	tr := &Translator{Py: py, Warnings: NewWarnings()}
+	unit := &TranslationUnit{
+		Regions: []Region{{Type: RegionTypePythonBlock, Data: []byte("!assert\nassert True\n")}},
+	}
+	if _, err := tr.TranslateUnit(context.Background(), unit); err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if all := tr.Warnings.All(); len(all) != 0 {
+		t.Fatalf("expected no warnings for an output-free assert block, got %v", all)
+	}
+}
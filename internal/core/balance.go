@@ -0,0 +1,117 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// regionSpan records which region produced the output starting at outLine,
+// so whole-file diagnostics can point back at a contributing region.
+type regionSpan struct {
+	outLine uint
+	region  Region
+}
+
+// bracketIssue describes the first bracket/brace/paren problem in a
+// generated file.
+type bracketIssue struct {
+	line uint // output line of the offending bracket (zero-based)
+	msg  string
+}
+
+// checkOutputBalance warns when the assembled output's brackets don't
+// balance — the most common symptom of a template emitting a partial block.
+// The warning is attributed to the region whose output contains the first
+// offending bracket.
+func (t *Translator) checkOutputBalance(path string, out []byte, spans []regionSpan) {
+	issue := findUnbalanced(out)
+	if issue == nil || len(spans) == 0 {
+		return
+	}
+
+	t.warn(path, regionAtOutLine(spans, issue.line), "unbalanced-output",
+		fmt.Sprintf("%s (output line %d)", issue.msg, issue.line+1))
+}
+
+// findUnbalanced scans generated source for the first mismatched bracket,
+// ignoring brackets inside strings, chars, and comments. Returns nil when
+// everything balances.
+func findUnbalanced(out []byte) *bracketIssue {
+	type open struct {
+		ch   byte
+		line uint
+	}
+	var stack []open
+	var inBlock bool
+
+	closerFor := map[byte]byte{')': '(', ']': '[', '}': '{'}
+
+	for lineNo, line := range strings.Split(string(out), "\n") {
+		var quote byte
+		escaped := false
+
+		for i := 0; i < len(line); i++ {
+			b := line[i]
+
+			if inBlock {
+				if b == '*' && i+1 < len(line) && line[i+1] == '/' {
+					inBlock = false
+					i++
+				}
+				continue
+			}
+			if quote != 0 {
+				switch {
+				case escaped:
+					escaped = false
+				case b == '\\':
+					escaped = true
+				case b == quote:
+					quote = 0
+				}
+				continue
+			}
+
+			switch b {
+			case '"', '\'':
+				quote = b
+			case '/':
+				if i+1 < len(line) {
+					if line[i+1] == '/' {
+						i = len(line) // rest of line is a comment
+					} else if line[i+1] == '*' {
+						inBlock = true
+						i++
+					}
+				}
+			case '(', '[', '{':
+				stack = append(stack, open{ch: b, line: uint(lineNo)})
+			case ')', ']', '}':
+				want := closerFor[b]
+				if len(stack) == 0 {
+					return &bracketIssue{
+						line: uint(lineNo),
+						msg:  fmt.Sprintf("unmatched %q", string(b)),
+					}
+				}
+				top := stack[len(stack)-1]
+				if top.ch != want {
+					return &bracketIssue{
+						line: uint(lineNo),
+						msg:  fmt.Sprintf("%q closed by %q", string(top.ch), string(b)),
+					}
+				}
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	if len(stack) > 0 {
+		top := stack[len(stack)-1]
+		return &bracketIssue{
+			line: top.line,
+			msg:  fmt.Sprintf("%q never closed", string(top.ch)),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFindUnbalanced(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		in   string
+		line int // offending output line, or -1 for balanced
+	}{
+		{"Balanced", "class A {\n  void f() { g(x[0]); }\n}\n", -1},
+		{"NeverClosed", "class A {\n  void f() {\n}\n", 0},
+		{"UnmatchedClose", "class A {\n}\n}\n", 2},
+		{"WrongCloser", "int a = f(x];\n", 0},
+		{"BracketInString", "String s = \"{[(\";\n", -1},
+		{"BracketInComment", "// {\n/* ( */\n", -1},
+		{"BracketInChar", "char c = '{';\n", -1},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			issue := findUnbalanced([]byte(tc.in))
+			if tc.line < 0 {
+				if issue != nil {
+					t.Fatalf("findUnbalanced = %+v, want nil", issue)
+				}
+				return
+			}
+			if issue == nil {
+				t.Fatalf("findUnbalanced = nil, want issue at line %d", tc.line)
+			}
+			if issue.line != uint(tc.line) {
+				t.Errorf("issue.line = %d, want %d (%s)", issue.line, tc.line, issue.msg)
+			}
+		})
+	}
+}
+
+func TestCheckBalance_WarnsOnContributingRegion(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("class A {\n`v`\n}\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	tr := &Translator{
+		Py: fakePythonEvaluator{eval: func(ctx context.Context, rt RegionType, code []byte) ([]byte, error) {
+			return []byte("void f());"), nil
+		}},
+		Warnings:     NewWarnings(),
+		CheckBalance: true,
+	}
+	if _, err := tr.translateUnit(t.Context(), unit, "in.japaya"); err != nil {
+		t.Fatalf("translateUnit: %v", err)
+	}
+
+	var found *Warning
+	for _, w := range tr.Warnings.All() {
+		if w.Rule == "unbalanced-output" {
+			w := w
+			found = &w
+		}
+	}
+	if found == nil {
+		t.Fatalf("warnings = %v, want an unbalanced-output finding", tr.Warnings.All())
+	}
+	// The stray close paren came from the snippet's line of the input.
+	if found.Start.Line != 1 {
+		t.Errorf("Start.Line = %d, want 1", found.Start.Line)
+	}
+}
+
+func TestCheckBalance_BalancedOutputIsQuiet(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("class A {\n  int x = `v`;\n}\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	tr := &Translator{
+		Py:           fakePythonEvaluator{eval: upperEval},
+		Warnings:     NewWarnings(),
+		CheckBalance: true,
+	}
+	if _, err := tr.translateUnit(t.Context(), unit, "in.japaya"); err != nil {
+		t.Fatalf("translateUnit: %v", err)
+	}
+	for _, w := range tr.Warnings.All() {
+		if w.Rule == "unbalanced-output" {
+			t.Fatalf("unexpected warning: %v", w)
+		}
+	}
+}
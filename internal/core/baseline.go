@@ -0,0 +1,112 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// A baseline records diagnostics a tree has already accepted, so enabling a
+// new check on a large legacy tree doesn't fail the build for findings that
+// predate it. Runs loading a baseline suppress exactly the recorded
+// warnings; anything new still prints and still trips -Werror. Entries
+// match on path, rule and message — not position — so unrelated edits that
+// shift line numbers don't invalidate the baseline.
+
+// baselineVersion is the format version written to baseline files.
+const baselineVersion = 1
+
+// BaselineEntry is one accepted diagnostic in a baseline file.
+type BaselineEntry struct {
+	Path    string `json:"path"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// baselineFile is the on-disk shape of a baseline.
+type baselineFile struct {
+	Version int             `json:"version"`
+	Entries []BaselineEntry `json:"entries"`
+}
+
+// Baseline is a loaded set of accepted diagnostics.
+type Baseline struct {
+	entries map[BaselineEntry]bool
+}
+
+// LoadBaseline reads a baseline file written by WriteBaseline.
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file baselineFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("baseline %s: %w", path, err)
+	}
+	if file.Version != baselineVersion {
+		return nil, fmt.Errorf("baseline %s: unsupported version %d", path, file.Version)
+	}
+	b := &Baseline{entries: make(map[BaselineEntry]bool, len(file.Entries))}
+	for _, e := range file.Entries {
+		b.entries[e] = true
+	}
+	return b, nil
+}
+
+// WriteBaseline records the given warnings as accepted, writing a baseline
+// file that later runs load with LoadBaseline. Entries are sorted and
+// deduplicated so regenerating over an unchanged tree produces an identical
+// file.
+func WriteBaseline(path string, warnings []Warning) error {
+	seen := make(map[BaselineEntry]bool, len(warnings))
+	var entries []BaselineEntry
+	for _, w := range warnings {
+		e := BaselineEntry{Path: w.Path, Rule: w.Rule, Message: w.Message}
+		if seen[e] {
+			continue
+		}
+		seen[e] = true
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		if entries[a].Path != entries[b].Path {
+			return entries[a].Path < entries[b].Path
+		}
+		if entries[a].Rule != entries[b].Rule {
+			return entries[a].Rule < entries[b].Rule
+		}
+		return entries[a].Message < entries[b].Message
+	})
+
+	data, err := json.MarshalIndent(baselineFile{Version: baselineVersion, Entries: entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Suppresses reports whether the baseline has accepted this warning. A nil
+// baseline suppresses nothing.
+func (b *Baseline) Suppresses(w Warning) bool {
+	if b == nil {
+		return false
+	}
+	return b.entries[BaselineEntry{Path: w.Path, Rule: w.Rule, Message: w.Message}]
+}
+
+// Filter returns the warnings the baseline does not suppress, preserving
+// order. A nil baseline returns the input unchanged.
+func (b *Baseline) Filter(warnings []Warning) []Warning {
+	if b == nil {
+		return warnings
+	}
+	var kept []Warning
+	for _, w := range warnings {
+		if !b.Suppresses(w) {
+			kept = append(kept, w)
+		}
+	}
+	return kept
+}
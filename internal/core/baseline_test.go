@@ -0,0 +1,59 @@
+package core
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBaseline_RoundTripSuppressesRecordedWarnings(t *testing.T) {
+	t.Parallel()
+
+	old := Warning{Path: "a.japaya", Start: Position{Line: 3}, Rule: "empty-region", Message: "region produced no output"}
+	path := filepath.Join(t.TempDir(), "japaya-baseline.json")
+	if err := WriteBaseline(path, []Warning{old, old}); err != nil {
+		t.Fatalf("WriteBaseline: %v", err)
+	}
+
+	b, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("LoadBaseline: %v", err)
+	}
+
+	// Positions drift as templates are edited; the baseline matches anyway.
+	moved := old
+	moved.Start = Position{Line: 40, Column: 2}
+	if !b.Suppresses(moved) {
+		t.Error("recorded warning not suppressed after its position moved")
+	}
+
+	fresh := Warning{Path: "a.japaya", Rule: "stale-output", Message: "something new"}
+	if b.Suppresses(fresh) {
+		t.Error("unrecorded warning suppressed")
+	}
+	if got := b.Filter([]Warning{moved, fresh}); len(got) != 1 || got[0].Rule != "stale-output" {
+		t.Errorf("Filter = %+v, want only the fresh warning", got)
+	}
+}
+
+func TestBaseline_NilSuppressesNothing(t *testing.T) {
+	t.Parallel()
+
+	var b *Baseline
+	w := Warning{Path: "a.japaya", Rule: "empty-region"}
+	if b.Suppresses(w) {
+		t.Error("nil baseline suppressed a warning")
+	}
+	if got := b.Filter([]Warning{w}); len(got) != 1 {
+		t.Errorf("Filter = %+v, want input unchanged", got)
+	}
+}
+
+func TestLoadBaseline_RejectsUnknownVersion(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "japaya-baseline.json")
+	writeFile(t, path, `{"version": 99, "entries": []}`)
+	if _, err := LoadBaseline(path); err == nil {
+		t.Fatal("want an error for an unsupported version")
+	}
+}
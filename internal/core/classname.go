@@ -0,0 +1,73 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// checkClassFileName verifies that the public top-level type a generated
+// file declares matches the file name it will be written under — the usual
+// symptom of a template parameterizing its body but not its file name. A
+// mismatch fails the file with the region that produced the declaration.
+func (t *Translator) checkClassFileName(path string, out []byte, spans []regionSpan) error {
+	base := filepath.Base(t.language().outputRelPath(path))
+	want := strings.TrimSuffix(base, filepath.Ext(base))
+
+	name, line, ok := publicTypeName(out)
+	if !ok || name == want {
+		return nil
+	}
+	return &TranslationError{
+		Region: regionAtOutLine(spans, line),
+		Err:    fmt.Errorf("public type %q does not match file name %q (expected %q); rename the file or parameterize the type name", name, base, want),
+	}
+}
+
+// publicTypeName finds the first public top-level type declaration in
+// generated source and returns its name and zero-based output line. ok is
+// false when the file declares no public top-level type.
+func publicTypeName(out []byte) (name string, line uint, ok bool) {
+	var depth int
+	var inBlock bool
+
+	for i, l := range strings.Split(string(out), "\n") {
+		trimmed := strings.TrimSpace(l)
+		if depth == 0 && !inBlock && isTypeDeclLine(trimmed) {
+			fields := strings.Fields(trimmed)
+			public := false
+			for j, tok := range fields {
+				if tok == "public" {
+					public = true
+				}
+				switch tok {
+				case "class", "interface", "enum", "record":
+					if public && j+1 < len(fields) {
+						n := fields[j+1]
+						// Strip generics, supertypes and the body opener.
+						n = strings.TrimRight(n, "{")
+						if k := strings.IndexAny(n, "<("); k >= 0 {
+							n = n[:k]
+						}
+						return n, uint(i), true
+					}
+				}
+			}
+		}
+		depth, inBlock = scanBraces(l, depth, inBlock)
+	}
+	return "", 0, false
+}
+
+// regionAtOutLine returns the region whose output contains the given output
+// line: the last span starting at or before it.
+func regionAtOutLine(spans []regionSpan, line uint) Region {
+	var r Region
+	for _, s := range spans {
+		if s.outLine > line {
+			break
+		}
+		r = s.region
+	}
+	return r
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPublicTypeName(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		in   string
+		name string
+		line uint
+		ok   bool
+	}{
+		{"public class Foo {\n}\n", "Foo", 0, true},
+		{"package p;\n\npublic final class Bar<T> extends Base {\n}\n", "Bar", 2, true},
+		{"public interface Baz {\n}\n", "Baz", 0, true},
+		{"public record Point(int x, int y) {}\n", "Point", 0, true},
+		{"class Helper {\n}\npublic enum Kind {\n}\n", "Kind", 2, true},
+		// Nested types don't count, and a file may have no public type.
+		{"class Outer {\n  public class Inner {\n  }\n}\n", "", 0, false},
+		{"class PackagePrivate {\n}\n", "", 0, false},
+	}
+	for _, tc := range cases {
+		name, line, ok := publicTypeName([]byte(tc.in))
+		if name != tc.name || line != tc.line || ok != tc.ok {
+			t.Errorf("publicTypeName(%q) = (%q, %d, %v), want (%q, %d, %v)",
+				tc.in, name, line, ok, tc.name, tc.line, tc.ok)
+		}
+	}
+}
+
+func TestTranslateFile_ClassNameMismatchFails(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "Foo.japaya")
+	writeFile(t, inPath, "public class `name()` {\n}\n")
+
+	py := fakePythonEvaluator{eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		return []byte("Bar"), nil
+	}}
+	tr := &Translator{Py: py, CheckClassName: true}
+	err := tr.TranslateFile(t.Context(), inPath, filepath.Join(dir, "Foo.java"))
+	if err == nil || !strings.Contains(err.Error(), `public type "Bar" does not match file name "Foo.java"`) {
+		t.Fatalf("err = %v, want a class/file mismatch error", err)
+	}
+	if _, serr := os.Stat(filepath.Join(dir, "Foo.java")); serr == nil {
+		t.Error("mismatching output was written anyway")
+	}
+}
+
+func TestTranslateFile_ClassNameMatchPasses(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "Foo.japaya")
+	writeFile(t, inPath, "public class `name()` {\n}\n")
+
+	py := fakePythonEvaluator{eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		return []byte("Foo"), nil
+	}}
+	tr := &Translator{Py: py, CheckClassName: true}
+	if err := tr.TranslateFile(t.Context(), inPath, filepath.Join(dir, "Foo.java")); err != nil {
+		t.Fatalf("TranslateFile: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTranslateFile_CheckDeterminismFailsOnDifferingOutputs(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "A.java")
+	out := filepath.Join(dir, "out", "A.java")
+	writeFile(t, in, "int x = `counter()`;\n")
+
+	calls := 0
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, regionType RegionType, code []byte) ([]byte, error) {
+			calls++
+			return []byte(fmt.Sprintf("%d", calls)), nil
+		},
+	}
+
+	tr := &Translator{Py: py, CheckDeterminism: true}
+	err := tr.TranslateFile(context.Background(), in, out)
+	if err == nil {
+		t.Fatal("expected differing outputs to fail the file")
+	}
+	if !strings.Contains(err.Error(), "differs between two evaluations") {
+		t.Fatalf("error %q should describe the determinism failure", err)
+	}
+	if !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("error %q should point at the first differing line", err)
+	}
+}
+
+func TestTranslateFile_CheckDeterminismPassesStableTemplates(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "A.java")
+	out := filepath.Join(dir, "out", "A.java")
+	writeFile(t, in, "int x = `1+2`;\n")
+
+	evals := 0
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, regionType RegionType, code []byte) ([]byte, error) {
+			evals++
+			return []byte("3"), nil
+		},
+	}
+
+	tr := &Translator{Py: py, CheckDeterminism: true}
+	if err := tr.TranslateFile(context.Background(), in, out); err != nil {
+		t.Fatalf("TranslateFile: %v", err)
+	}
+	if evals != 2 {
+		t.Fatalf("expected the region to be evaluated twice, got %d", evals)
+	}
+}
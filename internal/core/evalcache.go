@@ -0,0 +1,75 @@
+package core
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// An EvalCache memoizes annotated region results across every file in a
+// run. A region opts in with the cache annotation:
+//
+//	`#!cache read_version()`
+//
+// marking its result as a run-level constant — typically a value computed
+// in the prelude, like a version or build number read from disk — so the
+// same expensive lookup isn't repeated for thousands of files. Entries are
+// keyed by the snippet text, the translator's snippet defines and the
+// fingerprint the cache was built with (the CLI hashes python-dir, so
+// results can't outlive a helper edit in a long-lived process). Nothing
+// else enters the key, so an annotated region must not depend on per-file
+// state like japaya.package or the file's directory.
+type EvalCache struct {
+	fingerprint string
+
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+// NewEvalCache returns an empty cache. fingerprint should identify the
+// prelude and helpers the results depend on; any change to it is a new key
+// space.
+func NewEvalCache(fingerprint string) *EvalCache {
+	return &EvalCache{fingerprint: fingerprint, entries: map[string][]byte{}}
+}
+
+// Clear drops every cached result.
+func (c *EvalCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string][]byte{}
+}
+
+// key digests everything a cached result may depend on.
+func (c *EvalCache) key(typ RegionType, code []byte, defines map[string]string) string {
+	h := sha256.New()
+	io.WriteString(h, c.fingerprint)
+	fmt.Fprintf(h, "|%d|", typ)
+	h.Write(code)
+	keys := make([]string, 0, len(defines))
+	for k := range defines {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "|%s=%s", k, defines[k])
+	}
+	return string(h.Sum(nil))
+}
+
+// lookup returns the cached result for the snippet under the given defines.
+func (c *EvalCache) lookup(typ RegionType, code []byte, defines map[string]string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out, ok := c.entries[c.key(typ, code, defines)]
+	return out, ok
+}
+
+// store records a freshly evaluated result.
+func (c *EvalCache) store(typ RegionType, code []byte, defines map[string]string, out []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[c.key(typ, code, defines)] = append([]byte(nil), out...)
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestEvalCache_AnnotatedRegionEvaluatedOncePerRun(t *testing.T) {
+	t.Parallel()
+
+	evals := 0
+	py := fakePythonEvaluator{eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		evals++
+		return upperEval(ctx, typ, code)
+	}}
+	tr := &Translator{Py: py, EvalCache: NewEvalCache("prelude-v1")}
+
+	// The same annotated snippet in two different files evaluates once.
+	for _, src := range []string{
+		"class A { String v = `#!cache version()`; }\n",
+		"class B { String v = `#!cache version()`; }\n",
+	} {
+		unit, err := ParseBytes([]byte(src))
+		if err != nil {
+			t.Fatalf("ParseBytes: %v", err)
+		}
+		out, err := tr.TranslateUnit(t.Context(), unit)
+		if err != nil {
+			t.Fatalf("TranslateUnit: %v", err)
+		}
+		if want := "String v = <version()>;"; !bytes.Contains(out, []byte(want)) {
+			t.Fatalf("out = %q, want it to contain %q", out, want)
+		}
+	}
+	if evals != 1 {
+		t.Errorf("evals = %d, want the annotated region evaluated once for the run", evals)
+	}
+}
+
+func TestEvalCache_UnannotatedRegionsNotShared(t *testing.T) {
+	t.Parallel()
+
+	evals := 0
+	py := fakePythonEvaluator{eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		evals++
+		return upperEval(ctx, typ, code)
+	}}
+	tr := &Translator{Py: py, EvalCache: NewEvalCache("prelude-v1")}
+
+	for i := 0; i < 2; i++ {
+		unit, err := ParseBytes([]byte("int n = `counter()`;\n"))
+		if err != nil {
+			t.Fatalf("ParseBytes: %v", err)
+		}
+		if _, err := tr.TranslateUnit(t.Context(), unit); err != nil {
+			t.Fatalf("TranslateUnit: %v", err)
+		}
+	}
+	if evals != 2 {
+		t.Errorf("evals = %d, want unannotated regions evaluated every time", evals)
+	}
+}
+
+func TestEvalCache_DefinesEnterTheKey(t *testing.T) {
+	t.Parallel()
+
+	evals := 0
+	py := fakePythonEvaluator{eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		evals++
+		return upperEval(ctx, typ, code)
+	}}
+	cache := NewEvalCache("prelude-v1")
+
+	// Two runs sharing one cache but differing in their snippet defines must
+	// not see each other's results.
+	for _, env := range []map[string]string{
+		{"BUILD": "1"},
+		{"BUILD": "2"},
+	} {
+		tr := &Translator{Py: py, EvalCache: cache, SnippetEnv: env}
+		unit, err := ParseBytes([]byte("String v = `#!cache version()`;\n"))
+		if err != nil {
+			t.Fatalf("ParseBytes: %v", err)
+		}
+		if _, err := tr.TranslateUnit(t.Context(), unit); err != nil {
+			t.Fatalf("TranslateUnit: %v", err)
+		}
+	}
+	if evals != 2 {
+		t.Errorf("evals = %d, want a fresh evaluation per set of defines", evals)
+	}
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// Event is a single machine-readable progress event emitted during a
+// translation run. Fields other than Type are populated only where they make
+// sense for the event type.
+type Event struct {
+	Type       string    `json:"type"`                  // "file_started" | "file_done" | "file_skipped" | "region_evaluated" | "warning" | "error"
+	Path       string    `json:"path,omitempty"`        // input path, for file and region events
+	OutPath    string    `json:"out_path,omitempty"`    // output path, for file_done
+	RegionType string    `json:"region_type,omitempty"` // for region_evaluated
+	Start      *Position `json:"start,omitempty"`       // region start, for region_evaluated
+	DurationMS float64   `json:"duration_ms,omitempty"` // for region_evaluated and file_done
+	Message    string    `json:"message,omitempty"`     // for warning and error
+	Imports    []string  `json:"imports,omitempty"`     // modules imported by the file's snippets, for file_done
+}
+
+// EventSink receives progress events during translation. Emit may be called
+// from multiple goroutines; implementations must be safe for concurrent use.
+type EventSink interface {
+	Emit(Event)
+}
+
+// NDJSONSink is an EventSink that writes one JSON object per line.
+type NDJSONSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewNDJSONSink(w io.Writer) *NDJSONSink {
+	return &NDJSONSink{w: w}
+}
+
+func (s *NDJSONSink) Emit(e Event) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		// Events are best-effort diagnostics; never fail a build over one.
+		return
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = s.w.Write(line)
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// echoSnippetEval unwraps one layer of indirection per evaluation: code like
+// "gen" expands to a new statement region, anything else to its upper-cased
+// self, letting tests drive multi-pass expansion.
+func echoSnippetEval(ctx context.Context, rt RegionType, code []byte) ([]byte, error) {
+	if string(code) == "gen" {
+		return []byte("`inner`"), nil
+	}
+	return []byte(strings.ToUpper(string(code))), nil
+}
+
+func TestExpandRecursive_SecondPass(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("x = `gen`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	tr := &Translator{
+		Py:             fakePythonEvaluator{eval: echoSnippetEval},
+		MaxExpandDepth: 2,
+	}
+	out, err := tr.TranslateUnit(t.Context(), unit)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if string(out) != "x = INNER;\n" {
+		t.Errorf("output = %q, want %q", out, "x = INNER;\n")
+	}
+}
+
+func TestExpandRecursive_DepthExceeded(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("x = `gen`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	// Every pass regenerates another region, so any finite depth runs out.
+	tr := &Translator{
+		Py: fakePythonEvaluator{eval: func(ctx context.Context, rt RegionType, code []byte) ([]byte, error) {
+			return []byte("`gen`"), nil
+		}},
+		MaxExpandDepth: 3,
+	}
+	_, err = tr.TranslateUnit(t.Context(), unit)
+	if err == nil || !strings.Contains(err.Error(), "expansion passes") {
+		t.Fatalf("err = %v, want depth exceeded error", err)
+	}
+}
+
+func TestExpandRecursive_DisabledLeavesOutputAlone(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("x = `gen`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	tr := &Translator{Py: fakePythonEvaluator{eval: echoSnippetEval}}
+	out, err := tr.TranslateUnit(t.Context(), unit)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	// Single-pass mode splices the generated region verbatim.
+	if string(out) != "x = `inner`;\n" {
+		t.Errorf("output = %q, want %q", out, "x = `inner`;\n")
+	}
+}
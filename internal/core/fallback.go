@@ -0,0 +1,87 @@
+package core
+
+import "bytes"
+
+// splitDefaultFallback recognizes the error-fallback shorthand on a python
+// statement region: `expr | default("literal")` evaluates expr, and if the
+// snippet raises, splices the literal instead of failing the file (with a
+// warning). Useful for optional metadata like a git describe that isn't
+// available in every environment.
+//
+// The split happens at the last '|' that is not nested inside brackets or a
+// string literal, and only when everything after it is a default("...") or
+// default('...') call — so ordinary uses of python's `|` operator pass
+// through untouched. ok is false when the region has no shorthand.
+func splitDefaultFallback(code []byte) (expr []byte, fallback string, ok bool) {
+	var (
+		depth   int  // () [] {} nesting
+		quote   byte // active string quote, 0 if none
+		escaped bool // previous byte was a backslash (inside a string)
+	)
+	split := -1
+	for i := 0; i < len(code); i++ {
+		b := code[i]
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == quote:
+				quote = 0
+			}
+			continue
+		}
+		switch b {
+		case '\'', '"':
+			quote = b
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '|':
+			if depth == 0 {
+				split = i
+			}
+		}
+	}
+	if split <= 0 {
+		return nil, "", false
+	}
+
+	fallback, ok = parseDefaultCall(code[split+1:])
+	if !ok {
+		return nil, "", false
+	}
+	return bytes.TrimSpace(code[:split]), fallback, true
+}
+
+// parseDefaultCall matches default("literal") with either quote style,
+// returning the literal. Backslash escapes of the quote character and of
+// backslashes themselves are honored; anything fancier isn't a shorthand.
+func parseDefaultCall(code []byte) (string, bool) {
+	s := bytes.TrimSpace(code)
+	if len(s) < len(`default("")`) || string(s[:8]) != "default(" || s[len(s)-1] != ')' {
+		return "", false
+	}
+	inner := bytes.TrimSpace(s[8 : len(s)-1])
+	if len(inner) < 2 {
+		return "", false
+	}
+	quote := inner[0]
+	if (quote != '"' && quote != '\'') || inner[len(inner)-1] != quote {
+		return "", false
+	}
+	var lit []byte
+	for i := 1; i < len(inner)-1; i++ {
+		b := inner[i]
+		if b == '\\' && i+1 < len(inner)-1 && (inner[i+1] == quote || inner[i+1] == '\\') {
+			i++
+			b = inner[i]
+		} else if b == quote {
+			return "", false // unescaped quote ends the literal early
+		}
+		lit = append(lit, b)
+	}
+	return string(lit), true
+}
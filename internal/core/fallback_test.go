@@ -0,0 +1,105 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSplitDefaultFallback(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		in       string
+		expr     string
+		fallback string
+		ok       bool
+	}{
+		{"DoubleQuoted", `git_describe() | default("dev")`, "git_describe()", "dev", true},
+		{"SingleQuoted", `version | default('0')`, "version", "0", true},
+		{"EmptyLiteral", `v | default("")`, "v", "", true},
+		{"EscapedQuote", `v | default("say \"hi\"")`, "v", `say "hi"`, true},
+		{"NoShorthand", "1 + 2", "", "", false},
+		{"PlainPipe", "a | b", "", "", false},
+		{"UnquotedArg", "x | default(y)", "", "", false},
+		{"PipeInCall", "f(a | b)", "", "", false},
+		{"PipeInString", `'a | default("x")'`, "", "", false},
+		{"LastPipeWins", `a | b | default("z")`, "a | b", "z", true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			expr, fallback, ok := splitDefaultFallback([]byte(tc.in))
+			if ok != tc.ok {
+				t.Fatalf("splitDefaultFallback(%q) ok = %v, want %v", tc.in, ok, tc.ok)
+			}
+			if !ok {
+				return
+			}
+			if string(expr) != tc.expr || fallback != tc.fallback {
+				t.Errorf("splitDefaultFallback(%q) = (%q, %q), want (%q, %q)",
+					tc.in, expr, fallback, tc.expr, tc.fallback)
+			}
+		})
+	}
+}
+
+func TestTranslateUnit_DefaultFallbackSplicesLiteralAndWarns(t *testing.T) {
+	t.Parallel()
+
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, regionType RegionType, code []byte) ([]byte, error) {
+			if strings.Contains(string(code), "boom") {
+				return nil, errors.New("NameError: name 'boom' is not defined")
+			}
+			return []byte("live"), nil
+		},
+	}
+
+	tr := &Translator{Py: py, Warnings: NewWarnings()}
+	unit := &TranslationUnit{
+		Regions: []Region{
+			{Type: RegionTypeJava, Data: []byte("int x = ")},
+			{Type: RegionTypePythonStatement, Data: []byte(`boom() | default("0")`)},
+			{Type: RegionTypeJava, Data: []byte("; int y = ")},
+			{Type: RegionTypePythonStatement, Data: []byte(`ok() | default("9")`)},
+			{Type: RegionTypeJava, Data: []byte(";\n")},
+		},
+	}
+	out, err := tr.TranslateUnit(context.Background(), unit)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if want := "int x = 0; int y = live;\n"; string(out) != want {
+		t.Fatalf("output = %q, want %q", out, want)
+	}
+
+	warnings := tr.Warnings.All()
+	if len(warnings) != 1 || warnings[0].Rule != "default-fallback" {
+		t.Fatalf("expected one default-fallback warning, got %v", warnings)
+	}
+	if !strings.Contains(warnings[0].Message, "NameError") {
+		t.Fatalf("warning %q should carry the underlying error", warnings[0].Message)
+	}
+}
+
+func TestTranslateUnit_FailureWithoutFallbackStillFails(t *testing.T) {
+	t.Parallel()
+
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, regionType RegionType, code []byte) ([]byte, error) {
+			return nil, errors.New("boom")
+		},
+	}
+
+	unit := &TranslationUnit{
+		Regions: []Region{{Type: RegionTypePythonStatement, Data: []byte("boom()")}},
+	}
+	if _, err := TranslateUnit(context.Background(), unit, py); err == nil {
+		t.Fatal("expected a failing region without a fallback to fail the unit")
+	}
+}
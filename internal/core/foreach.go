@@ -0,0 +1,122 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Marks a python block as a row-expansion directive:
+//
+//	```!foreach rows.csv
+//	public int get{name}() {{ return {value}; }}
+//	```
+//
+// The referenced file (CSV with a header row, or a JSON array of objects) is
+// resolved relative to the input file. The rest of the block is a Java
+// template repeated once per row, with the row's fields bound in the snippet
+// namespace and substituted via str.format ({field} placeholders; double
+// braces for literals).
+const foreachPrefix = "!foreach"
+
+// isForeachBlock reports whether a python block region carries a !foreach
+// directive.
+func isForeachBlock(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte(foreachPrefix))
+}
+
+// expandForeach evaluates a !foreach block: one template expansion per row,
+// concatenated in row order. basePath is the input file (may be empty when
+// translating from a reader, in which case the row file is resolved against
+// the working directory).
+func (t *Translator) expandForeach(ctx context.Context, data []byte, basePath string) ([]byte, error) {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+
+	directive, template, _ := bytes.Cut(trimmed, []byte("\n"))
+	rowsArg := strings.TrimSpace(strings.TrimPrefix(string(directive), foreachPrefix))
+	if rowsArg == "" {
+		return nil, fmt.Errorf("%s directive missing a rows file", foreachPrefix)
+	}
+
+	rowsPath := rowsArg
+	if !filepath.IsAbs(rowsPath) && basePath != "" {
+		rowsPath = filepath.Join(filepath.Dir(basePath), rowsPath)
+	}
+
+	rows, err := loadRows(rowsPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s %q: %w", foreachPrefix, rowsArg, err)
+	}
+
+	var out []byte
+	for i, row := range rows {
+		rowJSON, err := json.Marshal(row)
+		if err != nil {
+			return nil, fmt.Errorf("%s row %d: %w", foreachPrefix, i, err)
+		}
+
+		// Bind the row's fields in the snippet namespace, then splice the
+		// formatted template. strconv.Quote output is a valid python string
+		// literal for the JSON/template text we feed it.
+		code := fmt.Sprintf(
+			"import json as _json\n_row = _json.loads(%s)\nglobals().update(_row)\nprint(%s.format(**_row), end='')",
+			strconv.Quote(string(rowJSON)), strconv.Quote(string(template)))
+
+		expanded, err := t.Py.Eval(ctx, RegionTypePythonBlock, []byte(code))
+		if err != nil {
+			return nil, fmt.Errorf("%s row %d: %w", foreachPrefix, i, err)
+		}
+		out = append(out, expanded...)
+	}
+
+	return out, nil
+}
+
+// loadRows reads a rows file into field maps. CSV files use their header row
+// as field names; JSON files must contain an array of objects.
+func loadRows(path string) ([]map[string]any, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		records, err := csv.NewReader(f).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, fmt.Errorf("csv file has no header row")
+		}
+		header := records[0]
+		rows := make([]map[string]any, 0, len(records)-1)
+		for _, rec := range records[1:] {
+			row := make(map[string]any, len(header))
+			for i, name := range header {
+				if i < len(rec) {
+					row[name] = rec[i]
+				}
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+
+	case ".json":
+		var rows []map[string]any
+		if err := json.NewDecoder(f).Decode(&rows); err != nil {
+			return nil, fmt.Errorf("expected a JSON array of objects: %w", err)
+		}
+		return rows, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported rows file type %q (expected .csv or .json)", filepath.Ext(path))
+	}
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"strconv"
+)
+
+// applyFormatSpec rewrites a python statement region that uses the format
+// shorthand `expr:spec` into an explicit format(expr, "spec") call, so
+// templates can write `total:>8,.2f` instead of spelling out format(...).
+//
+// The split happens at the first colon that is not nested inside brackets or
+// a string literal; a literal top-level colon can be kept with `\:`. Code
+// without a top-level colon is returned unchanged.
+func applyFormatSpec(code []byte) []byte {
+	var (
+		depth   int  // () [] {} nesting
+		quote   byte // active string quote, 0 if none
+		escaped bool // previous byte was a backslash (inside a string)
+	)
+
+	// cleaned is code with `\:` escapes collapsed; split is the index of the
+	// top-level colon within cleaned, or -1.
+	cleaned := make([]byte, 0, len(code))
+	split := -1
+
+	for i := 0; i < len(code); i++ {
+		b := code[i]
+
+		if quote != 0 {
+			cleaned = append(cleaned, b)
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch b {
+		case '\'', '"':
+			quote = b
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '\\':
+			// `\:` escapes the shorthand separator.
+			if i+1 < len(code) && code[i+1] == ':' {
+				cleaned = append(cleaned, ':')
+				i++
+				continue
+			}
+		case ':':
+			if depth == 0 && split < 0 {
+				split = len(cleaned)
+				cleaned = append(cleaned, b)
+				continue
+			}
+		}
+		cleaned = append(cleaned, b)
+	}
+
+	// No shorthand, or nothing to format before the colon.
+	if split <= 0 {
+		return cleaned
+	}
+
+	expr := cleaned[:split]
+	spec := cleaned[split+1:]
+
+	out := make([]byte, 0, len(expr)+len(spec)+16)
+	out = append(out, "format(("...)
+	out = append(out, expr...)
+	out = append(out, "), "...)
+	out = append(out, strconv.Quote(string(spec))...)
+	out = append(out, ')')
+	return out
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"context"
+	"testing"
+)
+
+func TestApplyFormatSpec(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"NoColon", "1 + 2", "1 + 2"},
+		{"SimpleSpec", "total:>8,.2f", `format((total), ">8,.2f")`},
+		{"EmptySpec", "x:", `format((x), "")`},
+		{"ColonInDict", "{'a': 1}['a']", "{'a': 1}['a']"},
+		{"ColonInSlice", "xs[1:3]", "xs[1:3]"},
+		{"ColonInString", "'a:b'", "'a:b'"},
+		{"EscapedColon", `label \: value`, "label : value"},
+		{"EscapedThenSpec", `d[\:]:>4`, `format((d[:]), ">4")`},
+		{"LeadingColon", ":>4", ":>4"},
+		{"SpecAfterCall", "f(a, b):^10", `format((f(a, b)), "^10")`},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := string(applyFormatSpec([]byte(tc.in))); got != tc.want {
+				t.Errorf("applyFormatSpec(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTranslateUnit_StatementFormatShorthand(t *testing.T) {
+	t.Parallel()
+
+	var got string
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, regionType RegionType, code []byte) ([]byte, error) {
+			got = string(code)
+			return []byte("ok"), nil
+		},
+	}
+
+	unit := &TranslationUnit{
+		Regions: []Region{{Type: RegionTypePythonStatement, Data: []byte("pi:.2f")}},
+	}
+	if _, err := TranslateUnit(context.Background(), unit, py); err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if want := `format((pi), ".2f")`; got != want {
+		t.Errorf("evaluator received %q, want %q", got, want)
+	}
+}
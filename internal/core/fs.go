@@ -0,0 +1,142 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// OutputSink receives translated files. Names are slash-separated paths
+// relative to the output root, whatever that means for the sink.
+type OutputSink interface {
+	WriteFile(name string, data []byte) error
+}
+
+// DirSink writes outputs under an OS directory root, creating parent
+// directories as needed.
+type DirSink struct {
+	Root string
+}
+
+func (s DirSink) WriteFile(name string, data []byte) error {
+	target := filepath.Join(s.Root, filepath.FromSlash(name))
+	if err := atomicWriteFile(target, data, 0o644); err != nil {
+		return fmt.Errorf("write output %q: %w", target, err)
+	}
+	return nil
+}
+
+// MemSink collects outputs in memory, which is handy for tests and for
+// embedders that post-process results before writing anywhere.
+type MemSink map[string][]byte
+
+func (s MemSink) WriteFile(name string, data []byte) error {
+	s[name] = append([]byte(nil), data...)
+	return nil
+}
+
+// zipSink writes outputs as entries of an open zip archive.
+type zipSink struct {
+	zw *zip.Writer
+}
+
+func (s zipSink) WriteFile(name string, data []byte) error {
+	w, err := s.zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("create archive entry %q: %w", name, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("write archive entry %q: %w", name, err)
+	}
+	return nil
+}
+
+// tarSink writes outputs as entries of an open tar stream. Every entry
+// shares one mod time so the stream is reproducible within a run.
+type tarSink struct {
+	tw      *tar.Writer
+	modTime time.Time
+}
+
+func (s tarSink) WriteFile(name string, data []byte) error {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o644,
+		Size:     int64(len(data)),
+		ModTime:  s.modTime,
+	}
+	if err := s.tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("write tar entry %q: %w", name, err)
+	}
+	if _, err := s.tw.Write(data); err != nil {
+		return fmt.Errorf("write tar entry %q: %w", name, err)
+	}
+	return nil
+}
+
+// TranslateFS translates a filesystem using a bare Translator.
+func TranslateFS(ctx context.Context, in fs.FS, out OutputSink, py PythonEvaluator) error {
+	return (&Translator{Py: py}).TranslateFS(ctx, in, out)
+}
+
+// TranslateFS walks any fs.FS (embedded filesystems, zip readers, virtual
+// overlays) with the usual junk-dir and extension filters and writes
+// translated files to the sink. This is the entry point for embedders that
+// don't work with OS paths.
+func (t *Translator) TranslateFS(ctx context.Context, in fs.FS, out OutputSink) error {
+	return t.translateFS(ctx, in, out, func(name string) string { return name })
+}
+
+// translateFS is TranslateFS with a label function so callers (e.g. archive
+// mode) can qualify entry names in events and errors.
+func (t *Translator) translateFS(ctx context.Context, in fs.FS, out OutputSink, label func(string) string) error {
+	if t.Py == nil {
+		return fmt.Errorf("nil PythonEvaluator")
+	}
+	if out == nil {
+		return fmt.Errorf("nil OutputSink")
+	}
+
+	// Entries here aren't OS paths, so there's no directory to scope snippets
+	// to; env additions still apply.
+	if ss, ok := t.Py.(SnippetScoper); ok {
+		ss.SetSnippetScope("", t.SnippetEnv)
+	}
+
+	return t.walkTranslatableFS(in, func(name, outRel string) error {
+		l := label(name)
+
+		t.emit(Event{Type: "file_started", Path: l})
+		started := time.Now()
+
+		f, err := in.Open(name)
+		if err != nil {
+			return fmt.Errorf("open input %q: %w", l, err)
+		}
+		fileCtx, cancel := t.fileContext(ctx)
+		defer cancel()
+		outBytes, terr := t.translateReader(fileCtx, f, l)
+		_ = f.Close()
+		if terr != nil {
+			return fmt.Errorf("translate %q: %w", l, terr)
+		}
+
+		if err := out.WriteFile(outRel, outBytes); err != nil {
+			return err
+		}
+
+		t.emit(Event{
+			Type:       "file_done",
+			Path:       l,
+			OutPath:    outRel,
+			DurationMS: float64(time.Since(started)) / float64(time.Millisecond),
+			Imports:    t.takeImports(l),
+		})
+		return nil
+	})
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTranslateFS_MemSink(t *testing.T) {
+	t.Parallel()
+
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+			return []byte("3"), nil
+		},
+	}
+
+	in := fstest.MapFS{
+		"A.japaya":    {Data: []byte("int x = `1+2`;\n")},
+		"sub/B.java":  {Data: []byte("public class B {}\n")},
+		"ignored.txt": {Data: []byte("ignore\n")},
+	}
+
+	out := MemSink{}
+	if err := TranslateFS(context.Background(), in, out, py); err != nil {
+		t.Fatalf("TranslateFS error: %v", err)
+	}
+
+	if got, want := string(out["A.java"]), "int x = 3;\n"; got != want {
+		t.Fatalf("A.java mismatch: got %q, want %q", got, want)
+	}
+	if got, want := string(out["sub/B.java"]), "public class B {}\n"; got != want {
+		t.Fatalf("sub/B.java mismatch: got %q, want %q", got, want)
+	}
+	if _, ok := out["ignored.txt"]; ok {
+		t.Fatalf("ignored.txt should not be translated")
+	}
+	if len(out) != 2 {
+		t.Fatalf("unexpected outputs: %v", out)
+	}
+}
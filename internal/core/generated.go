@@ -0,0 +1,161 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+)
+
+// annotateGenerated inserts a @Generated annotation (with its import) above
+// every top-level type declaration in src, so static analyzers can exclude
+// generated code. fqn is the annotation's fully qualified name; the value is
+// always "japaya".
+//
+// Returns the rewritten source plus the line numbers (in src's original
+// numbering) each new line was inserted before, in ascending order, so the
+// caller can fix up source maps.
+func annotateGenerated(src []byte, fqn string) ([]byte, []uint) {
+	simple := fqn
+	if i := strings.LastIndex(fqn, "."); i >= 0 {
+		simple = fqn[i+1:]
+	}
+	annotation := "@" + simple + "(\"japaya\")"
+	importLine := "import " + fqn + ";"
+
+	lines := bytes.Split(src, []byte("\n"))
+
+	// inserts maps an original line index to the lines to place before it.
+	inserts := make(map[int][]string)
+
+	var (
+		depth        int
+		inBlock      bool
+		packageLine  = -1
+		importSeen   = false
+		annotated    = false
+		prevNonBlank = ""
+	)
+
+	for i, line := range lines {
+		text := string(line)
+		trimmed := strings.TrimSpace(text)
+
+		if depth == 0 && !inBlock {
+			if strings.HasPrefix(trimmed, "package ") {
+				packageLine = i
+			}
+			if trimmed == importLine {
+				importSeen = true
+			}
+			if isTypeDeclLine(trimmed) && !strings.Contains(prevNonBlank, "@"+simple) {
+				indent := text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+				inserts[i] = append(inserts[i], indent+annotation)
+				annotated = true
+			}
+		}
+
+		if trimmed != "" {
+			prevNonBlank = trimmed
+		}
+		depth, inBlock = scanBraces(text, depth, inBlock)
+	}
+
+	if !annotated {
+		return src, nil
+	}
+
+	// The import goes after the package statement, or at the very top when
+	// there is none. Skip it for unqualified annotation names.
+	if !importSeen && strings.Contains(fqn, ".") {
+		at := 0
+		if packageLine >= 0 {
+			at = packageLine + 1
+		}
+		inserts[at] = append([]string{importLine}, inserts[at]...)
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(src) + 64)
+	var positions []uint
+	for i, line := range lines {
+		for _, ins := range inserts[i] {
+			out.WriteString(ins)
+			out.WriteByte('\n')
+			positions = append(positions, uint(i))
+		}
+		out.Write(line)
+		if i < len(lines)-1 {
+			out.WriteByte('\n')
+		}
+	}
+	return out.Bytes(), positions
+}
+
+// isTypeDeclLine reports whether a trimmed line begins a type declaration:
+// optional modifiers followed by class/interface/enum/record.
+func isTypeDeclLine(trimmed string) bool {
+	for _, tok := range strings.Fields(trimmed) {
+		switch tok {
+		case "class", "interface", "enum", "record":
+			return true
+		case "public", "private", "protected", "final", "abstract",
+			"sealed", "non-sealed", "static", "strictfp":
+			continue
+		default:
+			if strings.HasPrefix(tok, "@") {
+				continue
+			}
+			return false
+		}
+	}
+	return false
+}
+
+// scanBraces advances brace depth and block-comment state across one line,
+// ignoring braces inside strings, chars, and comments.
+func scanBraces(line string, depth int, inBlock bool) (int, bool) {
+	var quote byte
+	escaped := false
+
+	for i := 0; i < len(line); i++ {
+		b := line[i]
+
+		if inBlock {
+			if b == '*' && i+1 < len(line) && line[i+1] == '/' {
+				inBlock = false
+				i++
+			}
+			continue
+		}
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch b {
+		case '"', '\'':
+			quote = b
+		case '/':
+			if i+1 < len(line) {
+				if line[i+1] == '/' {
+					return depth, inBlock // rest of line is a comment
+				}
+				if line[i+1] == '*' {
+					inBlock = true
+					i++
+				}
+			}
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+	}
+	return depth, inBlock
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnnotateGenerated(t *testing.T) {
+	t.Parallel()
+
+	const fqn = "javax.annotation.processing.Generated"
+
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			"ClassWithPackage",
+			"package com.example;\n\npublic class A {\n  void f() {}\n}\n",
+			"package com.example;\nimport javax.annotation.processing.Generated;\n\n@Generated(\"japaya\")\npublic class A {\n  void f() {}\n}\n",
+		},
+		{
+			"NoPackage",
+			"enum Color { RED }\n",
+			"import javax.annotation.processing.Generated;\n@Generated(\"japaya\")\nenum Color { RED }\n",
+		},
+		{
+			"NestedTypeUntouched",
+			"class A {\n  class Inner {}\n}\n",
+			"import javax.annotation.processing.Generated;\n@Generated(\"japaya\")\nclass A {\n  class Inner {}\n}\n",
+		},
+		{
+			"TwoTopLevelTypes",
+			"class A {}\nclass B {}\n",
+			"import javax.annotation.processing.Generated;\n@Generated(\"japaya\")\nclass A {}\n@Generated(\"japaya\")\nclass B {}\n",
+		},
+		{
+			"AlreadyAnnotated",
+			"@Generated(\"japaya\")\nclass A {}\n",
+			"@Generated(\"japaya\")\nclass A {}\n",
+		},
+		{
+			"KeywordInStringOrComment",
+			"class A {\n  String s = \"class B {\";\n  // class C {\n}\n",
+			"import javax.annotation.processing.Generated;\n@Generated(\"japaya\")\nclass A {\n  String s = \"class B {\";\n  // class C {\n}\n",
+		},
+		{
+			"NoTypes",
+			"// just a comment\n",
+			"// just a comment\n",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, _ := annotateGenerated([]byte(tc.in), fqn)
+			if string(got) != tc.want {
+				t.Errorf("annotateGenerated:\n got: %q\nwant: %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAnnotateGenerated_ReportsInsertPositions(t *testing.T) {
+	t.Parallel()
+
+	in := "package p;\n\nclass A {}\n"
+	_, positions := annotateGenerated([]byte(in), "g.Generated")
+	// Import before line 1, annotation before line 2 (original numbering).
+	if len(positions) != 2 || positions[0] != 1 || positions[1] != 2 {
+		t.Fatalf("positions = %v, want [1 2]", positions)
+	}
+}
+
+func TestSourceMap_StaysAlignedAfterAnnotation(t *testing.T) {
+	t.Parallel()
+
+	unitData := "package p;\n\nclass A {\n  int x = `v`;\n}\n"
+	unit, err := ParseBytes([]byte(unitData))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	tr := &Translator{
+		Py:                  fakePythonEvaluator{eval: upperEval},
+		SourceMaps:          NewSourceMaps(),
+		GeneratedAnnotation: "g.Generated",
+	}
+	out, sm, err := tr.translateUnitMapped(t.Context(), unit, "in.japaya")
+	if err != nil {
+		t.Fatalf("translateUnitMapped: %v", err)
+	}
+
+	lines := strings.Split(string(out), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.Contains(line, "int x"):
+			// Output gained two lines (import + annotation) above this one.
+			if got := sm.InputLine(uint(i)); got != 3 {
+				t.Errorf("InputLine(%d) = %d, want 3 for %q", i, got, line)
+			}
+		case line == "}":
+			if got := sm.InputLine(uint(i)); got != 4 {
+				t.Errorf("InputLine(%d) = %d, want 4 for %q", i, got, line)
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeader_PrependedWithPlaceholders(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("class A {}\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	tr := &Translator{
+		Py:     fakePythonEvaluator{eval: upperEval},
+		Header: "// Copyright {{year}}.\n// Generated from {{file}}; do not edit.\n",
+	}
+	out, err := tr.translateUnit(t.Context(), unit, "src/A.japaya")
+	if err != nil {
+		t.Fatalf("translateUnit: %v", err)
+	}
+
+	want := fmt.Sprintf("// Copyright %d.\n// Generated from src/A.japaya; do not edit.\nclass A {}\n",
+		time.Now().Year())
+	if string(out) != want {
+		t.Errorf("output:\n got: %q\nwant: %q", out, want)
+	}
+}
+
+func TestHeader_YearHonorsEpoch(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("class A {}\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	tr := &Translator{
+		Py:     fakePythonEvaluator{eval: upperEval},
+		Header: "// Copyright {{year}}.\n",
+		Epoch:  time.Date(2000, 6, 1, 0, 0, 0, 0, time.UTC).Unix(),
+	}
+	out, err := tr.translateUnit(t.Context(), unit, "A.japaya")
+	if err != nil {
+		t.Fatalf("translateUnit: %v", err)
+	}
+	if !strings.HasPrefix(string(out), "// Copyright 2000.") {
+		t.Errorf("output %q should use the epoch's year", out)
+	}
+}
+
+func TestHeader_MissingTrailingNewlineAdded(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("class A {}\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	tr := &Translator{
+		Py:     fakePythonEvaluator{eval: upperEval},
+		Header: "// header",
+	}
+	out, err := tr.translateUnit(t.Context(), unit, "A.japaya")
+	if err != nil {
+		t.Fatalf("translateUnit: %v", err)
+	}
+	if string(out) != "// header\nclass A {}\n" {
+		t.Errorf("output = %q, want header on its own line", out)
+	}
+}
+
+func TestHeader_SourceMapShiftedPastHeader(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("class A {\n  int x = `v`;\n}\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	tr := &Translator{
+		Py:         fakePythonEvaluator{eval: upperEval},
+		SourceMaps: NewSourceMaps(),
+		Header:     "// line one\n// line two\n",
+	}
+	out, sm, err := tr.translateUnitMapped(t.Context(), unit, "in.japaya")
+	if err != nil {
+		t.Fatalf("translateUnitMapped: %v", err)
+	}
+
+	for i, line := range strings.Split(string(out), "\n") {
+		switch {
+		case strings.Contains(line, "int x"):
+			if got := sm.InputLine(uint(i)); got != 1 {
+				t.Errorf("InputLine(%d) = %d, want 1 for %q", i, got, line)
+			}
+		case line == "}":
+			if got := sm.InputLine(uint(i)); got != 2 {
+				t.Errorf("InputLine(%d) = %d, want 2 for %q", i, got, line)
+			}
+		}
+	}
+}
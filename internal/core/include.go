@@ -0,0 +1,137 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Marks a python block as an include directive:
+//
+//	```!include common/Header.japaya```
+//
+// The referenced template (resolved relative to the including file) is
+// translated in place of the block, snippets and all. Included templates may
+// include further templates; cycles are reported with the full include chain
+// rather than recursing until the stack runs out, and MaxIncludeDepth caps
+// how deep honest (acyclic) nesting may go.
+const includePrefix = "!include"
+
+// Default for Translator.MaxIncludeDepth when the field is zero.
+const defaultMaxIncludeDepth = 16
+
+// isIncludeBlock reports whether a python block region carries a !include
+// directive.
+func isIncludeBlock(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte(includePrefix))
+}
+
+// includeFrame is one hop in an include chain: the file that issued the
+// directive and where in it the directive sits.
+type includeFrame struct {
+	path string // the including file
+	line uint   // 1-based line of the !include directive, for reporting
+}
+
+// expandInclude translates the template named by a !include block and splices
+// the result. basePath is the including file (may be empty when translating
+// from a reader; the target is then resolved against the working directory).
+func (t *Translator) expandInclude(ctx context.Context, data []byte, basePath string, r Region) ([]byte, error) {
+	return t.expandIncludeFrom(ctx, data, basePath, r, nil)
+}
+
+// expandIncludeFrom is expandInclude with the chain of includes already
+// traversed, threaded through recursive calls for cycle and depth reporting.
+func (t *Translator) expandIncludeFrom(ctx context.Context, data []byte, basePath string, r Region, chain []includeFrame) ([]byte, error) {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	directive, _, _ := bytes.Cut(trimmed, []byte("\n"))
+	arg := strings.TrimSpace(strings.TrimPrefix(string(directive), includePrefix))
+	if arg == "" {
+		return nil, fmt.Errorf("%s directive missing a template path", includePrefix)
+	}
+
+	target := arg
+	if !filepath.IsAbs(target) && basePath != "" {
+		target = filepath.Join(filepath.Dir(basePath), target)
+	}
+	target = filepath.Clean(target)
+
+	chain = append(chain, includeFrame{path: basePath, line: r.Start.Line + 1})
+	for _, frame := range chain {
+		if frame.path == target {
+			return nil, fmt.Errorf("include cycle:\n%s", formatIncludeChain(chain, target))
+		}
+	}
+	maxDepth := t.MaxIncludeDepth
+	if maxDepth == 0 {
+		maxDepth = defaultMaxIncludeDepth
+	}
+	if len(chain) > maxDepth {
+		return nil, fmt.Errorf("includes nested more than %d deep:\n%s; raise MaxIncludeDepth if intended",
+			maxDepth, formatIncludeChain(chain, target))
+	}
+
+	raw, err := os.ReadFile(target)
+	if err != nil {
+		return nil, fmt.Errorf("%s %q: %w", includePrefix, arg, err)
+	}
+	unit, err := ParseBytesWithOptions(raw, t.ParseOptions)
+	if err != nil {
+		return nil, fmt.Errorf("%s %q: %w", includePrefix, arg, err)
+	}
+
+	var out []byte
+	for i, reg := range unit.Regions {
+		switch {
+		case reg.Type == RegionTypeJava:
+			out = append(out, reg.Data...)
+		case reg.Type == RegionTypePythonBlock && isIncludeBlock(stripRegionOpts(reg)):
+			nested, err := t.expandIncludeFrom(ctx, stripRegionOpts(reg), target, reg, chain)
+			if err != nil {
+				if _, ok := err.(*TranslationError); ok {
+					return nil, err
+				}
+				return nil, &TranslationError{Region: reg, Err: err}
+			}
+			out = append(out, nested...)
+		default:
+			translated, err := t.evalRegion(ctx, reg, target, i)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, translated...)
+		}
+	}
+	// The newline after the closing backticks survives in the including
+	// file, so the included template's own trailing newline is dropped to
+	// avoid doubling up.
+	return chompTrailingNewline(out), nil
+}
+
+// stripRegionOpts returns a block region's data with any leading #!
+// annotation removed, for directive detection only.
+func stripRegionOpts(r Region) []byte {
+	_, data, err := parseRegionOpts(r.Data, r.Type == RegionTypePythonBlock)
+	if err != nil {
+		return r.Data
+	}
+	return data
+}
+
+// formatIncludeChain renders one include per line, ending at the target that
+// closed the cycle or tripped the depth limit.
+func formatIncludeChain(chain []includeFrame, target string) string {
+	var b strings.Builder
+	for _, frame := range chain {
+		path := frame.path
+		if path == "" {
+			path = "<input>"
+		}
+		fmt.Fprintf(&b, "  %s:%d includes\n", path, frame.line)
+	}
+	fmt.Fprintf(&b, "  %s", target)
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTranslateFile_IncludeSplicesTemplate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "Header.japaya"),
+		"// shared header: `tag`\n")
+	in := filepath.Join(dir, "Main.japaya")
+	writeFile(t, in,
+		"```!include Header.japaya```\nclass Main {}\n")
+	out := filepath.Join(dir, "Main.java")
+
+	tr := &Translator{Py: fakePythonEvaluator{eval: upperEval}}
+	if err := tr.TranslateFile(t.Context(), in, out); err != nil {
+		t.Fatalf("TranslateFile: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	want := "// shared header: <tag>\nclass Main {}\n"
+	if string(got) != want {
+		t.Errorf("output:\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestTranslateFile_IncludeCycleReportsChain(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	a := filepath.Join(dir, "A.japaya")
+	b := filepath.Join(dir, "B.japaya")
+	writeFile(t, a, "```!include B.japaya```\n")
+	writeFile(t, b, "// via b\n```!include A.japaya```\n")
+
+	tr := &Translator{Py: fakePythonEvaluator{eval: upperEval}}
+	err := tr.TranslateFile(t.Context(), a, filepath.Join(dir, "A.java"))
+	if err == nil {
+		t.Fatal("TranslateFile succeeded, want an include cycle error")
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "include cycle") {
+		t.Fatalf("error = %q, want an include cycle report", msg)
+	}
+	// The chain names both hops with their directive positions.
+	if !strings.Contains(msg, "A.japaya:1 includes") || !strings.Contains(msg, "B.japaya:2 includes") {
+		t.Errorf("error %q should list the full chain with positions", msg)
+	}
+}
+
+func TestTranslateFile_IncludeDepthLimit(t *testing.T) {
+	t.Parallel()
+
+	// L1 includes L2 includes L3 includes L4: one hop too many for a limit of 2.
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "L1.japaya"), "```!include L2.japaya```\n")
+	writeFile(t, filepath.Join(dir, "L2.japaya"), "```!include L3.japaya```\n")
+	writeFile(t, filepath.Join(dir, "L3.japaya"), "```!include L4.japaya```\n")
+	writeFile(t, filepath.Join(dir, "L4.japaya"), "deep\n")
+
+	tr := &Translator{Py: fakePythonEvaluator{eval: upperEval}, MaxIncludeDepth: 2}
+	err := tr.TranslateFile(t.Context(), filepath.Join(dir, "L1.japaya"), filepath.Join(dir, "L1.java"))
+	if err == nil || !strings.Contains(err.Error(), "nested more than 2 deep") {
+		t.Fatalf("err = %v, want the depth limit to trip", err)
+	}
+
+	// The same chain passes once the limit accommodates it.
+	tr = &Translator{Py: fakePythonEvaluator{eval: upperEval}, MaxIncludeDepth: 3}
+	if err := tr.TranslateFile(t.Context(), filepath.Join(dir, "L1.japaya"), filepath.Join(dir, "L1.java")); err != nil {
+		t.Fatalf("TranslateFile with a higher limit: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Range is a half-open [Start, End) span of byte offsets into a
+// TranslationUnit's data. A pure insertion has Start == End.
+type Range struct {
+	Start int
+	End   int
+}
+
+// Update applies an edit that replaces the bytes in edit with newText,
+// re-tokenizing as little as possible.
+//
+// The common keystroke case — an edit that introduces no backticks and stays
+// inside one region's content — is patched in place: only the touched region
+// is re-sliced, and later regions just have their positions shifted. Any
+// edit that could move a delimiter falls back to a full re-parse.
+func (u *TranslationUnit) Update(edit Range, newText []byte) error {
+	if u == nil {
+		return fmt.Errorf("nil TranslationUnit")
+	}
+	if edit.Start < 0 || edit.End < edit.Start || edit.End > len(u.Data) {
+		return fmt.Errorf("edit range [%d, %d) out of bounds for %d bytes", edit.Start, edit.End, len(u.Data))
+	}
+
+	removed := u.Data[edit.Start:edit.End]
+
+	newData := make([]byte, 0, len(u.Data)+len(newText)-len(removed))
+	newData = append(newData, u.Data[:edit.Start]...)
+	newData = append(newData, newText...)
+	newData = append(newData, u.Data[edit.End:]...)
+
+	// Backticks entering or leaving the file can re-shape every region after
+	// the edit, so only delimiter-free edits take the incremental path.
+	if !bytes.ContainsRune(removed, '`') && !bytes.ContainsRune(newText, '`') {
+		if u.updateInPlace(edit, newText, newData) {
+			return nil
+		}
+	}
+
+	reparsed, err := ParseBytes(newData)
+	if err != nil {
+		return err
+	}
+	*u = *reparsed
+	return nil
+}
+
+// updateInPlace tries the incremental path, returning false if the edit is
+// not fully contained in a single region's content.
+func (u *TranslationUnit) updateInPlace(edit Range, newText []byte, newData []byte) bool {
+	edited := -1
+	for i := range u.Regions {
+		if edit.Start >= u.Regions[i].StartOff && edit.End <= u.Regions[i].EndOff {
+			edited = i
+			break
+		}
+	}
+	if edited < 0 {
+		// The edit touches a delimiter, an empty region, or the gap between
+		// regions; let the caller re-parse.
+		return false
+	}
+
+	r := &u.Regions[edited]
+	delta := len(newText) - (edit.End - edit.Start)
+
+	// Old and new positions of the edit's end, scanned from the edited
+	// region's start so only this region's bytes are re-walked.
+	oldEnd := scanPosition(u.Data, r.Start, r.StartOff, edit.End)
+	editStart := scanPosition(u.Data, r.Start, r.StartOff, edit.Start)
+	newEnd := scanPosition(newData, editStart, edit.Start, edit.Start+len(newText))
+
+	// shift maps a pre-edit position at or after the edit's end to its
+	// post-edit location.
+	shift := func(p Position) Position {
+		if p.Line == oldEnd.Line {
+			return Position{Line: newEnd.Line, Column: newEnd.Column + (p.Column - oldEnd.Column)}
+		}
+		return Position{Line: p.Line + newEnd.Line - oldEnd.Line, Column: p.Column}
+	}
+
+	r.EndOff += delta
+	r.RawEndOff += delta
+	r.End = shift(r.End)
+	r.RawEnd = shift(r.RawEnd)
+	r.Data = append([]byte(nil), newData[r.StartOff:r.EndOff]...)
+	r.Raw = append([]byte(nil), newData[r.RawStartOff:r.RawEndOff]...)
+
+	// Multi-line statements get their continuation lines joined by the
+	// parser; a plain re-slice would miss that.
+	if r.Type == RegionTypePythonStatement && bytes.ContainsRune(r.Data, '\n') {
+		return false
+	}
+
+	// Likewise CRLF content: the parser normalizes snippet terminators.
+	if r.Type != RegionTypeJava && bytes.ContainsRune(r.Data, '\r') {
+		return false
+	}
+
+	// A delimiter-free edit can still change things a full parse would see
+	// differently: a region emptied out entirely (ParseBytes drops those),
+	// or a statement gaining/losing its `java!`/`sh!` prefix. Bail out and
+	// let the caller re-parse; the mutations above are overwritten anyway.
+	if len(r.Data) == 0 {
+		return false
+	}
+	switch r.Type {
+	case RegionTypePythonStatement, RegionTypeJShellStatement, RegionTypeShellStatement:
+		content := r.Raw[1 : len(r.Raw)-1]
+		wantType := RegionTypePythonStatement
+		skip := 0
+		if bytes.HasPrefix(content, []byte(jshellPrefix)) {
+			wantType, skip = RegionTypeJShellStatement, len(jshellPrefix)
+		} else if bytes.HasPrefix(content, []byte(shellPrefix)) {
+			wantType, skip = RegionTypeShellStatement, len(shellPrefix)
+		}
+		if r.Type != wantType || r.StartOff != r.RawStartOff+1+skip {
+			return false
+		}
+	}
+
+	for i := edited + 1; i < len(u.Regions); i++ {
+		next := &u.Regions[i]
+		next.StartOff += delta
+		next.EndOff += delta
+		next.RawStartOff += delta
+		next.RawEndOff += delta
+		next.Start = shift(next.Start)
+		next.End = shift(next.End)
+		next.RawStart = shift(next.RawStart)
+		next.RawEnd = shift(next.RawEnd)
+	}
+
+	u.Data = newData
+	return true
+}
+
+// scanPosition walks data from a known (position, offset) pair to target,
+// returning the position there. target must be >= fromOff.
+func scanPosition(data []byte, from Position, fromOff, target int) Position {
+	for i := fromOff; i < target; i++ {
+		if data[i] == '\n' {
+			from.Line++
+			from.Column = 0
+		} else if data[i] != '\r' {
+			// \r\n is one terminator; \r never counts as a column (see the
+			// parser's advance1).
+			from.Column++
+		}
+	}
+	return from
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// applyEdit re-parses from scratch for comparison against Update.
+func reparsed(t *testing.T, data string) *TranslationUnit {
+	t.Helper()
+	unit, err := ParseBytes([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseBytes(%q): %v", data, err)
+	}
+	return unit
+}
+
+func TestUpdate_MatchesFullReparse(t *testing.T) {
+	t.Parallel()
+
+	const base = "class A {\n  int x = `expr`;\n}\n```\nprint('y')\n```\ntail\n"
+
+	cases := []struct {
+		name    string
+		edit    Range
+		newText string
+	}{
+		{"TypeInJava", Range{Start: 7, End: 7}, "B"},
+		{"DeleteInJava", Range{Start: 0, End: 5}, ""},
+		{"TypeInStatement", Range{Start: strings.Index(base, "expr"), End: strings.Index(base, "expr") + 4}, "other"},
+		{"TypeInBlock", Range{Start: strings.Index(base, "'y'"), End: strings.Index(base, "'y'") + 3}, "'longer'"},
+		{"InsertNewlineInBlock", Range{Start: strings.Index(base, "print"), End: strings.Index(base, "print")}, "pass\n"},
+		{"InsertBacktick", Range{Start: len(base), End: len(base)}, "`z`"},
+		{"DeleteAcrossRegions", Range{Start: strings.Index(base, "`expr`"), End: strings.Index(base, "tail")}, ""},
+		{"CreateJShellPrefix", Range{Start: strings.Index(base, "expr"), End: strings.Index(base, "expr")}, "java!"},
+		{"EmptyOutStatement", Range{Start: strings.Index(base, "expr"), End: strings.Index(base, "expr") + 4}, ""},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			unit := reparsed(t, base)
+			if err := unit.Update(tc.edit, []byte(tc.newText)); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+
+			edited := base[:tc.edit.Start] + tc.newText + base[tc.edit.End:]
+			want := reparsed(t, edited)
+			if !reflect.DeepEqual(unit, want) {
+				t.Errorf("Update diverged from full parse.\n got: %+v\nwant: %+v", unit, want)
+			}
+		})
+	}
+}
+
+func TestUpdate_SequentialEdits(t *testing.T) {
+	t.Parallel()
+
+	data := "a `one` b\n"
+	unit := reparsed(t, data)
+
+	// Simulate keystrokes: grow the snippet one byte at a time.
+	at := strings.Index(data, "one") + 3
+	for i, ch := range []string{"x", "y", "z"} {
+		if err := unit.Update(Range{Start: at + i, End: at + i}, []byte(ch)); err != nil {
+			t.Fatalf("Update %d: %v", i, err)
+		}
+	}
+
+	want := reparsed(t, "a `onexyz` b\n")
+	if !reflect.DeepEqual(unit, want) {
+		t.Errorf("sequential updates diverged.\n got: %+v\nwant: %+v", unit, want)
+	}
+}
+
+func TestUpdate_RejectsOutOfBounds(t *testing.T) {
+	t.Parallel()
+
+	unit := reparsed(t, "abc")
+	if err := unit.Update(Range{Start: 2, End: 5}, nil); err == nil {
+		t.Fatalf("expected out-of-bounds error")
+	}
+	if err := unit.Update(Range{Start: -1, End: 0}, nil); err == nil {
+		t.Fatalf("expected out-of-bounds error")
+	}
+}
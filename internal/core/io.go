@@ -1,29 +1,76 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
+// TranslatePath translates either a single file or a whole directory tree
+// using a bare Translator.
+func TranslatePath(ctx context.Context, inPath, outPath string, py PythonEvaluator) error {
+	return (&Translator{Py: py}).TranslatePath(ctx, inPath, outPath)
+}
+
 // TranslatePath translates either a single file or a whole directory tree.
 //
 // If inPath is a file: outPath must be a file path.
 // If inPath is a dir : outPath must be a dir path (will be created).
-func TranslatePath(ctx context.Context, inPath, outPath string, py PythonEvaluator) error {
-	if py == nil {
+func (t *Translator) TranslatePath(ctx context.Context, inPath, outPath string) error {
+	if t.Py == nil {
 		return fmt.Errorf("nil PythonEvaluator")
 	}
+
+	// The whole run shares one deadline; per-file deadlines are layered on in
+	// TranslateFile.
+	if t.TotalTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.TotalTimeout)
+		defer cancel()
+	}
+
 	inInfo, err := os.Stat(inPath)
 	if err != nil {
 		return fmt.Errorf("stat input %q: %w", inPath, err)
 	}
 
+	// An archive input is treated like a tree rooted at the archive.
+	if !inInfo.IsDir() && isArchivePath(inPath) {
+		return t.TranslateArchive(ctx, inPath, outPath)
+	}
+
 	if inInfo.IsDir() {
+		// A tar: output streams the tree as a single archive — to stdout
+		// for "tar:-", otherwise to the named tar file.
+		if dest, ok := tarOutDest(outPath); ok {
+			return t.translateTreeToTarDest(ctx, inPath, dest)
+		}
+
+		// An archive output writes the whole tree into a zip/jar instead of
+		// a directory.
+		if isArchivePath(outPath) {
+			return t.TranslateTreeToArchive(ctx, inPath, outPath)
+		}
+
+		if t.Verify {
+			// Verify mode writes nothing, so the output tree must already exist.
+			outInfo, err := os.Stat(outPath)
+			if err != nil {
+				return fmt.Errorf("stat output %q: %w", outPath, err)
+			}
+			if !outInfo.IsDir() {
+				return fmt.Errorf("input is a directory, but output %q is not a directory", outPath)
+			}
+			return t.TranslateTree(ctx, inPath, outPath)
+		}
+
 		// Ensure outPath is a directory (create if needed).
 		if err := os.MkdirAll(outPath, 0o755); err != nil {
 			return fmt.Errorf("mkdir output dir %q: %w", outPath, err)
@@ -35,21 +82,29 @@ func TranslatePath(ctx context.Context, inPath, outPath string, py PythonEvaluat
 		if !outInfo.IsDir() {
 			return fmt.Errorf("input is a directory, but output %q is not a directory", outPath)
 		}
-		return TranslateTree(ctx, inPath, outPath, py)
+		return t.TranslateTree(ctx, inPath, outPath)
 	}
 
 	// Input is a file; output must be a file (or a non-existing path).
 	// If output exists and is a directory, that's an error.
+	if _, ok := tarOutDest(outPath); ok {
+		return fmt.Errorf("tar output %q requires a directory input", outPath)
+	}
 	if outInfo, err := os.Stat(outPath); err == nil && outInfo.IsDir() {
 		return fmt.Errorf("input is a file, but output %q is a directory", outPath)
 	}
 
-	return TranslateFile(ctx, inPath, outPath, py)
+	return t.TranslateFile(ctx, inPath, outPath)
+}
+
+// TranslateTree walks a tree using a bare Translator.
+func TranslateTree(ctx context.Context, inRoot, outRoot string, py PythonEvaluator) error {
+	return (&Translator{Py: py}).TranslateTree(ctx, inRoot, outRoot)
 }
 
 // TranslateTree walks inRoot recursively and writes translated output into outRoot
 // preserving relative paths.
-func TranslateTree(ctx context.Context, inRoot, outRoot string, py PythonEvaluator) error {
+func (t *Translator) TranslateTree(ctx context.Context, inRoot, outRoot string) error {
 	inRoot = filepath.Clean(inRoot)
 	outRoot = filepath.Clean(outRoot)
 
@@ -62,6 +117,149 @@ func TranslateTree(ctx context.Context, inRoot, outRoot string, py PythonEvaluat
 		}
 	}
 
+	// Outputs are staged and only moved into place once the whole tree has
+	// translated, so a mid-run python error never leaves outRoot half-updated.
+	// (Verify mode writes nothing, so it runs against outRoot directly.)
+	writeRoot := outRoot
+	if !t.Verify {
+		if err := os.MkdirAll(outRoot, 0o755); err != nil {
+			return fmt.Errorf("mkdir output dir %q: %w", outRoot, err)
+		}
+		// Hold an advisory lock on the output root so a concurrent
+		// invocation can't interleave its renames with ours.
+		release, err := acquireOutputLock(outRoot)
+		if err != nil {
+			return err
+		}
+		defer release()
+		stage, err := os.MkdirTemp(outRoot, ".japaya-stage-*")
+		if err != nil {
+			return fmt.Errorf("create staging dir in %q: %w", outRoot, err)
+		}
+		defer os.RemoveAll(stage)
+		writeRoot = stage
+	}
+
+	// With -warn-stale, remember where every output lands so leftovers in
+	// outRoot can be listed afterwards.
+	var produced map[string]bool
+	if t.WarnStale && t.Warnings != nil {
+		produced = make(map[string]bool)
+	}
+
+	var verifyErr VerifyError
+	var treeErr TreeError
+	err = t.walkTranslatableFiles(inRoot, func(path, relPath string) error {
+		outPath := filepath.Join(writeRoot, relPath)
+
+		if !t.Verify {
+			// Ensure parent dirs exist (TranslateFile will do this if you used atomicWriteFile with MkdirAll,
+			// but it doesn't hurt to keep this invariant here if you don't).
+			if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+				return err
+			}
+		}
+
+		finalPath, err := t.translateFileTo(ctx, path, outPath, writeRoot)
+		if err != nil {
+			// In verify mode, keep walking so one stale file doesn't hide the
+			// rest; mismatches are merged below.
+			var ve *VerifyError
+			if errors.As(err, &ve) {
+				verifyErr.Mismatches = append(verifyErr.Mismatches, ve.Mismatches...)
+				return nil
+			}
+			if t.Summary != nil {
+				t.Summary.addFailed()
+			}
+			// Collect and keep walking so one bad template doesn't hide
+			// failures in the rest of the tree. A dead context still aborts:
+			// every remaining file would just fail the same way.
+			if ctx.Err() != nil {
+				return err
+			}
+			treeErr.add(path, err)
+			return nil
+		}
+		// A japaya.set_output override may have moved the file, including
+		// skipping it entirely (empty finalPath); keep the bookkeeping keyed
+		// by where the output actually lands.
+		if finalPath == "" {
+			return nil
+		}
+		if finalRel, relErr := filepath.Rel(writeRoot, finalPath); relErr == nil {
+			relPath = finalRel
+		}
+		if produced != nil {
+			produced[filepath.Join(outRoot, relPath)] = true
+		}
+		if t.Summary != nil && !t.Verify {
+			t.Summary.classifyOutput(path, finalPath, filepath.Join(outRoot, relPath))
+		}
+		if t.SourceMaps != nil && writeRoot != outRoot {
+			// The map was recorded under the staging path; key it by where
+			// the file will actually land.
+			t.SourceMaps.rename(finalPath, filepath.Join(outRoot, relPath))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(treeErr.Files) > 0 {
+		// Report failures by input path, never by completion order. A lone
+		// failure keeps its original error so errors.Is/As callers see it
+		// directly.
+		treeErr.sort()
+		if len(treeErr.Files) == 1 {
+			return treeErr.Files[0].Err
+		}
+		return &treeErr
+	}
+	if len(verifyErr.Mismatches) > 0 {
+		return &verifyErr
+	}
+	if !t.Verify {
+		if err := commitStagedTree(writeRoot, outRoot); err != nil {
+			return err
+		}
+	}
+	if produced != nil {
+		t.warnStaleOutputs(outRoot, produced)
+	}
+	return nil
+}
+
+// commitStagedTree moves every staged file into its final location. Renames
+// are atomic per file; staging lives inside the output root so they never
+// cross filesystems.
+func commitStagedTree(stage, outRoot string) error {
+	return filepath.WalkDir(stage, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(stage, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(outRoot, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := os.Rename(path, target); err != nil {
+			return fmt.Errorf("commit output %q: %w", target, err)
+		}
+		return nil
+	})
+}
+
+// walkTranslatableFiles walks inRoot, skipping junk dirs and non-translatable
+// files, and calls fn with each input path and its (extension-rewritten)
+// output-relative path.
+func (t *Translator) walkTranslatableFiles(inRoot string, fn func(path, relPath string) error) error {
 	return filepath.WalkDir(inRoot, func(path string, d fs.DirEntry, walkErr error) error {
 		if walkErr != nil {
 			return walkErr
@@ -85,7 +283,10 @@ func TranslateTree(ctx context.Context, inRoot, outRoot string, py PythonEvaluat
 		}
 
 		// Filter which files get translated.
-		if !shouldTranslatePath(path) {
+		if !t.language().translates(path) {
+			if t.Summary != nil {
+				t.Summary.addSkipped()
+			}
 			return nil
 		}
 
@@ -93,84 +294,242 @@ func TranslateTree(ctx context.Context, inRoot, outRoot string, py PythonEvaluat
 		if err != nil {
 			return err
 		}
-		relPath = outputRelPath(relPath)
+		return fn(path, t.language().outputRelPath(relPath))
+	})
+}
 
-		outPath := filepath.Join(outRoot, relPath)
+// ShouldTranslatePath reports whether tree mode would translate this file
+// under the configured language.
+func (t *Translator) ShouldTranslatePath(path string) bool {
+	return t.language().translates(path)
+}
 
-		// Ensure parent dirs exist (TranslateFile will do this if you used atomicWriteFile with MkdirAll,
-		// but it doesn't hurt to keep this invariant here if you don't).
-		if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
-			return err
+// OutputRelPath maps an input-relative path to its output-relative path
+// (rewriting template extensions, e.g. .japaya to .java). Exposed for tools
+// that mirror tree mode's layout, like watch mode.
+func (t *Translator) OutputRelPath(relPath string) string {
+	return t.language().outputRelPath(relPath)
+}
+
+// TranslateFile translates a single file using a bare Translator.
+func TranslateFile(ctx context.Context, inPath string, outPath string, py PythonEvaluator) error {
+	return (&Translator{Py: py}).TranslateFile(ctx, inPath, outPath)
+}
+
+// TranslateFile reads inPath, parses + translates it, and atomically writes
+// to outPath. A japaya.set_output override is resolved against outPath's
+// directory.
+func (t *Translator) TranslateFile(ctx context.Context, inPath string, outPath string) error {
+	_, err := t.translateFileTo(ctx, inPath, outPath, filepath.Dir(outPath))
+	return err
+}
+
+// translateFileTo is TranslateFile with the root a japaya.set_output
+// override resolves against made explicit — tree mode passes its output
+// root, so overrides are root-relative regardless of where the input sat.
+// Returns the path the output actually landed at.
+func (t *Translator) translateFileTo(ctx context.Context, inPath, outPath, outBase string) (string, error) {
+	if t.Py == nil {
+		return "", fmt.Errorf("nil PythonEvaluator")
+	}
+	if inPath == "" {
+		return "", fmt.Errorf("empty input path")
+	}
+	if outPath == "" {
+		return "", fmt.Errorf("empty output path")
+	}
+
+	t.emit(Event{Type: "file_started", Path: inPath})
+	started := time.Now()
+	endSpan := t.fileSpan(inPath)
+	defer endSpan()
+
+	// Snippets in this file see its directory as their cwd, so relative
+	// paths like open("schema.json") resolve next to the template.
+	if ss, ok := t.Py.(SnippetScoper); ok {
+		ss.SetSnippetScope(filepath.Dir(inPath), t.snippetEnvFor(inPath))
+	}
+
+	fileCtx, cancel := t.fileContext(ctx)
+	defer cancel()
+
+	outBytes, sm, err := t.translateFileBytes(fileCtx, inPath)
+	if err != nil {
+		var skip *FileSkippedError
+		if errors.As(err, &skip) {
+			return "", t.skipFile(inPath, skip.Reason)
 		}
+		return "", err
+	}
 
-		if err := TranslateFile(ctx, path, outPath, py); err != nil {
-			return err
+	// A snippet may redirect the file's output (japaya.set_output), so
+	// templates can compute their package/location from data rather than
+	// directory layout.
+	if opr, ok := t.Py.(OutputPathReporter); ok {
+		if override := opr.TakeOutputPath(); override != "" {
+			target, err := resolveOutputPath(outBase, override)
+			if err != nil {
+				return "", fmt.Errorf("output override from %q: %w", inPath, err)
+			}
+			outPath = target
 		}
-		return nil
-	})
-}
+	}
+
+	imports := t.takeImports(inPath)
+	if err := t.writeResources(inPath); err != nil {
+		return "", err
+	}
+	if t.SourceMaps != nil && sm != nil {
+		t.SourceMaps.record(outPath, sm)
+	}
 
-func shouldTranslatePath(path string) bool {
-	ext := strings.ToLower(filepath.Ext(path))
-	switch ext {
-	case ".java", ".japaya": // add/remove as needed
-		return true
-	default:
-		return false
+	if t.Verify {
+		existing, err := os.ReadFile(outPath)
+		if err != nil || !bytes.Equal(existing, outBytes) {
+			return "", &VerifyError{Mismatches: []VerifyMismatch{{OutPath: outPath, Got: outBytes}}}
+		}
+	} else if err := atomicWriteFile(outPath, outBytes, 0o644); err != nil {
+		return "", fmt.Errorf("write output %q: %w", outPath, err)
 	}
+
+	t.emit(Event{
+		Type:       "file_done",
+		Path:       inPath,
+		OutPath:    outPath,
+		DurationMS: float64(time.Since(started)) / float64(time.Millisecond),
+		Imports:    imports,
+	})
+	return outPath, nil
 }
 
-func outputRelPath(relPath string) string {
-	ext := strings.ToLower(filepath.Ext(relPath))
-	if ext == ".japaya" {
-		return strings.TrimSuffix(relPath, filepath.Ext(relPath)) + ".java"
+// resolveOutputPath joins a snippet-supplied output override onto the output
+// root, rejecting absolute paths and any that climb out of it.
+func resolveOutputPath(root, path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("output path %q is absolute", path)
+	}
+	clean := filepath.Clean(filepath.FromSlash(path))
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("output path %q escapes the output root", path)
 	}
-	return relPath
+	return filepath.Join(root, clean), nil
 }
 
-// TranslateFile reads inPath, parses + translates it, and atomically writes to outPath.
-func TranslateFile(ctx context.Context, inPath string, outPath string, py PythonEvaluator) error {
-	if py == nil {
-		return fmt.Errorf("nil PythonEvaluator")
+// skipFile records a japaya.skip outcome: nothing is written, the manifest
+// and summary remember the skip, and the per-file reporters are drained so
+// half-collected state can't leak into the next file.
+func (t *Translator) skipFile(inPath, reason string) error {
+	imports := t.takeImports(inPath)
+	if rr, ok := t.Py.(ResourceReporter); ok {
+		rr.TakeResources()
 	}
-	if inPath == "" {
-		return fmt.Errorf("empty input path")
+	if sr, ok := t.Py.(SlotReporter); ok {
+		sr.TakeSlots()
 	}
-	if outPath == "" {
-		return fmt.Errorf("empty output path")
+	if ir, ok := t.Py.(RequiredImportReporter); ok {
+		ir.TakeRequiredImports()
+	}
+	if opr, ok := t.Py.(OutputPathReporter); ok {
+		opr.TakeOutputPath()
 	}
+	if t.Manifest != nil {
+		t.Manifest.recordSkip(inPath, reason)
+	}
+	if t.Summary != nil {
+		t.Summary.addSkipped()
+	}
+	t.emit(Event{Type: "file_skipped", Path: inPath, Message: reason, Imports: imports})
+	return nil
+}
 
-	in, err := os.Open(inPath)
-	if err != nil {
-		return fmt.Errorf("open input %q: %w", inPath, err)
+// translateFileBytes reads and translates inPath, returning the output bytes
+// without writing anywhere. With CheckDeterminism set the translation runs
+// twice and differing outputs fail the file.
+func (t *Translator) translateFileBytes(ctx context.Context, inPath string) ([]byte, *SourceMap, error) {
+	run := func() ([]byte, *SourceMap, error) {
+		// Unsaved editor buffers shadow the on-disk file.
+		if t.Overlay != nil {
+			if contents, ok := t.Overlay.Get(inPath); ok {
+				return t.translateReaderMapped(ctx, bytes.NewReader(contents), inPath)
+			}
+		}
+
+		in, err := os.Open(inPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open input %q: %w", inPath, err)
+		}
+		defer in.Close()
+		return t.translateReaderMapped(ctx, in, inPath)
 	}
-	defer in.Close()
 
-	outBytes, err := TranslateReader(ctx, in, py) // see below
+	outBytes, sm, err := run()
 	if err != nil {
-		return fmt.Errorf("translate %q: %w", inPath, err)
+		return nil, nil, fmt.Errorf("translate %q: %w", inPath, err)
+	}
+	if t.CheckDeterminism {
+		again, _, err := run()
+		if err != nil {
+			return nil, nil, fmt.Errorf("translate %q: %w", inPath, err)
+		}
+		if !bytes.Equal(outBytes, again) {
+			return nil, nil, fmt.Errorf("translate %q: output differs between two evaluations (first difference at line %d); the template depends on time, randomness, or ordering",
+				inPath, firstDiffLine(outBytes, again))
+		}
 	}
+	return outBytes, sm, nil
+}
 
-	if err := atomicWriteFile(outPath, outBytes, 0o644); err != nil {
-		return fmt.Errorf("write output %q: %w", outPath, err)
+// firstDiffLine returns the 1-based line number where two outputs first
+// diverge.
+func firstDiffLine(a, b []byte) int {
+	line := 1
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
 	}
-	return nil
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return line
+		}
+		if a[i] == '\n' {
+			line++
+		}
+	}
+	return line
 }
 
-// TranslateReader is the “pipeline” entry point: parse + TranslateUnit.
+// TranslateReader translates from a reader using a bare Translator.
 func TranslateReader(ctx context.Context, r io.Reader, py PythonEvaluator) ([]byte, error) {
+	return (&Translator{Py: py}).TranslateReader(ctx, r)
+}
+
+// TranslateReader is the “pipeline” entry point: parse + TranslateUnit.
+func (t *Translator) TranslateReader(ctx context.Context, r io.Reader) ([]byte, error) {
+	return t.translateReader(ctx, r, "")
+}
+
+// translateReader is TranslateReader with the input path threaded through for
+// event reporting.
+func (t *Translator) translateReader(ctx context.Context, r io.Reader, path string) ([]byte, error) {
+	out, _, err := t.translateReaderMapped(ctx, r, path)
+	return out, err
+}
+
+// translateReaderMapped is translateReader plus the source map (nil unless a
+// SourceMaps collector is configured).
+func (t *Translator) translateReaderMapped(ctx context.Context, r io.Reader, path string) ([]byte, *SourceMap, error) {
 	if r == nil {
-		return nil, fmt.Errorf("nil reader")
+		return nil, nil, fmt.Errorf("nil reader")
 	}
-	if py == nil {
-		return nil, fmt.Errorf("nil PythonEvaluator")
+	if t.Py == nil {
+		return nil, nil, fmt.Errorf("nil PythonEvaluator")
 	}
 
-	unit, err := ParseReader(r)
+	unit, err := ParseReaderWithOptions(r, t.ParseOptions)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return TranslateUnit(ctx, unit, py)
+	return t.translateUnitMapped(ctx, unit, path)
 }
 
 // atomicWriteFile writes data to a temp file in the destination directory, then renames it.
@@ -2,9 +2,12 @@ package core
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 // Creates a regular file and parents.
@@ -179,3 +182,76 @@ func TestTranslatePath_DirectoryButOutputIsFile_Errors(t *testing.T) {
 		t.Fatalf("expected error when input is dir but output is file")
 	}
 }
+
+func TestTranslateTree_ErrorLeavesOutputUntouched(t *testing.T) {
+	t.Parallel()
+
+	inRoot := t.TempDir()
+	outRoot := t.TempDir()
+
+	writeFile(t, filepath.Join(inRoot, "A.java"), "ok `1` here\n")
+	writeFile(t, filepath.Join(inRoot, "B.java"), "bad `boom` here\n")
+
+	// Pre-existing output that a failed run must not disturb.
+	writeFile(t, filepath.Join(outRoot, "A.java"), "previous contents\n")
+
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+			if string(code) == "boom" {
+				return nil, errors.New("kaboom")
+			}
+			return []byte("1"), nil
+		},
+	}
+
+	err := TranslateTree(context.Background(), inRoot, outRoot, py)
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	// The old output survives and no staged leftovers remain.
+	got, readErr := os.ReadFile(filepath.Join(outRoot, "A.java"))
+	if readErr != nil {
+		t.Fatalf("read output: %v", readErr)
+	}
+	if string(got) != "previous contents\n" {
+		t.Fatalf("failed run must not modify outputs; got %q", string(got))
+	}
+
+	entries, readErr := os.ReadDir(outRoot)
+	if readErr != nil {
+		t.Fatalf("read output dir: %v", readErr)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".japaya-stage-") {
+			t.Fatalf("staging dir left behind: %s", e.Name())
+		}
+	}
+}
+
+func TestTranslateFile_FileTimeout_SurfacesRegion(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "Main.java")
+	writeFile(t, in, "class Main { `\"x\"` }\n")
+
+	// An evaluator that hangs until its context is cancelled.
+	py := fakePythonEvaluator{eval: func(ctx context.Context, _ RegionType, _ []byte) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+
+	tr := &Translator{Py: py, FileTimeout: 10 * time.Millisecond}
+	err := tr.TranslateFile(context.Background(), in, filepath.Join(dir, "out", "Main.java"))
+	if err == nil {
+		t.Fatalf("expected timeout error")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got: %v", err)
+	}
+	var te *TranslationError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected TranslationError pointing at the region, got: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"strings"
+)
+
+// Marks a python block as a Javadoc generator:
+//
+//	```!javadoc
+//	print(describe_endpoint(route))
+//	```
+//
+// The rest of the block runs as an ordinary python block, and its output is
+// spliced back as a line-wrapped /** ... */ comment indented to the column
+// where the block starts, so templates can generate documented APIs without
+// hand-rolling `*` prefixes.
+const javadocPrefix = "!javadoc"
+
+// Total line width Javadoc output is wrapped to, indentation included.
+const javadocWidth = 80
+
+// isJavadocBlock reports whether a python block region carries a !javadoc
+// directive.
+func isJavadocBlock(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte(javadocPrefix))
+}
+
+// expandJavadoc evaluates a !javadoc block and wraps its output as a Javadoc
+// comment at the region's indentation.
+func (t *Translator) expandJavadoc(ctx context.Context, r Region) ([]byte, error) {
+	trimmed := bytes.TrimLeft(r.Data, " \t\r\n")
+	_, body, _ := bytes.Cut(trimmed, []byte("\n"))
+
+	text, err := t.Py.Eval(ctx, RegionTypePythonBlock, body)
+	if err != nil {
+		return nil, err
+	}
+
+	indent := strings.Repeat(" ", int(r.RawStart.Column))
+	return []byte(formatJavadoc(string(text), indent)), nil
+}
+
+// formatJavadoc renders text as a Javadoc comment. Paragraphs (separated by
+// blank lines) are re-wrapped to javadocWidth; lines that look preformatted
+// (indented, or list/tag markers like `-`, `*`, `@param`) keep their own
+// line breaks.
+func formatJavadoc(text, indent string) string {
+	// The splice point already sits at the region's indentation, so the
+	// first line carries no indent of its own and the last line no newline.
+	var b strings.Builder
+	b.WriteString("/**\n")
+
+	prefix := indent + " * "
+	width := javadocWidth - len(prefix)
+	if width < 20 {
+		width = 20
+	}
+
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	var paragraph []string
+
+	flush := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		for _, line := range wrapWords(strings.Join(paragraph, " "), width) {
+			b.WriteString(prefix)
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+		paragraph = nil
+	}
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+			b.WriteString(indent)
+			b.WriteString(" *\n")
+		case preformattedDocLine(line):
+			flush()
+			b.WriteString(prefix)
+			b.WriteString(strings.TrimRight(line, " \t"))
+			b.WriteString("\n")
+		default:
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+	flush()
+
+	b.WriteString(indent)
+	b.WriteString(" */")
+	return b.String()
+}
+
+// preformattedDocLine reports whether a line should keep its own line break
+// instead of being merged into the surrounding paragraph.
+func preformattedDocLine(line string) bool {
+	if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+		return true
+	}
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "- ") ||
+		strings.HasPrefix(trimmed, "* ") ||
+		strings.HasPrefix(trimmed, "@")
+}
+
+// wrapWords greedily wraps s into lines of at most width characters. Words
+// longer than width get a line of their own.
+func wrapWords(s string, width int) []string {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		return nil
+	}
+
+	var lines []string
+	current := words[0]
+	for _, word := range words[1:] {
+		if len(current)+1+len(word) <= width {
+			current += " " + word
+		} else {
+			lines = append(lines, current)
+			current = word
+		}
+	}
+	return append(lines, current)
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFormatJavadoc_WrapsAndIndents(t *testing.T) {
+	t.Parallel()
+
+	text := "Returns the widget count. " + strings.Repeat("Very long sentence here. ", 5) + "\n" +
+		"\n" +
+		"@param name the widget name\n" +
+		"- first item\n"
+
+	got := formatJavadoc(text, "    ")
+
+	lines := strings.Split(got, "\n")
+	if lines[0] != "/**" {
+		t.Errorf("first line = %q, want %q", lines[0], "/**")
+	}
+	if last := lines[len(lines)-1]; last != "     */" {
+		t.Errorf("last line = %q, want %q", last, "     */")
+	}
+	for _, line := range lines[1 : len(lines)-1] {
+		if !strings.HasPrefix(line, "     *") {
+			t.Errorf("body line %q missing ` * ` prefix", line)
+		}
+		if len(line) > javadocWidth {
+			t.Errorf("line exceeds %d columns: %q", javadocWidth, line)
+		}
+	}
+
+	// Tag and list lines keep their own line breaks.
+	if !strings.Contains(got, " * @param name the widget name\n") {
+		t.Errorf("expected @param line preserved, got:\n%s", got)
+	}
+	if !strings.Contains(got, " * - first item\n") {
+		t.Errorf("expected list item preserved, got:\n%s", got)
+	}
+}
+
+func TestTranslateUnit_JavadocBlock(t *testing.T) {
+	t.Parallel()
+
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, regionType RegionType, code []byte) ([]byte, error) {
+			if regionType != RegionTypePythonBlock {
+				t.Fatalf("unexpected region type %v", regionType)
+			}
+			return []byte("Counts widgets.\n"), nil
+		},
+	}
+
+	input := "class A {\n  ```!javadoc\n  print(doc())\n  ```\n  int count();\n}\n"
+	unit, err := ParseBytes([]byte(input))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	out, err := TranslateUnit(context.Background(), unit, py)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+
+	want := "class A {\n  /**\n   * Counts widgets.\n   */\n  int count();\n}\n"
+	if string(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Language describes the host language being templated. Tree mode uses it to
+// decide which files to translate and how template extensions rewrite to
+// output extensions; header injection uses its comment syntax. Configure one
+// on a Translator to template something other than Java; nil means Java.
+type Language struct {
+	// Name identifies the language in messages, e.g. "kotlin".
+	Name string
+
+	// TemplateExts are the template file extensions (lowercase, with the
+	// leading dot); matching files are translated and renamed to OutputExt.
+	TemplateExts []string
+
+	// OutputExt replaces a template extension on output paths, e.g. ".kt".
+	OutputExt string
+
+	// PassthroughExts are extensions translated in place without renaming,
+	// letting plain source files carry occasional snippets.
+	PassthroughExts []string
+
+	// LineComment, when non-empty, is the token prefixed to each line of an
+	// injected header (see Translator.Header), so one header template works
+	// across languages. Empty prepends the header verbatim.
+	LineComment string
+}
+
+// javaLanguage is the default when no Language is configured, preserving the
+// original .japaya → .java behavior. LineComment stays empty so existing
+// header files keep their own comment markers.
+var javaLanguage = Language{
+	Name:            "java",
+	TemplateExts:    []string{".japaya"},
+	OutputExt:       ".java",
+	PassthroughExts: []string{".java"},
+}
+
+// language returns the configured language, defaulting to Java.
+func (t *Translator) language() *Language {
+	if t.Language != nil {
+		return t.Language
+	}
+	return &javaLanguage
+}
+
+// translates reports whether tree mode should translate a file with this
+// path under the language's extension lists.
+func (l *Language) translates(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range l.TemplateExts {
+		if ext == e {
+			return true
+		}
+	}
+	for _, e := range l.PassthroughExts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// outputRelPath maps an input-relative path to its output-relative path,
+// rewriting template extensions to OutputExt.
+func (l *Language) outputRelPath(relPath string) string {
+	ext := strings.ToLower(filepath.Ext(relPath))
+	for _, e := range l.TemplateExts {
+		if ext == e {
+			return strings.TrimSuffix(relPath, filepath.Ext(relPath)) + l.OutputExt
+		}
+	}
+	return relPath
+}
+
+// commentOut wraps one header line in the language's line-comment syntax.
+// Blank lines become a bare comment token so the header stays one block.
+func (l *Language) commentOut(line string) string {
+	if l.LineComment == "" {
+		return line
+	}
+	if line == "" {
+		return l.LineComment
+	}
+	return l.LineComment + " " + line
+}
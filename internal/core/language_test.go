@@ -0,0 +1,114 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLanguage_DefaultJavaExtensions(t *testing.T) {
+	t.Parallel()
+
+	tr := &Translator{}
+	cases := []struct {
+		path      string
+		translate bool
+		outRel    string
+	}{
+		{"a/B.japaya", true, "a/B.java"},
+		{"a/B.java", true, "a/B.java"},
+		{"a/B.JAPAYA", true, "a/B.java"},
+		{"a/readme.md", false, "a/readme.md"},
+	}
+	for _, tc := range cases {
+		if got := tr.ShouldTranslatePath(tc.path); got != tc.translate {
+			t.Errorf("ShouldTranslatePath(%q) = %v, want %v", tc.path, got, tc.translate)
+		}
+		if got := tr.OutputRelPath(tc.path); got != tc.outRel {
+			t.Errorf("OutputRelPath(%q) = %q, want %q", tc.path, got, tc.outRel)
+		}
+	}
+}
+
+func TestLanguage_CustomExtensions(t *testing.T) {
+	t.Parallel()
+
+	tr := &Translator{
+		Language: &Language{
+			Name:            "kotlin",
+			TemplateExts:    []string{".japaya", ".ktt"},
+			OutputExt:       ".kt",
+			PassthroughExts: []string{".kt"},
+		},
+	}
+	cases := []struct {
+		path      string
+		translate bool
+		outRel    string
+	}{
+		{"m/A.ktt", true, "m/A.kt"},
+		{"m/A.japaya", true, "m/A.kt"},
+		{"m/A.kt", true, "m/A.kt"},
+		{"m/A.java", false, "m/A.java"},
+	}
+	for _, tc := range cases {
+		if got := tr.ShouldTranslatePath(tc.path); got != tc.translate {
+			t.Errorf("ShouldTranslatePath(%q) = %v, want %v", tc.path, got, tc.translate)
+		}
+		if got := tr.OutputRelPath(tc.path); got != tc.outRel {
+			t.Errorf("OutputRelPath(%q) = %q, want %q", tc.path, got, tc.outRel)
+		}
+	}
+}
+
+func TestLanguage_HeaderCommentWrapping(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("key: `v`\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	tr := &Translator{
+		Py:       fakePythonEvaluator{eval: upperEval},
+		Header:   "Copyright.\n\nDo not edit.",
+		Language: &Language{Name: "yaml", LineComment: "#"},
+	}
+	out, err := tr.translateUnit(t.Context(), unit, "cfg.yaml")
+	if err != nil {
+		t.Fatalf("translateUnit: %v", err)
+	}
+
+	want := "# Copyright.\n#\n# Do not edit.\nkey: <v>\n"
+	if string(out) != want {
+		t.Errorf("output:\n got: %q\nwant: %q", out, want)
+	}
+}
+
+func TestTranslateTree_CustomOutputExtension(t *testing.T) {
+	t.Parallel()
+
+	inRoot := t.TempDir()
+	outRoot := t.TempDir()
+	writeFile(t, filepath.Join(inRoot, "q.japaya"), "select `v`;\n")
+
+	tr := &Translator{
+		Py: fakePythonEvaluator{eval: upperEval},
+		Language: &Language{
+			Name:         "sql",
+			TemplateExts: []string{".japaya"},
+			OutputExt:    ".sql",
+		},
+	}
+	if err := tr.TranslateTree(t.Context(), inRoot, outRoot); err != nil {
+		t.Fatalf("TranslateTree: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(outRoot, "q.sql"))
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	if string(got) != "select <v>;\n" {
+		t.Errorf("output = %q, want %q", got, "select <v>;\n")
+	}
+}
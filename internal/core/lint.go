@@ -0,0 +1,167 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// lintIgnoreMarker suppresses lint findings for the region that follows. It
+// may appear anywhere in a comment on the region's start line or the line
+// before, optionally followed by rule IDs; bare, it suppresses every rule.
+const lintIgnoreMarker = "japaya:ignore"
+
+// hugeSnippetLines is the block size beyond which lint suggests moving the
+// code into a python-dir helper module.
+const hugeSnippetLines = 20
+
+// LintBytes statically checks a template for common mistakes without
+// evaluating any python, returning one Warning per finding. Rules:
+//
+//   - stmt-not-expression: a `...` statement region that holds statements
+//     (assignments, imports, multiple lines) where an expression is expected.
+//   - block-never-prints: a ``` block ``` that contains no print call, so it
+//     contributes nothing to the output.
+//   - backtick-in-string: a region whose code has unbalanced double quotes,
+//     the usual symptom of a backtick inside a Java string literal.
+//   - huge-snippet: a block long enough that it belongs in a python-dir
+//     helper module.
+func LintBytes(data []byte, path string) ([]Warning, error) {
+	unit, err := ParseBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	var warnings []Warning
+	warn := func(r Region, rule, message string) {
+		if lintSuppressed(lines, r, rule) {
+			return
+		}
+		warnings = append(warnings, Warning{
+			Path:    path,
+			Start:   r.Start,
+			Rule:    rule,
+			Message: message,
+		})
+	}
+
+	for _, r := range unit.Regions {
+		switch r.Type {
+		case RegionTypePythonStatement:
+			if reason := statementLikeReason(r.Data); reason != "" {
+				warn(r, "stmt-not-expression",
+					"statement region holds "+reason+"; use a block or an expression")
+			}
+			if bytes.Count(r.Data, []byte(`"`))%2 == 1 {
+				warn(r, "backtick-in-string",
+					"region code has unbalanced double quotes; is the opening backtick inside a Java string?")
+			}
+		case RegionTypePythonBlock:
+			if isForeachBlock(r.Data) || isJavadocBlock(r.Data) {
+				continue
+			}
+			if !bytes.Contains(r.Data, []byte("print")) {
+				warn(r, "block-never-prints",
+					"block region never prints, so it produces no output")
+			}
+			if n := bytes.Count(bytes.TrimSpace(r.Data), []byte("\n")) + 1; n > hugeSnippetLines {
+				warn(r, "huge-snippet",
+					fmt.Sprintf("%d-line snippet; consider moving it into a python-dir module", n))
+			}
+		}
+	}
+	return warnings, nil
+}
+
+// lintSuppressed reports whether a japaya:ignore comment on the region's
+// start line or the line above covers the given rule.
+func lintSuppressed(lines []string, r Region, rule string) bool {
+	check := func(i uint) bool {
+		if i >= uint(len(lines)) {
+			return false
+		}
+		line := lines[i]
+		at := strings.Index(line, lintIgnoreMarker)
+		if at < 0 {
+			return false
+		}
+		rest := strings.Fields(line[at+len(lintIgnoreMarker):])
+		if len(rest) == 0 {
+			return true // bare marker suppresses everything
+		}
+		for _, id := range rest {
+			if strings.TrimSuffix(id, ",") == rule {
+				return true
+			}
+		}
+		return false
+	}
+	if check(r.Start.Line) {
+		return true
+	}
+	return r.Start.Line > 0 && check(r.Start.Line-1)
+}
+
+// statementLikeReason describes why statement-region code looks like
+// statements rather than a single expression; empty means it looks fine.
+func statementLikeReason(code []byte) string {
+	trimmed := strings.TrimSpace(string(code))
+	if strings.Contains(trimmed, "\n") {
+		return "multiple lines"
+	}
+	first, _, _ := strings.Cut(trimmed, " ")
+	switch first {
+	case "import", "from", "def", "class", "for", "while", "if", "return", "pass", "del", "global", "assert", "raise":
+		return "a " + first + " statement"
+	}
+	if hasTopLevelAssignment(trimmed) {
+		return "an assignment"
+	}
+	return ""
+}
+
+// hasTopLevelAssignment reports whether code contains a bare `=` outside of
+// strings and brackets — i.e. an assignment, not a comparison or a keyword
+// argument.
+func hasTopLevelAssignment(code string) bool {
+	var depth int
+	var quote byte
+	escaped := false
+
+	for i := 0; i < len(code); i++ {
+		b := code[i]
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == quote:
+				quote = 0
+			}
+			continue
+		}
+		switch b {
+		case '"', '\'':
+			quote = b
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '=':
+			if depth > 0 {
+				continue // keyword argument
+			}
+			if i+1 < len(code) && code[i+1] == '=' {
+				i++ // comparison
+				continue
+			}
+			if i > 0 && strings.ContainsRune("=!<>:", rune(code[i-1])) {
+				continue // comparison or walrus
+			}
+			return true // plain or augmented assignment
+		}
+	}
+	return false
+}
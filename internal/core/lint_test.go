@@ -0,0 +1,106 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// lintRules runs LintBytes and returns just the rule IDs, in order.
+func lintRules(t *testing.T, input string) []string {
+	t.Helper()
+	warnings, err := LintBytes([]byte(input), "in.japaya")
+	if err != nil {
+		t.Fatalf("LintBytes: %v", err)
+	}
+	rules := make([]string, len(warnings))
+	for i, w := range warnings {
+		rules[i] = w.Rule
+	}
+	return rules
+}
+
+func TestLint_Rules(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		in    string
+		rules []string
+	}{
+		{"CleanExpression", "int x = `n + 1`;\n", nil},
+		{"ImportInStatement", "`import os`\n", []string{"stmt-not-expression"}},
+		{"AssignmentInStatement", "`x = 1`\n", []string{"stmt-not-expression"}},
+		{"KeywordArgIsFine", "`f(x=1)`\n", nil},
+		{"ComparisonIsFine", "`a == b`\n", nil},
+		{"BlockWithPrint", "```\nprint(1)\n```\n", nil},
+		{"BlockNeverPrints", "```\nx = 1\n```\n", []string{"block-never-prints"}},
+		{"ForeachBlockExempt", "```!foreach rows.csv\n${a}\n```\n", nil},
+		{"UnbalancedQuote", "String s = `\"hello`;\n", []string{"backtick-in-string"}},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := lintRules(t, tc.in)
+			if len(got) != len(tc.rules) {
+				t.Fatalf("rules = %v, want %v", got, tc.rules)
+			}
+			for i := range got {
+				if got[i] != tc.rules[i] {
+					t.Fatalf("rules = %v, want %v", got, tc.rules)
+				}
+			}
+		})
+	}
+}
+
+func TestLint_HugeSnippet(t *testing.T) {
+	t.Parallel()
+
+	in := "```\nprint(1)\n" + strings.Repeat("pass\n", hugeSnippetLines+1) + "```\n"
+	got := lintRules(t, in)
+	if len(got) != 1 || got[0] != "huge-snippet" {
+		t.Fatalf("rules = %v, want [huge-snippet]", got)
+	}
+}
+
+func TestLint_Suppression(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{"LineAboveBare", "// japaya:ignore\n`x = 1`\n", 0},
+		{"LineAboveNamedRule", "// japaya:ignore stmt-not-expression\n`x = 1`\n", 0},
+		{"SameLine", "int y = `x = 1`; // japaya:ignore\n", 0},
+		{"WrongRuleStillReported", "// japaya:ignore huge-snippet\n`x = 1`\n", 1},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := lintRules(t, tc.in); len(got) != tc.want {
+				t.Fatalf("got %d finding(s) %v, want %d", len(got), got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLint_ReportsPosition(t *testing.T) {
+	t.Parallel()
+
+	warnings, err := LintBytes([]byte("class A {\n  `x = 1`\n}\n"), "in.japaya")
+	if err != nil {
+		t.Fatalf("LintBytes: %v", err)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("warnings = %v, want one", warnings)
+	}
+	if warnings[0].Start.Line != 1 {
+		t.Errorf("Start.Line = %d, want 1", warnings[0].Start.Line)
+	}
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// lockFileName is created in the output root for the duration of a tree
+// translation, so two concurrent invocations (say, IDE watch mode plus a
+// manual build) can't interleave their staged renames.
+const lockFileName = ".japaya.lock"
+
+// acquireOutputLock takes an advisory lock on outRoot and returns a release
+// function. The lock is a file holding the owner's pid; if the file already
+// exists and that pid is still running, a clear "another japaya is running"
+// error comes back. A lock left behind by a dead process is reclaimed.
+func acquireOutputLock(outRoot string) (release func(), err error) {
+	path := filepath.Join(outRoot, lockFileName)
+	for retried := false; ; retried = true {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			_, werr := fmt.Fprintf(f, "%d\n", os.Getpid())
+			if cerr := f.Close(); werr == nil {
+				werr = cerr
+			}
+			if werr != nil {
+				_ = os.Remove(path)
+				return nil, fmt.Errorf("write lock file %q: %w", path, werr)
+			}
+			return func() { _ = os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) || retried {
+			return nil, fmt.Errorf("create lock file %q: %w", path, err)
+		}
+
+		contents, rerr := os.ReadFile(path)
+		if rerr != nil {
+			if errors.Is(rerr, os.ErrNotExist) {
+				continue // holder released between our attempts; try again
+			}
+			return nil, fmt.Errorf("read lock file %q: %w", path, rerr)
+		}
+		pid, perr := strconv.Atoi(strings.TrimSpace(string(contents)))
+		if perr == nil && pidRunning(pid) {
+			return nil, fmt.Errorf("another japaya (pid %d) is running against %q; wait for it to finish, or remove %q if it crashed", pid, outRoot, path)
+		}
+		// Unparseable or dead owner: the lock is stale. Reclaim it and make
+		// one more attempt.
+		_ = os.Remove(path)
+	}
+}
+
+// pidRunning reports whether pid looks like a live process. Errors we can't
+// interpret (permissions, platforms without signal 0) count as running, so a
+// doubtful lock is honored rather than stolen.
+func pidRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || !errors.Is(err, os.ErrProcessDone)
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAcquireOutputLock_SecondAcquireFails(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	release, err := acquireOutputLock(dir)
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	if _, err := acquireOutputLock(dir); err == nil {
+		t.Fatal("second acquire should fail while the lock is held")
+	} else if !strings.Contains(err.Error(), "another japaya") {
+		t.Fatalf("error %q should say another japaya is running", err)
+	}
+
+	release()
+	release2, err := acquireOutputLock(dir)
+	if err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	release2()
+}
+
+func TestAcquireOutputLock_ReclaimsStaleLock(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	// A lock file whose contents don't name a live process is stale —
+	// e.g. left behind by a crash before the pid was written.
+	if err := os.WriteFile(filepath.Join(dir, lockFileName), []byte("garbage\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	release, err := acquireOutputLock(dir)
+	if err != nil {
+		t.Fatalf("acquire over stale lock: %v", err)
+	}
+	release()
+	if _, err := os.Stat(filepath.Join(dir, lockFileName)); !os.IsNotExist(err) {
+		t.Fatalf("release should remove the lock file; stat err = %v", err)
+	}
+}
+
+func TestTranslateTree_FailsWhileOutputRootIsLocked(t *testing.T) {
+	t.Parallel()
+
+	inRoot := t.TempDir()
+	outRoot := t.TempDir()
+	writeFile(t, filepath.Join(inRoot, "A.java"), "public class A {}\n")
+
+	// Simulate a concurrent invocation holding the lock.
+	if err := os.WriteFile(filepath.Join(outRoot, lockFileName), []byte(fmt.Sprintf("%d\n", os.Getpid())), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, regionType RegionType, code []byte) ([]byte, error) {
+			return nil, nil
+		},
+	}
+	err := TranslateTree(context.Background(), inRoot, outRoot, py)
+	if err == nil {
+		t.Fatal("expected TranslateTree to fail while the output root is locked")
+	}
+	if !strings.Contains(err.Error(), "another japaya") {
+		t.Fatalf("error %q should say another japaya is running", err)
+	}
+}
@@ -0,0 +1,188 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"strings"
+)
+
+// expandMacros replaces @name(key=value, ...) calls in java text with the
+// expansion of a macro the evaluator registered under that name. Only strict
+// key=value argument lists are treated as candidates, so annotations like
+// @Override or @SuppressWarnings("x") never reach the worker, and candidates
+// the worker does not recognize splice through unchanged.
+func (t *Translator) expandMacros(ctx context.Context, me MacroEvaluator, chunk []byte, r Region, path string) ([]byte, error) {
+	if !bytes.ContainsRune(chunk, '@') {
+		return chunk, nil
+	}
+
+	out := make([]byte, 0, len(chunk))
+	for i := 0; i < len(chunk); i++ {
+		if chunk[i] != '@' {
+			out = append(out, chunk[i])
+			continue
+		}
+		name, args, size, ok := parseMacroCall(chunk[i:])
+		if !ok {
+			out = append(out, chunk[i])
+			continue
+		}
+		expansion, known, err := me.EvalMacro(ctx, name, args)
+		if err != nil {
+			return nil, &TranslationError{Region: r, Err: err}
+		}
+		if !known {
+			out = append(out, chunk[i])
+			continue
+		}
+		out = append(out, expansion...)
+		i += size - 1
+	}
+	return out, nil
+}
+
+// parseMacroCall parses @name(key=value, ...) at the start of data,
+// returning the macro name, its arguments, and the call's byte length. ok is
+// false whenever the text does not fit that shape exactly.
+func parseMacroCall(data []byte) (name string, args map[string]string, size int, ok bool) {
+	i := 1 // past the '@'
+	start := i
+	for i < len(data) && isMacroIdentByte(data[i], i > start) {
+		i++
+	}
+	if i == start || i >= len(data) || data[i] != '(' {
+		return "", nil, 0, false
+	}
+	name = string(data[start:i])
+
+	end, found := matchMacroClose(data, i+1)
+	if !found {
+		return "", nil, 0, false
+	}
+
+	args, ok = parseMacroArgs(string(data[i+1 : end]))
+	if !ok {
+		return "", nil, 0, false
+	}
+	return name, args, end + 1, true
+}
+
+// matchMacroClose finds the paren closing a macro call opened just before
+// start, tracking nesting and quotes. Calls stay on one line.
+func matchMacroClose(data []byte, start int) (int, bool) {
+	depth := 1
+	var quote byte
+	escaped := false
+
+	for i := start; i < len(data); i++ {
+		b := data[i]
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == quote:
+				quote = 0
+			}
+			continue
+		}
+		switch b {
+		case '"', '\'':
+			quote = b
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i, true
+			}
+		case '\n':
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// parseMacroArgs parses a strict key=value argument list. An empty list is
+// fine; anything that is not ident=value pairs separated by top-level commas
+// (an annotation's value array, a bare string, ...) is rejected.
+func parseMacroArgs(list string) (map[string]string, bool) {
+	args := map[string]string{}
+	if strings.TrimSpace(list) == "" {
+		return args, true
+	}
+	for _, pair := range splitTopLevel(list) {
+		key, value, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, false
+		}
+		key = strings.TrimSpace(key)
+		if !isMacroIdent(key) {
+			return nil, false
+		}
+		args[key] = strings.TrimSpace(value)
+	}
+	return args, true
+}
+
+// splitTopLevel splits on commas outside parens, brackets, and quotes.
+func splitTopLevel(s string) []string {
+	var parts []string
+	var depth int
+	var quote byte
+	escaped := false
+	last := 0
+
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == quote:
+				quote = 0
+			}
+			continue
+		}
+		switch b {
+		case '"', '\'':
+			quote = b
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, s[last:])
+}
+
+func isMacroIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isMacroIdentByte(s[i], i > 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func isMacroIdentByte(b byte, tail bool) bool {
+	switch {
+	case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b == '_':
+		return true
+	case b >= '0' && b <= '9':
+		return tail
+	default:
+		return false
+	}
+}
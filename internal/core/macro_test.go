@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fakeMacroEvaluator knows one macro, "getterSetter", which expands to a
+// sorted key=value listing so tests can see the parsed arguments.
+type fakeMacroEvaluator struct {
+	fakePythonEvaluator
+}
+
+func (f fakeMacroEvaluator) EvalMacro(ctx context.Context, name string, args map[string]string) (string, bool, error) {
+	if name != "getterSetter" {
+		return "", false, nil
+	}
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + args[k]
+	}
+	return fmt.Sprintf("/*%s*/", strings.Join(pairs, " ")), true, nil
+}
+
+func TestExpandMacros(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			"KnownMacro",
+			"class A {\n  @getterSetter(name=foo, type=int)\n}\n",
+			"class A {\n  /*name=foo type=int*/\n}\n",
+		},
+		{
+			"NoArgs",
+			"@getterSetter()\n",
+			"/**/\n",
+		},
+		{
+			"UnknownMacroUntouched",
+			"@toString(name=foo)\n",
+			"@toString(name=foo)\n",
+		},
+		{
+			"PlainAnnotationUntouched",
+			"@Override\npublic String toString() { return \"\"; }\n",
+			"@Override\npublic String toString() { return \"\"; }\n",
+		},
+		{
+			"ValueAnnotationUntouched",
+			"@SuppressWarnings(\"unchecked\")\n",
+			"@SuppressWarnings(\"unchecked\")\n",
+		},
+		{
+			"QuotedValueWithComma",
+			"@getterSetter(doc=\"a, b\")\n",
+			"/*doc=\"a, b\"*/\n",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			unit, err := ParseBytes([]byte(tc.in))
+			if err != nil {
+				t.Fatalf("ParseBytes: %v", err)
+			}
+			tr := &Translator{Py: fakeMacroEvaluator{}}
+			out, err := tr.TranslateUnit(t.Context(), unit)
+			if err != nil {
+				t.Fatalf("TranslateUnit: %v", err)
+			}
+			if string(out) != tc.want {
+				t.Errorf("output:\n got: %q\nwant: %q", out, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseMacroCall_Rejections(t *testing.T) {
+	t.Parallel()
+
+	rejected := []string{
+		"@Override",            // no argument list
+		"@Foo(\"bare\")",       // not key=value
+		"@Foo(a=1, \"x\")",     // mixed
+		"@Foo(a=1",             // never closes
+		"@Foo(a=1,\nb=2)",      // spans lines
+		"@1Foo(a=1)",           // bad identifier
+		"@Foo({\"a\", \"b\"})", // annotation value array
+	}
+	for _, in := range rejected {
+		if _, _, _, ok := parseMacroCall([]byte(in)); ok {
+			t.Errorf("parseMacroCall(%q) accepted, want rejection", in)
+		}
+	}
+
+	// A keyed value may itself contain braces and commas.
+	keyed := "@Foo(value={\"a\", \"b\"})"
+	if name, args, _, ok := parseMacroCall([]byte(keyed)); !ok || name != "Foo" || args["value"] != "{\"a\", \"b\"}" {
+		t.Errorf("parseMacroCall(%q) = %q %v %v, want keyed braces accepted", keyed, name, args, ok)
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"sort"
+	"sync"
+)
+
+// Manifest records which top-level python modules each translated file's
+// snippets imported. When the Translator's Py also implements
+// ImportReporter and a Manifest is configured, TranslateFile drains the
+// reporter after each file and records the result here.
+//
+// Watch mode uses this for cache invalidation: editing helpers.py
+// re-translates only the files whose snippets imported helpers.
+type Manifest struct {
+	mu      sync.Mutex
+	imports map[string][]string // input path -> sorted imported modules
+	skipped map[string]string   // input path -> japaya.skip reason
+}
+
+// NewManifest returns an empty manifest ready for use.
+func NewManifest() *Manifest {
+	return &Manifest{
+		imports: make(map[string][]string),
+		skipped: make(map[string]string),
+	}
+}
+
+// record replaces the imports recorded for path. A file that translates
+// normally is no longer skipped.
+func (m *Manifest) record(path string, imports []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.skipped, path)
+	if len(imports) == 0 {
+		delete(m.imports, path)
+		return
+	}
+	m.imports[path] = append([]string(nil), imports...)
+}
+
+// recordSkip remembers that path's snippets asked to skip the file, with
+// their reason.
+func (m *Manifest) recordSkip(path, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.skipped == nil {
+		m.skipped = make(map[string]string)
+	}
+	m.skipped[path] = reason
+}
+
+// Skipped reports whether path was skipped via japaya.skip, and the reason
+// the snippet gave.
+func (m *Manifest) Skipped(path string) (reason string, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	reason, ok = m.skipped[path]
+	return reason, ok
+}
+
+// SkippedFiles returns the input paths skipped via japaya.skip, sorted for
+// determinism.
+func (m *Manifest) SkippedFiles() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	files := make([]string, 0, len(m.skipped))
+	for path := range m.skipped {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Imports returns the modules recorded for path, or nil if none.
+func (m *Manifest) Imports(path string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.imports[path]...)
+}
+
+// FilesImporting returns the input paths whose snippets imported module,
+// sorted for determinism.
+func (m *Manifest) FilesImporting(module string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var files []string
+	for path, mods := range m.imports {
+		for _, mod := range mods {
+			if mod == module {
+				files = append(files, path)
+				break
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"fmt"
+)
+
+// Delimiter schemes understood by MigrateBytes.
+const (
+	// SchemeBacktick is the native scheme: `expr` statements and ``` blocks.
+	SchemeBacktick = "backtick"
+	// SchemeDollar writes statement regions as ${expr}, the shell-style
+	// interpolation some projects standardize on. Block regions have no
+	// dollar form and keep their backticks.
+	SchemeDollar = "dollar"
+)
+
+// MigrateBytes rewrites a template between delimiter schemes, returning the
+// rewritten source. Region extents come from the parser (or a scanner for
+// the dollar scheme), so surrounding text is reproduced byte for byte and a
+// second migration to the same scheme is a no-op.
+func MigrateBytes(data []byte, to string) ([]byte, error) {
+	switch to {
+	case SchemeDollar:
+		return migrateToDollar(data)
+	case SchemeBacktick:
+		return migrateToBacktick(data)
+	default:
+		return nil, fmt.Errorf("unknown delimiter scheme %q", to)
+	}
+}
+
+// migrateToDollar turns `expr` statement regions into ${expr}, preserving
+// java!/sh! prefixes. Blocks pass through untouched.
+func migrateToDollar(data []byte) ([]byte, error) {
+	unit, err := ParseBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(data))
+	pos := 0
+	for _, r := range unit.Regions {
+		switch r.Type {
+		case RegionTypePythonStatement, RegionTypeJShellStatement, RegionTypeShellStatement:
+			out = append(out, data[pos:r.RawStartOff]...)
+			// Raw is `prefix code`; keep everything between the backticks so
+			// the evaluator prefix survives the rewrite.
+			inner := r.Raw[1 : len(r.Raw)-1]
+			out = append(out, '$', '{')
+			out = append(out, inner...)
+			out = append(out, '}')
+			pos = r.RawEndOff
+		}
+	}
+	out = append(out, data[pos:]...)
+	return out, nil
+}
+
+// migrateToBacktick is the reverse direction: ${expr} becomes `expr`. The
+// scanner tracks nested braces and python quotes so an expression like
+// ${d["}"]} converts whole.
+func migrateToBacktick(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] != '$' || i+1 >= len(data) || data[i+1] != '{' {
+			out = append(out, data[i])
+			continue
+		}
+		end, ok := matchDollarClose(data, i+2)
+		if !ok {
+			out = append(out, data[i])
+			continue
+		}
+		out = append(out, '`')
+		out = append(out, data[i+2:end]...)
+		out = append(out, '`')
+		i = end // skip past the closing brace
+	}
+	return out, nil
+}
+
+// matchDollarClose finds the brace closing a ${ opened just before start,
+// skipping braces inside python string literals. Returns false when the
+// region never closes (so the caller leaves the text alone).
+func matchDollarClose(data []byte, start int) (int, bool) {
+	depth := 1
+	var quote byte
+	escaped := false
+
+	for i := start; i < len(data); i++ {
+		b := data[i]
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == quote:
+				quote = 0
+			}
+			continue
+		}
+		switch b {
+		case '"', '\'':
+			quote = b
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i, true
+			}
+		case '\n':
+			return 0, false // statements are single-line; don't eat the file
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestMigrateBytes_ToDollar(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"Statement", "int x = `n + 1`;\n", "int x = ${n + 1};\n"},
+		{"JShellPrefixKept", "int x = `java! f()`;\n", "int x = ${java! f()};\n"},
+		{"BlockUntouched", "```\nprint(1)\n```\n", "```\nprint(1)\n```\n"},
+		{"PlainJava", "class A {}\n", "class A {}\n"},
+		{"Idempotent", "int x = ${n};\n", "int x = ${n};\n"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := MigrateBytes([]byte(tc.in), SchemeDollar)
+			if err != nil {
+				t.Fatalf("MigrateBytes: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("migrated:\n got: %q\nwant: %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMigrateBytes_ToBacktick(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"Statement", "int x = ${n + 1};\n", "int x = `n + 1`;\n"},
+		{"BraceInString", "int x = ${d[\"}\"]};\n", "int x = `d[\"}\"]`;\n"},
+		{"NestedBraces", "int x = ${ {1: 2}[1] };\n", "int x = ` {1: 2}[1] `;\n"},
+		{"UnclosedLeftAlone", "String s = \"${oops\";\n", "String s = \"${oops\";\n"},
+		{"Idempotent", "int x = `n`;\n", "int x = `n`;\n"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := MigrateBytes([]byte(tc.in), SchemeBacktick)
+			if err != nil {
+				t.Fatalf("MigrateBytes: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("migrated:\n got: %q\nwant: %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMigrateBytes_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	in := "class A {\n  int x = `n + 1`;\n  String s = `sh! date`;\n}\n"
+	dollar, err := MigrateBytes([]byte(in), SchemeDollar)
+	if err != nil {
+		t.Fatalf("to dollar: %v", err)
+	}
+	back, err := MigrateBytes(dollar, SchemeBacktick)
+	if err != nil {
+		t.Fatalf("to backtick: %v", err)
+	}
+	if string(back) != in {
+		t.Errorf("round trip:\n got: %q\nwant: %q", back, in)
+	}
+}
+
+func TestMigrateBytes_UnknownScheme(t *testing.T) {
+	t.Parallel()
+
+	if _, err := MigrateBytes([]byte("x"), "angle"); err == nil {
+		t.Fatalf("expected error for unknown scheme")
+	}
+}
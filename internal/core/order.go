@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Regions normally evaluate top to bottom. An order annotation moves a
+// region earlier or later in evaluation without moving its splice point:
+//
+//	```#!order=-1
+//	setup()```
+//
+// runs before every unannotated region even when it sits at the bottom of
+// the file for readability. Lower orders evaluate first, unannotated regions
+// are order 0, and regions sharing an order keep their document order.
+// Output still lands exactly where each region appears.
+
+// regionEvalOrder returns a region's order annotation, or 0 when it has
+// none.
+func regionEvalOrder(r Region) (int, error) {
+	opts, _, err := parseRegionOpts(r.Data, r.Type == RegionTypePythonBlock)
+	if err != nil {
+		// The main loop reports malformed annotations with full context.
+		return 0, nil
+	}
+	val, ok := opts.Raw["order"]
+	if !ok {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, &TranslationError{Region: r, Err: fmt.Errorf("region option order=%q: not an integer", val)}
+	}
+	return n, nil
+}
+
+// preEvalResult is one pre-evaluated region's outcome; err is non-nil only
+// under a fail-at-end policy, which the splice loop collects.
+type preEvalResult struct {
+	out []byte
+	err error
+}
+
+// preEvalOrdered evaluates the unit's regions out of document order when any
+// carries an order annotation, returning each result keyed by region index
+// so the splice loop can place it where the region appears. Returns nil when
+// no region is annotated and evaluation can stay strictly top to bottom.
+func (t *Translator) preEvalOrdered(ctx context.Context, unit *TranslationUnit, path string) (map[int]preEvalResult, error) {
+	type ordered struct {
+		idx   int
+		order int
+	}
+	var regions []ordered
+	reordered := false
+	for i, r := range unit.Regions {
+		if r.Type == RegionTypeJava {
+			continue
+		}
+		if r.Type == RegionTypePythonBlock && isSlotBlock(r.Data) {
+			continue // slot markers splice placeholders, nothing to evaluate
+		}
+		order, err := regionEvalOrder(r)
+		if err != nil {
+			return nil, err
+		}
+		if order != 0 {
+			reordered = true
+		}
+		regions = append(regions, ordered{idx: i, order: order})
+	}
+	if !reordered {
+		return nil, nil
+	}
+
+	sort.SliceStable(regions, func(a, b int) bool {
+		return regions[a].order < regions[b].order
+	})
+
+	results := make(map[int]preEvalResult, len(regions))
+	for _, o := range regions {
+		translated, err := t.evalRegion(ctx, unit.Regions[o.idx], path, o.idx)
+		if err != nil && !t.deferRegionError(ctx, err) {
+			return nil, err
+		}
+		results[o.idx] = preEvalResult{out: translated, err: err}
+	}
+	return results, nil
+}
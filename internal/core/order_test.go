@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTranslateUnit_OrderAnnotationEvaluatesEarly(t *testing.T) {
+	t.Parallel()
+
+	// The setup block sits at the bottom for readability but must evaluate
+	// first; splice positions stay where the regions appear.
+	in := "int a = `use()`;\n" +
+		"```#!order=-1\nsetup()```\n"
+	unit, err := ParseBytes([]byte(in))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	var evaluated []string
+	py := fakePythonEvaluator{eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		evaluated = append(evaluated, strings.TrimSpace(string(code)))
+		if strings.Contains(string(code), "setup") {
+			return []byte("// setup ran"), nil
+		}
+		return []byte("1"), nil
+	}}
+	out, err := TranslateUnit(t.Context(), unit, py)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+
+	if len(evaluated) != 2 || !strings.Contains(evaluated[0], "setup") {
+		t.Errorf("evaluation order = %v, want the setup block first", evaluated)
+	}
+	want := "int a = 1;\n// setup ran\n"
+	if string(out) != want {
+		t.Errorf("output:\n got: %q\nwant: %q", out, want)
+	}
+}
+
+func TestTranslateUnit_EqualOrdersKeepDocumentOrder(t *testing.T) {
+	t.Parallel()
+
+	in := "`#!order=1 b()` `a()` `#!order=1 c()`\n"
+	unit, err := ParseBytes([]byte(in))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	var evaluated []string
+	py := fakePythonEvaluator{eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		evaluated = append(evaluated, strings.TrimSpace(string(code)))
+		return []byte("x"), nil
+	}}
+	if _, err := TranslateUnit(t.Context(), unit, py); err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if len(evaluated) != 3 || evaluated[0] != "a()" || evaluated[1] != "b()" || evaluated[2] != "c()" {
+		t.Errorf("evaluation order = %v, want a() then b() then c()", evaluated)
+	}
+}
+
+func TestTranslateUnit_MalformedOrderFails(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("int a = `#!order=soon v()`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	_, err = TranslateUnit(t.Context(), unit, fakePythonEvaluator{eval: upperEval})
+	if err == nil || !strings.Contains(err.Error(), `order="soon"`) {
+		t.Fatalf("err = %v, want a malformed-order error", err)
+	}
+}
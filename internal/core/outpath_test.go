@@ -0,0 +1,67 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// outputEval is a fake evaluator whose snippets "set" a pending output-path
+// override.
+type outputEval struct {
+	fakePythonEvaluator
+	pending string
+}
+
+func (e *outputEval) TakeOutputPath() string {
+	out := e.pending
+	e.pending = ""
+	return out
+}
+
+func TestTranslateTree_OutputOverrideIsRootRelative(t *testing.T) {
+	t.Parallel()
+
+	inRoot := t.TempDir()
+	outRoot := t.TempDir()
+	writeFile(t, filepath.Join(inRoot, "templates", "Foo.japaya"), "class Foo { `v` }\n")
+
+	py := &outputEval{}
+	py.eval = func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		py.pending = "com/example/v2/Foo.java"
+		return []byte("1"), nil
+	}
+	tr := &Translator{Py: py}
+	if err := tr.TranslateTree(t.Context(), inRoot, outRoot); err != nil {
+		t.Fatalf("TranslateTree: %v", err)
+	}
+
+	moved := filepath.Join(outRoot, "com", "example", "v2", "Foo.java")
+	if _, err := os.Stat(moved); err != nil {
+		t.Errorf("overridden output: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outRoot, "templates", "Foo.java")); err == nil {
+		t.Error("mirrored output written despite the override")
+	}
+}
+
+func TestTranslateFile_OutputOverrideMustStayInside(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "Foo.japaya")
+	writeFile(t, inPath, "class Foo { `v` }\n")
+
+	py := &outputEval{}
+	py.eval = func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		py.pending = "../outside/Foo.java"
+		return []byte("1"), nil
+	}
+	tr := &Translator{Py: py}
+	err := tr.TranslateFile(t.Context(), inPath, filepath.Join(dir, "Foo.java"))
+	if err == nil || !strings.Contains(err.Error(), "escapes the output root") {
+		t.Fatalf("err = %v, want an escape error", err)
+	}
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// Overlay holds in-memory file contents that shadow on-disk files during
+// translation. A daemon or LSP front end records unsaved editor buffers here
+// so diagnostics and outputs reflect what the user sees, not what was last
+// written to disk. Paths are normalized with filepath.Clean, so Set and the
+// translator agree on the key even if callers spell the path differently.
+//
+// Safe for concurrent use.
+type Overlay struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+// NewOverlay returns an empty overlay ready for use.
+func NewOverlay() *Overlay {
+	return &Overlay{files: make(map[string][]byte)}
+}
+
+// Set shadows path with the given contents until Delete is called.
+func (o *Overlay) Set(path string, contents []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.files[filepath.Clean(path)] = append([]byte(nil), contents...)
+}
+
+// Delete removes the shadow for path, so reads fall through to disk again
+// (typically after the editor saves the buffer).
+func (o *Overlay) Delete(path string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.files, filepath.Clean(path))
+}
+
+// Get returns the shadowed contents for path, if any.
+func (o *Overlay) Get(path string) ([]byte, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	contents, ok := o.files[filepath.Clean(path)]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), contents...), true
+}
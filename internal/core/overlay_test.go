@@ -0,0 +1,49 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOverlay_ShadowsDiskDuringTranslateFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "Main.java")
+	out := filepath.Join(dir, "out", "Main.java")
+	writeFile(t, in, "on disk `a`\n")
+
+	py := fakePythonEvaluator{eval: upperEval}
+
+	ov := NewOverlay()
+	// A non-clean spelling of the same path must still shadow it.
+	ov.Set(filepath.Join(dir, ".", "Main.java"), []byte("in buffer `b`\n"))
+
+	tr := &Translator{Py: py, Overlay: ov}
+	if err := tr.TranslateFile(context.Background(), in, out); err != nil {
+		t.Fatalf("TranslateFile: %v", err)
+	}
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != "in buffer <b>\n" {
+		t.Fatalf("expected overlay contents to win, got %q", got)
+	}
+
+	// Deleting the shadow falls back to disk.
+	ov.Delete(in)
+	if err := tr.TranslateFile(context.Background(), in, out); err != nil {
+		t.Fatalf("TranslateFile: %v", err)
+	}
+	got, err = os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != "on disk <a>\n" {
+		t.Fatalf("expected disk contents after Delete, got %q", got)
+	}
+}
@@ -7,15 +7,48 @@ import (
 	"log"
 )
 
+// Marks a single-backtick statement as java, evaluated by JShell.
+const jshellPrefix = "java!"
+
+// Marks a single-backtick statement as a shell command (opt-in at run time).
+const shellPrefix = "sh!"
+
+// ParseOptions configures optional syntax extensions; the zero value is the
+// classic backtick-only syntax.
+type ParseOptions struct {
+	// DollarInterp additionally recognizes ${expr} as a statement region,
+	// the interpolation style Groovy/Kotlin template users expect. Braces
+	// nest inside the expression and python quotes are respected; backtick
+	// regions keep working, so both can coexist during a migration.
+	DollarInterp bool
+
+	// Limits bounds what the parser will accept; see ParseLimits.
+	Limits ParseLimits
+}
+
+// ParseLimits caps the parser's appetite so a pathological or hostile input
+// can't exhaust memory when japaya runs as a service. Exceeding a limit is
+// a ParseError positioned at the offending byte. Zero values mean no limit.
+type ParseLimits struct {
+	MaxFileSize   int // input size in bytes
+	MaxRegionSize int // a snippet region's content size in bytes (java passthrough is exempt)
+	MaxRegions    int // regions per file
+}
+
 // Pulls the bytes out of a reader, then parses using that
 func ParseReader(reader io.Reader) (*TranslationUnit, error) {
+	return ParseReaderWithOptions(reader, ParseOptions{})
+}
+
+// ParseReaderWithOptions is ParseReader with syntax extensions enabled.
+func ParseReaderWithOptions(reader io.Reader, opts ParseOptions) (*TranslationUnit, error) {
 	data, err := io.ReadAll(reader)
 	if err != nil {
 		log.Println("Encountered error reading data:", err)
 		return nil, err
 	}
 
-	return ParseBytes(data)
+	return ParseBytesWithOptions(data, opts)
 }
 
 // ParseBytes splits a Japaya source file into regions:
@@ -25,6 +58,18 @@ func ParseReader(reader io.Reader) (*TranslationUnit, error) {
 //
 // Positions are half-open: [Start, End).
 func ParseBytes(data []byte) (*TranslationUnit, error) {
+	return ParseBytesWithOptions(data, ParseOptions{})
+}
+
+// ParseBytesWithOptions is ParseBytes with syntax extensions enabled (see
+// ParseOptions).
+func ParseBytesWithOptions(data []byte, opts ParseOptions) (*TranslationUnit, error) {
+	if max := opts.Limits.MaxFileSize; max > 0 && len(data) > max {
+		return nil, &ParseError{
+			Msg: fmt.Sprintf("input is %d bytes, exceeding the %d-byte file size limit", len(data), max),
+		}
+	}
+
 	unit := &TranslationUnit{Data: data}
 
 	type pos struct {
@@ -33,7 +78,9 @@ func ParseBytes(data []byte) (*TranslationUnit, error) {
 		col  uint // 0-based (bytes, not runes)
 	}
 
-	// Advance p by one byte, updating line/col.
+	// Advance p by one byte, updating line/col. A carriage return never
+	// counts as a column, so \r\n is one line terminator and CRLF files get
+	// the same columns as their LF twins.
 	// (Column counts bytes. Good enough for now; revisit if you need UTF-16 columns.)
 	advance1 := func(p *pos) {
 		if p.i >= len(data) {
@@ -42,7 +89,7 @@ func ParseBytes(data []byte) (*TranslationUnit, error) {
 		if data[p.i] == '\n' {
 			p.line++
 			p.col = 0
-		} else {
+		} else if data[p.i] != '\r' {
 			p.col++
 		}
 		p.i++
@@ -53,15 +100,48 @@ func ParseBytes(data []byte) (*TranslationUnit, error) {
 		return append([]byte(nil), b...)
 	}
 
-	emit := func(t RegionType, start pos, end pos) {
-		if end.i <= start.i {
+	// rawStart/rawEnd span the region including its delimiters; for java
+	// regions they are the same as start/end. Limit violations land in
+	// limitErr; the main loop bails out on the next iteration.
+	var limitErr error
+	emit := func(t RegionType, start pos, end pos, rawStart pos, rawEnd pos) {
+		if end.i <= start.i || limitErr != nil {
+			return
+		}
+		if max := opts.Limits.MaxRegionSize; max > 0 && t != RegionTypeJava && end.i-start.i > max {
+			limitErr = &ParseError{
+				Pos: Position{Line: start.line, Column: start.col},
+				Msg: fmt.Sprintf("region is %d bytes, exceeding the %d-byte region size limit", end.i-start.i, max),
+			}
+			return
+		}
+		if max := opts.Limits.MaxRegions; max > 0 && len(unit.Regions) >= max {
+			limitErr = &ParseError{
+				Pos: Position{Line: start.line, Column: start.col},
+				Msg: fmt.Sprintf("file has more than %d regions, exceeding the region count limit", max),
+			}
 			return
 		}
+		content := own(data[start.i:end.i])
+		if t != RegionTypeJava {
+			content = normalizeNewlines(content)
+		}
+		if t == RegionTypePythonStatement {
+			content = joinStatementLines(content)
+		}
 		unit.Regions = append(unit.Regions, Region{
-			Type:  t,
-			Start: Position{Line: start.line, Column: start.col},
-			End:   Position{Line: end.line, Column: end.col},
-			Data:  own(data[start.i:end.i]),
+			Type:     t,
+			Start:    Position{Line: start.line, Column: start.col},
+			End:      Position{Line: end.line, Column: end.col},
+			Data:     content,
+			RawStart: Position{Line: rawStart.line, Column: rawStart.col},
+			RawEnd:   Position{Line: rawEnd.line, Column: rawEnd.col},
+			Raw:      own(data[rawStart.i:rawEnd.i]),
+
+			StartOff:    start.i,
+			EndOff:      end.i,
+			RawStartOff: rawStart.i,
+			RawEndOff:   rawEnd.i,
 		})
 	}
 
@@ -89,6 +169,48 @@ func ParseBytes(data []byte) (*TranslationUnit, error) {
 	javaStart := p
 
 	for p.i < len(data) {
+		if limitErr != nil {
+			return nil, limitErr
+		}
+
+		// ${expr} interpolation, when enabled.
+		if opts.DollarInterp && data[p.i] == '$' && p.i+1 < len(data) && data[p.i+1] == '{' {
+			emit(RegionTypeJava, javaStart, p, javaStart, p)
+
+			openPos := p
+			advance1(&p) // $
+			advance1(&p) // {
+			contentStart := p
+
+			closeIdx := dollarCloseIndex(data, p.i)
+			if closeIdx < 0 {
+				return nil, &ParseError{
+					Pos: Position{Line: openPos.line, Column: openPos.col},
+					Msg: "unterminated ${ interpolation (missing closing })",
+				}
+			}
+
+			contentEnd := advanceTo(contentStart, closeIdx)
+
+			typ := RegionTypePythonStatement
+			if bytes.HasPrefix(data[contentStart.i:contentEnd.i], []byte(jshellPrefix)) {
+				typ = RegionTypeJShellStatement
+				contentStart = advanceTo(contentStart, contentStart.i+len(jshellPrefix))
+			} else if bytes.HasPrefix(data[contentStart.i:contentEnd.i], []byte(shellPrefix)) {
+				typ = RegionTypeShellStatement
+				contentStart = advanceTo(contentStart, contentStart.i+len(shellPrefix))
+			} else if cr, n, ok := customRegionByPrefix(data[contentStart.i:contentEnd.i]); ok {
+				typ = cr.typ
+				contentStart = advanceTo(contentStart, contentStart.i+n)
+			}
+
+			// Move p past closing }
+			p = advanceTo(contentEnd, closeIdx+1)
+			emit(typ, contentStart, contentEnd, openPos, p)
+			javaStart = p
+			continue
+		}
+
 		// Look for a backtick. Anything else is Java.
 		if data[p.i] != '`' {
 			advance1(&p)
@@ -102,7 +224,7 @@ func ParseBytes(data []byte) (*TranslationUnit, error) {
 		}
 
 		// Emit Java region before this delimiter
-		emit(RegionTypeJava, javaStart, p)
+		emit(RegionTypeJava, javaStart, p, javaStart, p)
 
 		if isTriple {
 			// Consume opening ```
@@ -122,10 +244,10 @@ func ParseBytes(data []byte) (*TranslationUnit, error) {
 			}
 
 			contentEnd := advanceTo(contentStart, closeIdx)
-			emit(RegionTypePythonBlock, contentStart, contentEnd)
 
 			// Move p past closing ```
 			p = advanceTo(contentEnd, closeIdx+3)
+			emit(RegionTypePythonBlock, contentStart, contentEnd, openPos, p)
 			javaStart = p
 			continue
 		}
@@ -145,19 +267,104 @@ func ParseBytes(data []byte) (*TranslationUnit, error) {
 		}
 
 		contentEnd := advanceTo(contentStart, closeIdx)
-		emit(RegionTypePythonStatement, contentStart, contentEnd)
+
+		// A `java!` prefix marks a jshell statement instead of python; the
+		// prefix itself is not part of the content. Registered custom
+		// prefixes are checked the same way (see RegisterRegionType).
+		typ := RegionTypePythonStatement
+		if bytes.HasPrefix(data[contentStart.i:contentEnd.i], []byte(jshellPrefix)) {
+			typ = RegionTypeJShellStatement
+			contentStart = advanceTo(contentStart, contentStart.i+len(jshellPrefix))
+		} else if bytes.HasPrefix(data[contentStart.i:contentEnd.i], []byte(shellPrefix)) {
+			typ = RegionTypeShellStatement
+			contentStart = advanceTo(contentStart, contentStart.i+len(shellPrefix))
+		} else if cr, n, ok := customRegionByPrefix(data[contentStart.i:contentEnd.i]); ok {
+			typ = cr.typ
+			contentStart = advanceTo(contentStart, contentStart.i+n)
+		}
 
 		// Move p past closing `
 		p = advanceTo(contentEnd, closeIdx+1)
+		emit(typ, contentStart, contentEnd, openPos, p)
 		javaStart = p
 	}
 
 	// Trailing Java
-	emit(RegionTypeJava, javaStart, p)
+	emit(RegionTypeJava, javaStart, p, javaStart, p)
+	if limitErr != nil {
+		return nil, limitErr
+	}
 
 	return unit, nil
 }
 
+// joinStatementLines rewrites a multi-line statement region into the single
+// logical line python expects of an expression: newlines become one space
+// and each line sheds its surrounding indentation, so a long chained helper
+// call can be formatted readably without switching to a block. String
+// literals can't span lines under this rule; expressions that need them
+// belong in a block.
+func joinStatementLines(data []byte) []byte {
+	if !bytes.ContainsRune(data, '\n') {
+		return data
+	}
+	var parts [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if line = bytes.Trim(line, " \t\r"); len(line) > 0 {
+			parts = append(parts, line)
+		}
+	}
+	return bytes.Join(parts, []byte(" "))
+}
+
+// normalizeNewlines rewrites \r\n terminators to \n in a snippet region's
+// content, so code from CRLF files reaches the evaluators — and the
+// directive parsers, which match line by line — without stray carriage
+// returns. Raw and the byte offsets keep the file's exact bytes, and java
+// passthrough keeps its own terminators.
+func normalizeNewlines(data []byte) []byte {
+	if !bytes.Contains(data, []byte("\r\n")) {
+		return data
+	}
+	return bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+}
+
+// dollarCloseIndex finds the brace closing a ${ whose expression starts at
+// start, honoring nested braces and python string quotes. Returns -1 when
+// the interpolation never closes.
+func dollarCloseIndex(data []byte, start int) int {
+	depth := 1
+	var quote byte
+	escaped := false
+
+	for i := start; i < len(data); i++ {
+		b := data[i]
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == quote:
+				quote = 0
+			}
+			continue
+		}
+		switch b {
+		case '"', '\'':
+			quote = b
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
 // Optional richer error.
 type ParseError struct {
 	Pos Position
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseBytesWithOptions_DollarInterp(t *testing.T) {
+	t.Parallel()
+
+	opts := ParseOptions{DollarInterp: true}
+
+	tests := []struct {
+		name string
+		in   string
+		want []wantRegion
+	}{
+		{
+			name: "dollar_statement",
+			in:   "a ${x} b",
+			want: []wantRegion{
+				{
+					typ:       RegionTypeJava,
+					startLine: 0, startCol: 0,
+					endLine: 0, endCol: 2,
+					dataString: "a ",
+				},
+				{
+					typ:       RegionTypePythonStatement,
+					startLine: 0, startCol: 4,
+					endLine: 0, endCol: 5,
+					dataString: "x",
+				},
+				{
+					typ:       RegionTypeJava,
+					startLine: 0, startCol: 6,
+					endLine: 0, endCol: 8,
+					dataString: " b",
+				},
+			},
+		},
+		{
+			name: "nested_braces",
+			in:   "${ {1: 2}[1] }",
+			want: []wantRegion{
+				{
+					typ:       RegionTypePythonStatement,
+					startLine: 0, startCol: 2,
+					endLine: 0, endCol: 13,
+					dataString: " {1: 2}[1] ",
+				},
+			},
+		},
+		{
+			name: "brace_in_string",
+			in:   "${d[\"}\"]}",
+			want: []wantRegion{
+				{
+					typ:       RegionTypePythonStatement,
+					startLine: 0, startCol: 2,
+					endLine: 0, endCol: 8,
+					dataString: "d[\"}\"]",
+				},
+			},
+		},
+		{
+			name: "coexists_with_backticks",
+			in:   "`a`${b}",
+			want: []wantRegion{
+				{
+					typ:       RegionTypePythonStatement,
+					startLine: 0, startCol: 1,
+					endLine: 0, endCol: 2,
+					dataString: "a",
+				},
+				{
+					typ:       RegionTypePythonStatement,
+					startLine: 0, startCol: 5,
+					endLine: 0, endCol: 6,
+					dataString: "b",
+				},
+			},
+		},
+		{
+			name: "jshell_prefix",
+			in:   "${java! f()}",
+			want: []wantRegion{
+				{
+					typ:       RegionTypeJShellStatement,
+					startLine: 0, startCol: 7,
+					endLine: 0, endCol: 11,
+					dataString: " f()",
+				},
+			},
+		},
+		{
+			name: "bare_dollar_is_java",
+			in:   "cost: $5\n",
+			want: []wantRegion{
+				{
+					typ:       RegionTypeJava,
+					startLine: 0, startCol: 0,
+					endLine: 1, endCol: 0,
+					dataString: "cost: $5\n",
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			unit, err := ParseBytesWithOptions([]byte(tc.in), opts)
+			if err != nil {
+				t.Fatalf("ParseBytesWithOptions: %v", err)
+			}
+			if len(unit.Regions) != len(tc.want) {
+				t.Fatalf("got %d regions, want %d: %+v", len(unit.Regions), len(tc.want), unit.Regions)
+			}
+			for i, w := range tc.want {
+				r := unit.Regions[i]
+				if r.Type != w.typ || string(r.Data) != w.dataString ||
+					r.Start.Line != w.startLine || r.Start.Column != w.startCol ||
+					r.End.Line != w.endLine || r.End.Column != w.endCol {
+					t.Errorf("region %d = %+v, want %+v", i, r, w)
+				}
+			}
+		})
+	}
+}
+
+func TestParseBytesWithOptions_DollarDisabledByDefault(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("a ${x} b"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	if len(unit.Regions) != 1 || unit.Regions[0].Type != RegionTypeJava {
+		t.Fatalf("regions = %+v, want one java region", unit.Regions)
+	}
+}
+
+func TestParseBytesWithOptions_UnterminatedDollar(t *testing.T) {
+	t.Parallel()
+
+	_, err := ParseBytesWithOptions([]byte("x ${oops"), ParseOptions{DollarInterp: true})
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("err = %v, want ParseError", err)
+	}
+	if perr.Pos.Column != 2 {
+		t.Errorf("Pos.Column = %d, want 2", perr.Pos.Column)
+	}
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseBytesWithOptions_Limits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		in      string
+		limits  ParseLimits
+		wantErr string // "" means the input must parse
+	}{
+		{
+			name:    "file_size_exceeded",
+			in:      "class A { int x = `1`; }\n",
+			limits:  ParseLimits{MaxFileSize: 10},
+			wantErr: "file size limit",
+		},
+		{
+			name:   "file_size_within_limit",
+			in:     "class A {}\n",
+			limits: ParseLimits{MaxFileSize: 64},
+		},
+		{
+			name:    "region_size_exceeded",
+			in:      "int x = `" + strings.Repeat("1+", 20) + "1`;\n",
+			limits:  ParseLimits{MaxRegionSize: 8},
+			wantErr: "region size limit",
+		},
+		{
+			name:   "region_size_counts_content_not_file",
+			in:     strings.Repeat("// java\n", 10) + "int x = `1`;\n",
+			limits: ParseLimits{MaxRegionSize: 1024},
+		},
+		{
+			name:    "region_count_exceeded",
+			in:      "a `1` b `2` c `3` d\n",
+			limits:  ParseLimits{MaxRegions: 3},
+			wantErr: "region count limit",
+		},
+		{
+			name:   "zero_limits_unlimited",
+			in:     "a `1` b `2` c `3` d\n",
+			limits: ParseLimits{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseBytesWithOptions([]byte(tc.in), ParseOptions{Limits: tc.limits})
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ParseBytesWithOptions: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected an error mentioning %q, got nil", tc.wantErr)
+			}
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Errorf("error should be a *ParseError, got %T: %v", err, err)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("error %q should mention %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseBytesWithOptions_RegionSizeErrorCarriesPosition(t *testing.T) {
+	t.Parallel()
+
+	in := "class A {\n  int x = `" + strings.Repeat("y", 32) + "`;\n}\n"
+	_, err := ParseBytesWithOptions([]byte(in), ParseOptions{Limits: ParseLimits{MaxRegionSize: 8}})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("error should be a *ParseError, got %T: %v", err, err)
+	}
+	if pe.Pos.Line != 1 {
+		t.Errorf("Pos.Line = %d, want 1 (the oversized region's start)", pe.Pos.Line)
+	}
+}
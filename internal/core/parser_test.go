@@ -212,3 +212,147 @@ func TestParseBytes_UnterminatedBlockError(t *testing.T) {
 		t.Fatalf("parse error position mismatch: got (%d,%d), want (0,0)", pe.Pos.Line, pe.Pos.Column)
 	}
 }
+
+func TestParseBytes_RawExtentsIncludeDelimiters(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("a `x` b ```\npy\n``` c"))
+	if err != nil {
+		t.Fatalf("ParseBytes returned error: %v", err)
+	}
+
+	type wantRaw struct {
+		typ       RegionType
+		rawString string
+		rawStart  Position
+		rawEnd    Position
+	}
+
+	want := []wantRaw{
+		{RegionTypeJava, "a ", Position{0, 0}, Position{0, 2}},
+		{RegionTypePythonStatement, "`x`", Position{0, 2}, Position{0, 5}},
+		{RegionTypeJava, " b ", Position{0, 5}, Position{0, 8}},
+		{RegionTypePythonBlock, "```\npy\n```", Position{0, 8}, Position{2, 3}},
+		{RegionTypeJava, " c", Position{2, 3}, Position{2, 5}},
+	}
+
+	if got := len(unit.Regions); got != len(want) {
+		t.Fatalf("region count mismatch: got %d, want %d\nregions: %#v", got, len(want), unit.Regions)
+	}
+
+	for i, w := range want {
+		g := unit.Regions[i]
+		if g.Type != w.typ {
+			t.Fatalf("region %d type mismatch: got %v, want %v", i, g.Type, w.typ)
+		}
+		if got := string(g.Raw); got != w.rawString {
+			t.Fatalf("region %d raw mismatch:\n got: %q\nwant: %q", i, got, w.rawString)
+		}
+		if g.RawStart != w.rawStart {
+			t.Fatalf("region %d raw start mismatch: got (%d,%d), want (%d,%d)",
+				i, g.RawStart.Line, g.RawStart.Column, w.rawStart.Line, w.rawStart.Column)
+		}
+		if g.RawEnd != w.rawEnd {
+			t.Fatalf("region %d raw end mismatch: got (%d,%d), want (%d,%d)",
+				i, g.RawEnd.Line, g.RawEnd.Column, w.rawEnd.Line, w.rawEnd.Column)
+		}
+	}
+}
+
+func TestParseBytes_JShellStatementPrefix(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("int v = `java! 1+2`;"))
+	if err != nil {
+		t.Fatalf("ParseBytes returned error: %v", err)
+	}
+
+	if got := len(unit.Regions); got != 3 {
+		t.Fatalf("region count mismatch: got %d, want 3\nregions: %#v", got, unit.Regions)
+	}
+
+	r := unit.Regions[1]
+	if r.Type != RegionTypeJShellStatement {
+		t.Fatalf("unexpected type: %v", r.Type)
+	}
+	if got, want := string(r.Data), " 1+2"; got != want {
+		t.Fatalf("data mismatch: got %q, want %q", got, want)
+	}
+	// Content starts after the backtick and the java! prefix.
+	if r.Start.Line != 0 || r.Start.Column != 14 {
+		t.Fatalf("start mismatch: got (%d,%d), want (0,14)", r.Start.Line, r.Start.Column)
+	}
+	// Raw extent still covers the whole delimited region.
+	if got, want := string(r.Raw), "`java! 1+2`"; got != want {
+		t.Fatalf("raw mismatch: got %q, want %q", got, want)
+	}
+}
+
+func TestParseBytes_MultilineStatementJoinsContinuationLines(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("int x = `helper()\n    .chain(1,\n           2)`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	var stmt *Region
+	for i := range unit.Regions {
+		if unit.Regions[i].Type == RegionTypePythonStatement {
+			stmt = &unit.Regions[i]
+		}
+	}
+	if stmt == nil {
+		t.Fatal("no python statement region parsed")
+	}
+	if want := "helper() .chain(1, 2)"; string(stmt.Data) != want {
+		t.Errorf("Data = %q, want continuation lines joined as %q", stmt.Data, want)
+	}
+	// The raw text keeps the original formatting and delimiters.
+	if want := "`helper()\n    .chain(1,\n           2)`"; string(stmt.Raw) != want {
+		t.Errorf("Raw = %q, want the unjoined source %q", stmt.Raw, want)
+	}
+	if stmt.Start.Line != 0 || stmt.End.Line != 2 {
+		t.Errorf("span = %d:%d, want positions still covering lines 0-2", stmt.Start.Line, stmt.End.Line)
+	}
+}
+
+func TestParseBytes_CRLFPositionsAndContent(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("class A {\r\n```\r\nprint(1)\r\n```\r\nint x = `expr`;\r\n}\r\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	var block, stmt *Region
+	for i := range unit.Regions {
+		switch unit.Regions[i].Type {
+		case RegionTypePythonBlock:
+			block = &unit.Regions[i]
+		case RegionTypePythonStatement:
+			stmt = &unit.Regions[i]
+		}
+	}
+	if block == nil || stmt == nil {
+		t.Fatalf("regions = %v, want a block and a statement", unit.Regions)
+	}
+
+	// Block content reaches the evaluator with \n terminators only; the raw
+	// extent keeps the file's exact bytes.
+	if want := "\nprint(1)\n"; string(block.Data) != want {
+		t.Errorf("block Data = %q, want CRLF normalized to %q", block.Data, want)
+	}
+	if want := "```\r\nprint(1)\r\n```"; string(block.Raw) != want {
+		t.Errorf("block Raw = %q, want the original bytes %q", block.Raw, want)
+	}
+
+	// \r\n counts as one line terminator, so positions in the CRLF file
+	// match its LF twin: the statement content starts at (4,9).
+	if stmt.Start.Line != 4 || stmt.Start.Column != 9 {
+		t.Errorf("stmt start = (%d,%d), want (4,9)", stmt.Start.Line, stmt.Start.Column)
+	}
+	if string(stmt.Data) != "expr" {
+		t.Errorf("stmt Data = %q, want %q", stmt.Data, "expr")
+	}
+}
@@ -0,0 +1,38 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// snippetEnvFor returns the env additions snippets see while translating
+// inPath: SnippetEnv plus, when PackageRoot enables package inference, the
+// file's inferred package name under JAPAYA_PACKAGE (surfaced to snippets as
+// japaya.package).
+func (t *Translator) snippetEnvFor(inPath string) map[string]string {
+	if t.PackageRoot == "" {
+		return t.SnippetEnv
+	}
+	env := make(map[string]string, len(t.SnippetEnv)+1)
+	for k, v := range t.SnippetEnv {
+		env[k] = v
+	}
+	env["JAPAYA_PACKAGE"] = inferPackage(t.PackageRoot, inPath)
+	return env
+}
+
+// inferPackage derives a java package name from inPath's directory relative
+// to root, turning path separators into dots — src/com/example/Foo.japaya
+// under root src becomes com.example. Files directly in the root or outside
+// it get the empty (default) package.
+func inferPackage(root, inPath string) string {
+	rel, err := filepath.Rel(root, inPath)
+	if err != nil {
+		return ""
+	}
+	dir := filepath.ToSlash(filepath.Dir(rel))
+	if dir == "." || dir == ".." || strings.HasPrefix(dir, "../") {
+		return ""
+	}
+	return strings.ReplaceAll(dir, "/", ".")
+}
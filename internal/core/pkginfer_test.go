@@ -0,0 +1,53 @@
+package core
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestInferPackage(t *testing.T) {
+	t.Parallel()
+
+	root := filepath.Join("src", "main", "java")
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{filepath.Join(root, "com", "example", "Foo.japaya"), "com.example"},
+		{filepath.Join(root, "com", "example", "deep", "Bar.japaya"), "com.example.deep"},
+		{filepath.Join(root, "Top.japaya"), ""},              // default package
+		{filepath.Join("elsewhere", "Out.japaya"), ""},       // outside the root
+		{filepath.Join("src", "main", "Sibling.japaya"), ""}, // above the root
+	}
+	for _, tc := range cases {
+		if got := inferPackage(root, tc.in); got != tc.want {
+			t.Errorf("inferPackage(%q, %q) = %q, want %q", root, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestTranslateFile_ExposesInferredPackage(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "com", "example")
+	inPath := filepath.Join(dir, "A.japaya")
+	writeFile(t, inPath, "class A { `v` }\n")
+
+	py := &scopingEvaluator{fakePythonEvaluator: fakePythonEvaluator{eval: upperEval}}
+	tr := &Translator{
+		Py:          py,
+		PackageRoot: root,
+		SnippetEnv:  map[string]string{"SCHEMA_DIR": "schemas"},
+	}
+	if err := tr.TranslateFile(t.Context(), inPath, filepath.Join(dir, "A.java")); err != nil {
+		t.Fatalf("TranslateFile: %v", err)
+	}
+
+	if py.env["JAPAYA_PACKAGE"] != "com.example" {
+		t.Errorf("scope env JAPAYA_PACKAGE = %q, want %q", py.env["JAPAYA_PACKAGE"], "com.example")
+	}
+	if py.env["SCHEMA_DIR"] != "schemas" {
+		t.Errorf("scope env = %v, want SnippetEnv carried alongside", py.env)
+	}
+}
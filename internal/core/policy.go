@@ -0,0 +1,48 @@
+package core
+
+import (
+	"context"
+	"errors"
+)
+
+// FailMode says when diagnostics of a severity stop translation.
+type FailMode int
+
+const (
+	// FailFast stops the file at the first error. The zero value, matching
+	// the translator's historical behavior.
+	FailFast FailMode = iota
+
+	// FailAtEnd keeps evaluating the rest of the file and fails it once
+	// with every collected error, so one broken snippet doesn't hide the
+	// others.
+	FailAtEnd
+)
+
+// ErrorPolicy decides, per diagnostic severity, whether translation stops.
+// Warnings never stop anything (Warnings collects them; -Werror fails the
+// run at the end). Fatal errors — an unreadable input, a dead worker, a
+// cancelled or timed-out run — always stop immediately. The configurable
+// tier is the recoverable one: errors attributed to a single region, whose
+// neighbors could still evaluate.
+type ErrorPolicy struct {
+	// RecoverableErrors selects FailFast or FailAtEnd for region errors.
+	RecoverableErrors FailMode
+}
+
+// deferRegionError reports whether a region error should be collected and
+// translation continue to the end of the file. Only recoverable errors
+// qualify: the error must be attributed to a region, the context still
+// alive, and the policy set to FailAtEnd. Skips are not errors and always
+// take effect at once.
+func (t *Translator) deferRegionError(ctx context.Context, err error) bool {
+	if t.Policy.RecoverableErrors != FailAtEnd || ctx.Err() != nil {
+		return false
+	}
+	var skip *FileSkippedError
+	if errors.As(err, &skip) {
+		return false
+	}
+	var te *TranslationError
+	return errors.As(err, &te)
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTranslateUnit_FailAtEndCollectsEveryRegionError(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("int a = `bad1()`;\nint b = `ok()`;\nint c = `bad2()`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	var evaluated []string
+	py := fakePythonEvaluator{eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		evaluated = append(evaluated, string(code))
+		if strings.HasPrefix(string(code), "bad") {
+			return nil, fmt.Errorf("boom in %s", code)
+		}
+		return []byte("1"), nil
+	}}
+
+	tr := &Translator{Py: py, Policy: ErrorPolicy{RecoverableErrors: FailAtEnd}}
+	_, err = tr.TranslateUnit(t.Context(), unit)
+	if err == nil {
+		t.Fatal("want an error")
+	}
+	if !strings.Contains(err.Error(), "boom in bad1()") || !strings.Contains(err.Error(), "boom in bad2()") {
+		t.Errorf("err = %v, want both region errors reported", err)
+	}
+	if len(evaluated) != 3 {
+		t.Errorf("evaluated %v, want all three regions despite the first failure", evaluated)
+	}
+}
+
+func TestTranslateUnit_DefaultPolicyStopsAtFirstError(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("int a = `bad()`;\nint b = `ok()`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	var evaluated int
+	py := fakePythonEvaluator{eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		evaluated++
+		return nil, fmt.Errorf("boom")
+	}}
+	if _, err := TranslateUnit(t.Context(), unit, py); err == nil {
+		t.Fatal("want an error")
+	}
+	if evaluated != 1 {
+		t.Errorf("evaluated %d regions, want translation to stop at the first", evaluated)
+	}
+}
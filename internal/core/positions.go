@@ -0,0 +1,114 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// UTF16Position is a position whose column counts UTF-16 code units rather
+// than bytes — the convention LSP clients use. Like Position it is
+// zero-based.
+type UTF16Position struct {
+	Line   uint
+	Column uint
+}
+
+// lineStarts returns the byte offset of the start of every line in the
+// unit's data. Line 0 always starts at offset 0.
+func (u *TranslationUnit) lineStarts() []int {
+	starts := []int{0}
+	for i, b := range u.Data {
+		if b == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// OffsetToPosition converts a byte offset into a (line, column) Position.
+// Offsets from 0 through len(Data) inclusive are valid, so the end of the
+// file has a position too.
+func (u *TranslationUnit) OffsetToPosition(off int) (Position, error) {
+	if off < 0 || off > len(u.Data) {
+		return Position{}, fmt.Errorf("offset %d out of bounds for %d bytes", off, len(u.Data))
+	}
+
+	starts := u.lineStarts()
+	// The last line whose start is <= off.
+	line := sort.Search(len(starts), func(i int) bool { return starts[i] > off }) - 1
+	return Position{Line: uint(line), Column: uint(off - starts[line])}, nil
+}
+
+// PositionToOffset converts a (line, column) Position back into a byte
+// offset. The column may point one past the last byte of the line (or of the
+// file, for the final line) but may not cross into the next line.
+func (u *TranslationUnit) PositionToOffset(p Position) (int, error) {
+	starts := u.lineStarts()
+	if int(p.Line) >= len(starts) {
+		return 0, fmt.Errorf("line %d out of bounds for %d lines", p.Line, len(starts))
+	}
+
+	off := starts[p.Line] + int(p.Column)
+	end := len(u.Data)
+	if int(p.Line)+1 < len(starts) {
+		end = starts[p.Line+1] - 1 // the byte before the newline
+	}
+	if off > end {
+		return 0, fmt.Errorf("column %d past the end of line %d", p.Column, p.Line)
+	}
+	return off, nil
+}
+
+// OffsetToUTF16 converts a byte offset into a UTF-16 position. Bytes that do
+// not form valid UTF-8 count as one code unit each, matching how they decode
+// to the replacement character.
+func (u *TranslationUnit) OffsetToUTF16(off int) (UTF16Position, error) {
+	p, err := u.OffsetToPosition(off)
+	if err != nil {
+		return UTF16Position{}, err
+	}
+
+	lineStart := off - int(p.Column)
+	var units uint
+	for i := lineStart; i < off; {
+		r, size := utf8.DecodeRune(u.Data[i:])
+		if i+size > off {
+			return UTF16Position{}, fmt.Errorf("offset %d splits a UTF-8 sequence", off)
+		}
+		units += utf16Len(r)
+		i += size
+	}
+	return UTF16Position{Line: p.Line, Column: units}, nil
+}
+
+// UTF16ToOffset converts a UTF-16 position into a byte offset.
+func (u *TranslationUnit) UTF16ToOffset(p UTF16Position) (int, error) {
+	start, err := u.PositionToOffset(Position{Line: p.Line, Column: 0})
+	if err != nil {
+		return 0, err
+	}
+
+	off := start
+	var units uint
+	for units < p.Column {
+		if off >= len(u.Data) || u.Data[off] == '\n' {
+			return 0, fmt.Errorf("utf-16 column %d past the end of line %d", p.Column, p.Line)
+		}
+		r, size := utf8.DecodeRune(u.Data[off:])
+		units += utf16Len(r)
+		off += size
+	}
+	if units > p.Column {
+		return 0, fmt.Errorf("utf-16 column %d splits a surrogate pair on line %d", p.Column, p.Line)
+	}
+	return off, nil
+}
+
+// utf16Len is the number of UTF-16 code units needed to encode r.
+func utf16Len(r rune) uint {
+	if r >= 0x10000 {
+		return 2 // surrogate pair
+	}
+	return 1
+}
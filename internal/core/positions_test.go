@@ -0,0 +1,107 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOffsetPositionRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	// Mixed ASCII, a two-byte rune (é), and a four-byte rune (😀).
+	unit := &TranslationUnit{Data: []byte("ab\ncafé\n😀 x\n")}
+
+	for off := 0; off <= len(unit.Data); off++ {
+		p, err := unit.OffsetToPosition(off)
+		if err != nil {
+			t.Fatalf("OffsetToPosition(%d): %v", off, err)
+		}
+		back, err := unit.PositionToOffset(p)
+		if err != nil {
+			t.Fatalf("PositionToOffset(%+v): %v", p, err)
+		}
+		if back != off {
+			t.Errorf("round trip %d -> %+v -> %d", off, p, back)
+		}
+	}
+}
+
+func TestOffsetToPosition_KnownValues(t *testing.T) {
+	t.Parallel()
+
+	unit := &TranslationUnit{Data: []byte("ab\ncd\n")}
+
+	cases := []struct {
+		off  int
+		want Position
+	}{
+		{0, Position{0, 0}},
+		{2, Position{0, 2}}, // the newline itself
+		{3, Position{1, 0}},
+		{5, Position{1, 2}},
+		{6, Position{2, 0}}, // end of file starts an empty final line
+	}
+	for _, tc := range cases {
+		got, err := unit.OffsetToPosition(tc.off)
+		if err != nil {
+			t.Fatalf("OffsetToPosition(%d): %v", tc.off, err)
+		}
+		if got != tc.want {
+			t.Errorf("OffsetToPosition(%d) = %+v, want %+v", tc.off, got, tc.want)
+		}
+	}
+
+	if _, err := unit.OffsetToPosition(-1); err == nil {
+		t.Errorf("expected error for negative offset")
+	}
+	if _, err := unit.OffsetToPosition(7); err == nil {
+		t.Errorf("expected error past end of data")
+	}
+}
+
+func TestPositionToOffset_RejectsPastLineEnd(t *testing.T) {
+	t.Parallel()
+
+	unit := &TranslationUnit{Data: []byte("ab\ncd\n")}
+	if _, err := unit.PositionToOffset(Position{Line: 0, Column: 3}); err == nil {
+		t.Errorf("expected error for column crossing into next line")
+	}
+	if _, err := unit.PositionToOffset(Position{Line: 9, Column: 0}); err == nil {
+		t.Errorf("expected error for line out of bounds")
+	}
+}
+
+func TestUTF16Conversions(t *testing.T) {
+	t.Parallel()
+
+	// "😀" is 4 bytes and 2 UTF-16 units; "é" is 2 bytes and 1 unit.
+	data := "😀é x\n"
+	unit := &TranslationUnit{Data: []byte(data)}
+
+	xOff := strings.Index(data, "x")
+	got, err := unit.OffsetToUTF16(xOff)
+	if err != nil {
+		t.Fatalf("OffsetToUTF16(%d): %v", xOff, err)
+	}
+	if want := (UTF16Position{Line: 0, Column: 4}); got != want {
+		t.Errorf("OffsetToUTF16(%d) = %+v, want %+v", xOff, got, want)
+	}
+
+	back, err := unit.UTF16ToOffset(got)
+	if err != nil {
+		t.Fatalf("UTF16ToOffset(%+v): %v", got, err)
+	}
+	if back != xOff {
+		t.Errorf("UTF16ToOffset(%+v) = %d, want %d", got, back, xOff)
+	}
+
+	// Column 1 lands in the middle of the emoji's surrogate pair.
+	if _, err := unit.UTF16ToOffset(UTF16Position{Line: 0, Column: 1}); err == nil {
+		t.Errorf("expected error for column inside a surrogate pair")
+	}
+
+	// An offset inside the emoji's UTF-8 bytes is not a valid boundary.
+	if _, err := unit.OffsetToUTF16(2); err == nil {
+		t.Errorf("expected error for offset splitting a UTF-8 sequence")
+	}
+}
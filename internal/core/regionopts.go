@@ -0,0 +1,120 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// regionOptsPrefix opens a per-region annotation, shebang-style: the opener
+// of `#!timeout=5s 2+2` or the first line of a ```#!timeout=30s, shared```
+// block.
+const regionOptsPrefix = "#!"
+
+// RegionOptions carries the annotations parsed off a single region, so a
+// heavy snippet can ask for a longer timeout without a global flag.
+type RegionOptions struct {
+	// Timeout bounds this region's evaluation; 0 means the file's budget.
+	Timeout time.Duration
+
+	// Raw holds every annotation key (value "" for bare flags like
+	// "shared"), handed to evaluators that implement RegionOptionReceiver
+	// so evaluator-specific knobs don't need core changes.
+	Raw map[string]string
+}
+
+// RegionOptionReceiver is implemented by evaluators that want the raw
+// annotations of the region about to be evaluated. Core calls it with the
+// region's options before Eval and with nil afterwards.
+type RegionOptionReceiver interface {
+	SetRegionOptions(opts map[string]string)
+}
+
+// parseRegionOpts splits a leading #! annotation off a region's content.
+// For statements the annotation runs to the first whitespace
+// (`#!timeout=5s 2+2`); for blocks it is the whole first line, so options
+// can be comma-spaced. Returns the content unchanged when there is no
+// annotation.
+func parseRegionOpts(data []byte, block bool) (RegionOptions, []byte, error) {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if !bytes.HasPrefix(trimmed, []byte(regionOptsPrefix)) {
+		return RegionOptions{}, data, nil
+	}
+	spec := trimmed[len(regionOptsPrefix):]
+
+	var rest []byte
+	if block {
+		if i := bytes.IndexByte(spec, '\n'); i >= 0 {
+			rest = spec[i+1:]
+			spec = spec[:i]
+		} else {
+			rest = nil
+		}
+	} else {
+		if i := bytes.IndexAny(spec, " \t"); i >= 0 {
+			rest = spec[i+1:]
+			spec = spec[:i]
+		} else {
+			rest = nil
+		}
+	}
+
+	opts, err := parseRegionOptSpec(string(spec))
+	if err != nil {
+		return RegionOptions{}, nil, err
+	}
+	return opts, rest, nil
+}
+
+// shouldChomp decides whether a block's trailing newline is stripped: the
+// region's chomp / no-chomp annotation wins, otherwise ChompBlocks applies.
+func (t *Translator) shouldChomp(opts RegionOptions) bool {
+	if _, ok := opts.Raw["chomp"]; ok {
+		return true
+	}
+	if _, ok := opts.Raw["no-chomp"]; ok {
+		return false
+	}
+	return t.ChompBlocks
+}
+
+// chompTrailingNewline removes at most one trailing newline (\n or \r\n).
+func chompTrailingNewline(out []byte) []byte {
+	if len(out) > 0 && out[len(out)-1] == '\n' {
+		out = out[:len(out)-1]
+		if len(out) > 0 && out[len(out)-1] == '\r' {
+			out = out[:len(out)-1]
+		}
+	}
+	return out
+}
+
+// parseRegionOptSpec parses the comma-separated key[=value] list of an
+// annotation.
+func parseRegionOptSpec(spec string) (RegionOptions, error) {
+	var opts RegionOptions
+	opts.Raw = make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, val, _ := strings.Cut(entry, "=")
+		if key == "" {
+			return RegionOptions{}, fmt.Errorf("malformed region option %q", entry)
+		}
+		if key == "timeout" {
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return RegionOptions{}, fmt.Errorf("region option timeout=%q: %w", val, err)
+			}
+			opts.Timeout = d
+		}
+		opts.Raw[key] = val
+	}
+	if len(opts.Raw) == 0 {
+		return RegionOptions{}, fmt.Errorf("empty region annotation")
+	}
+	return opts, nil
+}
@@ -0,0 +1,226 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRegionOpts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		in          string
+		block       bool
+		wantTimeout time.Duration
+		wantRaw     map[string]string
+		wantRest    string
+		wantErr     string
+	}{
+		{
+			name:        "statement_timeout",
+			in:          "#!timeout=5s 2+2",
+			wantTimeout: 5 * time.Second,
+			wantRaw:     map[string]string{"timeout": "5s"},
+			wantRest:    "2+2",
+		},
+		{
+			name:     "statement_bare_flag",
+			in:       "#!shared expr",
+			wantRaw:  map[string]string{"shared": ""},
+			wantRest: "expr",
+		},
+		{
+			name:        "block_first_line_comma_spaced",
+			in:          "#!timeout=30s, shared\nprint('x')\n",
+			block:       true,
+			wantTimeout: 30 * time.Second,
+			wantRaw:     map[string]string{"timeout": "30s", "shared": ""},
+			wantRest:    "print('x')\n",
+		},
+		{
+			name:     "no_annotation_passthrough",
+			in:       "1+2",
+			wantRest: "1+2",
+		},
+		{
+			name:    "bad_duration",
+			in:      "#!timeout=fast x",
+			wantErr: "timeout",
+		},
+		{
+			name:    "empty_annotation",
+			in:      "#! x",
+			wantErr: "empty region annotation",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts, rest, err := parseRegionOpts([]byte(tc.in), tc.block)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("error = %v, want it to mention %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRegionOpts: %v", err)
+			}
+			if opts.Timeout != tc.wantTimeout {
+				t.Errorf("Timeout = %v, want %v", opts.Timeout, tc.wantTimeout)
+			}
+			if string(rest) != tc.wantRest {
+				t.Errorf("rest = %q, want %q", rest, tc.wantRest)
+			}
+			if len(opts.Raw) != len(tc.wantRaw) {
+				t.Fatalf("Raw = %v, want %v", opts.Raw, tc.wantRaw)
+			}
+			for k, v := range tc.wantRaw {
+				if got, ok := opts.Raw[k]; !ok || got != v {
+					t.Errorf("Raw[%q] = %q (present=%v), want %q", k, got, ok, v)
+				}
+			}
+		})
+	}
+}
+
+func TestTranslateUnit_TimeoutAnnotationCancelsSlowRegion(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("int x = `#!timeout=20ms slow()`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	py := fakePythonEvaluator{eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return []byte("too late"), nil
+		}
+	}}
+	_, err = TranslateUnit(t.Context(), unit, py)
+	if err == nil {
+		t.Fatalf("expected a timeout error")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+// optsRecordingEval remembers the region options core handed it.
+type optsRecordingEval struct {
+	fakePythonEvaluator
+	got     []map[string]string
+	current map[string]string
+}
+
+func (e *optsRecordingEval) SetRegionOptions(opts map[string]string) {
+	e.current = opts
+	e.got = append(e.got, opts)
+}
+
+func TestTranslateUnit_AnnotationsReachTheEvaluator(t *testing.T) {
+	t.Parallel()
+
+	py := &optsRecordingEval{}
+	py.eval = func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		if _, ok := py.current["shared"]; ok {
+			return []byte("shared"), nil
+		}
+		return []byte("isolated"), nil
+	}
+
+	unit, err := ParseBytes([]byte("a `#!shared x` b `y` c\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	out, err := TranslateUnit(t.Context(), unit, py)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if string(out) != "a shared b isolated c\n" {
+		t.Errorf("output = %q", out)
+	}
+	// Annotated region: options set, then cleared. Unannotated: untouched.
+	if len(py.got) != 2 || py.got[0] == nil || py.got[1] != nil {
+		t.Errorf("SetRegionOptions calls = %v, want set-then-clear for the annotated region only", py.got)
+	}
+	if py.got[0]["shared"] != "" {
+		t.Errorf("shared flag = %q, want bare flag", py.got[0]["shared"])
+	}
+}
+
+func TestTranslateUnit_ChompStripsBlockTrailingNewline(t *testing.T) {
+	t.Parallel()
+
+	py := fakePythonEvaluator{eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		return []byte("line1\nline2\n"), nil
+	}}
+
+	in := "a\n```print('x')```\nb\n"
+	unit, err := ParseBytes([]byte(in))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	// Without chomp, print's trailing newline makes a blank splice line.
+	out, err := TranslateUnit(t.Context(), unit, py)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if string(out) != "a\nline1\nline2\n\nb\n" {
+		t.Errorf("unchomped output = %q", out)
+	}
+
+	tr := &Translator{Py: py, ChompBlocks: true}
+	out, err = tr.TranslateUnit(t.Context(), unit)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if string(out) != "a\nline1\nline2\nb\n" {
+		t.Errorf("chomped output = %q", out)
+	}
+}
+
+func TestTranslateUnit_ChompAnnotationsOverrideGlobal(t *testing.T) {
+	t.Parallel()
+
+	py := fakePythonEvaluator{eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		return []byte("out\n"), nil
+	}}
+
+	// Per-region chomp without the global flag.
+	unit, err := ParseBytes([]byte("a ```#!chomp\nprint('out')``` b\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	out, err := TranslateUnit(t.Context(), unit, py)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if string(out) != "a out b\n" {
+		t.Errorf("chomp-annotated output = %q", out)
+	}
+
+	// Per-region no-chomp under the global flag.
+	unit, err = ParseBytes([]byte("a ```#!no-chomp\nprint('out')``` b\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	tr := &Translator{Py: py, ChompBlocks: true}
+	out, err = tr.TranslateUnit(t.Context(), unit)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if string(out) != "a out\n b\n" {
+		t.Errorf("no-chomp-annotated output = %q", out)
+	}
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// RegionHandler evaluates a custom region's content and returns the bytes
+// spliced into the output.
+type RegionHandler func(ctx context.Context, code []byte) ([]byte, error)
+
+// regionTypeCustomBase is where dynamically allocated region types start,
+// well clear of the built-in enum.
+const regionTypeCustomBase RegionType = 1000
+
+// customRegion is one registered region kind.
+type customRegion struct {
+	name    string
+	typ     RegionType
+	prefix  []byte // the in-template marker, e.g. "sql!"
+	handler RegionHandler
+}
+
+// registry holds the process-wide custom region kinds. The parser and
+// translator consult it under a read lock, so registration is cheap to
+// check on the hot path.
+var registry struct {
+	mu      sync.RWMutex
+	byType  map[RegionType]*customRegion
+	entries []*customRegion
+	next    RegionType
+}
+
+// RegisterRegionType adds a custom region kind for embedders: regions
+// written as `prefix ...` (or ${prefix ...} with DollarInterp) route their
+// content to handler instead of the python evaluator, the same way the
+// built-in `java!` and `sh!` prefixes select JShell and the shell. prefix
+// must end in '!' so custom regions are visually distinct from python
+// expressions; name labels the kind in events and errors. The returned
+// RegionType identifies the kind in parsed Regions. Registration is
+// process-wide and typically happens during init.
+func RegisterRegionType(name, prefix string, handler RegionHandler) (RegionType, error) {
+	if name == "" {
+		return 0, fmt.Errorf("custom region type needs a name")
+	}
+	if len(prefix) < 2 || prefix[len(prefix)-1] != '!' {
+		return 0, fmt.Errorf("custom region prefix %q must end in '!'", prefix)
+	}
+	if handler == nil {
+		return 0, fmt.Errorf("custom region type %q needs a handler", name)
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	for _, cr := range registry.entries {
+		if cr.name == name {
+			return 0, fmt.Errorf("region type %q is already registered", name)
+		}
+		if string(cr.prefix) == prefix {
+			return 0, fmt.Errorf("region prefix %q is already registered to %q", prefix, cr.name)
+		}
+	}
+	if prefix == jshellPrefix || prefix == shellPrefix {
+		return 0, fmt.Errorf("region prefix %q is reserved", prefix)
+	}
+
+	if registry.byType == nil {
+		registry.byType = make(map[RegionType]*customRegion)
+		registry.next = regionTypeCustomBase
+	}
+	cr := &customRegion{name: name, typ: registry.next, prefix: []byte(prefix), handler: handler}
+	registry.next++
+	registry.byType[cr.typ] = cr
+	registry.entries = append(registry.entries, cr)
+	return cr.typ, nil
+}
+
+// customRegionByPrefix matches a region's content against the registered
+// prefixes, returning the kind and the prefix length to strip.
+func customRegionByPrefix(content []byte) (*customRegion, int, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	for _, cr := range registry.entries {
+		if bytes.HasPrefix(content, cr.prefix) {
+			return cr, len(cr.prefix), true
+		}
+	}
+	return nil, 0, false
+}
+
+// customRegionByType looks up a registered kind by its allocated type.
+func customRegionByType(typ RegionType) (*customRegion, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	cr, ok := registry.byType[typ]
+	return cr, ok
+}
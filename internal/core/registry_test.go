@@ -0,0 +1,137 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRegisterRegionType_HandlerReceivesContentAndSplices(t *testing.T) {
+	t.Parallel()
+
+	typ, err := RegisterRegionType("sql", "sql!", func(ctx context.Context, code []byte) ([]byte, error) {
+		return []byte("/* " + strings.TrimSpace(string(code)) + " */"), nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterRegionType: %v", err)
+	}
+
+	unit, err := ParseBytes([]byte("String q = `sql! SELECT 1`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	var found bool
+	for _, r := range unit.Regions {
+		if r.Type == typ {
+			found = true
+			if string(r.Data) != " SELECT 1" {
+				t.Errorf("region data = %q, want the prefix stripped", r.Data)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("no region of the registered type; regions: %+v", unit.Regions)
+	}
+
+	out, err := TranslateUnit(t.Context(), unit, fakePythonEvaluator{eval: upperEval})
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if string(out) != "String q = /* SELECT 1 */;\n" {
+		t.Errorf("output = %q", out)
+	}
+}
+
+func TestRegisterRegionType_HandlerErrorCarriesRegion(t *testing.T) {
+	t.Parallel()
+
+	_, err := RegisterRegionType("proto", "proto!", func(ctx context.Context, code []byte) ([]byte, error) {
+		return nil, errors.New("bad descriptor")
+	})
+	if err != nil {
+		t.Fatalf("RegisterRegionType: %v", err)
+	}
+
+	unit, err := ParseBytes([]byte("class A {\n  byte[] d = `proto! Foo`;\n}\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	_, err = TranslateUnit(t.Context(), unit, fakePythonEvaluator{eval: upperEval})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	var te *TranslationError
+	if !errors.As(err, &te) {
+		t.Fatalf("error should be a *TranslationError, got %T: %v", err, err)
+	}
+	if te.Region.Start.Line != 1 {
+		t.Errorf("Region.Start.Line = %d, want 1", te.Region.Start.Line)
+	}
+	if !strings.Contains(err.Error(), "proto statement") || !strings.Contains(err.Error(), "bad descriptor") {
+		t.Errorf("error %q should name the custom kind and the cause", err)
+	}
+}
+
+func TestRegisterRegionType_Validation(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, code []byte) ([]byte, error) { return code, nil }
+
+	if _, err := RegisterRegionType("", "x!", handler); err == nil {
+		t.Errorf("empty name should be rejected")
+	}
+	if _, err := RegisterRegionType("noBang", "nobang", handler); err == nil {
+		t.Errorf("prefix without '!' should be rejected")
+	}
+	if _, err := RegisterRegionType("nil-handler", "nilh!", nil); err == nil {
+		t.Errorf("nil handler should be rejected")
+	}
+	if _, err := RegisterRegionType("reserved", "sh!", handler); err == nil {
+		t.Errorf("reserved prefix should be rejected")
+	}
+
+	if _, err := RegisterRegionType("dup", "dup!", handler); err != nil {
+		t.Fatalf("RegisterRegionType: %v", err)
+	}
+	if _, err := RegisterRegionType("dup", "dup2!", handler); err == nil {
+		t.Errorf("duplicate name should be rejected")
+	}
+	if _, err := RegisterRegionType("dup2", "dup!", handler); err == nil {
+		t.Errorf("duplicate prefix should be rejected")
+	}
+}
+
+func TestRegisterRegionType_DollarInterpRoutesCustomPrefix(t *testing.T) {
+	t.Parallel()
+
+	typ, err := RegisterRegionType("tmpl", "tmpl!", func(ctx context.Context, code []byte) ([]byte, error) {
+		return []byte(fmt.Sprintf("<%s>", strings.TrimSpace(string(code)))), nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterRegionType: %v", err)
+	}
+
+	unit, err := ParseBytesWithOptions([]byte("a ${tmpl! x} b\n"), ParseOptions{DollarInterp: true})
+	if err != nil {
+		t.Fatalf("ParseBytesWithOptions: %v", err)
+	}
+	var found bool
+	for _, r := range unit.Regions {
+		if r.Type == typ {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("no region of the registered type; regions: %+v", unit.Regions)
+	}
+
+	out, err := TranslateUnit(t.Context(), unit, fakePythonEvaluator{eval: upperEval})
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if string(out) != "a <x> b\n" {
+		t.Errorf("output = %q", out)
+	}
+}
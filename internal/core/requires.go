@@ -0,0 +1,122 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// flushRequiredImports drains the java imports snippets required via
+// japaya.require_import (when Py implements RequiredImportReporter) and, with
+// ManageImports on, inserts any that are missing into the output's import
+// block. With the option off the requirements are dropped with a warning, so
+// a template written for managed imports doesn't silently lose them.
+func (t *Translator) flushRequiredImports(out []byte, sm *SourceMap, path string) []byte {
+	rr, ok := t.Py.(RequiredImportReporter)
+	if !ok {
+		return out
+	}
+	required := rr.TakeRequiredImports()
+	if len(required) == 0 {
+		return out
+	}
+	if !t.ManageImports {
+		t.warn(path, Region{}, "require-import-dropped",
+			fmt.Sprintf("snippets required %d import(s) via japaya.require_import but import management is off; enable -manage-imports", len(required)))
+		return out
+	}
+
+	inserted, positions := insertRequiredImports(out, required)
+	// Positions come back ascending in pre-insertion numbering; apply them
+	// in reverse so earlier ones stay valid.
+	for i := len(positions) - 1; i >= 0; i-- {
+		sm.noteInsertedLine(positions[i])
+	}
+	return inserted
+}
+
+// insertRequiredImports inserts `import X;` lines for the given fully
+// qualified names into src's import block: after the last existing top-level
+// import, or failing that after the package statement, or at the very top.
+// Names already imported are skipped and the inserted lines are sorted;
+// existing imports are left exactly where they are so source maps only shift
+// at the insertion point.
+//
+// Returns the rewritten source plus the line numbers (in src's original
+// numbering) each new line was inserted before, in ascending order, so the
+// caller can fix up source maps.
+func insertRequiredImports(src []byte, fqns []string) ([]byte, []uint) {
+	lines := bytes.Split(src, []byte("\n"))
+
+	var (
+		depth          int
+		inBlock        bool
+		packageLine    = -1
+		lastImportLine = -1
+	)
+	existing := make(map[string]bool)
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(string(line))
+
+		if depth == 0 && !inBlock {
+			if strings.HasPrefix(trimmed, "package ") {
+				packageLine = i
+			}
+			if rest, ok := strings.CutPrefix(trimmed, "import "); ok {
+				existing[strings.TrimSpace(strings.TrimSuffix(rest, ";"))] = true
+				lastImportLine = i
+			}
+		}
+		depth, inBlock = scanBraces(string(line), depth, inBlock)
+	}
+
+	var missing []string
+	seen := make(map[string]bool, len(fqns))
+	for _, fqn := range fqns {
+		if existing[fqn] || seen[fqn] {
+			continue
+		}
+		seen[fqn] = true
+		missing = append(missing, fqn)
+	}
+	if len(missing) == 0 {
+		return src, nil
+	}
+	sort.Strings(missing)
+
+	at := 0
+	switch {
+	case lastImportLine >= 0:
+		at = lastImportLine + 1
+	case packageLine >= 0:
+		at = packageLine + 1
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(src) + len(missing)*32)
+	var positions []uint
+	for i, line := range lines {
+		if i == at {
+			for _, fqn := range missing {
+				out.WriteString("import " + fqn + ";")
+				out.WriteByte('\n')
+				positions = append(positions, uint(i))
+			}
+		}
+		out.Write(line)
+		if i < len(lines)-1 {
+			out.WriteByte('\n')
+		}
+	}
+	// A file ending in its import block without a trailing newline puts the
+	// insertion point one past the last line; append there.
+	if at >= len(lines) {
+		for _, fqn := range missing {
+			out.WriteString("\nimport " + fqn + ";")
+			positions = append(positions, uint(len(lines)))
+		}
+	}
+	return out.Bytes(), positions
+}
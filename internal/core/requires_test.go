@@ -0,0 +1,109 @@
+package core
+
+import (
+	"context"
+	"testing"
+)
+
+// requireEval is a fake evaluator whose snippets "require" the queued java
+// imports alongside their output.
+type requireEval struct {
+	fakePythonEvaluator
+	pending []string
+}
+
+func (e *requireEval) TakeRequiredImports() []string {
+	out := e.pending
+	e.pending = nil
+	return out
+}
+
+func TestTranslateUnit_RequiredImportsInserted(t *testing.T) {
+	t.Parallel()
+
+	in := "package demo;\n" +
+		"import java.util.Set;\n" +
+		"class A {\n" +
+		"  `field()`\n" +
+		"}\n"
+	unit, err := ParseBytes([]byte(in))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	// Requirements arrive unsorted with a duplicate and one import the file
+	// already has; only the missing ones are inserted, sorted.
+	py := &requireEval{}
+	py.eval = func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		py.pending = append(py.pending,
+			"java.util.Map", "java.util.List", "java.util.List", "java.util.Set")
+		return []byte("int f;"), nil
+	}
+
+	tr := &Translator{Py: py, ManageImports: true}
+	out, err := tr.TranslateUnit(t.Context(), unit)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	want := "package demo;\n" +
+		"import java.util.Set;\n" +
+		"import java.util.List;\n" +
+		"import java.util.Map;\n" +
+		"class A {\n" +
+		"  int f;\n" +
+		"}\n"
+	if string(out) != want {
+		t.Errorf("output:\n got: %q\nwant: %q", out, want)
+	}
+}
+
+func TestTranslateUnit_RequiredImportsWithoutPackage(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("class A {\n  `field()`\n}\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	py := &requireEval{}
+	py.eval = func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		py.pending = append(py.pending, "java.util.List")
+		return []byte("int f;"), nil
+	}
+
+	tr := &Translator{Py: py, ManageImports: true}
+	out, err := tr.TranslateUnit(t.Context(), unit)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	want := "import java.util.List;\nclass A {\n  int f;\n}\n"
+	if string(out) != want {
+		t.Errorf("output:\n got: %q\nwant: %q", out, want)
+	}
+}
+
+func TestTranslateUnit_RequiredImportsDroppedWhenUnmanaged(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("int x = `v`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	py := &requireEval{}
+	py.eval = func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		py.pending = append(py.pending, "java.util.List")
+		return []byte("1"), nil
+	}
+
+	tr := &Translator{Py: py, Warnings: NewWarnings()}
+	out, err := tr.TranslateUnit(t.Context(), unit)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if string(out) != "int x = 1;\n" {
+		t.Errorf("output = %q", out)
+	}
+	warnings := tr.Warnings.All()
+	if len(warnings) != 1 || warnings[0].Rule != "require-import-dropped" {
+		t.Fatalf("warnings = %+v, want one require-import-dropped", warnings)
+	}
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// writeResources drains the resources snippets emitted while translating
+// inPath and writes them under ResourcesDir, so a template can keep its
+// generated code and its generated resource bundle in sync. With no
+// ResourcesDir configured the resources are dropped with a warning; verify
+// mode drains without writing so stale state can't leak into the next file.
+func (t *Translator) writeResources(inPath string) error {
+	rr, ok := t.Py.(ResourceReporter)
+	if !ok {
+		return nil
+	}
+	resources := rr.TakeResources()
+	if len(resources) == 0 || t.Verify {
+		return nil
+	}
+	if t.ResourcesDir == "" {
+		for _, res := range resources {
+			t.warn(inPath, Region{}, "resource-discarded",
+				fmt.Sprintf("emitted resource %q discarded; set a resources output root to keep it", res.Path))
+		}
+		return nil
+	}
+	for _, res := range resources {
+		target, err := resolveResourcePath(t.ResourcesDir, res.Path)
+		if err != nil {
+			return fmt.Errorf("resource from %q: %w", inPath, err)
+		}
+		if err := atomicWriteFile(target, res.Data, 0o644); err != nil {
+			return fmt.Errorf("write resource %q: %w", res.Path, err)
+		}
+		t.emit(Event{Type: "resource_written", Path: inPath, OutPath: target})
+	}
+	return nil
+}
+
+// resolveResourcePath joins a snippet-supplied resource path onto the
+// resources root, rejecting absolute paths and any that climb out of it.
+func resolveResourcePath(root, path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("resource path %q is absolute", path)
+	}
+	clean := filepath.Clean(filepath.FromSlash(path))
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("resource path %q escapes the resources root", path)
+	}
+	return filepath.Join(root, clean), nil
+}
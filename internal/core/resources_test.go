@@ -0,0 +1,96 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// resourceEval is a fake evaluator whose snippets "emit" the queued
+// resources alongside their output.
+type resourceEval struct {
+	fakePythonEvaluator
+	pending []Resource
+}
+
+func (e *resourceEval) TakeResources() []Resource {
+	out := e.pending
+	e.pending = nil
+	return out
+}
+
+func TestTranslateFile_WritesEmittedResources(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "Msg.japaya")
+	writeFile(t, in, "class Msg { String s = `greeting()`; }\n")
+	resDir := filepath.Join(dir, "resources")
+
+	py := &resourceEval{}
+	py.eval = func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		py.pending = append(py.pending, Resource{
+			Path: "i18n/messages.properties",
+			Data: []byte("greeting=hi\n"),
+		})
+		return []byte(`"hi"`), nil
+	}
+
+	tr := &Translator{Py: py, ResourcesDir: resDir}
+	if err := tr.TranslateFile(t.Context(), in, filepath.Join(dir, "Msg.java")); err != nil {
+		t.Fatalf("TranslateFile: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(resDir, "i18n", "messages.properties"))
+	if err != nil {
+		t.Fatalf("resource file: %v", err)
+	}
+	if string(got) != "greeting=hi\n" {
+		t.Errorf("resource contents = %q", got)
+	}
+}
+
+func TestTranslateFile_ResourceEscapingRootFails(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "Bad.japaya")
+	writeFile(t, in, "int x = `v`;\n")
+
+	py := &resourceEval{}
+	py.eval = func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		py.pending = append(py.pending, Resource{Path: "../outside.txt", Data: []byte("nope")})
+		return []byte("1"), nil
+	}
+
+	tr := &Translator{Py: py, ResourcesDir: filepath.Join(dir, "resources")}
+	err := tr.TranslateFile(t.Context(), in, filepath.Join(dir, "Bad.java"))
+	if err == nil || !strings.Contains(err.Error(), "escapes the resources root") {
+		t.Fatalf("err = %v, want a path escape rejection", err)
+	}
+}
+
+func TestTranslateFile_ResourcesWithoutRootWarn(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "Drop.japaya")
+	writeFile(t, in, "int x = `v`;\n")
+
+	py := &resourceEval{}
+	py.eval = func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		py.pending = append(py.pending, Resource{Path: "dropped.txt", Data: []byte("x")})
+		return []byte("1"), nil
+	}
+
+	tr := &Translator{Py: py, Warnings: NewWarnings()}
+	if err := tr.TranslateFile(t.Context(), in, filepath.Join(dir, "Drop.java")); err != nil {
+		t.Fatalf("TranslateFile: %v", err)
+	}
+	warnings := tr.Warnings.All()
+	if len(warnings) != 1 || warnings[0].Rule != "resource-discarded" {
+		t.Fatalf("warnings = %+v, want one resource-discarded", warnings)
+	}
+}
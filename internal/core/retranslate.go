@@ -0,0 +1,157 @@
+package core
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// A RegionOutputCache remembers each region's evaluated output from a file's
+// previous translation. Daemon and watch loops set one on the Translator so
+// that re-translating a file after an edit re-runs only the snippets whose
+// text changed; unchanged regions splice their recorded output into place —
+// a large latency win for files with many expensive blocks.
+//
+// Entries are keyed by the region's exact text, so editing the java around
+// a region (or moving the region) still hits. Only plain python regions
+// cache: directives, jshell and shell regions, and any snippet that touches
+// the japaya API always re-run, since their output can depend on more than
+// the region's text. Helper imports a cached region reported are replayed
+// into the manifest so python-dir invalidation keeps working; watch mode
+// clears the whole cache when a helper module changes so dependent snippets
+// re-run.
+//
+// One deliberate gap: a cached region's snippet does not re-run, so python
+// state it would have redefined is whatever the last evaluation left in the
+// worker. That is the nature of partial re-translation; one-shot builds
+// should leave the cache nil.
+type RegionOutputCache struct {
+	mu      sync.Mutex
+	files   map[string]map[string]cachedRegion
+	used    map[string]map[string]bool // keys touched since the last sweep
+	pending []string                   // imports to replay for the current file
+}
+
+// cachedRegion is one region's recorded evaluation.
+type cachedRegion struct {
+	out     []byte
+	imports []string
+}
+
+// NewRegionOutputCache returns an empty cache ready to set on a Translator.
+func NewRegionOutputCache() *RegionOutputCache {
+	return &RegionOutputCache{
+		files: map[string]map[string]cachedRegion{},
+		used:  map[string]map[string]bool{},
+	}
+}
+
+// Clear drops every cached entry, forcing the next translation to re-run
+// all snippets. Watch mode clears when a python-dir helper changes, since
+// any cached region may have called into it.
+func (c *RegionOutputCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files = map[string]map[string]cachedRegion{}
+	c.used = map[string]map[string]bool{}
+	c.pending = nil
+}
+
+// regionCacheKey identifies a region by type and exact text.
+func regionCacheKey(r Region) string {
+	return regionTypeString(r.Type) + "\x00" + string(r.Data)
+}
+
+// lookup returns the recorded output for the region, replaying its imports
+// into the pending set on a hit.
+func (c *RegionOutputCache) lookup(path string, r Region) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := regionCacheKey(r)
+	entry, ok := c.files[path][key]
+	if !ok {
+		return nil, false
+	}
+	c.markUsed(path, key)
+	c.pending = append(c.pending, entry.imports...)
+	return entry.out, true
+}
+
+// store records a freshly evaluated region's output and the imports it
+// reported.
+func (c *RegionOutputCache) store(path string, r Region, out []byte, imports []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := regionCacheKey(r)
+	if c.files[path] == nil {
+		c.files[path] = map[string]cachedRegion{}
+	}
+	c.files[path][key] = cachedRegion{out: append([]byte(nil), out...), imports: imports}
+	c.markUsed(path, key)
+	c.pending = append(c.pending, imports...)
+}
+
+// markUsed marks a key live for the next sweep. Callers hold mu.
+func (c *RegionOutputCache) markUsed(path, key string) {
+	if c.used[path] == nil {
+		c.used[path] = map[string]bool{}
+	}
+	c.used[path][key] = true
+}
+
+// takePending merges the imports replayed or captured since the file's
+// translation began into the evaluator-reported set, and drops cache entries
+// the translation no longer touched so edited-away regions don't accumulate.
+func (c *RegionOutputCache) takePending(path string, imports []string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen := map[string]bool{}
+	var merged []string
+	for _, imp := range append(imports, c.pending...) {
+		if !seen[imp] {
+			seen[imp] = true
+			merged = append(merged, imp)
+		}
+	}
+	sort.Strings(merged)
+	c.pending = nil
+
+	for key := range c.files[path] {
+		if !c.used[path][key] {
+			delete(c.files[path], key)
+		}
+	}
+	delete(c.used, path)
+	return merged
+}
+
+// cacheableRegion reports whether a plain python region's output may be
+// reused across evaluations. Snippets that touch the japaya API can have
+// side effects beyond their spliced text — slots, resources, required
+// imports, skips — so they always re-run.
+func cacheableRegion(r Region) bool {
+	return !bytes.Contains(r.Data, []byte("japaya."))
+}
+
+// cachedRegionOutput returns the recorded output for the region, if the
+// translator has a cache and the region is cacheable.
+func (t *Translator) cachedRegionOutput(path string, r Region) ([]byte, bool) {
+	if t.RegionCache == nil || !cacheableRegion(r) {
+		return nil, false
+	}
+	return t.RegionCache.lookup(path, r)
+}
+
+// recordRegionOutput stores a freshly evaluated region's output, draining
+// the helper imports it reported into the cache so later hits replay them.
+func (t *Translator) recordRegionOutput(path string, r Region, out []byte) {
+	if t.RegionCache == nil || !cacheableRegion(r) {
+		return
+	}
+	var imports []string
+	if ir, ok := t.Py.(ImportReporter); ok {
+		imports = ir.TakeImports()
+	}
+	t.RegionCache.store(path, r, out, imports)
+}
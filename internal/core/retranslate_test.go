@@ -0,0 +1,137 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// importingEval reports a fixed helper import for every evaluation, like a
+// worker whose snippets import a python-dir module.
+type importingEval struct {
+	fakePythonEvaluator
+	imports []string
+}
+
+func (e *importingEval) TakeImports() []string {
+	out := e.imports
+	e.imports = nil
+	return out
+}
+
+func TestRegionCache_SecondTranslationSkipsUnchangedRegions(t *testing.T) {
+	t.Parallel()
+
+	evals := 0
+	py := fakePythonEvaluator{eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		evals++
+		return upperEval(ctx, typ, code)
+	}}
+	tr := &Translator{Py: py, RegionCache: NewRegionOutputCache()}
+
+	unit, err := ParseBytes([]byte("int a = `x`;\nint b = `y`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	out, err := tr.TranslateUnit(t.Context(), unit)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if want := "int a = <x>;\nint b = <y>;\n"; string(out) != want {
+		t.Fatalf("out = %q, want %q", out, want)
+	}
+	if evals != 2 {
+		t.Fatalf("evals = %d, want 2 on the first translation", evals)
+	}
+
+	// Unchanged regions splice from the cache without re-running.
+	out, err = tr.TranslateUnit(t.Context(), unit)
+	if err != nil {
+		t.Fatalf("TranslateUnit (again): %v", err)
+	}
+	if want := "int a = <x>;\nint b = <y>;\n"; string(out) != want {
+		t.Fatalf("re-translated out = %q, want %q", out, want)
+	}
+	if evals != 2 {
+		t.Errorf("evals = %d, want no re-evaluation of unchanged regions", evals)
+	}
+
+	// An edit to one region re-runs only that region.
+	edited, err := ParseBytes([]byte("int a = `x`;\nint b = `z`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	out, err = tr.TranslateUnit(t.Context(), edited)
+	if err != nil {
+		t.Fatalf("TranslateUnit (edited): %v", err)
+	}
+	if want := "int a = <x>;\nint b = <z>;\n"; string(out) != want {
+		t.Fatalf("edited out = %q, want %q", out, want)
+	}
+	if evals != 3 {
+		t.Errorf("evals = %d, want only the edited region re-run", evals)
+	}
+
+	// Clearing forces everything to re-run.
+	tr.RegionCache.Clear()
+	if _, err := tr.TranslateUnit(t.Context(), edited); err != nil {
+		t.Fatalf("TranslateUnit (after clear): %v", err)
+	}
+	if evals != 5 {
+		t.Errorf("evals = %d, want both regions re-run after Clear", evals)
+	}
+}
+
+func TestRegionCache_JapayaAPIRegionsAlwaysReRun(t *testing.T) {
+	t.Parallel()
+
+	evals := 0
+	py := fakePythonEvaluator{eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		evals++
+		return []byte("7"), nil
+	}}
+	tr := &Translator{Py: py, RegionCache: NewRegionOutputCache()}
+
+	unit, err := ParseBytes([]byte("int e = `japaya.epoch`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := tr.TranslateUnit(t.Context(), unit); err != nil {
+			t.Fatalf("TranslateUnit: %v", err)
+		}
+	}
+	if evals != 2 {
+		t.Errorf("evals = %d, want japaya-API regions to re-run every time", evals)
+	}
+}
+
+func TestRegionCache_ReplaysImportsIntoManifest(t *testing.T) {
+	t.Parallel()
+
+	py := &importingEval{}
+	py.eval = func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		py.imports = []string{"helper"}
+		return []byte("1"), nil
+	}
+	tr := &Translator{
+		Py:          py,
+		Manifest:    NewManifest(),
+		RegionCache: NewRegionOutputCache(),
+	}
+
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "A.japaya")
+	writeFile(t, inPath, "class A { `helper.n()` }\n")
+
+	for i := 0; i < 2; i++ {
+		if err := tr.TranslateFile(t.Context(), inPath, filepath.Join(dir, "A.java")); err != nil {
+			t.Fatalf("TranslateFile: %v", err)
+		}
+		// The second pass hits the cache; its imports must still reach the
+		// manifest or python-dir invalidation would miss this file.
+		if got := tr.Manifest.FilesImporting("helper"); len(got) != 1 || got[0] != inPath {
+			t.Fatalf("pass %d: FilesImporting(helper) = %v, want [%s]", i+1, got, inPath)
+		}
+	}
+}
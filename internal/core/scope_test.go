@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// scopingEvaluator records the snippet scope the translator hands it.
+type scopingEvaluator struct {
+	fakePythonEvaluator
+	cwd string
+	env map[string]string
+}
+
+func (s *scopingEvaluator) SetSnippetScope(cwd string, env map[string]string) {
+	s.cwd = cwd
+	s.env = env
+}
+
+func TestTranslateFile_ScopesSnippetsToInputDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "A.japaya")
+	writeFile(t, inPath, "class A { `v` }\n")
+
+	py := &scopingEvaluator{fakePythonEvaluator: fakePythonEvaluator{eval: upperEval}}
+	tr := &Translator{
+		Py:         py,
+		SnippetEnv: map[string]string{"SCHEMA_DIR": "schemas"},
+	}
+	if err := tr.TranslateFile(t.Context(), inPath, filepath.Join(dir, "A.java")); err != nil {
+		t.Fatalf("TranslateFile: %v", err)
+	}
+
+	if py.cwd != dir {
+		t.Errorf("scope cwd = %q, want input dir %q", py.cwd, dir)
+	}
+	if py.env["SCHEMA_DIR"] != "schemas" {
+		t.Errorf("scope env = %v, want SnippetEnv passed through", py.env)
+	}
+}
+
+func TestTranslateFile_NonScopingEvaluatorIsFine(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "A.japaya")
+	writeFile(t, inPath, "class A { `v` }\n")
+
+	tr := &Translator{Py: fakePythonEvaluator{eval: upperEval}}
+	if err := tr.TranslateFile(context.Background(), inPath, filepath.Join(dir, "A.java")); err != nil {
+		t.Fatalf("TranslateFile: %v", err)
+	}
+}
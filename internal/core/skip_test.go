@@ -0,0 +1,55 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTranslateFile_SkipWritesNothing(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "Flagged.japaya")
+	outPath := filepath.Join(dir, "Flagged.java")
+	writeFile(t, inPath, "```cond()```\nclass Flagged {}\n")
+
+	py := fakePythonEvaluator{eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		return nil, &FileSkippedError{Reason: "feature off"}
+	}}
+	manifest := NewManifest()
+	summary := NewRunSummary()
+	tr := &Translator{Py: py, Manifest: manifest, Summary: summary}
+
+	if err := tr.TranslateFile(t.Context(), inPath, outPath); err != nil {
+		t.Fatalf("TranslateFile: %v", err)
+	}
+	if _, err := os.Stat(outPath); err == nil {
+		t.Error("skipped file's output was written anyway")
+	}
+	if reason, ok := manifest.Skipped(inPath); !ok || reason != "feature off" {
+		t.Errorf("manifest skip = (%q, %v), want the recorded reason", reason, ok)
+	}
+	if got := summary.Report().Skipped; got != 1 {
+		t.Errorf("summary skipped = %d, want 1", got)
+	}
+}
+
+func TestTranslateFile_SuccessClearsEarlierSkip(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "Flagged.japaya")
+	writeFile(t, inPath, "class Flagged { `v` }\n")
+
+	manifest := NewManifest()
+	manifest.recordSkip(inPath, "feature off")
+	tr := &Translator{Py: fakePythonEvaluator{eval: upperEval}, Manifest: manifest}
+	if err := tr.TranslateFile(t.Context(), inPath, filepath.Join(dir, "Flagged.java")); err != nil {
+		t.Fatalf("TranslateFile: %v", err)
+	}
+	if _, ok := manifest.Skipped(inPath); ok {
+		t.Error("successful translation left the file marked skipped")
+	}
+}
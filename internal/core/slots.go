@@ -0,0 +1,91 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Marks a python block as a named-slot flush point:
+//
+//	```!slot imports```
+//
+// Snippets anywhere in the file append to the slot via japaya.slot("imports",
+// text); once every region has been evaluated the appends are spliced here,
+// joined one per line with exact duplicates dropped. That lets a region
+// generating a field also register the import it needs at the top of the
+// file. Markers are resolved by the buffered translation path only;
+// TranslateStream leaves them unexpanded.
+const slotPrefix = "!slot"
+
+// isSlotBlock reports whether a python block region carries a !slot marker.
+func isSlotBlock(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte(slotPrefix))
+}
+
+// slotMarkerName extracts the slot name from a !slot marker.
+func slotMarkerName(data []byte) (string, error) {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	directive, _, _ := bytes.Cut(trimmed, []byte("\n"))
+	name := strings.TrimSpace(strings.TrimPrefix(string(directive), slotPrefix))
+	if name == "" {
+		return "", fmt.Errorf("%s marker missing a slot name", slotPrefix)
+	}
+	return name, nil
+}
+
+// slotPlaceholder is the byte sequence spliced where a marker sat until the
+// unit's appends are flushed into it. NULs keep it from colliding with any
+// text a snippet could plausibly generate.
+func slotPlaceholder(name string) []byte {
+	return []byte("\x00japaya:slot:" + name + "\x00")
+}
+
+// flushSlots drains the evaluator's slot appends and replaces each marker
+// placeholder with its slot's content. Appends to a slot with no marker are
+// reported as warnings rather than dropped silently.
+func (t *Translator) flushSlots(out []byte, markers map[string]bool, path string) []byte {
+	var slots map[string][]string
+	if sr, ok := t.Py.(SlotReporter); ok {
+		for _, a := range sr.TakeSlots() {
+			if slots == nil {
+				slots = make(map[string][]string)
+			}
+			slots[a.Name] = append(slots[a.Name], a.Text)
+		}
+	}
+	if len(markers) == 0 && len(slots) == 0 {
+		return out
+	}
+
+	for name := range markers {
+		content := joinSlotContent(slots[name])
+		out = bytes.Replace(out, slotPlaceholder(name), []byte(content), 1)
+	}
+	for name, entries := range slots {
+		if !markers[name] {
+			t.warn(path, Region{}, "slot-unflushed",
+				fmt.Sprintf("%d append(s) to slot %q but the file has no ```%s %s``` marker", len(entries), name, slotPrefix, name))
+		}
+	}
+	return out
+}
+
+// joinSlotContent renders a slot's appends one per line, dropping exact
+// duplicates so repeated japaya.slot("imports", ...) calls from independent
+// regions don't stack the same import twice.
+func joinSlotContent(entries []string) string {
+	var b strings.Builder
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(entry)
+	}
+	return b.String()
+}
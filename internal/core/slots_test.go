@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// slotEval is a fake evaluator whose snippets "append" the queued slot
+// entries alongside their output.
+type slotEval struct {
+	fakePythonEvaluator
+	pending []SlotAppend
+}
+
+func (e *slotEval) TakeSlots() []SlotAppend {
+	out := e.pending
+	e.pending = nil
+	return out
+}
+
+func TestTranslateUnit_SlotMarkerCollectsAppends(t *testing.T) {
+	t.Parallel()
+
+	in := "```!slot imports```\n" +
+		"class A {\n" +
+		"  `field(1)`\n" +
+		"  `field(2)`\n" +
+		"}\n"
+	unit, err := ParseBytes([]byte(in))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	// Both regions register the List import; only one adds Map. The
+	// duplicate is dropped and order of first appearance is kept.
+	py := &slotEval{}
+	py.eval = func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		py.pending = append(py.pending, SlotAppend{Name: "imports", Text: "import java.util.List;"})
+		if string(code) == "field(2)" {
+			py.pending = append(py.pending, SlotAppend{Name: "imports", Text: "import java.util.Map;"})
+		}
+		return []byte("int f" + string(code[6:7]) + ";"), nil
+	}
+
+	tr := &Translator{Py: py}
+	out, err := tr.TranslateUnit(t.Context(), unit)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	want := "import java.util.List;\nimport java.util.Map;\n" +
+		"class A {\n" +
+		"  int f1;\n" +
+		"  int f2;\n" +
+		"}\n"
+	if string(out) != want {
+		t.Errorf("output:\n got: %q\nwant: %q", out, want)
+	}
+}
+
+func TestTranslateUnit_SlotWithoutMarkerWarns(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("int x = `v`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	py := &slotEval{}
+	py.eval = func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		py.pending = append(py.pending, SlotAppend{Name: "imports", Text: "import x;"})
+		return []byte("1"), nil
+	}
+
+	tr := &Translator{Py: py, Warnings: NewWarnings()}
+	out, err := tr.TranslateUnit(t.Context(), unit)
+	if err != nil {
+		t.Fatalf("TranslateUnit: %v", err)
+	}
+	if string(out) != "int x = 1;\n" {
+		t.Errorf("output = %q", out)
+	}
+	warnings := tr.Warnings.All()
+	if len(warnings) != 1 || warnings[0].Rule != "slot-unflushed" {
+		t.Fatalf("warnings = %+v, want one slot-unflushed", warnings)
+	}
+}
+
+func TestTranslateUnit_SlotMarkerNeedsName(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("```!slot```\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	_, err = TranslateUnit(t.Context(), unit, fakePythonEvaluator{eval: upperEval})
+	if err == nil || !strings.Contains(err.Error(), "missing a slot name") {
+		t.Fatalf("err = %v, want a missing-name error", err)
+	}
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"sync"
+)
+
+// SourceMap maps lines of a generated file back to lines of its input.
+// Java regions pass through line for line; every output line produced by a
+// snippet maps to the line where the snippet starts in the input.
+type SourceMap struct {
+	InPath string
+	segs   []mapSegment
+}
+
+// mapSegment covers output lines from outLine until the next segment.
+type mapSegment struct {
+	outLine  uint // first output line covered by this segment
+	inLine   uint // input line corresponding to outLine
+	oneToOne bool // java passthrough: later lines advance in both files
+}
+
+// InputLine returns the input line a generated line came from. Lines past
+// the mapped range stick to the last segment, so slightly ragged diagnostics
+// still point somewhere useful.
+func (m *SourceMap) InputLine(outLine uint) uint {
+	if m == nil || len(m.segs) == 0 {
+		return outLine
+	}
+
+	seg := m.segs[0]
+	for _, s := range m.segs[1:] {
+		if s.outLine > outLine {
+			break
+		}
+		seg = s
+	}
+	if seg.oneToOne && outLine > seg.outLine {
+		return seg.inLine + (outLine - seg.outLine)
+	}
+	return seg.inLine
+}
+
+// noteInsertedLine records that one line was inserted before output line p
+// (in pre-insertion numbering) after the map was built. Call with positions
+// in descending order so earlier positions stay valid.
+func (m *SourceMap) noteInsertedLine(p uint) {
+	if m == nil || len(m.segs) == 0 {
+		return
+	}
+
+	// The segment the insertion lands in: the last one starting at or
+	// before p.
+	idx := -1
+	for i, s := range m.segs {
+		if s.outLine > p {
+			break
+		}
+		idx = i
+	}
+
+	// A one-to-one segment split mid-way needs a continuation segment so
+	// its later lines keep their offset.
+	var cont *mapSegment
+	if idx >= 0 && m.segs[idx].oneToOne && m.segs[idx].outLine < p {
+		cont = &mapSegment{
+			outLine:  p + 1,
+			inLine:   m.segs[idx].inLine + (p - m.segs[idx].outLine),
+			oneToOne: true,
+		}
+	}
+
+	for i := range m.segs {
+		if m.segs[i].outLine >= p {
+			m.segs[i].outLine++
+		}
+	}
+
+	if cont != nil {
+		m.segs = append(m.segs, mapSegment{})
+		copy(m.segs[idx+2:], m.segs[idx+1:])
+		m.segs[idx+1] = *cont
+	}
+}
+
+// SourceMaps collects the per-file maps produced during a run, keyed by
+// output path. Configure one on a Translator to enable error mapping.
+type SourceMaps struct {
+	mu    sync.Mutex
+	byOut map[string]*SourceMap
+}
+
+// NewSourceMaps returns an empty collector ready for use.
+func NewSourceMaps() *SourceMaps {
+	return &SourceMaps{byOut: make(map[string]*SourceMap)}
+}
+
+// record stores the map for an output path, replacing any previous run's.
+func (s *SourceMaps) record(outPath string, m *SourceMap) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byOut[outPath] = m
+}
+
+// rename re-keys a recorded map, e.g. from a staging path to the final
+// output path once a tree commit lands.
+func (s *SourceMaps) rename(oldPath, newPath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m, ok := s.byOut[oldPath]; ok {
+		delete(s.byOut, oldPath)
+		s.byOut[newPath] = m
+	}
+}
+
+// Lookup returns the map for an output path, or nil if none was recorded.
+func (s *SourceMaps) Lookup(outPath string) *SourceMap {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.byOut[outPath]
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestSourceMaps_RecordedDuringTranslateFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "Main.japaya")
+	out := filepath.Join(dir, "out", "Main.java")
+
+	// The snippet on input line 1 expands to three output lines, shifting
+	// everything after it down by two.
+	writeFile(t, in, "class Main {\n```\ngen\n```\n  int x;\n}\n")
+
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, regionType RegionType, code []byte) ([]byte, error) {
+			return []byte("  int a;\n  int b;\n  int c;\n"), nil
+		},
+	}
+
+	tr := &Translator{Py: py, SourceMaps: NewSourceMaps()}
+	if err := tr.TranslateFile(context.Background(), in, out); err != nil {
+		t.Fatalf("TranslateFile: %v", err)
+	}
+
+	sm := tr.SourceMaps.Lookup(out)
+	if sm == nil {
+		t.Fatalf("no source map recorded for %q", out)
+	}
+	if sm.InPath != in {
+		t.Errorf("InPath = %q, want %q", sm.InPath, in)
+	}
+
+	cases := []struct {
+		outLine uint
+		want    uint
+	}{
+		{0, 0}, // "class Main {" passes through
+		{1, 1}, // first generated line -> snippet start
+		{2, 1}, // second generated line -> snippet start
+		{3, 1},
+		{5, 4}, // "  int x;" shifted down one line in the output
+		{6, 5}, // "}"
+	}
+	for _, tc := range cases {
+		if got := sm.InputLine(tc.outLine); got != tc.want {
+			t.Errorf("InputLine(%d) = %d, want %d", tc.outLine, got, tc.want)
+		}
+	}
+}
+
+func TestSourceMaps_TreeModeKeysByFinalPath(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inRoot := filepath.Join(dir, "in")
+	outRoot := filepath.Join(dir, "out")
+	writeFile(t, filepath.Join(inRoot, "A.japaya"), "a `x` b\n")
+
+	py := fakePythonEvaluator{eval: upperEval}
+	tr := &Translator{Py: py, SourceMaps: NewSourceMaps()}
+	if err := tr.TranslatePath(context.Background(), inRoot, outRoot); err != nil {
+		t.Fatalf("TranslatePath: %v", err)
+	}
+
+	final := filepath.Join(outRoot, "A.java")
+	if tr.SourceMaps.Lookup(final) == nil {
+		t.Fatalf("expected source map keyed by final output path %q", final)
+	}
+}
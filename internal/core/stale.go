@@ -0,0 +1,35 @@
+package core
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// warnStaleOutputs walks outRoot after a tree run and warns for every file
+// the run did not produce — typically leftovers from a renamed or deleted
+// template. Nothing is deleted; the listing is the safe first step for trees
+// adopting japaya over existing generated code.
+func (t *Translator) warnStaleOutputs(outRoot string, produced map[string]bool) {
+	_ = filepath.WalkDir(outRoot, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if name == ".git" || name == "node_modules" || name == "bin" || name == "dist" ||
+				strings.HasPrefix(name, ".japaya-stage-") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == lockFileName {
+			return nil
+		}
+		if !produced[path] {
+			t.warn(path, Region{}, "stale-output",
+				"file was not produced by this run; if its template is gone, delete it")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,33 @@
+package core
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTranslateTree_WarnStaleListsLeftovers(t *testing.T) {
+	t.Parallel()
+
+	inRoot := t.TempDir()
+	outRoot := t.TempDir()
+	writeFile(t, filepath.Join(inRoot, "Foo.japaya"), "class Foo { `v` }\n")
+	// A leftover from a template that no longer exists.
+	writeFile(t, filepath.Join(outRoot, "Old.java"), "class Old {}\n")
+
+	tr := &Translator{
+		Py:        fakePythonEvaluator{eval: upperEval},
+		Warnings:  NewWarnings(),
+		WarnStale: true,
+	}
+	if err := tr.TranslateTree(t.Context(), inRoot, outRoot); err != nil {
+		t.Fatalf("TranslateTree: %v", err)
+	}
+
+	warnings := tr.Warnings.All()
+	if len(warnings) != 1 || warnings[0].Rule != "stale-output" {
+		t.Fatalf("warnings = %+v, want one stale-output", warnings)
+	}
+	if warnings[0].Path != filepath.Join(outRoot, "Old.java") {
+		t.Errorf("stale warning path = %q, want the leftover file", warnings[0].Path)
+	}
+}
@@ -0,0 +1,219 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// TranslateStream translates from r to w using a bare Translator.
+func TranslateStream(ctx context.Context, r io.Reader, w io.Writer, py PythonEvaluator) error {
+	return (&Translator{Py: py}).TranslateStream(ctx, r, w)
+}
+
+// TranslateStream is the memory-efficient counterpart of TranslateReader:
+// java regions are copied through to w as they are scanned instead of being
+// accumulated, so peak memory stays proportional to the largest snippet and
+// its output rather than the whole file. Snippet regions are still buffered
+// (they are small by construction).
+//
+// Output may be partially written when an error occurs, so callers that need
+// all-or-nothing semantics should stream into a temp file and rename, the
+// way TranslateFile does.
+func (t *Translator) TranslateStream(ctx context.Context, r io.Reader, w io.Writer) error {
+	if r == nil {
+		return fmt.Errorf("nil reader")
+	}
+	if w == nil {
+		return fmt.Errorf("nil writer")
+	}
+	if t.Py == nil {
+		return fmt.Errorf("nil PythonEvaluator")
+	}
+
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+
+	s := &streamScanner{br: br}
+	regionIndex := 0
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if b != '`' {
+			if werr := bw.WriteByte(b); werr != nil {
+				return werr
+			}
+			s.advance(b)
+			continue
+		}
+
+		region, err := s.scanSnippet()
+		if err != nil {
+			return err
+		}
+		// ParseBytes drops empty regions; mirror that here.
+		if len(region.Data) == 0 {
+			continue
+		}
+		translated, err := t.evalRegion(ctx, *region, "", regionIndex)
+		if err != nil {
+			return err
+		}
+		regionIndex++
+		if _, werr := bw.Write(translated); werr != nil {
+			return werr
+		}
+	}
+
+	return bw.Flush()
+}
+
+// streamScanner tracks the current position and scans snippet regions off a
+// bufio.Reader. It mirrors ParseBytes' delimiter rules (see parser.go) but
+// never holds more than one snippet in memory.
+type streamScanner struct {
+	br   *bufio.Reader
+	line uint
+	col  uint
+	off  int
+}
+
+// advance updates line/col/offset for one consumed byte. Carriage returns
+// never count as a column, matching the buffered parser's accounting.
+func (s *streamScanner) advance(b byte) {
+	if b == '\n' {
+		s.line++
+		s.col = 0
+	} else if b != '\r' {
+		s.col++
+	}
+	s.off++
+}
+
+// pos returns the current position.
+func (s *streamScanner) pos() Position {
+	return Position{Line: s.line, Column: s.col}
+}
+
+// scanSnippet is called with one opening backtick already consumed (but not
+// yet counted). It reads the remainder of the snippet region, returning a
+// Region equivalent to the one ParseBytes would produce.
+func (s *streamScanner) scanSnippet() (*Region, error) {
+	openPos := s.pos()
+	openOff := s.off
+	s.advance('`')
+
+	// A second and third backtick select block form. Peek so a lone ` or ``
+	// pair keeps statement semantics.
+	isTriple := false
+	if peeked, _ := s.br.Peek(2); len(peeked) == 2 && peeked[0] == '`' && peeked[1] == '`' {
+		isTriple = true
+		for i := 0; i < 2; i++ {
+			if _, err := s.br.ReadByte(); err != nil {
+				return nil, err
+			}
+			s.advance('`')
+		}
+	}
+
+	delim := "`"
+	if isTriple {
+		delim = "```"
+	}
+
+	contentStart := s.pos()
+	contentStartOff := s.off
+	var content bytes.Buffer
+	for {
+		// A full delimiter terminates the region; consume it and stop.
+		if peeked, _ := s.br.Peek(len(delim)); len(peeked) == len(delim) && allBackticks(peeked) {
+			for i := 0; i < len(delim); i++ {
+				if _, err := s.br.ReadByte(); err != nil {
+					return nil, err
+				}
+			}
+			break
+		}
+
+		b, err := s.br.ReadByte()
+		if err == io.EOF {
+			msg := "unterminated python statement (missing closing `)"
+			if isTriple {
+				msg = "unterminated python block (missing closing ```)"
+			}
+			return nil, &ParseError{Pos: openPos, Msg: msg}
+		}
+		if err != nil {
+			return nil, err
+		}
+		content.WriteByte(b)
+		s.advance(b)
+	}
+	contentEnd := s.pos()
+	contentEndOff := s.off
+	for range delim {
+		s.advance('`')
+	}
+
+	data := content.Bytes()
+	typ := RegionTypePythonBlock
+	if !isTriple {
+		typ = RegionTypePythonStatement
+		if bytes.HasPrefix(data, []byte(jshellPrefix)) {
+			typ = RegionTypeJShellStatement
+			data = data[len(jshellPrefix):]
+			contentStart.Column += uint(len(jshellPrefix))
+			contentStartOff += len(jshellPrefix)
+		} else if bytes.HasPrefix(data, []byte(shellPrefix)) {
+			typ = RegionTypeShellStatement
+			data = data[len(shellPrefix):]
+			contentStart.Column += uint(len(shellPrefix))
+			contentStartOff += len(shellPrefix)
+		}
+	}
+
+	raw := make([]byte, 0, len(delim)*2+content.Len())
+	raw = append(raw, delim...)
+	raw = append(raw, content.Bytes()...)
+	raw = append(raw, delim...)
+
+	// Content gets the same cleanup ParseBytes applies in emit: CRLF
+	// terminators normalized, statement continuation lines joined.
+	data = normalizeNewlines(append([]byte(nil), data...))
+	if typ == RegionTypePythonStatement {
+		data = joinStatementLines(data)
+	}
+
+	return &Region{
+		Type:     typ,
+		Start:    contentStart,
+		End:      contentEnd,
+		Data:     data,
+		RawStart: openPos,
+		RawEnd:   s.pos(),
+		Raw:      raw,
+
+		StartOff:    contentStartOff,
+		EndOff:      contentEndOff,
+		RawStartOff: openOff,
+		RawEndOff:   s.off,
+	}, nil
+}
+
+// allBackticks reports whether every byte is '`'.
+func allBackticks(b []byte) bool {
+	for _, c := range b {
+		if c != '`' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// upperEval marks evaluated snippets so tests can see which bytes went
+// through the evaluator.
+func upperEval(ctx context.Context, regionType RegionType, code []byte) ([]byte, error) {
+	return append([]byte("<"), append(bytes.TrimSpace(code), '>')...), nil
+}
+
+func TestTranslateStream_MatchesTranslateReader(t *testing.T) {
+	t.Parallel()
+
+	inputs := []string{
+		"class A { }\n",
+		"int x = `expr`;\n",
+		"before\n```\nblock\n```\nafter\n",
+		"a `one` b `two` c\n",
+		"``\nempty statement dropped\n",
+		"tail has no newline `x`",
+	}
+
+	py := fakePythonEvaluator{eval: upperEval}
+
+	for _, input := range inputs {
+		want, err := TranslateReader(context.Background(), strings.NewReader(input), py)
+		if err != nil {
+			t.Fatalf("TranslateReader(%q): %v", input, err)
+		}
+
+		var got bytes.Buffer
+		if err := TranslateStream(context.Background(), strings.NewReader(input), &got, py); err != nil {
+			t.Fatalf("TranslateStream(%q): %v", input, err)
+		}
+		if !bytes.Equal(got.Bytes(), want) {
+			t.Errorf("TranslateStream(%q) = %q, want %q", input, got.Bytes(), want)
+		}
+	}
+}
+
+func TestTranslateStream_WritesJavaBeforeSnippetError(t *testing.T) {
+	t.Parallel()
+
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, regionType RegionType, code []byte) ([]byte, error) {
+			return nil, errors.New("boom")
+		},
+	}
+
+	var got bytes.Buffer
+	err := TranslateStream(context.Background(), strings.NewReader("prefix `x` suffix"), &got, py)
+	if err == nil {
+		t.Fatalf("expected evaluator error")
+	}
+	var te *TranslationError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected TranslationError, got %v", err)
+	}
+}
+
+func TestTranslateStream_UnterminatedStatement(t *testing.T) {
+	t.Parallel()
+
+	py := fakePythonEvaluator{eval: upperEval}
+
+	var got bytes.Buffer
+	err := TranslateStream(context.Background(), strings.NewReader("int x = `oops"), &got, py)
+
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// RunSummary accumulates end-of-run statistics for a tree translation: how
+// many files were translated, unchanged, copied through verbatim, skipped or
+// failed, and how much wall time python evaluation took. Configure one on the
+// Translator to enable the accounting; nil disables it.
+type RunSummary struct {
+	mu         sync.Mutex
+	translated int
+	unchanged  int
+	skipped    int
+	copied     int
+	failed     int
+	evalTime   time.Duration
+}
+
+// NewRunSummary returns an empty summary ready for use.
+func NewRunSummary() *RunSummary {
+	return &RunSummary{}
+}
+
+// SummaryReport is a point-in-time snapshot of a RunSummary, shaped for JSON
+// consumers like build dashboards.
+type SummaryReport struct {
+	Translated   int     `json:"translated"`
+	Unchanged    int     `json:"unchanged"`
+	Copied       int     `json:"copied"`
+	Skipped      int     `json:"skipped"`
+	Failed       int     `json:"failed"`
+	PythonEvalMS float64 `json:"python_eval_ms"`
+}
+
+// Report snapshots the summary's counters.
+func (s *RunSummary) Report() SummaryReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SummaryReport{
+		Translated:   s.translated,
+		Unchanged:    s.unchanged,
+		Copied:       s.copied,
+		Skipped:      s.skipped,
+		Failed:       s.failed,
+		PythonEvalMS: float64(s.evalTime) / float64(time.Millisecond),
+	}
+}
+
+// String renders the one-line human form printed at the end of a run.
+func (r SummaryReport) String() string {
+	evalTime := time.Duration(r.PythonEvalMS * float64(time.Millisecond)).Round(time.Millisecond)
+	return fmt.Sprintf("japaya: %d translated, %d unchanged, %d copied, %d skipped, %d failed; python eval %s",
+		r.Translated, r.Unchanged, r.Copied, r.Skipped, r.Failed, evalTime)
+}
+
+func (s *RunSummary) bump(counter *int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*counter++
+}
+
+func (s *RunSummary) addSkipped() { s.bump(&s.skipped) }
+func (s *RunSummary) addFailed()  { s.bump(&s.failed) }
+
+// addEvalTime accounts wall time spent evaluating python regions.
+func (s *RunSummary) addEvalTime(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evalTime += d
+}
+
+// classifyOutput buckets one successfully translated file by comparing its
+// fresh output against the input and whatever is already at the destination:
+// identical to the existing output means unchanged, identical to the input
+// means the template was copied through verbatim, anything else counts as
+// translated. Read failures fall back to translated.
+func (s *RunSummary) classifyOutput(inPath, stagedPath, finalPath string) {
+	out, err := os.ReadFile(stagedPath)
+	if err != nil {
+		s.bump(&s.translated)
+		return
+	}
+	if existing, err := os.ReadFile(finalPath); err == nil && bytes.Equal(existing, out) {
+		s.bump(&s.unchanged)
+		return
+	}
+	if in, err := os.ReadFile(inPath); err == nil && bytes.Equal(in, out) {
+		s.bump(&s.copied)
+		return
+	}
+	s.bump(&s.translated)
+}
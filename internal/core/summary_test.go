@@ -0,0 +1,62 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTranslateTree_SummaryCountsCategories(t *testing.T) {
+	t.Parallel()
+
+	inRoot := t.TempDir()
+	outRoot := filepath.Join(t.TempDir(), "out")
+
+	writeFile(t, filepath.Join(inRoot, "A.japaya"), "int x = `1+2`;\n")
+	writeFile(t, filepath.Join(inRoot, "B.java"), "public class B {}\n") // no regions: copied verbatim
+	writeFile(t, filepath.Join(inRoot, "README.md"), "hi\n")             // not translatable: skipped
+
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, regionType RegionType, code []byte) ([]byte, error) {
+			return []byte("3"), nil
+		},
+	}
+
+	sum := NewRunSummary()
+	tr := &Translator{Py: py, Summary: sum}
+	if err := tr.TranslateTree(context.Background(), inRoot, outRoot); err != nil {
+		t.Fatalf("TranslateTree: %v", err)
+	}
+
+	rep := sum.Report()
+	if rep.Translated != 1 || rep.Copied != 1 || rep.Skipped != 1 || rep.Unchanged != 0 || rep.Failed != 0 {
+		t.Fatalf("first run report = %+v", rep)
+	}
+	if rep.PythonEvalMS < 0 {
+		t.Fatalf("negative eval time: %+v", rep)
+	}
+
+	// A second identical run finds both outputs already up to date.
+	sum2 := NewRunSummary()
+	tr.Summary = sum2
+	if err := tr.TranslateTree(context.Background(), inRoot, outRoot); err != nil {
+		t.Fatalf("second TranslateTree: %v", err)
+	}
+	rep2 := sum2.Report()
+	if rep2.Unchanged != 2 || rep2.Translated != 0 || rep2.Copied != 0 {
+		t.Fatalf("second run report = %+v", rep2)
+	}
+}
+
+func TestSummaryReport_String(t *testing.T) {
+	t.Parallel()
+
+	rep := SummaryReport{Translated: 2, Unchanged: 1, Copied: 3, Skipped: 4, Failed: 1, PythonEvalMS: 1500}
+	s := rep.String()
+	for _, want := range []string{"2 translated", "1 unchanged", "3 copied", "4 skipped", "1 failed", "1.5s"} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("summary %q missing %q", s, want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// Tracer receives spans around translation work so japaya's contribution to a
+// build shows up in existing observability pipelines. StartSpan begins a span
+// and returns the func that ends it; callers always invoke the returned func
+// exactly once. Implementations must be safe for concurrent use. A nil
+// Translator.Tracer disables tracing entirely.
+//
+// Span names and attribute keys use the "japaya." prefix:
+//
+//	japaya.translate_file   japaya.path
+//	japaya.eval_region      japaya.path, japaya.region_index,
+//	                        japaya.region_kind, japaya.snippet_sha256
+type Tracer interface {
+	StartSpan(name string, attrs map[string]string) (end func())
+}
+
+// fileSpan starts the span covering one file's translation; the returned func
+// is never nil.
+func (t *Translator) fileSpan(path string) func() {
+	if t.Tracer == nil {
+		return func() {}
+	}
+	return t.Tracer.StartSpan("japaya.translate_file", map[string]string{
+		"japaya.path": path,
+	})
+}
+
+// evalSpan starts the span covering one region's evaluation. The snippet
+// hash lets dashboards aggregate repeated snippets across files without
+// shipping their source.
+func (t *Translator) evalSpan(path string, index int, r Region) func() {
+	if t.Tracer == nil {
+		return func() {}
+	}
+	sum := sha256.Sum256(r.Data)
+	return t.Tracer.StartSpan("japaya.eval_region", map[string]string{
+		"japaya.path":           path,
+		"japaya.region_index":   strconv.Itoa(index),
+		"japaya.region_kind":    regionTypeString(r.Type),
+		"japaya.snippet_sha256": hex.EncodeToString(sum[:]),
+	})
+}
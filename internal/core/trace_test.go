@@ -0,0 +1,87 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// recordingTracer collects spans as (name, attrs) pairs and counts how many
+// were ended.
+type recordingTracer struct {
+	mu    sync.Mutex
+	names []string
+	attrs []map[string]string
+	ended int
+}
+
+func (rt *recordingTracer) StartSpan(name string, attrs map[string]string) func() {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.names = append(rt.names, name)
+	rt.attrs = append(rt.attrs, attrs)
+	return func() {
+		rt.mu.Lock()
+		defer rt.mu.Unlock()
+		rt.ended++
+	}
+}
+
+func TestTranslateFile_EmitsSpans(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "A.japaya")
+	writeFile(t, inPath, "class A { `one` and `two` }\n")
+
+	rt := &recordingTracer{}
+	tr := &Translator{Py: fakePythonEvaluator{eval: upperEval}, Tracer: rt}
+	if err := tr.TranslateFile(t.Context(), inPath, filepath.Join(dir, "A.java")); err != nil {
+		t.Fatalf("TranslateFile: %v", err)
+	}
+
+	if len(rt.names) != 3 {
+		t.Fatalf("got %d spans %v, want translate_file + 2 eval_region", len(rt.names), rt.names)
+	}
+	if rt.names[0] != "japaya.translate_file" {
+		t.Errorf("first span = %q, want japaya.translate_file", rt.names[0])
+	}
+	if rt.attrs[0]["japaya.path"] != inPath {
+		t.Errorf("file span path = %q, want %q", rt.attrs[0]["japaya.path"], inPath)
+	}
+
+	// `one` is the second region of the unit (after the leading java text).
+	region := rt.attrs[1]
+	if rt.names[1] != "japaya.eval_region" {
+		t.Errorf("second span = %q, want japaya.eval_region", rt.names[1])
+	}
+	if region["japaya.region_index"] != "1" {
+		t.Errorf("region_index = %q, want %q", region["japaya.region_index"], "1")
+	}
+	if region["japaya.region_kind"] != "python statement" {
+		t.Errorf("region_kind = %q, want %q", region["japaya.region_kind"], "python statement")
+	}
+	sum := sha256.Sum256([]byte("one"))
+	if region["japaya.snippet_sha256"] != hex.EncodeToString(sum[:]) {
+		t.Errorf("snippet_sha256 = %q, want hash of the snippet source", region["japaya.snippet_sha256"])
+	}
+
+	if rt.ended != len(rt.names) {
+		t.Errorf("%d of %d spans were ended", rt.ended, len(rt.names))
+	}
+}
+
+func TestTranslateFile_NilTracerEmitsNothing(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "A.japaya")
+	writeFile(t, inPath, "class A { `v` }\n")
+
+	tr := &Translator{Py: fakePythonEvaluator{eval: upperEval}}
+	if err := tr.TranslateFile(t.Context(), inPath, filepath.Join(dir, "A.java")); err != nil {
+		t.Fatalf("TranslateFile: %v", err)
+	}
+}
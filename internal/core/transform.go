@@ -0,0 +1,91 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// JavaTransform rewrites a java region's bytes before they are spliced into
+// the output. Transforms run in registration order; each receives the
+// previous one's output.
+type JavaTransform func(chunk []byte) ([]byte, error)
+
+// StripLinesTransform drops every line containing marker, so development
+// scaffolding like `// japaya-dev-only` never reaches generated output.
+func StripLinesTransform(marker string) JavaTransform {
+	m := []byte(marker)
+	return func(chunk []byte) ([]byte, error) {
+		if !bytes.Contains(chunk, m) {
+			return chunk, nil
+		}
+		out := make([]byte, 0, len(chunk))
+		for len(chunk) > 0 {
+			line := chunk
+			if i := bytes.IndexByte(chunk, '\n'); i >= 0 {
+				line = chunk[:i+1]
+				chunk = chunk[i+1:]
+			} else {
+				chunk = nil
+			}
+			if !bytes.Contains(line, m) {
+				out = append(out, line...)
+			}
+		}
+		return out, nil
+	}
+}
+
+// ReplaceTransform substitutes every occurrence of old with new, the
+// workhorse for package-prefix rewrites.
+func ReplaceTransform(old, new string) JavaTransform {
+	o, n := []byte(old), []byte(new)
+	return func(chunk []byte) ([]byte, error) {
+		return bytes.ReplaceAll(chunk, o, n), nil
+	}
+}
+
+// ParseTransformChain builds a transform chain from its config form:
+// semicolon-separated entries, each "strip-lines:MARKER" or
+// "replace:OLD=NEW". Used by the -transform flag; library embedders can set
+// Translator.Transforms directly with arbitrary functions instead.
+func ParseTransformChain(spec string) ([]JavaTransform, error) {
+	var chain []JavaTransform
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kind, arg, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("transform %q: want NAME:ARG", entry)
+		}
+		switch kind {
+		case "strip-lines":
+			if arg == "" {
+				return nil, fmt.Errorf("transform %q: strip-lines needs a marker", entry)
+			}
+			chain = append(chain, StripLinesTransform(arg))
+		case "replace":
+			old, new, ok := strings.Cut(arg, "=")
+			if !ok || old == "" {
+				return nil, fmt.Errorf("transform %q: replace wants OLD=NEW", entry)
+			}
+			chain = append(chain, ReplaceTransform(old, new))
+		default:
+			return nil, fmt.Errorf("unknown transform %q (want strip-lines or replace)", kind)
+		}
+	}
+	return chain, nil
+}
+
+// applyTransforms runs the configured chain over one java chunk.
+func (t *Translator) applyTransforms(chunk []byte) ([]byte, error) {
+	var err error
+	for _, tf := range t.Transforms {
+		if chunk, err = tf(chunk); err != nil {
+			return nil, err
+		}
+	}
+	return chunk, nil
+}
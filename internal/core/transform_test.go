@@ -0,0 +1,101 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTranslateUnit_TransformsRewriteJavaRegions(t *testing.T) {
+	t.Parallel()
+
+	in := "package com.old.app;\n" +
+		"int debug = 1; // japaya-dev-only\n" +
+		"int x = `v`;\n"
+	unit, err := ParseBytes([]byte(in))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	tr := &Translator{
+		Py: fakePythonEvaluator{eval: upperEval},
+		Transforms: []JavaTransform{
+			StripLinesTransform("// japaya-dev-only"),
+			ReplaceTransform("com.old", "com.shiny"),
+		},
+	}
+	out, err := tr.translateUnit(t.Context(), unit, "in.japaya")
+	if err != nil {
+		t.Fatalf("translateUnit: %v", err)
+	}
+
+	want := "package com.shiny.app;\nint x = <v>;\n"
+	if string(out) != want {
+		t.Errorf("output:\n got: %q\nwant: %q", out, want)
+	}
+}
+
+func TestTranslateUnit_TransformErrorCarriesRegion(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("class A {}\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	tr := &Translator{
+		Py: fakePythonEvaluator{eval: upperEval},
+		Transforms: []JavaTransform{
+			func(chunk []byte) ([]byte, error) { return nil, errors.New("rewrite exploded") },
+		},
+	}
+	_, err = tr.translateUnit(t.Context(), unit, "in.japaya")
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	var te *TranslationError
+	if !errors.As(err, &te) {
+		t.Errorf("error should be a *TranslationError, got %T: %v", err, err)
+	}
+	if !strings.Contains(err.Error(), "rewrite exploded") {
+		t.Errorf("error %q should carry the transform failure", err)
+	}
+}
+
+func TestParseTransformChain(t *testing.T) {
+	t.Parallel()
+
+	chain, err := ParseTransformChain("strip-lines:DEV; replace:a.b=c.d")
+	if err != nil {
+		t.Fatalf("ParseTransformChain: %v", err)
+	}
+	if len(chain) != 2 {
+		t.Fatalf("got %d transforms, want 2", len(chain))
+	}
+	out, err := chain[1]([]byte("import a.b.X;\n"))
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	if string(out) != "import c.d.X;\n" {
+		t.Errorf("replace output = %q", out)
+	}
+
+	for _, bad := range []string{"nope", "mystery:x", "strip-lines:", "replace:noequals", "replace:=v"} {
+		if _, err := ParseTransformChain(bad); err == nil {
+			t.Errorf("ParseTransformChain(%q) should fail", bad)
+		}
+	}
+}
+
+func TestStripLinesTransform_DropsWholeLinesOnly(t *testing.T) {
+	t.Parallel()
+
+	tf := StripLinesTransform("SECRET")
+	out, err := tf([]byte("keep\nhas SECRET here\nalso keep"))
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	if string(out) != "keep\nalso keep" {
+		t.Errorf("output = %q", out)
+	}
+}
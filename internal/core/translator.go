@@ -1,38 +1,622 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const extraBufferPrediction = 64
 
+// Translator bundles a PythonEvaluator with run-wide configuration. The zero
+// value is not usable; Py must be set.
+type Translator struct {
+	Py     PythonEvaluator
+	JShell JShellEvaluator // Optional; required only if the input uses `java!` regions
+	Shell  ShellEvaluator  // Optional; `sh!` regions fail unless explicitly enabled
+	Events EventSink       // Optional sink for progress events (nil disables)
+	Tracer Tracer          // Optional span tracer for build observability (nil disables; see Tracer)
+	Verify bool            // Compare against existing outputs instead of writing (see VerifyError)
+
+	// Optional per-file import manifest, populated when Py also implements
+	// ImportReporter. Enables dependency-aware rebuilds (see Manifest).
+	Manifest *Manifest
+
+	// RegionCache, when set, reuses each region's output from the file's
+	// previous translation so re-translations after an edit re-run only the
+	// snippets whose text changed (see RegionOutputCache). Daemon and watch
+	// loops only; one-shot builds should leave it nil.
+	RegionCache *RegionOutputCache
+
+	// EvalCache, when set, memoizes the results of regions carrying the
+	// cache annotation across every file in the run, so a run-level
+	// constant computed in the prelude is evaluated once instead of per
+	// file (see EvalCache).
+	EvalCache *EvalCache
+
+	// ResourcesDir is the root under which resources emitted via
+	// japaya.emit_resource are written (when Py also implements
+	// ResourceReporter). Empty discards emitted resources with a warning.
+	ResourcesDir string
+
+	// PackageRoot, when non-empty, enables package inference: each file's
+	// package name is derived from its directory path relative to this root
+	// (separators become dots) and exposed to its snippets as
+	// japaya.package, so templates don't hard-code package names that break
+	// when files move (see inferPackage).
+	PackageRoot string
+
+	// ManageImports inserts the java imports snippets required via
+	// japaya.require_import into each generated file's import block, sorted
+	// and deduplicated against the imports already there (needs Py to
+	// implement RequiredImportReporter). Off, such requirements are dropped
+	// with a warning.
+	ManageImports bool
+
+	// FileTimeout bounds the translation of each individual file; 0 disables.
+	// A timed-out file fails with a TranslationError pointing at the region
+	// that was being evaluated.
+	FileTimeout time.Duration
+
+	// TotalTimeout bounds a whole TranslatePath run; 0 disables.
+	TotalTimeout time.Duration
+
+	// Optional in-memory contents that shadow on-disk input files, so a
+	// daemon can translate unsaved editor buffers (see Overlay).
+	Overlay *Overlay
+
+	// Optional collector of per-file line maps from generated output back to
+	// inputs, used to rewrite javac diagnostics (see SourceMaps).
+	SourceMaps *SourceMaps
+
+	// When non-empty, the fully qualified name of an annotation inserted
+	// above every top-level type in generated files (with its import), e.g.
+	// "javax.annotation.processing.Generated".
+	GeneratedAnnotation string
+
+	// Header is prepended to every generated file, after expanding {{year}}
+	// (current year) and {{file}} (the input path). Typically a license
+	// header loaded via -header-file. Wrapped in the language's line-comment
+	// syntax when one is configured (see Language).
+	Header string
+
+	// Language overrides the host language being templated; nil means Java
+	// (see Language).
+	Language *Language
+
+	// ParseOptions enables syntax extensions like ${expr} interpolation
+	// (see ParseOptions); the zero value is classic backtick syntax.
+	ParseOptions ParseOptions
+
+	// Optional collector of non-fatal diagnostics; nil disables the checks
+	// (see Warnings).
+	Warnings *Warnings
+
+	// Policy decides how diagnostics of each severity affect control flow;
+	// the zero value stops a file at its first error (see ErrorPolicy).
+	Policy ErrorPolicy
+
+	// Optional accumulator of end-of-run statistics for tree runs; nil
+	// disables the accounting (see RunSummary).
+	Summary *RunSummary
+
+	// CheckBalance verifies that each generated file's brackets balance,
+	// warning with the nearest contributing region when they don't. Needs
+	// Warnings to be set.
+	CheckBalance bool
+
+	// WarnStale lists output files a tree run did not produce — leftovers
+	// from renamed or deleted templates — without deleting anything. Needs
+	// Warnings to be set.
+	WarnStale bool
+
+	// CheckClassName verifies that the public top-level type a generated
+	// file declares matches its file name, failing the file with the region
+	// that produced the declaration when they differ.
+	CheckClassName bool
+
+	// ChompBlocks strips the single trailing newline print leaves on block
+	// output, which otherwise becomes a blank line at the splice point.
+	// Individual regions override with a chomp / no-chomp annotation.
+	ChompBlocks bool
+
+	// AlignStatements pads statement results to the width their region
+	// occupied in the template, preserving the columns of java text after
+	// the splice (see alignStatement). Individual regions override with an
+	// align / no-align annotation.
+	AlignStatements bool
+
+	// CheckDeterminism translates every file twice and fails if the two
+	// outputs differ, catching templates that depend on time, randomness,
+	// or dict ordering before they cause unreproducible builds.
+	CheckDeterminism bool
+
+	// MaxIncludeDepth caps how deeply !include blocks may nest; zero means
+	// the default of 16. Cycles are detected separately and always fail with
+	// the full include chain.
+	MaxIncludeDepth int
+
+	// MaxExpandDepth enables macro-style recursive expansion: when > 1,
+	// translated output is re-scanned for regions and expanded again, up to
+	// this many passes in total, failing if regions remain after the last.
+	// Source maps describe the first pass only.
+	MaxExpandDepth int
+
+	// Epoch, when non-zero, is the unix timestamp used for tool-generated
+	// dates — the header's {{year}}, tar entry mod times — honoring
+	// SOURCE_DATE_EPOCH for reproducible builds.
+	Epoch int64
+
+	// Transforms rewrite java regions during translation — stripping
+	// dev-only lines, renaming package prefixes — so simple output rewrites
+	// don't need a sed pass after japaya (see JavaTransform). Applied by the
+	// buffered paths only; TranslateStream copies java through untouched.
+	// Line-removing transforms skew source maps for the affected lines.
+	Transforms []JavaTransform
+
+	// SnippetEnv holds extra environment variables made visible to snippets
+	// when Py also implements SnippetScoper. File-based translation also
+	// scopes each file's snippets to the input file's directory.
+	SnippetEnv map[string]string
+}
+
+// epochTime returns the pinned Epoch as a time, or the wall clock when no
+// epoch is configured.
+func (t *Translator) epochTime() time.Time {
+	if t.Epoch != 0 {
+		return time.Unix(t.Epoch, 0).UTC()
+	}
+	return time.Now()
+}
+
+// expandHeader renders the configured header for one input file.
+func (t *Translator) expandHeader(path string) string {
+	h := strings.ReplaceAll(t.Header, "{{year}}", strconv.Itoa(t.epochTime().Year()))
+	h = strings.ReplaceAll(h, "{{file}}", path)
+	if !strings.HasSuffix(h, "\n") {
+		h += "\n"
+	}
+	if lang := t.language(); lang.LineComment != "" {
+		lines := strings.Split(strings.TrimSuffix(h, "\n"), "\n")
+		for i, line := range lines {
+			lines[i] = lang.commentOut(line)
+		}
+		h = strings.Join(lines, "\n") + "\n"
+	}
+	return h
+}
+
+// fileContext derives the per-file context from ctx. The returned cancel
+// func must always be called.
+func (t *Translator) fileContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if t.FileTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, t.FileTimeout)
+}
+
+// takeImports drains the evaluator's reported imports for the file just
+// translated, recording them in the manifest if one is configured. Recording
+// also clears any earlier japaya.skip marker for the file.
+func (t *Translator) takeImports(path string) []string {
+	var imports []string
+	if ir, ok := t.Py.(ImportReporter); ok {
+		imports = ir.TakeImports()
+	}
+	if t.RegionCache != nil {
+		imports = t.RegionCache.takePending(path, imports)
+	}
+	if t.Manifest != nil {
+		t.Manifest.record(path, imports)
+	}
+	return imports
+}
+
+// emit sends an event to the configured sink, if any.
+func (t *Translator) emit(e Event) {
+	if t.Events != nil {
+		t.Events.Emit(e)
+	}
+}
+
+// TranslateUnit translates a parsed unit using a bare Translator. Callers
+// that need configuration beyond the evaluator should build a Translator and
+// use its methods instead.
 func TranslateUnit(ctx context.Context, unit *TranslationUnit, py PythonEvaluator) ([]byte, error) {
+	return (&Translator{Py: py}).TranslateUnit(ctx, unit)
+}
+
+// TranslateUnit splices the unit's regions into an output file, evaluating
+// python regions through the configured evaluator.
+func (t *Translator) TranslateUnit(ctx context.Context, unit *TranslationUnit) ([]byte, error) {
+	return t.translateUnit(ctx, unit, "")
+}
+
+// TranslateRegions translates an already-parsed slice of regions, for tools
+// that hold a parsed unit — language servers, formatters, partial
+// re-translation — and want to re-evaluate specific regions without
+// re-reading and re-parsing the whole file. Equivalent to TranslateUnit on a
+// unit containing exactly those regions.
+func TranslateRegions(ctx context.Context, regions []Region, py PythonEvaluator) ([]byte, error) {
+	return (&Translator{Py: py}).TranslateRegions(ctx, regions)
+}
+
+// TranslateRegions is the method form of the package-level TranslateRegions,
+// evaluating through the translator's full configuration.
+func (t *Translator) TranslateRegions(ctx context.Context, regions []Region) ([]byte, error) {
+	return t.translateUnit(ctx, &TranslationUnit{Regions: regions}, "")
+}
+
+// translateUnit is TranslateUnit with the input path threaded through for
+// event reporting. path may be empty when translating from a reader.
+func (t *Translator) translateUnit(ctx context.Context, unit *TranslationUnit, path string) ([]byte, error) {
+	out, _, err := t.translateUnitMapped(ctx, unit, path)
+	return out, err
+}
+
+// translateUnitMapped additionally builds a line-level source map when a
+// SourceMaps collector is configured; otherwise the map is nil and no line
+// counting happens.
+func (t *Translator) translateUnitMapped(ctx context.Context, unit *TranslationUnit, path string) ([]byte, *SourceMap, error) {
 	if unit == nil {
-		return nil, fmt.Errorf("nil TranslationUnit")
+		return nil, nil, fmt.Errorf("nil TranslationUnit")
 	}
-	if py == nil {
-		return nil, fmt.Errorf("nil PythonEvaluator")
+	if t.Py == nil {
+		return nil, nil, fmt.Errorf("nil PythonEvaluator")
 	}
 
 	out := make([]byte, 0, len(unit.Data)+extraBufferPrediction)
 
-	for _, r := range unit.Regions {
-		switch r.Type {
-		case RegionTypeJava:
-			out = append(out, r.Data...)
-		case RegionTypePythonStatement:
-			fallthrough
-		case RegionTypePythonBlock:
-			translated, err := py.Eval(ctx, r.Type, r.Data)
+	var sm *SourceMap
+	var outLine uint
+	if t.SourceMaps != nil {
+		sm = &SourceMap{InPath: path}
+	}
+
+	if t.Header != "" {
+		header := t.expandHeader(path)
+		out = append(out, header...)
+		outLine += uint(strings.Count(header, "\n"))
+	}
+
+	checkBalance := t.CheckBalance && t.Warnings != nil
+	checkClassName := t.CheckClassName && path != ""
+	collectSpans := checkBalance || checkClassName
+	var spans []regionSpan
+	var slotMarkers map[string]bool
+
+	// Order annotations evaluate regions out of document order; the splice
+	// loop below still places every result where its region appears.
+	preEval, err := t.preEvalOrdered(ctx, unit, path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Region errors deferred by a fail-at-end policy (see ErrorPolicy).
+	var deferred []error
+
+	for i, r := range unit.Regions {
+		if sm != nil {
+			sm.segs = append(sm.segs, mapSegment{
+				outLine:  outLine,
+				inLine:   r.Start.Line,
+				oneToOne: r.Type == RegionTypeJava,
+			})
+		}
+		if collectSpans {
+			spans = append(spans, regionSpan{outLine: outLine, region: r})
+		}
+
+		var chunk []byte
+		if r.Type == RegionTypeJava {
+			chunk = r.Data
+			if me, ok := t.Py.(MacroEvaluator); ok {
+				expanded, err := t.expandMacros(ctx, me, chunk, r, path)
+				if err != nil {
+					return nil, nil, err
+				}
+				chunk = expanded
+			}
+			transformed, err := t.applyTransforms(chunk)
 			if err != nil {
-				return nil, &TranslationError{Region: r, Err: err}
+				return nil, nil, &TranslationError{Region: r, Err: err}
 			}
-			out = append(out, translated...)
-		default:
-			return nil, fmt.Errorf("unknown region type: %v", r.Type)
+			chunk = transformed
+		} else if r.Type == RegionTypePythonBlock && isSlotBlock(r.Data) {
+			// Slot markers splice a placeholder; the appends collected from
+			// the whole file land there once every region has run.
+			name, err := slotMarkerName(r.Data)
+			if err != nil {
+				return nil, nil, &TranslationError{Region: r, Err: err}
+			}
+			if slotMarkers == nil {
+				slotMarkers = make(map[string]bool)
+			}
+			slotMarkers[name] = true
+			chunk = slotPlaceholder(name)
+		} else {
+			var translated []byte
+			var evalErr error
+			if pre, ok := preEval[i]; ok {
+				translated, evalErr = pre.out, pre.err
+			} else {
+				translated, evalErr = t.evalRegion(ctx, r, path, i)
+			}
+			if evalErr != nil {
+				// Under a fail-at-end policy a region error is collected and
+				// the rest of the file still evaluates; the file fails once
+				// with everything that broke.
+				if !t.deferRegionError(ctx, evalErr) {
+					return nil, nil, evalErr
+				}
+				deferred = append(deferred, evalErr)
+				translated = nil
+			}
+			chunk = translated
+		}
+		out = append(out, chunk...)
+		if sm != nil || collectSpans {
+			outLine += uint(bytes.Count(chunk, []byte("\n")))
 		}
 	}
 
-	return out, nil
+	if len(deferred) > 0 {
+		return nil, nil, errors.Join(deferred...)
+	}
+
+	out = t.flushSlots(out, slotMarkers, path)
+
+	// Before annotation rewrites, so span lines still match the output.
+	if checkBalance {
+		t.checkOutputBalance(path, out, spans)
+	}
+	if checkClassName {
+		if err := t.checkClassFileName(path, out, spans); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if t.MaxExpandDepth > 1 {
+		expanded, err := t.expandRecursive(ctx, out, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		out = expanded
+	}
+
+	out = t.flushRequiredImports(out, sm, path)
+
+	if t.GeneratedAnnotation != "" {
+		annotated, inserted := annotateGenerated(out, t.GeneratedAnnotation)
+		out = annotated
+		// Positions come back ascending in pre-insertion numbering; apply
+		// them in reverse so earlier ones stay valid.
+		for i := len(inserted) - 1; i >= 0; i-- {
+			sm.noteInsertedLine(inserted[i])
+		}
+	}
+
+	return out, sm, nil
+}
+
+// expandRecursive re-scans translated output for regions and expands them
+// until the result is pure java, treating the caller's pass as pass one.
+// Templates that keep generating templates past MaxExpandDepth fail rather
+// than loop.
+func (t *Translator) expandRecursive(ctx context.Context, out []byte, path string) ([]byte, error) {
+	for depth := 2; ; depth++ {
+		unit, err := ParseBytes(out)
+		if err != nil {
+			return nil, fmt.Errorf("expansion pass %d: %w", depth, err)
+		}
+		evaluable := false
+		for _, r := range unit.Regions {
+			if r.Type != RegionTypeJava {
+				evaluable = true
+				break
+			}
+		}
+		if !evaluable {
+			return out, nil
+		}
+		if depth > t.MaxExpandDepth {
+			return nil, fmt.Errorf("output still contains regions after %d expansion passes; raise MaxExpandDepth if intended", t.MaxExpandDepth)
+		}
+
+		next := make([]byte, 0, len(out))
+		for i, r := range unit.Regions {
+			if r.Type == RegionTypeJava {
+				transformed, err := t.applyTransforms(r.Data)
+				if err != nil {
+					return nil, &TranslationError{Region: r, Err: err}
+				}
+				next = append(next, transformed...)
+				continue
+			}
+			translated, err := t.evalRegion(ctx, r, path, i)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, translated...)
+		}
+		out = next
+	}
+}
+
+// evalRegion evaluates a single non-java region through the appropriate
+// evaluator, emitting region events along the way. Shared by the buffered
+// and streaming translation paths. index is the region's position within its
+// unit, used only for tracing.
+func (t *Translator) evalRegion(ctx context.Context, r Region, path string, index int) ([]byte, error) {
+	endSpan := t.evalSpan(path, index, r)
+	defer endSpan()
+
+	switch r.Type {
+	case RegionTypePythonStatement, RegionTypePythonBlock:
+		if len(bytes.TrimSpace(r.Data)) == 0 {
+			t.warn(path, r, "empty-region", "empty python region")
+		}
+		t.checkStatementMisuse(path, r)
+
+		// A leading #! annotation tunes this region's evaluation; it is
+		// stripped before directive detection so the two compose.
+		opts, data, err := parseRegionOpts(r.Data, r.Type == RegionTypePythonBlock)
+		if err != nil {
+			return nil, &TranslationError{Region: r, Err: err}
+		}
+		if opts.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+			defer cancel()
+		}
+		if recv, ok := t.Py.(RegionOptionReceiver); ok && opts.Raw != nil {
+			recv.SetRegionOptions(opts.Raw)
+			defer recv.SetRegionOptions(nil)
+		}
+
+		start := time.Now()
+		var translated []byte
+		var fallback *string
+		if r.Type == RegionTypePythonBlock && isAssertBlock(data) {
+			translated, err = t.evalAssert(ctx, data)
+		} else if r.Type == RegionTypePythonBlock && isIncludeBlock(data) {
+			translated, err = t.expandInclude(ctx, data, path, r)
+		} else if r.Type == RegionTypePythonBlock && isForeachBlock(data) {
+			translated, err = t.expandForeach(ctx, data, path)
+		} else if r.Type == RegionTypePythonBlock && isJavadocBlock(data) {
+			stripped := r
+			stripped.Data = data
+			translated, err = t.expandJavadoc(ctx, stripped)
+		} else {
+			code := data
+			if r.Type == RegionTypePythonStatement {
+				// `expr | default("lit")` shorthand splices the literal if
+				// the expression raises, instead of failing the file.
+				if expr, lit, ok := splitDefaultFallback(code); ok {
+					code = expr
+					fallback = &lit
+				}
+				// `expr:spec` shorthand becomes an explicit format call.
+				code = applyFormatSpec(code)
+			}
+			_, runConstant := opts.Raw["cache"]
+			if runConstant && t.EvalCache != nil {
+				// The cache annotation marks the result a run-level
+				// constant, shared across files rather than per path.
+				if cached, ok := t.EvalCache.lookup(r.Type, code, t.SnippetEnv); ok {
+					translated = cached
+				} else {
+					translated, err = t.Py.Eval(ctx, r.Type, code)
+					if err == nil {
+						t.EvalCache.store(r.Type, code, t.SnippetEnv, translated)
+					}
+				}
+			} else if cached, ok := t.cachedRegionOutput(path, r); ok {
+				translated = cached
+			} else {
+				translated, err = t.Py.Eval(ctx, r.Type, code)
+				if err == nil {
+					t.recordRegionOutput(path, r, translated)
+				}
+			}
+		}
+		if t.Summary != nil {
+			t.Summary.addEvalTime(time.Since(start))
+		}
+		if err != nil && fallback != nil && ctx.Err() == nil {
+			t.warn(path, r, "default-fallback",
+				fmt.Sprintf("region failed (%v); splicing default %q", err, *fallback))
+			translated, err = []byte(*fallback), nil
+		}
+		if err != nil {
+			terr := &TranslationError{Region: r, Err: err}
+			t.emit(Event{
+				Type:    "error",
+				Path:    path,
+				Start:   &r.Start,
+				Message: terr.Error(),
+			})
+			return nil, terr
+		}
+		if r.Type == RegionTypePythonBlock && t.shouldChomp(opts) {
+			translated = chompTrailingNewline(translated)
+		}
+		if r.Type == RegionTypePythonStatement && t.shouldAlign(opts) {
+			translated = t.alignStatement(path, r, translated)
+		}
+		t.emit(Event{
+			Type:       "region_evaluated",
+			Path:       path,
+			RegionType: regionTypeString(r.Type),
+			Start:      &r.Start,
+			DurationMS: float64(time.Since(start)) / float64(time.Millisecond),
+		})
+		t.checkSplicedOutput(path, r, translated)
+		return translated, nil
+	case RegionTypeJShellStatement, RegionTypeShellStatement:
+		eval := t.JShell
+		var evalErr error
+		if r.Type == RegionTypeShellStatement {
+			eval = t.Shell
+			if eval == nil {
+				evalErr = fmt.Errorf("shell regions are not enabled")
+			}
+		} else if eval == nil {
+			evalErr = fmt.Errorf("no jshell evaluator configured")
+		}
+		if evalErr != nil {
+			return nil, &TranslationError{Region: r, Err: evalErr}
+		}
+		start := time.Now()
+		translated, err := eval.Eval(ctx, r.Data)
+		if err != nil {
+			terr := &TranslationError{Region: r, Err: err}
+			t.emit(Event{
+				Type:    "error",
+				Path:    path,
+				Start:   &r.Start,
+				Message: terr.Error(),
+			})
+			return nil, terr
+		}
+		t.emit(Event{
+			Type:       "region_evaluated",
+			Path:       path,
+			RegionType: regionTypeString(r.Type),
+			Start:      &r.Start,
+			DurationMS: float64(time.Since(start)) / float64(time.Millisecond),
+		})
+		t.checkSplicedOutput(path, r, translated)
+		return translated, nil
+	default:
+		cr, ok := customRegionByType(r.Type)
+		if !ok {
+			return nil, fmt.Errorf("unknown region type: %v", r.Type)
+		}
+		start := time.Now()
+		translated, err := cr.handler(ctx, r.Data)
+		if err != nil {
+			terr := &TranslationError{Region: r, Err: err}
+			t.emit(Event{
+				Type:    "error",
+				Path:    path,
+				Start:   &r.Start,
+				Message: terr.Error(),
+			})
+			return nil, terr
+		}
+		t.emit(Event{
+			Type:       "region_evaluated",
+			Path:       path,
+			RegionType: regionTypeString(r.Type),
+			Start:      &r.Start,
+			DurationMS: float64(time.Since(start)) / float64(time.Millisecond),
+		})
+		t.checkSplicedOutput(path, r, translated)
+		return translated, nil
+	}
 }
@@ -1,8 +1,11 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"strings"
 	"testing"
 )
 
@@ -215,3 +218,103 @@ func TestTranslateUnit_UnknownRegionType(t *testing.T) {
 		t.Fatalf("expected error for unknown region type")
 	}
 }
+
+func TestTranslator_EmitsNDJSONEvents(t *testing.T) {
+	t.Parallel()
+
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+			return []byte("3"), nil
+		},
+	}
+
+	var buf bytes.Buffer
+	tr := &Translator{Py: py, Events: NewNDJSONSink(&buf)}
+
+	unit, err := ParseBytes([]byte("int x = `1+2`;"))
+	if err != nil {
+		t.Fatalf("ParseBytes error: %v", err)
+	}
+
+	if _, err := tr.TranslateUnit(context.Background(), unit); err != nil {
+		t.Fatalf("TranslateUnit error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 event line, got %d:\n%s", len(lines), buf.String())
+	}
+
+	var ev Event
+	if err := json.Unmarshal([]byte(lines[0]), &ev); err != nil {
+		t.Fatalf("invalid event JSON %q: %v", lines[0], err)
+	}
+	if ev.Type != "region_evaluated" {
+		t.Fatalf("unexpected event type: %q", ev.Type)
+	}
+	if ev.RegionType != "python statement" {
+		t.Fatalf("unexpected region type: %q", ev.RegionType)
+	}
+	if ev.Start == nil || ev.Start.Line != 0 || ev.Start.Column != 9 {
+		t.Fatalf("unexpected start: %#v", ev.Start)
+	}
+}
+
+func TestTranslateRegions_ReEvaluatesSubsetOfParsedUnit(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("int a = `x`;\nint b = `y`;\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+
+	// Re-evaluate only the python regions, the way an editor re-translating
+	// a change would, without re-parsing the file.
+	var regions []Region
+	for _, r := range unit.Regions {
+		if r.Type != RegionTypeJava {
+			regions = append(regions, r)
+		}
+	}
+	if len(regions) != 2 {
+		t.Fatalf("parsed %d python regions, want 2", len(regions))
+	}
+
+	out, err := TranslateRegions(context.Background(), regions, fakePythonEvaluator{eval: upperEval})
+	if err != nil {
+		t.Fatalf("TranslateRegions: %v", err)
+	}
+	if want := "<x><y>"; string(out) != want {
+		t.Fatalf("out = %q, want %q", string(out), want)
+	}
+}
+
+// snippetLineError fakes an evaluator error that knows the line within the
+// snippet, like python.PythonError does.
+type snippetLineError struct{ line int }
+
+func (e *snippetLineError) Error() string    { return "boom" }
+func (e *snippetLineError) SnippetLine() int { return e.line }
+
+func TestTranslationError_ReportsAbsoluteLineInBlocks(t *testing.T) {
+	t.Parallel()
+
+	py := fakePythonEvaluator{eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+		return nil, &snippetLineError{line: 3}
+	}}
+	tr := &Translator{Py: py}
+
+	unit, err := ParseBytes([]byte("class A {\n```\nx = 1\n1/0\n```\n}\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	_, err = tr.TranslateUnit(t.Context(), unit)
+	if err == nil {
+		t.Fatal("want the block's error")
+	}
+	// Snippet line 3 is "1/0", which sits on file line 3 (the block opens
+	// on line 1 and its content begins with the newline after the ```).
+	if want := "at file line 3 (snippet line 3): 1/0"; !strings.Contains(err.Error(), want) {
+		t.Errorf("err = %v, want it to contain %q", err, want)
+	}
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TreeError aggregates per-file failures from a tree run. Failures are
+// recorded in whatever order files finish — safe under concurrent workers —
+// but always reported sorted by input path and region position, so CI logs
+// stay diffable between runs.
+type TreeError struct {
+	mu    sync.Mutex
+	Files []FileError
+}
+
+// FileError is one input file's failure.
+type FileError struct {
+	Path string
+	Err  error
+}
+
+// add records a failure; callers may add from multiple goroutines.
+func (e *TreeError) add(path string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.Files = append(e.Files, FileError{Path: path, Err: err})
+}
+
+// sort orders failures by input path, breaking ties within a file by the
+// failing region's position.
+func (e *TreeError) sort() {
+	sort.SliceStable(e.Files, func(i, j int) bool {
+		if e.Files[i].Path != e.Files[j].Path {
+			return e.Files[i].Path < e.Files[j].Path
+		}
+		li, ci := errorPosition(e.Files[i].Err)
+		lj, cj := errorPosition(e.Files[j].Err)
+		if li != lj {
+			return li < lj
+		}
+		return ci < cj
+	})
+}
+
+func (e *TreeError) Error() string {
+	lines := make([]string, len(e.Files))
+	for i, fe := range e.Files {
+		lines[i] = fe.Err.Error()
+	}
+	return fmt.Sprintf("%d file(s) failed to translate:\n%s", len(e.Files), strings.Join(lines, "\n"))
+}
+
+// Unwrap exposes the individual failures to errors.Is/As.
+func (e *TreeError) Unwrap() []error {
+	errs := make([]error, len(e.Files))
+	for i, fe := range e.Files {
+		errs[i] = fe.Err
+	}
+	return errs
+}
+
+// errorPosition extracts the failing region's start position, or zeros when
+// the error carries none.
+func errorPosition(err error) (line, column uint) {
+	var te *TranslationError
+	if errors.As(err, &te) {
+		return te.Region.Start.Line, te.Region.Start.Column
+	}
+	return 0, 0
+}
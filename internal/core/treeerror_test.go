@@ -0,0 +1,113 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTranslateTree_ReportsAllFailuresSortedByPath(t *testing.T) {
+	t.Parallel()
+
+	inRoot := t.TempDir()
+	outRoot := filepath.Join(t.TempDir(), "out")
+
+	writeFile(t, filepath.Join(inRoot, "c.java"), "int x = `boom`;\n")
+	writeFile(t, filepath.Join(inRoot, "a.java"), "int y = `boom`;\n")
+	writeFile(t, filepath.Join(inRoot, "b.java"), "int z = `ok`;\n")
+
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+			if strings.Contains(string(code), "boom") {
+				return nil, errors.New("kaboom")
+			}
+			return []byte("0"), nil
+		},
+	}
+
+	err := TranslateTree(context.Background(), inRoot, outRoot, py)
+	if err == nil {
+		t.Fatalf("expected an error, got nil")
+	}
+	var te *TreeError
+	if !errors.As(err, &te) {
+		t.Fatalf("error should be a *TreeError, got %T: %v", err, err)
+	}
+	if len(te.Files) != 2 {
+		t.Fatalf("got %d failures, want 2: %v", len(te.Files), err)
+	}
+	if filepath.Base(te.Files[0].Path) != "a.java" || filepath.Base(te.Files[1].Path) != "c.java" {
+		t.Errorf("failures not sorted by path: %q, %q", te.Files[0].Path, te.Files[1].Path)
+	}
+	if !strings.HasPrefix(err.Error(), "2 file(s) failed to translate:") {
+		t.Errorf("message = %q, want the failure count up front", err)
+	}
+}
+
+func TestTranslateTree_SingleFailureKeepsOriginalError(t *testing.T) {
+	t.Parallel()
+
+	inRoot := t.TempDir()
+	writeFile(t, filepath.Join(inRoot, "only.java"), "int x = `boom`;\n")
+
+	py := fakePythonEvaluator{
+		eval: func(ctx context.Context, typ RegionType, code []byte) ([]byte, error) {
+			return nil, errors.New("kaboom")
+		},
+	}
+
+	err := TranslateTree(context.Background(), inRoot, filepath.Join(t.TempDir(), "out"), py)
+	if err == nil {
+		t.Fatalf("expected an error, got nil")
+	}
+	var te *TreeError
+	if errors.As(err, &te) {
+		t.Errorf("a lone failure should surface directly, not as a *TreeError")
+	}
+	var tre *TranslationError
+	if !errors.As(err, &tre) {
+		t.Errorf("error should carry the TranslationError, got: %v", err)
+	}
+}
+
+func TestTreeError_SortsByPathAndPosition(t *testing.T) {
+	t.Parallel()
+
+	regionErr := func(line, col uint) error {
+		return fmt.Errorf("translate: %w", &TranslationError{
+			Region: Region{Start: Position{Line: line, Column: col}},
+			Err:    errors.New("boom"),
+		})
+	}
+
+	var te TreeError
+	te.add("b.java", regionErr(7, 0))
+	te.add("a.java", regionErr(9, 4))
+	te.add("a.java", regionErr(2, 1))
+	te.add("a.java", regionErr(2, 0))
+	te.sort()
+
+	type key struct {
+		path      string
+		line, col uint
+	}
+	var got []key
+	for _, fe := range te.Files {
+		line, col := errorPosition(fe.Err)
+		got = append(got, key{fe.Path, line, col})
+	}
+	want := []key{
+		{"a.java", 2, 0},
+		{"a.java", 2, 1},
+		{"a.java", 9, 4},
+		{"b.java", 7, 0},
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Files[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 )
 
@@ -18,14 +20,32 @@ const (
 	RegionTypeJava            RegionType = iota // Type for a java region
 	RegionTypePythonStatement                   // Type for a python statement region
 	RegionTypePythonBlock                       // Type for a python code block region
+	RegionTypeJShellStatement                   // Type for a `java! ...` statement region evaluated by JShell
+	RegionTypeShellStatement                    // Type for a `sh! ...` statement region run by the system shell
 )
 
 // Represents a half-open [Start, end) region of a file
+//
+// Start/End/Data cover the region's content only. RawStart/RawEnd/Raw
+// additionally cover the surrounding delimiters (the backticks for python
+// regions), so tools that rewrite files can reproduce a region exactly.
+// For java regions the raw extent equals the content extent.
 type Region struct {
 	Type  RegionType // The type of data in this region
-	Start Position   // The starting position of this region
-	End   Position   // The ending position of this region
-	Data  []byte     // The data in the region
+	Start Position   // The starting position of this region's content
+	End   Position   // The ending position of this region's content
+	Data  []byte     // The content of the region (delimiters excluded)
+
+	RawStart Position // The starting position including delimiters
+	RawEnd   Position // The ending position including delimiters
+	Raw      []byte   // The data including delimiters
+
+	// Byte offsets into TranslationUnit.Data mirroring the positions above.
+	// Half-open like everything else; used by the incremental parser.
+	StartOff    int
+	EndOff      int
+	RawStartOff int
+	RawEndOff   int
 }
 
 // Represents a single translation unit (file)
@@ -40,15 +60,37 @@ type TranslationError struct {
 }
 
 func (e *TranslationError) Error() string {
-	return fmt.Sprintf("%s at %d:%d-%d:%d: %v",
+	msg := fmt.Sprintf("%s at %d:%d-%d:%d: %v",
 		regionTypeString(e.Region.Type),
 		e.Region.Start.Line, e.Region.Start.Column,
 		e.Region.End.Line, e.Region.End.Column,
 		e.Err)
+	// When the evaluator knows the line within the snippet, spell out the
+	// absolute file line and quote its source, so errors deep in a
+	// multi-line block don't leave the author counting lines by hand.
+	var sl SnippetLiner
+	if errors.As(e.Err, &sl) {
+		if k := sl.SnippetLine(); k > 0 {
+			lines := bytes.Split(e.Region.Data, []byte("\n"))
+			if k <= len(lines) && len(lines) > 1 {
+				msg += fmt.Sprintf("\n\tat file line %d (snippet line %d): %s",
+					int(e.Region.Start.Line)+k-1, k, bytes.TrimSpace(lines[k-1]))
+			}
+		}
+	}
+	return msg
 }
 
 func (e *TranslationError) Unwrap() error { return e.Err }
 
+// SnippetLiner is implemented by evaluator errors that know the 1-based
+// line within the snippet where the failure occurred (see
+// python.PythonError). TranslationError uses it to point at the absolute
+// file line of a multi-line block.
+type SnippetLiner interface {
+	SnippetLine() int
+}
+
 func regionTypeString(t RegionType) string {
 	switch t {
 	case RegionTypeJava:
@@ -57,11 +99,32 @@ func regionTypeString(t RegionType) string {
 		return "python statement"
 	case RegionTypePythonBlock:
 		return "python block"
+	case RegionTypeJShellStatement:
+		return "jshell statement"
+	case RegionTypeShellStatement:
+		return "shell statement"
 	default:
+		if cr, ok := customRegionByType(t); ok {
+			return cr.name + " statement"
+		}
 		return "unknown"
 	}
 }
 
+// FileSkippedError is returned by a PythonEvaluator when a snippet calls
+// japaya.skip: translation of the file stops, nothing is written, and the
+// skip is recorded in the run's accounting rather than treated as a failure.
+type FileSkippedError struct {
+	Reason string
+}
+
+func (e *FileSkippedError) Error() string {
+	if e.Reason == "" {
+		return "file skipped by snippet"
+	}
+	return "file skipped by snippet: " + e.Reason
+}
+
 type PythonError struct {
 	Message   string
 	Line      *uint // line within the python snippet (0-based), if known
@@ -75,3 +138,92 @@ func (e *PythonError) Error() string { return e.Message }
 type PythonEvaluator interface {
 	Eval(ctx context.Context, mode RegionType, code []byte) ([]byte, error)
 }
+
+// Optionally implemented by a PythonEvaluator that can report which modules
+// its snippets imported. TakeImports drains the set accumulated since the
+// last call, so draining after each file attributes imports per file.
+type ImportReporter interface {
+	TakeImports() []string
+}
+
+// Resource is a named non-Java file a snippet emitted via
+// japaya.emit_resource, written under the translator's ResourcesDir.
+type Resource struct {
+	Path string // relative to the resources root
+	Data []byte
+}
+
+// Optionally implemented by a PythonEvaluator whose snippets can emit named
+// resource files alongside their spliced output. TakeResources drains the
+// resources accumulated since the last call, so draining after each file
+// attributes them per file.
+type ResourceReporter interface {
+	TakeResources() []Resource
+}
+
+// SlotAppend is one snippet append to a named output slot (via japaya.slot),
+// flushed wherever the template places its !slot marker for that name.
+type SlotAppend struct {
+	Name string
+	Text string
+}
+
+// Optionally implemented by a PythonEvaluator whose snippets can append to
+// named output slots. TakeSlots drains the appends accumulated since the
+// last call, in emission order.
+type SlotReporter interface {
+	TakeSlots() []SlotAppend
+}
+
+// Optionally implemented by a PythonEvaluator whose snippets can override
+// where the current file's output is written via japaya.set_output.
+// TakeOutputPath drains the override — the last call wins — or returns ""
+// when no snippet set one; the path is interpreted relative to the output
+// root.
+type OutputPathReporter interface {
+	TakeOutputPath() string
+}
+
+// Optionally implemented by a PythonEvaluator whose snippets can require
+// java imports via japaya.require_import. TakeRequiredImports drains the
+// sorted set accumulated since the last call; the translator inserts any
+// that are missing into the generated file's import block.
+type RequiredImportReporter interface {
+	TakeRequiredImports() []string
+}
+
+// Optionally implemented by a PythonEvaluator that caches imported modules
+// across evaluations. Invalidate drops the given top-level modules from the
+// cache so the next import re-reads them from disk.
+type ModuleInvalidator interface {
+	Invalidate(ctx context.Context, modules []string) error
+}
+
+// Optionally implemented by a PythonEvaluator whose snippets can register
+// macros. EvalMacro expands one @name(key=value, ...) call from a java
+// region; known is false when no macro with that name is registered, so the
+// caller leaves ordinary annotations untouched.
+type MacroEvaluator interface {
+	EvalMacro(ctx context.Context, name string, args map[string]string) (out string, known bool, err error)
+}
+
+// Optionally implemented by a PythonEvaluator that can scope snippet
+// execution. SetSnippetScope sets the working directory snippets run in
+// (empty leaves the worker's own cwd alone) and extra environment variables
+// visible to them; the translator points cwd at each input file's directory
+// so relative open() calls resolve next to the template.
+type SnippetScoper interface {
+	SetSnippetScope(cwd string, env map[string]string)
+}
+
+// Implemented by internal/jshell. Evaluates a java expression and returns its
+// string form for splicing.
+type JShellEvaluator interface {
+	Eval(ctx context.Context, code []byte) ([]byte, error)
+}
+
+// Implemented by internal/shell. Runs a command and returns its stdout for
+// splicing. Opt-in only: shell regions execute arbitrary commands.
+type ShellEvaluator interface {
+	Eval(ctx context.Context, command []byte) ([]byte, error)
+}
@@ -0,0 +1,27 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VerifyMismatch describes one output file that is stale or missing.
+type VerifyMismatch struct {
+	OutPath string // the output file that differs
+	Got     []byte // freshly translated content (what the file should hold)
+}
+
+// VerifyError reports outputs that do not match a fresh translation. In
+// verify mode nothing is written; callers can diff Got against the files on
+// disk.
+type VerifyError struct {
+	Mismatches []VerifyMismatch
+}
+
+func (e *VerifyError) Error() string {
+	paths := make([]string, len(e.Mismatches))
+	for i, m := range e.Mismatches {
+		paths[i] = m.OutPath
+	}
+	return fmt.Sprintf("%d output file(s) out of date: %s", len(e.Mismatches), strings.Join(paths, ", "))
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Warning is a non-fatal diagnostic noticed during translation. Unlike a
+// TranslationError it does not stop the run; the CLI prints collected
+// warnings after a build and -Werror promotes them to a failure.
+type Warning struct {
+	Path    string
+	Start   Position
+	Rule    string // stable identifier, e.g. "empty-region"
+	Message string
+}
+
+// String formats the warning like a compiler diagnostic.
+func (w Warning) String() string {
+	return fmt.Sprintf("%s:%d:%d: warning: %s [%s]",
+		w.Path, w.Start.Line+1, w.Start.Column+1, w.Message, w.Rule)
+}
+
+// Warnings collects the warnings produced during a run. Configure one on a
+// Translator to enable the checks; nil skips them entirely.
+type Warnings struct {
+	mu   sync.Mutex
+	list []Warning
+}
+
+// NewWarnings returns an empty collector ready for use.
+func NewWarnings() *Warnings {
+	return &Warnings{}
+}
+
+// add appends one warning.
+func (w *Warnings) add(warning Warning) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.list = append(w.list, warning)
+}
+
+// All returns the collected warnings in the order they were recorded.
+func (w *Warnings) All() []Warning {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Warning(nil), w.list...)
+}
+
+// warn records a warning against a region when collection is enabled,
+// mirroring it to the event sink.
+func (t *Translator) warn(path string, r Region, rule, message string) {
+	if t.Warnings == nil {
+		return
+	}
+	w := Warning{Path: path, Start: r.Start, Rule: rule, Message: message}
+	t.Warnings.add(w)
+	t.emit(Event{
+		Type:    "warning",
+		Path:    path,
+		Start:   &w.Start,
+		Message: message + " [" + rule + "]",
+	})
+}
+
+// misuseLineSpan is how many lines a statement region may span before it
+// looks like a forgotten closing backtick.
+const misuseLineSpan = 3
+
+// checkStatementMisuse warns when a statement region looks like Java text
+// swallowed by a missing closing backtick — spanning several lines, or
+// containing characters like braces and semicolons that belong to the host
+// language. Catching this before evaluation spares the author a confusing
+// python syntax error about their own Java code.
+func (t *Translator) checkStatementMisuse(path string, r Region) {
+	if t.Warnings == nil || r.Type != RegionTypePythonStatement {
+		return
+	}
+	// The raw text keeps the newlines the parser's line joining removed, so
+	// a runaway region still counts as the lines it swallowed.
+	src := r.Raw
+	if len(src) == 0 {
+		src = r.Data
+	}
+	lines := bytes.Count(src, []byte("\n")) + 1
+	if lines > misuseLineSpan {
+		t.warn(path, r, "suspect-backtick",
+			fmt.Sprintf("statement region spans %d lines; missing a closing backtick?", lines))
+		return
+	}
+	// Semicolons are alien to python; braces only raise suspicion once the
+	// region also spans lines (dict and f-string literals are fine).
+	if bytes.ContainsRune(r.Data, ';') || (lines > 1 && bytes.ContainsRune(r.Data, '{')) {
+		t.warn(path, r, "suspect-backtick",
+			"statement region contains host-language punctuation; missing a closing backtick?")
+	}
+}
+
+// checkSplicedOutput records warnings about suspicious evaluator output:
+// nothing at all, or braces that don't balance (a common sign of a snippet
+// emitting a partial declaration).
+func (t *Translator) checkSplicedOutput(path string, r Region, out []byte) {
+	if t.Warnings == nil {
+		return
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		// Assertion blocks are output-free by design.
+		if r.Type == RegionTypePythonBlock && isAssertBlock(r.Data) {
+			return
+		}
+		t.warn(path, r, "empty-output", "region produced no output")
+		return
+	}
+	if !bytes.ContainsAny(out, "{}") {
+		return
+	}
+	var depth int
+	var inBlock bool
+	for _, line := range strings.Split(string(out), "\n") {
+		depth, inBlock = scanBraces(line, depth, inBlock)
+	}
+	if depth != 0 {
+		t.warn(path, r, "unbalanced-braces",
+			fmt.Sprintf("spliced output has unbalanced braces (depth %+d)", depth))
+	}
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// translateCollectingWarnings runs one unit through a Translator with a
+// warnings collector and a canned evaluator result.
+func translateCollectingWarnings(t *testing.T, input string, eval func(context.Context, RegionType, []byte) ([]byte, error)) []Warning {
+	t.Helper()
+	unit, err := ParseBytes([]byte(input))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	tr := &Translator{
+		Py:       fakePythonEvaluator{eval: eval},
+		Warnings: NewWarnings(),
+	}
+	if _, err := tr.translateUnit(t.Context(), unit, "in.japaya"); err != nil {
+		t.Fatalf("translateUnit: %v", err)
+	}
+	return tr.Warnings.All()
+}
+
+func TestWarnings_EmptyRegion(t *testing.T) {
+	t.Parallel()
+
+	got := translateCollectingWarnings(t, "class A { ` ` }\n",
+		func(ctx context.Context, rt RegionType, code []byte) ([]byte, error) {
+			return []byte("x"), nil
+		})
+	if len(got) != 1 || got[0].Rule != "empty-region" {
+		t.Fatalf("warnings = %v, want one empty-region", got)
+	}
+	if !strings.Contains(got[0].String(), "warning:") {
+		t.Errorf("String() = %q, want compiler-style format", got[0].String())
+	}
+}
+
+func TestWarnings_EmptyOutput(t *testing.T) {
+	t.Parallel()
+
+	got := translateCollectingWarnings(t, "class A { `v` }\n",
+		func(ctx context.Context, rt RegionType, code []byte) ([]byte, error) {
+			return []byte("  \n"), nil
+		})
+	if len(got) != 1 || got[0].Rule != "empty-output" {
+		t.Fatalf("warnings = %v, want one empty-output", got)
+	}
+}
+
+func TestWarnings_UnbalancedBraces(t *testing.T) {
+	t.Parallel()
+
+	got := translateCollectingWarnings(t, "`v`\n",
+		func(ctx context.Context, rt RegionType, code []byte) ([]byte, error) {
+			return []byte("void f() {\n"), nil
+		})
+	if len(got) != 1 || got[0].Rule != "unbalanced-braces" {
+		t.Fatalf("warnings = %v, want one unbalanced-braces", got)
+	}
+}
+
+func TestWarnings_BalancedOutputIsClean(t *testing.T) {
+	t.Parallel()
+
+	got := translateCollectingWarnings(t, "`v`\n",
+		func(ctx context.Context, rt RegionType, code []byte) ([]byte, error) {
+			return []byte("void f() { return; } // brace in comment: {\n"), nil
+		})
+	if len(got) != 0 {
+		t.Fatalf("warnings = %v, want none", got)
+	}
+}
+
+func TestWarnings_NilCollectorSkipsChecks(t *testing.T) {
+	t.Parallel()
+
+	unit, err := ParseBytes([]byte("` `\n"))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	tr := &Translator{Py: fakePythonEvaluator{eval: upperEval}}
+	if _, err := tr.translateUnit(t.Context(), unit, "in.japaya"); err != nil {
+		t.Fatalf("translateUnit: %v", err)
+	}
+}
+
+func TestWarnings_SuspectBacktick(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{"SpansManyLines", "`a\nb\nc\nd`\n", 1},
+		{"Semicolon", "`int x = 1;`\n", 1},
+		{"MultiLineBrace", "`f() {\nreturn`\n", 1},
+		{"DictLiteralFine", "`{1: 2}[1]`\n", 0},
+		{"PlainExpressionFine", "`n + 1`\n", 0},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := translateCollectingWarnings(t, tc.in,
+				func(ctx context.Context, rt RegionType, code []byte) ([]byte, error) {
+					return []byte("x"), nil
+				})
+			n := 0
+			for _, w := range got {
+				if w.Rule == "suspect-backtick" {
+					n++
+				}
+			}
+			if n != tc.want {
+				t.Fatalf("got %d suspect-backtick warning(s) in %v, want %d", n, got, tc.want)
+			}
+		})
+	}
+}
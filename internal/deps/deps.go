@@ -0,0 +1,169 @@
+// Package deps fetches snippet helper libraries declared in japaya.toml's
+// [deps] section into a managed directory, so template libraries can be
+// shared between projects without vendoring them. Each dependency lands in
+// its own subdirectory of the managed dir; pointing -python-dir at that dir
+// makes the libraries importable from snippets. Pinned revisions are
+// recorded in a lockfile next to the config, and dependencies whose lock
+// entry still matches are not fetched again.
+package deps
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Dep is one [deps] entry. Exactly one of Git and Archive is set.
+type Dep struct {
+	Name    string
+	Git     string // git URL, cloned at Rev
+	Rev     string // git revision to check out; empty means the remote HEAD
+	Archive string // zip or tar.gz archive, by URL or local path
+}
+
+// ParseConfig reads the [deps] section of a japaya.toml. The parser covers
+// the subset of TOML the section needs — quoted strings and one-line inline
+// tables — rather than pulling in a full TOML dependency:
+//
+//	[deps]
+//	textutils = "https://example.com/textutils.git"
+//	schema    = { git = "https://example.com/schema.git", rev = "v1.2.0" }
+//	legacy    = { archive = "https://example.com/legacy-1.0.zip" }
+//
+// A bare string is shorthand for a git URL. Sections other than [deps] are
+// ignored, so the config can grow unrelated settings.
+func ParseConfig(data []byte) ([]Dep, error) {
+	var deps []Dep
+	inDeps := false
+	for lineNo, line := range strings.Split(string(data), "\n") {
+		line = stripComment(line)
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			inDeps = line == "[deps]"
+			continue
+		}
+		if !inDeps {
+			continue
+		}
+
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("japaya.toml line %d: expected key = value", lineNo+1)
+		}
+		d := Dep{Name: strings.TrimSpace(key)}
+		if d.Name == "" {
+			return nil, fmt.Errorf("japaya.toml line %d: empty dependency name", lineNo+1)
+		}
+		if err := validateDepName(d.Name); err != nil {
+			return nil, fmt.Errorf("japaya.toml line %d: %w", lineNo+1, err)
+		}
+		val = strings.TrimSpace(val)
+		switch {
+		case strings.HasPrefix(val, "{"):
+			fields, err := parseInlineTable(val)
+			if err != nil {
+				return nil, fmt.Errorf("japaya.toml line %d: %w", lineNo+1, err)
+			}
+			d.Git = fields["git"]
+			d.Rev = fields["rev"]
+			d.Archive = fields["archive"]
+			for k := range fields {
+				if k != "git" && k != "rev" && k != "archive" {
+					return nil, fmt.Errorf("japaya.toml line %d: unknown field %q", lineNo+1, k)
+				}
+			}
+		default:
+			s, err := parseQuoted(val)
+			if err != nil {
+				return nil, fmt.Errorf("japaya.toml line %d: %w", lineNo+1, err)
+			}
+			d.Git = s
+		}
+		if (d.Git == "") == (d.Archive == "") {
+			return nil, fmt.Errorf("japaya.toml line %d: %q needs exactly one of git or archive", lineNo+1, d.Name)
+		}
+		if d.Archive != "" && d.Rev != "" {
+			return nil, fmt.Errorf("japaya.toml line %d: rev only applies to git dependencies", lineNo+1)
+		}
+		deps = append(deps, d)
+	}
+
+	sort.Slice(deps, func(i, j int) bool { return deps[i].Name < deps[j].Name })
+	for i := 1; i < len(deps); i++ {
+		if deps[i].Name == deps[i-1].Name {
+			return nil, fmt.Errorf("japaya.toml: duplicate dependency %q", deps[i].Name)
+		}
+	}
+	return deps, nil
+}
+
+// validateDepName rejects names that would escape the managed deps dir:
+// Sync joins the name onto the directory and replaces the result wholesale,
+// so a name like ".." or "../x" would delete and recreate trees outside it.
+func validateDepName(name string) error {
+	if name == "." || name == ".." {
+		return fmt.Errorf("invalid dependency name %q", name)
+	}
+	if strings.ContainsAny(name, `/\`) || filepath.IsAbs(name) {
+		return fmt.Errorf("dependency name %q must be a plain directory name", name)
+	}
+	return nil
+}
+
+// stripComment removes a # comment, honoring quotes so URLs with fragments
+// survive.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		b := line[i]
+		if quote != 0 {
+			if b == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch b {
+		case '"', '\'':
+			quote = b
+		case '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseInlineTable parses `{ key = "value", ... }` into a map.
+func parseInlineTable(val string) (map[string]string, error) {
+	if !strings.HasPrefix(val, "{") || !strings.HasSuffix(val, "}") {
+		return nil, fmt.Errorf("malformed inline table %q", val)
+	}
+	fields := make(map[string]string)
+	inner := strings.TrimSpace(val[1 : len(val)-1])
+	if inner == "" {
+		return fields, nil
+	}
+	for _, pair := range strings.Split(inner, ",") {
+		key, raw, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed inline table entry %q", pair)
+		}
+		s, err := parseQuoted(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, err
+		}
+		fields[strings.TrimSpace(key)] = s
+	}
+	return fields, nil
+}
+
+// parseQuoted parses a double- or single-quoted TOML string.
+func parseQuoted(val string) (string, error) {
+	if len(val) < 2 || (val[0] != '"' && val[0] != '\'') || val[len(val)-1] != val[0] {
+		return "", fmt.Errorf("expected a quoted string, got %q", val)
+	}
+	return val[1 : len(val)-1], nil
+}
@@ -0,0 +1,234 @@
+package deps
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	t.Parallel()
+
+	config := `
+# helper libraries
+[settings]
+ignored = "yes"
+
+[deps]
+textutils = "https://example.com/textutils.git" # shorthand
+schema    = { git = "https://example.com/schema.git", rev = "v1.2.0" }
+legacy    = { archive = "https://example.com/legacy-1.0.zip" }
+`
+	deps, err := ParseConfig([]byte(config))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+
+	want := []Dep{
+		{Name: "legacy", Archive: "https://example.com/legacy-1.0.zip"},
+		{Name: "schema", Git: "https://example.com/schema.git", Rev: "v1.2.0"},
+		{Name: "textutils", Git: "https://example.com/textutils.git"},
+	}
+	if len(deps) != len(want) {
+		t.Fatalf("got %d deps, want %d: %+v", len(deps), len(want), deps)
+	}
+	for i, d := range deps {
+		if d != want[i] {
+			t.Errorf("deps[%d] = %+v, want %+v", i, d, want[i])
+		}
+	}
+}
+
+func TestParseConfig_Errors(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"UnknownField", "[deps]\nx = { git = \"u\", branch = \"main\" }\n", "unknown field"},
+		{"RevWithArchive", "[deps]\nx = { archive = \"u.zip\", rev = \"v1\" }\n", "rev only applies"},
+		{"BothSources", "[deps]\nx = { git = \"u\", archive = \"u.zip\" }\n", "exactly one"},
+		{"NeitherSource", "[deps]\nx = { }\n", "exactly one"},
+		{"Unquoted", "[deps]\nx = u\n", "quoted string"},
+		{"Duplicate", "[deps]\nx = \"a\"\nx = \"b\"\n", "duplicate"},
+		// Names reach filepath.Join and os.RemoveAll in Sync, so anything
+		// that could escape the managed directory is rejected up front.
+		{"DotDotName", "[deps]\n.. = \"u\"\n", "invalid dependency name"},
+		{"SeparatorName", "[deps]\n../x = \"u\"\n", "plain directory name"},
+		{"BackslashName", "[deps]\na\\b = \"u\"\n", "plain directory name"},
+		{"AbsoluteName", "[deps]\n/tmp/x = \"u\"\n", "plain directory name"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := ParseConfig([]byte(tc.config))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("ParseConfig error = %v, want it to mention %q", err, tc.want)
+			}
+		})
+	}
+}
+
+// writeZipArchive builds a zip at path with the given name→contents entries.
+func writeZipArchive(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, contents := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip create %q: %v", name, err)
+		}
+		if _, err := w.Write([]byte(contents)); err != nil {
+			t.Fatalf("zip write %q: %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write %q: %v", path, err)
+	}
+}
+
+func TestSync_ArchiveExtractedAndLocked(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "lib.zip")
+	writeZipArchive(t, archive, map[string]string{
+		"textutils.py":    "def shout(s): return s.upper()\n",
+		"sub/__init__.py": "",
+		"sub/helpers.py":  "PI = 3\n",
+	})
+
+	depsDir := filepath.Join(dir, "deps")
+	lockPath := filepath.Join(dir, LockFileName)
+	ds := []Dep{{Name: "textutils", Archive: archive}}
+
+	if err := Sync(ds, depsDir, lockPath); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(depsDir, "textutils", "textutils.py"))
+	if err != nil {
+		t.Fatalf("extracted file: %v", err)
+	}
+	if !strings.Contains(string(got), "shout") {
+		t.Errorf("extracted contents = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(depsDir, "textutils", "sub", "helpers.py")); err != nil {
+		t.Errorf("nested file: %v", err)
+	}
+
+	lockData, err := os.ReadFile(lockPath)
+	if err != nil {
+		t.Fatalf("lockfile: %v", err)
+	}
+	var lock map[string]lockEntry
+	if err := json.Unmarshal(lockData, &lock); err != nil {
+		t.Fatalf("parse lockfile: %v", err)
+	}
+	entry := lock["textutils"]
+	if entry.Archive != archive || entry.SHA256 == "" {
+		t.Errorf("lock entry = %+v, want archive source with sha256", entry)
+	}
+
+	// A second sync with a matching lock must not refetch: deleting the
+	// source archive makes any fetch attempt fail loudly.
+	if err := os.Remove(archive); err != nil {
+		t.Fatal(err)
+	}
+	if err := Sync(ds, depsDir, lockPath); err != nil {
+		t.Fatalf("second Sync should be a no-op, got: %v", err)
+	}
+}
+
+func TestSync_ArchiveRejectsEscapingEntries(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "evil.zip")
+	writeZipArchive(t, archive, map[string]string{"../escape.py": "pwned = True\n"})
+
+	err := Sync([]Dep{{Name: "evil", Archive: archive}},
+		filepath.Join(dir, "deps"), filepath.Join(dir, LockFileName))
+	if err == nil || !strings.Contains(err.Error(), "escapes") {
+		t.Errorf("Sync error = %v, want path-escape rejection", err)
+	}
+}
+
+func TestSync_GitDependencyPinnedToRev(t *testing.T) {
+	t.Parallel()
+
+	git, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git not on PATH")
+	}
+
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "lib")
+	if err := os.MkdirAll(repo, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command(git, append([]string{"-C", repo,
+			"-c", "user.email=test@example.com", "-c", "user.name=test"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	run("init", "--quiet")
+	if err := os.WriteFile(filepath.Join(repo, "lib.py"), []byte("V = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", "-A")
+	run("commit", "--quiet", "-m", "v1")
+	pinned := run("rev-parse", "HEAD")
+	if err := os.WriteFile(filepath.Join(repo, "lib.py"), []byte("V = 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", "-A")
+	run("commit", "--quiet", "-m", "v2")
+
+	depsDir := filepath.Join(dir, "deps")
+	lockPath := filepath.Join(dir, LockFileName)
+	ds := []Dep{{Name: "lib", Git: repo, Rev: pinned}}
+
+	if err := Sync(ds, depsDir, lockPath); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(depsDir, "lib", "lib.py"))
+	if err != nil {
+		t.Fatalf("fetched file: %v", err)
+	}
+	if string(got) != "V = 1\n" {
+		t.Errorf("lib.py = %q, want the pinned revision's contents", got)
+	}
+	if _, err := os.Stat(filepath.Join(depsDir, "lib", ".git")); !os.IsNotExist(err) {
+		t.Errorf(".git should be stripped from fetched dependencies")
+	}
+
+	lockData, err := os.ReadFile(lockPath)
+	if err != nil {
+		t.Fatalf("lockfile: %v", err)
+	}
+	var lock map[string]lockEntry
+	if err := json.Unmarshal(lockData, &lock); err != nil {
+		t.Fatalf("parse lockfile: %v", err)
+	}
+	if lock["lib"].Commit != pinned {
+		t.Errorf("lock commit = %q, want %q", lock["lib"].Commit, pinned)
+	}
+}
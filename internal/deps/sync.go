@@ -0,0 +1,291 @@
+package deps
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// LockFileName sits next to japaya.toml and records what each dependency
+// resolved to — the exact commit for git, the archive's sha256 otherwise —
+// so a later sync can tell pinned-and-present from needs-fetching.
+const LockFileName = "japaya.lock.json"
+
+// lockEntry is one dependency's resolved state.
+type lockEntry struct {
+	Git     string `json:"git,omitempty"`
+	Rev     string `json:"rev,omitempty"`
+	Commit  string `json:"commit,omitempty"`
+	Archive string `json:"archive,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+}
+
+// Sync brings dir in line with deps, fetching anything missing or changed
+// and rewriting the lockfile at lockPath. Dependencies whose lock entry
+// matches the config and whose directory exists are left untouched, so a
+// pinned sync is cheap and offline.
+func Sync(deps []Dep, dir, lockPath string) error {
+	lock, err := readLock(lockPath)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("mkdir deps dir %q: %w", dir, err)
+	}
+
+	updated := make(map[string]lockEntry, len(deps))
+	for _, d := range deps {
+		target := filepath.Join(dir, d.Name)
+		if entry, ok := lock[d.Name]; ok && entrySatisfies(entry, d) && dirExists(target) {
+			updated[d.Name] = entry
+			continue
+		}
+
+		var entry lockEntry
+		if d.Git != "" {
+			entry, err = fetchGit(d, target)
+		} else {
+			entry, err = fetchArchive(d, target)
+		}
+		if err != nil {
+			return fmt.Errorf("sync %q: %w", d.Name, err)
+		}
+		updated[d.Name] = entry
+	}
+
+	return writeLock(lockPath, updated)
+}
+
+// entrySatisfies reports whether a lock entry still pins the configured
+// source. An unpinned git dependency (no rev) is refetched every sync,
+// since its HEAD may have moved.
+func entrySatisfies(entry lockEntry, d Dep) bool {
+	if d.Git != "" {
+		return entry.Git == d.Git && entry.Rev == d.Rev && d.Rev != "" && entry.Commit != ""
+	}
+	return entry.Archive == d.Archive && entry.SHA256 != ""
+}
+
+// fetchGit clones the dependency at its pinned revision, strips the .git
+// directory and moves the tree into place.
+func fetchGit(d Dep, target string) (lockEntry, error) {
+	git, err := exec.LookPath("git")
+	if err != nil {
+		return lockEntry{}, fmt.Errorf("git dependencies need git on PATH: %w", err)
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Dir(target), ".japaya-dep-*")
+	if err != nil {
+		return lockEntry{}, err
+	}
+	defer os.RemoveAll(tmp)
+
+	clone := filepath.Join(tmp, "clone")
+	if out, err := exec.Command(git, "clone", "--quiet", d.Git, clone).CombinedOutput(); err != nil {
+		return lockEntry{}, fmt.Errorf("git clone %q: %v\n%s", d.Git, err, out)
+	}
+	if d.Rev != "" {
+		if out, err := exec.Command(git, "-C", clone, "checkout", "--quiet", d.Rev).CombinedOutput(); err != nil {
+			return lockEntry{}, fmt.Errorf("git checkout %q: %v\n%s", d.Rev, err, out)
+		}
+	}
+	out, err := exec.Command(git, "-C", clone, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return lockEntry{}, fmt.Errorf("git rev-parse: %w", err)
+	}
+	commit := strings.TrimSpace(string(out))
+
+	if err := os.RemoveAll(filepath.Join(clone, ".git")); err != nil {
+		return lockEntry{}, err
+	}
+	if err := replaceDir(clone, target); err != nil {
+		return lockEntry{}, err
+	}
+	return lockEntry{Git: d.Git, Rev: d.Rev, Commit: commit}, nil
+}
+
+// fetchArchive downloads (or reads) the archive, extracts it and moves the
+// tree into place.
+func fetchArchive(d Dep, target string) (lockEntry, error) {
+	data, err := readSource(d.Archive)
+	if err != nil {
+		return lockEntry{}, err
+	}
+	sum := sha256.Sum256(data)
+
+	tmp, err := os.MkdirTemp(filepath.Dir(target), ".japaya-dep-*")
+	if err != nil {
+		return lockEntry{}, err
+	}
+	defer os.RemoveAll(tmp)
+
+	extracted := filepath.Join(tmp, "extracted")
+	switch {
+	case strings.HasSuffix(d.Archive, ".zip"):
+		err = extractZip(data, extracted)
+	case strings.HasSuffix(d.Archive, ".tar.gz"), strings.HasSuffix(d.Archive, ".tgz"):
+		err = extractTarGz(data, extracted)
+	default:
+		err = fmt.Errorf("unsupported archive type %q (want .zip, .tar.gz or .tgz)", d.Archive)
+	}
+	if err != nil {
+		return lockEntry{}, err
+	}
+
+	if err := replaceDir(extracted, target); err != nil {
+		return lockEntry{}, err
+	}
+	return lockEntry{Archive: d.Archive, SHA256: hex.EncodeToString(sum[:])}, nil
+}
+
+// readSource fetches an http(s) URL or reads a local file.
+func readSource(src string) ([]byte, error) {
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		resp, err := http.Get(src)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetch %q: %s", src, resp.Status)
+		}
+		return io.ReadAll(resp.Body)
+	}
+	return os.ReadFile(src)
+}
+
+// safeJoin joins an archive entry name under root, rejecting entries that
+// would escape it.
+func safeJoin(root, name string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	if cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) || filepath.IsAbs(cleaned) {
+		return "", fmt.Errorf("archive entry %q escapes the extraction dir", name)
+	}
+	return filepath.Join(root, cleaned), nil
+}
+
+func extractZip(data []byte, root string) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		path, err := safeJoin(root, f.Name)
+		if err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		contents, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, contents, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractTarGz(data []byte, root string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		path, err := safeJoin(root, hdr.Name)
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			contents, err := io.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, contents, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// replaceDir atomically-ish swaps target for src: the old tree goes away
+// only after the new one extracted successfully.
+func replaceDir(src, target string) error {
+	if err := os.RemoveAll(target); err != nil {
+		return err
+	}
+	return os.Rename(src, target)
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// readLock loads the lockfile, returning an empty map when it doesn't exist.
+func readLock(path string) (map[string]lockEntry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]lockEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	lock := map[string]lockEntry{}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("parse lockfile %q: %w", path, err)
+	}
+	return lock, nil
+}
+
+// writeLock rewrites the lockfile. encoding/json sorts map keys, so the
+// file is stable and diffs stay minimal.
+func writeLock(path string, lock map[string]lockEntry) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
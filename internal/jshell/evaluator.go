@@ -0,0 +1,40 @@
+package jshell
+
+import (
+	"context"
+	"sync"
+)
+
+// Evaluator implements core.JShellEvaluator on top of JShellWorker.
+//
+// JShell startup is slow (it boots a JVM), so the worker is started lazily on
+// first use; inputs with no `java!` regions never pay for it.
+type Evaluator struct {
+	jshellCmd string
+
+	once     sync.Once
+	w        *JShellWorker
+	startErr error
+}
+
+func NewEvaluator(jshellCmd string) *Evaluator {
+	return &Evaluator{jshellCmd: jshellCmd}
+}
+
+func (e *Evaluator) Close() error {
+	if e.w == nil {
+		return nil
+	}
+	return e.w.Close()
+}
+
+// Eval satisfies core.JShellEvaluator.
+func (e *Evaluator) Eval(ctx context.Context, code []byte) ([]byte, error) {
+	e.once.Do(func() {
+		e.w, e.startErr = StartJShellWorker(e.jshellCmd)
+	})
+	if e.startErr != nil {
+		return nil, e.startErr
+	}
+	return e.w.Eval(ctx, code)
+}
@@ -0,0 +1,215 @@
+package jshell
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+)
+
+// Sentinels bracketing a snippet's value on the worker's stdout. The payload
+// markers only appear if the wrapped statement actually ran; the done marker
+// is printed by a separate statement so we can detect failed snippets.
+const (
+	markBegin = "\x01JAPAYA_B\x01"
+	markEnd   = "\x01JAPAYA_E\x01"
+	markDone  = "\x01JAPAYA_DONE\x01"
+)
+
+// JShellWorker is a long-lived jshell process that evaluates java expressions.
+// It runs with silent feedback; each request wraps the expression in a print
+// statement bracketed by sentinels, so output can be extracted and failures
+// detected without parsing jshell's own feedback.
+type JShellWorker struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+
+	// stderr is collected in the background for error messages.
+	stderrMu  sync.Mutex
+	stderrBuf bytes.Buffer
+
+	mu sync.Mutex
+
+	closeOnce  sync.Once
+	closeError error
+
+	closing atomic.Bool
+}
+
+// Get the jshell executable command
+func defaultJShellCmd() string {
+	return "jshell"
+}
+
+func StartJShellWorker(jshellCmd string) (*JShellWorker, error) {
+	// Load with defaults if not provided
+	if jshellCmd == "" {
+		jshellCmd = defaultJShellCmd()
+	}
+
+	// -q keeps startup quiet; feedback is silenced below so snippet output is
+	// the only thing on stdout. --execution local avoids a second JVM.
+	cmd := exec.Command(jshellCmd, "-q", "--execution", "local")
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	w := &JShellWorker{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+	}
+
+	// Drain stderr so the process can't block on it, keeping what we see for
+	// error messages.
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := stderr.Read(buf)
+			if n > 0 {
+				w.stderrMu.Lock()
+				w.stderrBuf.Write(buf[:n])
+				w.stderrMu.Unlock()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// Silence feedback; from here on stdout carries only snippet output.
+	if _, err := io.WriteString(stdin, "/set feedback silent\n"); err != nil {
+		_ = cmd.Process.Kill()
+		return nil, fmt.Errorf("failed configuring jshell: %w", err)
+	}
+
+	return w, nil
+}
+
+// Closes stdin and waits for the jshell process to exit
+func (w *JShellWorker) Close() error {
+	w.closeOnce.Do(func() {
+		w.closing.Store(true)
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		_, _ = io.WriteString(w.stdin, "/exit\n")
+		_ = w.stdin.Close()
+		w.closeError = w.cmd.Wait()
+	})
+
+	return w.closeError
+}
+
+func (w *JShellWorker) IsClosed() bool {
+	return w.closing.Load()
+}
+
+// Evaluate a java expression and return its string form
+func (w *JShellWorker) Eval(ctx context.Context, code []byte) ([]byte, error) {
+	if w.IsClosed() {
+		return nil, fmt.Errorf("jshell worker is closed")
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.IsClosed() {
+		return nil, fmt.Errorf("jshell worker is closed")
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	return w.evalOne(code)
+}
+
+// Evaluate a single expression
+//
+// NOTE: This should be run under the mutex
+func (w *JShellWorker) evalOne(code []byte) ([]byte, error) {
+	w.stderrMu.Lock()
+	w.stderrBuf.Reset()
+	w.stderrMu.Unlock()
+
+	// One statement prints the bracketed value; a second prints the done
+	// marker. If the expression fails to compile or throws, the first prints
+	// nothing and we detect the missing brackets below.
+	var req bytes.Buffer
+	fmt.Fprintf(&req, "System.out.print(\"%s\" + String.valueOf(%s) + \"%s\");\n",
+		javaEscape(markBegin), string(code), javaEscape(markEnd))
+	fmt.Fprintf(&req, "System.out.print(\"%s\");\n", javaEscape(markDone))
+	fmt.Fprint(&req, "System.out.flush();\n")
+
+	if _, err := w.stdin.Write(req.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed writing to jshell worker: %w", err)
+	}
+
+	// Read stdout until the done marker.
+	var out bytes.Buffer
+	doneBytes := []byte(markDone)
+	for !bytes.Contains(out.Bytes(), doneBytes) {
+		b, err := w.stdout.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("failed reading from jshell worker: %w", err)
+		}
+		out.WriteByte(b)
+	}
+
+	payload := out.Bytes()
+	begin := bytes.Index(payload, []byte(markBegin))
+	end := bytes.Index(payload, []byte(markEnd))
+	if begin < 0 || end < 0 || end < begin {
+		w.stderrMu.Lock()
+		stderr := w.stderrBuf.String()
+		w.stderrMu.Unlock()
+
+		msg := "jshell snippet failed to evaluate"
+		if stderr != "" {
+			msg += ": " + stderr
+		}
+		return nil, fmt.Errorf("%s", msg)
+	}
+
+	return append([]byte(nil), payload[begin+len(markBegin):end]...), nil
+}
+
+// javaEscape renders a sentinel as java string-literal escapes.
+func javaEscape(s string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		if r < 0x20 {
+			fmt.Fprintf(&b, "\\u%04x", r)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
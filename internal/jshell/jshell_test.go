@@ -0,0 +1,46 @@
+package jshell
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+)
+
+func mustStart(t *testing.T) *JShellWorker {
+	t.Helper()
+
+	if _, err := exec.LookPath(defaultJShellCmd()); err != nil {
+		t.Skip("jshell not found on PATH")
+	}
+
+	w, err := StartJShellWorker("")
+	if err != nil {
+		t.Fatalf("StartJShellWorker error: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	return w
+}
+
+func TestStartEvalClose_BasicExpression(t *testing.T) {
+	w := mustStart(t)
+
+	out, err := w.Eval(context.Background(), []byte("1+2"))
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	if string(out) != "3" {
+		t.Fatalf("unexpected out: %q", string(out))
+	}
+
+	if w.IsClosed() {
+		t.Fatalf("expected open worker")
+	}
+}
+
+func TestEval_BadExpressionFails(t *testing.T) {
+	w := mustStart(t)
+
+	if _, err := w.Eval(context.Background(), []byte("no such expr")); err == nil {
+		t.Fatalf("expected error for invalid expression")
+	}
+}
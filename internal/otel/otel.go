@@ -0,0 +1,227 @@
+// Package otel exports translation spans over OTLP/HTTP so japaya shows up
+// in existing build observability. It speaks the JSON encoding of the OTLP
+// trace protocol directly rather than pulling in the OpenTelemetry SDK; the
+// handful of spans a translation run produces doesn't justify the dependency.
+package otel
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchSize is how many finished spans accumulate before an export; Close
+// flushes whatever remains.
+const batchSize = 64
+
+// tracesPath is the standard OTLP/HTTP path for trace data.
+const tracesPath = "/v1/traces"
+
+// Exporter implements core.Tracer by batching finished spans and POSTing them
+// to an OTLP/HTTP collector. All spans from one Exporter share a trace ID, so
+// a run appears as a single trace. Export failures are remembered and
+// reported by Close; tracing never fails a build.
+type Exporter struct {
+	url     string
+	service string
+	client  *http.Client
+
+	mu      sync.Mutex
+	traceID string
+	spans   []span
+	lastErr error
+}
+
+// span is one finished span awaiting export.
+type span struct {
+	name       string
+	spanID     string
+	start, end time.Time
+	attrs      map[string]string
+}
+
+// New returns an Exporter posting to the collector at endpoint, a base URL
+// like "http://localhost:4318"; the standard /v1/traces path is appended
+// unless endpoint already ends with it. service becomes the resource's
+// service.name.
+func New(endpoint string, service string) *Exporter {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, tracesPath) {
+		url += tracesPath
+	}
+	return &Exporter{
+		url:     url,
+		service: service,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		traceID: randomHex(16),
+	}
+}
+
+// StartSpan implements core.Tracer. The span is recorded for export when the
+// returned func runs.
+func (e *Exporter) StartSpan(name string, attrs map[string]string) func() {
+	s := span{
+		name:   name,
+		spanID: randomHex(8),
+		start:  time.Now(),
+		attrs:  attrs,
+	}
+	return func() {
+		s.end = time.Now()
+
+		e.mu.Lock()
+		e.spans = append(e.spans, s)
+		var batch []span
+		if len(e.spans) >= batchSize {
+			batch = e.spans
+			e.spans = nil
+		}
+		e.mu.Unlock()
+
+		if batch != nil {
+			e.export(batch)
+		}
+	}
+}
+
+// Close flushes any buffered spans and returns the most recent export error,
+// if any.
+func (e *Exporter) Close() error {
+	e.mu.Lock()
+	batch := e.spans
+	e.spans = nil
+	e.mu.Unlock()
+
+	if len(batch) > 0 {
+		e.export(batch)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.lastErr
+}
+
+// export POSTs one batch, recording any failure for Close to report.
+func (e *Exporter) export(batch []span) {
+	body, err := json.Marshal(e.payload(batch))
+	if err != nil {
+		e.setErr(err)
+		return
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		e.setErr(err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		e.setErr(fmt.Errorf("otlp export: collector returned %s", resp.Status))
+	}
+}
+
+func (e *Exporter) setErr(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastErr = err
+}
+
+// The otlp* types mirror the JSON encoding of the OTLP trace protocol,
+// limited to the fields japaya populates.
+type otlpPayload struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// payload builds the OTLP request for one batch.
+func (e *Exporter) payload(batch []span) otlpPayload {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		spans = append(spans, otlpSpan{
+			TraceID:           e.traceID,
+			SpanID:            s.spanID,
+			Name:              s.name,
+			Kind:              1, // SPAN_KIND_INTERNAL
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        attributes(s.attrs),
+		})
+	}
+	return otlpPayload{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{{
+			Key:   "service.name",
+			Value: otlpValue{StringValue: e.service},
+		}}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "japaya"},
+			Spans: spans,
+		}},
+	}}}
+}
+
+// attributes converts a span's attribute map, sorted for stable output.
+func attributes(attrs map[string]string) []otlpAttribute {
+	if len(attrs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]otlpAttribute, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, otlpAttribute{Key: k, Value: otlpValue{StringValue: attrs[k]}})
+	}
+	return out
+}
+
+// randomHex returns n random bytes hex-encoded, as OTLP IDs require.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,87 @@
+package otel
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestExporter_PostsOTLPSpans(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var payloads []otlpPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != tracesPath {
+			t.Errorf("collector hit %q, want %q", r.URL.Path, tracesPath)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var p otlpPayload
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Errorf("bad payload: %v", err)
+		}
+		mu.Lock()
+		payloads = append(payloads, p)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	exp := New(srv.URL, "japaya-test")
+	end := exp.StartSpan("japaya.translate_file", map[string]string{"japaya.path": "A.japaya"})
+	end()
+	end = exp.StartSpan("japaya.eval_region", map[string]string{"japaya.region_index": "1"})
+	end()
+	if err := exp.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(payloads) != 1 {
+		t.Fatalf("got %d exports, want 1 batched export", len(payloads))
+	}
+	rs := payloads[0].ResourceSpans
+	if len(rs) != 1 || len(rs[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected payload shape: %+v", payloads[0])
+	}
+	if got := rs[0].Resource.Attributes[0]; got.Key != "service.name" || got.Value.StringValue != "japaya-test" {
+		t.Errorf("resource attribute = %+v, want service.name=japaya-test", got)
+	}
+	spans := rs[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	if spans[0].Name != "japaya.translate_file" || spans[1].Name != "japaya.eval_region" {
+		t.Errorf("span names = %q, %q", spans[0].Name, spans[1].Name)
+	}
+	if spans[0].TraceID != spans[1].TraceID || len(spans[0].TraceID) != 32 {
+		t.Errorf("spans should share one 16-byte trace ID, got %q and %q", spans[0].TraceID, spans[1].TraceID)
+	}
+	if spans[0].SpanID == spans[1].SpanID || len(spans[0].SpanID) != 16 {
+		t.Errorf("span IDs should be distinct 8-byte IDs, got %q and %q", spans[0].SpanID, spans[1].SpanID)
+	}
+	if spans[0].EndTimeUnixNano < spans[0].StartTimeUnixNano {
+		t.Errorf("span ends before it starts: %+v", spans[0])
+	}
+	if got := spans[1].Attributes[0]; got.Key != "japaya.region_index" || got.Value.StringValue != "1" {
+		t.Errorf("span attribute = %+v, want japaya.region_index=1", got)
+	}
+}
+
+func TestExporter_CloseReportsCollectorErrors(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no thanks", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	exp := New(srv.URL, "japaya-test")
+	exp.StartSpan("japaya.translate_file", nil)()
+	if err := exp.Close(); err == nil {
+		t.Fatal("Close should surface the collector's rejection")
+	}
+}
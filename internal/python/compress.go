@@ -0,0 +1,32 @@
+package python
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// compressionThreshold is the payload size above which request code and
+// response output travel gzip-compressed. Small payloads aren't worth the
+// CPU; large generated lookup tables shrink dramatically, which matters most
+// when the evaluator sits at the far end of a slow pipe.
+const compressionThreshold = 32 << 10
+
+// gzipBytes compresses data for a protocol payload.
+func gzipBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(data)
+	_ = zw.Close()
+	return buf.Bytes()
+}
+
+// gunzipBytes reverses gzipBytes.
+func gunzipBytes(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
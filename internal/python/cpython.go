@@ -0,0 +1,184 @@
+//go:build cpython
+
+package python
+
+/*
+#cgo pkg-config: python3-embed
+#include <Python.h>
+#include <stdlib.h>
+
+// Run code through the shim installed by cpythonShim and return the result
+// as a freshly-allocated UTF-8 C string, or NULL on error (with the error
+// text in *errOut, also freshly allocated).
+static char *japayaEval(const char *kind, const char *code, char **errOut) {
+	PyObject *mainMod = PyImport_AddModule("__main__"); // borrowed
+	PyObject *globals = PyModule_GetDict(mainMod);      // borrowed
+
+	PyObject *fn = PyDict_GetItemString(globals, "_japaya_run"); // borrowed
+	if (fn == NULL) {
+		*errOut = strdup("cpython shim not initialized");
+		return NULL;
+	}
+
+	PyObject *result = PyObject_CallFunction(fn, "ss", kind, code);
+	if (result == NULL) {
+		PyObject *type, *value, *tb;
+		PyErr_Fetch(&type, &value, &tb);
+		PyObject *str = value != NULL ? PyObject_Str(value) : NULL;
+		const char *msg = str != NULL ? PyUnicode_AsUTF8(str) : "unknown python error";
+		*errOut = strdup(msg != NULL ? msg : "unknown python error");
+		Py_XDECREF(str);
+		Py_XDECREF(type);
+		Py_XDECREF(value);
+		Py_XDECREF(tb);
+		return NULL;
+	}
+
+	const char *out = PyUnicode_AsUTF8(result);
+	char *copy = out != NULL ? strdup(out) : strdup("");
+	Py_DECREF(result);
+	return copy;
+}
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"github.com/justinottesen/japaya/internal/core"
+)
+
+// cpythonShim mirrors the subprocess worker's stmt/block semantics inside an
+// in-process interpreter: stmt evaluates an expression and returns str() of
+// the result, block execs statements and returns captured stdout.
+const cpythonShim = `
+import io
+from contextlib import redirect_stdout
+
+def _japaya_run(kind, code):
+    globs = {"__builtins__": __builtins__}
+    if kind == "stmt":
+        result = eval(code, globs, {})
+        return "" if result is None else str(result)
+    buf = io.StringIO()
+    with redirect_stdout(buf):
+        exec(code, globs, {})
+    return buf.getvalue()
+`
+
+// CPythonEvaluator runs snippets on an interpreter embedded via cgo,
+// avoiding subprocess and JSON-protocol overhead. Only available when built
+// with the cpython tag (requires python3-embed via pkg-config).
+//
+// CPython must stay on the OS thread that initialized it, so all work is
+// funnelled to a dedicated locked goroutine.
+type CPythonEvaluator struct {
+	reqs chan cpythonRequest
+	done chan struct{}
+}
+
+type cpythonRequest struct {
+	kind string
+	code []byte
+	resp chan cpythonResponse
+}
+
+type cpythonResponse struct {
+	out []byte
+	err error
+}
+
+func NewCPythonEvaluator() (*CPythonEvaluator, error) {
+	e := &CPythonEvaluator{
+		reqs: make(chan cpythonRequest),
+		done: make(chan struct{}),
+	}
+
+	initErr := make(chan error, 1)
+	go e.loop(initErr)
+	if err := <-initErr; err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// loop owns the interpreter for the evaluator's lifetime.
+func (e *CPythonEvaluator) loop(initErr chan<- error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	C.Py_Initialize()
+	if C.Py_IsInitialized() == 0 {
+		initErr <- fmt.Errorf("Py_Initialize failed")
+		return
+	}
+
+	shim := C.CString(cpythonShim)
+	rc := C.PyRun_SimpleString(shim)
+	C.free(unsafe.Pointer(shim))
+	if rc != 0 {
+		C.Py_Finalize()
+		initErr <- fmt.Errorf("failed installing cpython shim")
+		return
+	}
+	initErr <- nil
+
+	for req := range e.reqs {
+		req.resp <- evalOnInterpreter(req.kind, req.code)
+	}
+
+	C.Py_Finalize()
+	close(e.done)
+}
+
+func evalOnInterpreter(kind string, code []byte) cpythonResponse {
+	cKind := C.CString(kind)
+	cCode := C.CString(string(code))
+	defer C.free(unsafe.Pointer(cKind))
+	defer C.free(unsafe.Pointer(cCode))
+
+	var cErr *C.char
+	cOut := C.japayaEval(cKind, cCode, &cErr)
+	if cOut == nil {
+		msg := C.GoString(cErr)
+		C.free(unsafe.Pointer(cErr))
+		return cpythonResponse{err: &PythonError{Kind: kind, ErrMsg: msg}}
+	}
+
+	out := []byte(C.GoString(cOut))
+	C.free(unsafe.Pointer(cOut))
+	return cpythonResponse{out: out}
+}
+
+// Close shuts down the interpreter thread.
+func (e *CPythonEvaluator) Close() error {
+	close(e.reqs)
+	<-e.done
+	return nil
+}
+
+// Eval satisfies core.PythonEvaluator.
+func (e *CPythonEvaluator) Eval(ctx context.Context, t core.RegionType, code []byte) ([]byte, error) {
+	var kind string
+	switch t {
+	case core.RegionTypePythonStatement:
+		kind = "stmt"
+	case core.RegionTypePythonBlock:
+		kind = "block"
+	default:
+		return nil, fmt.Errorf("cpython evaluator received non-python region type: %v", t)
+	}
+
+	req := cpythonRequest{kind: kind, code: code, resp: make(chan cpythonResponse, 1)}
+	select {
+	case e.reqs <- req:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	resp := <-req.resp
+	return resp.out, resp.err
+}
@@ -0,0 +1,27 @@
+//go:build !cpython
+
+package python
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/justinottesen/japaya/internal/core"
+)
+
+// CPythonEvaluator is only available when built with the cpython tag; see
+// cpython.go. This stub keeps default (cgo-free) builds working.
+type CPythonEvaluator struct{}
+
+var errCPythonNotBuilt = fmt.Errorf("cpython backend not built in (rebuild with -tags cpython)")
+
+func NewCPythonEvaluator() (*CPythonEvaluator, error) {
+	return nil, errCPythonNotBuilt
+}
+
+func (e *CPythonEvaluator) Close() error { return nil }
+
+// Eval satisfies core.PythonEvaluator.
+func (e *CPythonEvaluator) Eval(ctx context.Context, t core.RegionType, code []byte) ([]byte, error) {
+	return nil, errCPythonNotBuilt
+}
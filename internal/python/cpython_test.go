@@ -0,0 +1,38 @@
+//go:build cpython
+
+package python
+
+import (
+	"context"
+	"testing"
+
+	"github.com/justinottesen/japaya/internal/core"
+)
+
+func TestCPythonEvaluator_StmtAndBlock(t *testing.T) {
+	e, err := NewCPythonEvaluator()
+	if err != nil {
+		t.Fatalf("NewCPythonEvaluator error: %v", err)
+	}
+	t.Cleanup(func() { _ = e.Close() })
+
+	out, err := e.Eval(context.Background(), core.RegionTypePythonStatement, []byte("1+2"))
+	if err != nil {
+		t.Fatalf("Eval stmt error: %v", err)
+	}
+	if string(out) != "3" {
+		t.Fatalf("unexpected out: %q", string(out))
+	}
+
+	out, err = e.Eval(context.Background(), core.RegionTypePythonBlock, []byte("print('hi')"))
+	if err != nil {
+		t.Fatalf("Eval block error: %v", err)
+	}
+	if string(out) != "hi\n" {
+		t.Fatalf("unexpected out: %q", string(out))
+	}
+
+	if _, err := e.Eval(context.Background(), core.RegionTypePythonStatement, []byte("1/0")); err == nil {
+		t.Fatalf("expected error for division by zero")
+	}
+}
@@ -0,0 +1,83 @@
+package python
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Container-side mount points for the worker script and helper modules.
+const (
+	dockerWorkerDir = "/japaya/worker"
+	dockerPyDir     = "/japaya/python-dir"
+)
+
+// StartDockerPythonWorker runs the worker inside the given container image
+// via `docker run`, so builds use a pinned, hermetic Python environment
+// regardless of the host machine. The worker script and the python-dir are
+// mounted read-only. pythonCmd is the interpreter inside the image
+// ("python3" when empty).
+//
+// File descriptors don't cross the container boundary, so the protocol rides
+// on stdout like on Windows; snippets that write to the real stdout at the
+// OS level can corrupt framing there.
+func StartDockerPythonWorker(image string, pythonCmd string, pythonDir string) (*PythonWorker, error) {
+	if image == "" {
+		return nil, fmt.Errorf("empty docker image")
+	}
+	docker, err := exec.LookPath("docker")
+	if err != nil {
+		return nil, fmt.Errorf("docker engine: %w", err)
+	}
+	if pythonCmd == "" {
+		pythonCmd = "python3"
+	}
+
+	tmpDir, err := os.MkdirTemp("", "japaya-py-*")
+	if err != nil {
+		return nil, err
+	}
+	workerPath := filepath.Join(tmpDir, "worker.py")
+	if err := os.WriteFile(workerPath, embeddedWorkerPy, 0o644); err != nil {
+		_ = os.RemoveAll(tmpDir)
+		return nil, err
+	}
+
+	cmd := exec.Command(docker, dockerRunArgs(image, pythonCmd, pythonDir, tmpDir)...)
+	setProcessGroup(cmd)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		_ = os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		_ = os.RemoveAll(tmpDir)
+		return nil, err
+	}
+
+	return launchWorker(cmd, stdin, stdout, nil, tmpDir)
+}
+
+// dockerRunArgs builds the `docker run` argument list for a worker in image.
+// workerDir is the host directory holding worker.py.
+func dockerRunArgs(image, pythonCmd, pythonDir, workerDir string) []string {
+	args := []string{
+		"run", "--rm", "-i",
+		"-v", workerDir + ":" + dockerWorkerDir + ":ro",
+	}
+	if pythonDir != "" {
+		// Mount sources must be absolute for docker.
+		if abs, err := filepath.Abs(pythonDir); err == nil {
+			pythonDir = abs
+		}
+		args = append(args,
+			"-v", pythonDir+":"+dockerPyDir+":ro",
+			"-e", "JAPAYA_PY_DIR="+dockerPyDir,
+			"-e", "PYTHONPATH="+dockerPyDir,
+		)
+	}
+	return append(args, image, pythonCmd, "-u", dockerWorkerDir+"/worker.py")
+}
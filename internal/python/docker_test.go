@@ -0,0 +1,60 @@
+package python
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDockerRunArgs_MountsAndCommand(t *testing.T) {
+	t.Parallel()
+
+	args := dockerRunArgs("example/py:3.12", "python3", "/src/helpers", "/tmp/japaya-py-1")
+	got := strings.Join(args, " ")
+
+	for _, want := range []string{
+		"run --rm -i",
+		"-v /tmp/japaya-py-1:" + dockerWorkerDir + ":ro",
+		"-v /src/helpers:" + dockerPyDir + ":ro",
+		"-e JAPAYA_PY_DIR=" + dockerPyDir,
+		"-e PYTHONPATH=" + dockerPyDir,
+		"example/py:3.12 python3 -u " + dockerWorkerDir + "/worker.py",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("docker args missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestDockerRunArgs_NoPythonDir(t *testing.T) {
+	t.Parallel()
+
+	got := strings.Join(dockerRunArgs("img", "python3", "", "/w"), " ")
+	if strings.Contains(got, dockerPyDir) {
+		t.Errorf("no python-dir mount expected:\n%s", got)
+	}
+}
+
+func TestDockerRunArgs_RelativePythonDirBecomesAbsolute(t *testing.T) {
+	t.Parallel()
+
+	got := strings.Join(dockerRunArgs("img", "python3", "helpers", "/w"), " ")
+	abs, _ := filepath.Abs("helpers")
+	if !strings.Contains(got, "-v "+abs+":"+dockerPyDir+":ro") {
+		t.Errorf("expected the mount source made absolute:\n%s", got)
+	}
+}
+
+func TestStartDockerPythonWorker_Validation(t *testing.T) {
+	t.Parallel()
+
+	if _, err := StartDockerPythonWorker("", "", ""); err == nil {
+		t.Error("expected an error for an empty image")
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		if _, err := StartDockerPythonWorker("img", "", ""); err == nil {
+			t.Error("expected an error when docker is not installed")
+		}
+	}
+}
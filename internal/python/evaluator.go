@@ -2,7 +2,11 @@ package python
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"sync"
+	"time"
 
 	"github.com/justinottesen/japaya/internal/core"
 )
@@ -13,20 +17,302 @@ type Evaluator struct {
 	// For example, if python.go exposes `type Worker struct {...}`,
 	// store `w *Worker`.
 	w *PythonWorker
+
+	// Kept so a crashed worker can be restarted with the same setup,
+	// whatever the engine.
+	start func() (*PythonWorker, error)
+
+	// Extra teardown beyond the worker itself — the fork engine's parent
+	// process. Nil for engines whose worker is self-contained.
+	stop func() error
+
+	mu      sync.Mutex // guards the fields below and swaps of w
+	gen     int        // bumped on every restart, for single-flight restarts
+	closed  bool
+	retries int
+	backoff time.Duration
 }
 
 func NewEvaluator(pythonCmd string, pythonDir string) (*Evaluator, error) {
-	// Construct your existing wrapper here.
-	w, err := StartPythonWorker(pythonCmd, pythonDir)
+	return NewSandboxedEvaluator(pythonCmd, pythonDir, nil)
+}
+
+// NewSandboxedEvaluator is NewEvaluator with the worker's filesystem access
+// confined; see SandboxConfig. sb may be nil for an unrestricted worker.
+func NewSandboxedEvaluator(pythonCmd string, pythonDir string, sb *SandboxConfig) (*Evaluator, error) {
+	return newEvaluator(func() (*PythonWorker, error) {
+		return StartSandboxedPythonWorker(pythonCmd, pythonDir, sb)
+	})
+}
+
+// NewDockerEvaluator is NewEvaluator with the worker running inside a
+// container image; see StartDockerPythonWorker.
+func NewDockerEvaluator(image string, pythonCmd string, pythonDir string) (*Evaluator, error) {
+	return newEvaluator(func() (*PythonWorker, error) {
+		return StartDockerPythonWorker(image, pythonCmd, pythonDir)
+	})
+}
+
+// newEvaluator wraps a worker started by start, keeping start around so
+// retries can replace a crashed worker the same way.
+func newEvaluator(start func() (*PythonWorker, error)) (*Evaluator, error) {
+	w, err := start()
 	if err != nil {
 		return nil, err
 	}
-	return &Evaluator{w: w}, nil
+	return &Evaluator{w: w, start: start}, nil
 }
 
 func (e *Evaluator) Close() error {
 	// Close/kill the worker subprocess cleanly.
-	return e.w.Close() // <-- rename to your real close
+	e.mu.Lock()
+	e.closed = true
+	w := e.w
+	e.mu.Unlock()
+	err := w.Close() // <-- rename to your real close
+	if e.stop != nil {
+		if stopErr := e.stop(); err == nil {
+			err = stopErr
+		}
+	}
+	return err
+}
+
+// Shutdown is Close with a caller-supplied kill deadline; see
+// PythonWorker.Shutdown.
+func (e *Evaluator) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	e.closed = true
+	w := e.w
+	e.mu.Unlock()
+	err := w.Shutdown(ctx)
+	if e.stop != nil {
+		if stopErr := e.stop(); err == nil {
+			err = stopErr
+		}
+	}
+	return err
+}
+
+// worker returns the current worker; restarts may swap it, so forwarding
+// methods snapshot it rather than reading e.w directly.
+func (e *Evaluator) worker() *PythonWorker {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.w
+}
+
+// SetRetryPolicy makes Eval retry evaluations that fail on worker transport
+// errors — crashes, broken pipes — restarting the worker, sleeping backoff
+// before the first retry and doubling it for each later one, up to retries
+// attempts beyond the first. Python exceptions are never retried; they would
+// fail identically the second time. Stateful repl and session evaluations
+// are also never retried, since their accumulated namespace died with the
+// worker. The default is no retries.
+func (e *Evaluator) SetRetryPolicy(retries int, backoff time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.retries = retries
+	e.backoff = backoff
+}
+
+// restartWorker replaces the worker after a transport failure. gen
+// identifies the worker the caller saw fail; if another goroutine already
+// replaced it, the restart is skipped. The restart counter and per-run
+// settings carry over so monitoring and scoping survive the swap.
+func (e *Evaluator) restartWorker(gen int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return errors.New("evaluator is closed")
+	}
+	if e.gen != gen {
+		return nil
+	}
+	old := e.w
+	w, err := e.start()
+	if err != nil {
+		return err
+	}
+	w.metrics.restarts.Store(old.metrics.restarts.Load() + 1)
+	w.maxResponseSize.Store(old.maxResponseSize.Load())
+	old.traceMu.Lock()
+	w.SetTrace(old.traceW, old.traceMax)
+	old.traceMu.Unlock()
+	old.mu.Lock()
+	w.SetSnippetScope(old.scopeCwd, old.scopeEnv)
+	old.mu.Unlock()
+	_ = old.Close()
+	e.w = w
+	e.gen++
+	return nil
+}
+
+// evalRetry runs op against the current worker, restarting and retrying on
+// transient failures per the configured policy.
+func (e *Evaluator) evalRetry(ctx context.Context, op func(w *PythonWorker) ([]byte, error)) ([]byte, error) {
+	e.mu.Lock()
+	retries, delay := e.retries, e.backoff
+	e.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		e.mu.Lock()
+		w, gen := e.w, e.gen
+		e.mu.Unlock()
+		out, err := op(w)
+		if err == nil || attempt >= retries || ctx.Err() != nil || !isTransient(err) {
+			return out, err
+		}
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+			delay *= 2
+		}
+		if rerr := e.restartWorker(gen); rerr != nil {
+			return nil, fmt.Errorf("restart python worker: %w", rerr)
+		}
+	}
+}
+
+// isTransient reports whether err came from the worker transport rather than
+// the snippet. Python exceptions surface as *PythonError and deliberate
+// skips as *core.FileSkippedError; anything else means the worker itself
+// broke.
+func isTransient(err error) bool {
+	var pe *PythonError
+	var skip *core.FileSkippedError
+	return !errors.As(err, &pe) && !errors.As(err, &skip)
+}
+
+// Repl evaluates code in the worker's persistent repl namespace. Unlike Eval,
+// names defined by one call remain visible to later ones.
+func (e *Evaluator) Repl(ctx context.Context, code []byte) ([]byte, error) {
+	return e.worker().Eval(ctx, "repl", code)
+}
+
+// TakeImports satisfies core.ImportReporter: it drains the set of top-level
+// modules imported by snippets since the last call.
+func (e *Evaluator) TakeImports() []string {
+	return e.worker().TakeImports()
+}
+
+// TakeSlots satisfies core.SlotReporter: it drains the named-slot appends
+// snippets made via japaya.slot since the last call.
+func (e *Evaluator) TakeSlots() []core.SlotAppend {
+	slots := e.worker().TakeSlots()
+	if len(slots) == 0 {
+		return nil
+	}
+	out := make([]core.SlotAppend, len(slots))
+	for i, s := range slots {
+		out[i] = core.SlotAppend{Name: s.Name, Text: s.Text}
+	}
+	return out
+}
+
+// TakeRequiredImports satisfies core.RequiredImportReporter: it drains the
+// sorted set of java imports snippets required via japaya.require_import
+// since the last call.
+func (e *Evaluator) TakeRequiredImports() []string {
+	return e.worker().TakeRequiredImports()
+}
+
+// TakeOutputPath satisfies core.OutputPathReporter: it drains the override
+// the current file's snippets set via japaya.set_output, or "" when none.
+func (e *Evaluator) TakeOutputPath() string {
+	return e.worker().TakeOutputPath()
+}
+
+// TakeResources satisfies core.ResourceReporter: it drains the resources
+// snippets emitted via japaya.emit_resource since the last call.
+func (e *Evaluator) TakeResources() []core.Resource {
+	files := e.worker().TakeResources()
+	if len(files) == 0 {
+		return nil
+	}
+	resources := make([]core.Resource, len(files))
+	for i, f := range files {
+		resources[i] = core.Resource{Path: f.Path, Data: f.Data}
+	}
+	return resources
+}
+
+// Invalidate drops cached modules from the worker so the next snippet that
+// imports them re-reads them from disk. Watch mode calls this when a
+// python-dir helper changes.
+func (e *Evaluator) Invalidate(ctx context.Context, modules []string) error {
+	return e.worker().Invalidate(ctx, modules)
+}
+
+// EvalMacro satisfies core.MacroEvaluator: it invokes a macro a snippet
+// registered via japaya.macro(name), reporting known=false for names no
+// macro claimed so plain Java annotations pass through untouched.
+func (e *Evaluator) EvalMacro(ctx context.Context, name string, args map[string]string) (string, bool, error) {
+	return e.worker().EvalMacro(ctx, name, args)
+}
+
+// ExecModule imports a named module from the python-dir and reports what it
+// exports, so tools can validate helper libraries before translation starts.
+func (e *Evaluator) ExecModule(ctx context.Context, name string) (ModuleInfo, error) {
+	return e.worker().ExecModule(ctx, name)
+}
+
+// EvalSession executes code block-style in a namespace that persists across
+// calls sharing the same session ID, so shared-namespace features can keep
+// one session per file or per run.
+func (e *Evaluator) EvalSession(ctx context.Context, session string, code []byte) ([]byte, error) {
+	return e.worker().EvalSession(ctx, session, code)
+}
+
+// EndSession discards a session's namespace so the ID can be reused fresh.
+func (e *Evaluator) EndSession(ctx context.Context, session string) error {
+	return e.worker().EndSession(ctx, session)
+}
+
+// SetSnippetScope satisfies core.SnippetScoper: subsequent evaluations run
+// with the given working directory and env additions, so snippets' relative
+// paths resolve next to the file being translated.
+func (e *Evaluator) SetSnippetScope(cwd string, env map[string]string) {
+	e.worker().SetSnippetScope(cwd, env)
+}
+
+// SetTrace appends a debug line per worker request and response to w; see
+// PythonWorker.SetTrace.
+func (e *Evaluator) SetTrace(w io.Writer, maxSnippet int) {
+	e.worker().SetTrace(w, maxSnippet)
+}
+
+// SetMaxResponseSize bounds how much memory a single snippet's output may
+// occupy on the way back from the worker; n <= 0 restores the default.
+func (e *Evaluator) SetMaxResponseSize(n int) {
+	e.worker().SetMaxResponseSize(n)
+}
+
+// Metrics exposes the underlying worker's activity counters, so daemons can
+// publish them (see Metrics.Publish).
+func (e *Evaluator) Metrics() *Metrics {
+	return e.worker().Metrics()
+}
+
+// EvalAsync queues an evaluation and returns a channel that receives its
+// Result exactly once, so embedders can have many snippets in flight and
+// collect results as they land.
+func (e *Evaluator) EvalAsync(ctx context.Context, t core.RegionType, code []byte) <-chan Result {
+	switch t {
+	case core.RegionTypePythonStatement:
+		return e.worker().EvalAsync(ctx, "stmt", code)
+	case core.RegionTypePythonBlock:
+		return e.worker().EvalAsync(ctx, "block", code)
+	default:
+		ch := make(chan Result, 1)
+		ch <- Result{Err: fmt.Errorf("python evaluator received non-python region type: %v", t)}
+		return ch
+	}
 }
 
 // Eval satisfies core.PythonEvaluator.
@@ -34,10 +320,30 @@ func (e *Evaluator) Close() error {
 func (e *Evaluator) Eval(ctx context.Context, t core.RegionType, code []byte) ([]byte, error) {
 	switch t {
 	case core.RegionTypePythonStatement:
-		return e.w.Eval(ctx, "stmt", code)
+		return e.evalRetry(ctx, func(w *PythonWorker) ([]byte, error) {
+			return w.Eval(ctx, "stmt", code)
+		})
+	case core.RegionTypePythonBlock:
+		return e.evalRetry(ctx, func(w *PythonWorker) ([]byte, error) {
+			return w.Eval(ctx, "block", code)
+		})
+	default:
+		return nil, fmt.Errorf("python evaluator received non-python region type: %v", t)
+	}
+}
+
+// EvalTyped evaluates code like Eval but returns the worker's structured
+// result, so callers can receive bytes and multi-file payloads that don't
+// fit the plain out string.
+func (e *Evaluator) EvalTyped(ctx context.Context, t core.RegionType, code []byte) (*TypedResult, error) {
+	kind := ""
+	switch t {
+	case core.RegionTypePythonStatement:
+		kind = "stmt"
 	case core.RegionTypePythonBlock:
-		return e.w.Eval(ctx, "block", code)
+		kind = "block"
 	default:
 		return nil, fmt.Errorf("python evaluator received non-python region type: %v", t)
 	}
+	return e.worker().EvalTyped(ctx, kind, code)
 }
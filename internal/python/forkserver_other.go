@@ -0,0 +1,11 @@
+//go:build !unix
+
+package python
+
+import "errors"
+
+// NewForkServerEvaluator forks workers from a pre-imported parent process,
+// which needs fork(2); see forkserver_unix.go. Not available here.
+func NewForkServerEvaluator(pythonCmd string, pythonDir string, preload []string) (*Evaluator, error) {
+	return nil, errors.New("the fork engine requires a POSIX system")
+}
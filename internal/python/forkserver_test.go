@@ -0,0 +1,63 @@
+//go:build unix
+
+package python
+
+import (
+	"context"
+	"testing"
+
+	"github.com/justinottesen/japaya/internal/core"
+)
+
+func TestForkServerEvaluator_WorkersInheritPreloadedModules(t *testing.T) {
+	t.Parallel()
+
+	e, err := NewForkServerEvaluator(pickPythonCmd(t), "", []string{"base64"})
+	if err != nil {
+		t.Fatalf("NewForkServerEvaluator error: %v", err)
+	}
+	t.Cleanup(func() { _ = e.Close() })
+
+	ctx := context.Background()
+	out, err := e.Eval(ctx, core.RegionTypePythonStatement, []byte("'base64' in __import__('sys').modules"))
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	if string(out) != "True" {
+		t.Errorf("preloaded-module check = %q, want True in the forked worker", out)
+	}
+
+	// Ordinary evaluation works through the forked worker too.
+	out, err = e.Eval(ctx, core.RegionTypePythonStatement, []byte("1+2"))
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	if string(out) != "3" {
+		t.Errorf("Eval = %q, want 3", out)
+	}
+}
+
+func TestForkServerEvaluator_RestartForksAFreshWorker(t *testing.T) {
+	t.Parallel()
+
+	e, err := NewForkServerEvaluator(pickPythonCmd(t), "", nil)
+	if err != nil {
+		t.Fatalf("NewForkServerEvaluator error: %v", err)
+	}
+	t.Cleanup(func() { _ = e.Close() })
+	e.SetRetryPolicy(1, 0)
+
+	ctx := context.Background()
+	// Kill the forked worker; the retry policy must fork a replacement from
+	// the still-running parent.
+	if _, err := e.Eval(ctx, core.RegionTypePythonBlock, []byte("import os\nos._exit(9)")); err == nil {
+		t.Fatal("want an error from the killed worker")
+	}
+	out, err := e.Eval(ctx, core.RegionTypePythonStatement, []byte("40+2"))
+	if err != nil {
+		t.Fatalf("Eval after crash: %v", err)
+	}
+	if string(out) != "42" {
+		t.Errorf("Eval = %q, want 42 from the replacement worker", out)
+	}
+}
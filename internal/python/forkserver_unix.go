@@ -0,0 +1,194 @@
+//go:build unix
+
+package python
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A forkServer is a long-lived python process that loads the prelude and any
+// preload modules once, then forks a child per worker request. Children
+// inherit the parent's imported modules, so each worker start skips the
+// import cost of heavy helper libraries like jinja2 or pandas. Worker pipes
+// travel to the server over a unix socket pair (SCM_RIGHTS); the server
+// replies with each child's pid so the Go side can kill it directly.
+type forkServer struct {
+	cmd    *exec.Cmd
+	conn   *net.UnixConn
+	tmpDir string
+
+	mu sync.Mutex // serializes spawn round trips on conn
+
+	closeOnce  sync.Once
+	closeError error
+}
+
+// startForkServer launches the fork-server process, importing pythonDir's
+// prelude and the named preload modules before the first worker is asked
+// for.
+func startForkServer(pythonCmd string, pythonDir string, preload []string) (*forkServer, error) {
+	tmpDir, err := os.MkdirTemp("", "japaya-py-*")
+	if err != nil {
+		return nil, err
+	}
+	workerPath := filepath.Join(tmpDir, "worker.py")
+	if err := os.WriteFile(workerPath, embeddedWorkerPy, 0o600); err != nil {
+		_ = os.RemoveAll(tmpDir)
+		return nil, err
+	}
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		_ = os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	parentEnd := os.NewFile(uintptr(fds[0]), "fork-control")
+	childEnd := os.NewFile(uintptr(fds[1]), "fork-control-child")
+	fileConn, err := net.FileConn(parentEnd)
+	_ = parentEnd.Close() // FileConn dup'ed it
+	if err != nil {
+		_ = childEnd.Close()
+		_ = os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	conn := fileConn.(*net.UnixConn)
+
+	if pythonCmd == "" {
+		pythonCmd = defaultPythonCmd()
+	}
+	cmd := exec.Command(pythonCmd, "-u", workerPath)
+	setProcessGroup(cmd)
+	cmd.Stdin = nil
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{childEnd} // becomes fd 3 in the server
+
+	env := os.Environ()
+	env = append(env, "JAPAYA_FORK_SERVER=1", "JAPAYA_FORK_FD=3")
+	if len(preload) > 0 {
+		env = append(env, "JAPAYA_PRELOAD="+strings.Join(preload, ","))
+	}
+	env = pythonDirEnv(env, pythonCmd, pythonDir, false)
+	cmd.Env = env
+
+	if err := cmd.Start(); err != nil {
+		_ = conn.Close()
+		_ = childEnd.Close()
+		_ = os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	_ = childEnd.Close() // the server holds its own copy after Start
+
+	return &forkServer{cmd: cmd, conn: conn, tmpDir: tmpDir}, nil
+}
+
+// spawn asks the server to fork one worker, returning it wired up like a
+// directly exec'ed one. The worker's stdin and protocol pipes are created
+// here and their far ends passed to the server over the control socket.
+func (s *forkServer) spawn() (*PythonWorker, error) {
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	protoR, protoW, err := os.Pipe()
+	if err != nil {
+		_ = stdinR.Close()
+		_ = stdinW.Close()
+		return nil, err
+	}
+
+	s.mu.Lock()
+	rights := syscall.UnixRights(int(stdinR.Fd()), int(protoW.Fd()))
+	_, _, err = s.conn.WriteMsgUnix([]byte{'s'}, rights, nil)
+	var pidBuf [4]byte
+	if err == nil {
+		s.conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+		_, err = s.conn.Read(pidBuf[:])
+		s.conn.SetReadDeadline(time.Time{})
+	}
+	s.mu.Unlock()
+
+	// Our copies of the far ends close regardless; the child holds its own.
+	_ = stdinR.Close()
+	_ = protoW.Close()
+	if err != nil {
+		_ = stdinW.Close()
+		_ = protoR.Close()
+		return nil, fmt.Errorf("fork server did not produce a worker: %w", err)
+	}
+	pid := int(binary.LittleEndian.Uint32(pidBuf[:]))
+
+	p := &PythonWorker{
+		pid:       pid,
+		stdin:     stdinW,
+		protoFile: protoR,
+		pending:   make(map[uint64]func(callResult)),
+		exited:    make(chan struct{}),
+	}
+	p.maxResponseSize.Store(defaultMaxResponseSize)
+
+	// The server auto-reaps its children, so there is no Wait to harvest;
+	// poll the pid until it is gone. No exit status survives the reap, which
+	// crash reports tolerate.
+	go func() {
+		for syscall.Kill(pid, 0) == nil {
+			time.Sleep(50 * time.Millisecond)
+		}
+		close(p.exited)
+	}()
+
+	go p.readLoop(bufio.NewReaderSize(protoR, responseBufferSize))
+	return p, nil
+}
+
+// Close shuts the server down: closing the control socket ends its accept
+// loop, then the process is given a moment to exit before being killed.
+// Workers already forked are unaffected; their owners close them.
+func (s *forkServer) Close() error {
+	s.closeOnce.Do(func() {
+		_ = s.conn.Close()
+
+		done := make(chan error, 1)
+		go func() { done <- s.cmd.Wait() }()
+		select {
+		case err := <-done:
+			s.closeError = err
+		case <-time.After(defaultCloseTimeout):
+			killProcessGroup(s.cmd)
+			<-done
+			s.closeError = fmt.Errorf("fork server did not exit; killed")
+		}
+		_ = os.RemoveAll(s.tmpDir)
+	})
+	return s.closeError
+}
+
+// NewForkServerEvaluator is NewEvaluator with workers forked from a single
+// pre-imported parent process instead of exec'ed from scratch (fork-server
+// model; POSIX only). preload names modules the parent imports once — heavy
+// helper libraries like jinja2 or pandas — so forked workers start with them
+// already loaded. Children inherit the parent's prelude and preloads as of
+// server start; edits to those need a fresh run.
+func NewForkServerEvaluator(pythonCmd string, pythonDir string, preload []string) (*Evaluator, error) {
+	fs, err := startForkServer(pythonCmd, pythonDir, preload)
+	if err != nil {
+		return nil, err
+	}
+	e, err := newEvaluator(fs.spawn)
+	if err != nil {
+		_ = fs.Close()
+		return nil, err
+	}
+	e.stop = fs.Close
+	return e, nil
+}
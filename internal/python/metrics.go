@@ -0,0 +1,149 @@
+package python
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBounds are the upper bounds of the evaluation-latency histogram
+// buckets; an implicit final bucket catches everything slower.
+var latencyBounds = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	25 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	2500 * time.Millisecond,
+	10 * time.Second,
+}
+
+// Metrics counts evaluator activity — requests sent, in flight, failed,
+// worker restarts, and an evaluation-latency histogram — so daemon-mode
+// embedders can monitor throughput and spot pathological templates. The
+// zero value is ready; all methods are safe for concurrent use.
+type Metrics struct {
+	requests atomic.Int64 // requests sent to the worker
+	inFlight atomic.Int64 // requests awaiting a response
+	failures atomic.Int64 // requests that completed with an error
+
+	// Worker processes torn down and replaced. The worker never restarts
+	// itself; pools and daemons that replace one record it here.
+	restarts atomic.Int64
+
+	totalLatency atomic.Int64 // nanoseconds across completed requests
+	buckets      [len(latencyBounds) + 1]atomic.Int64
+}
+
+// startRequest records a request going out.
+func (m *Metrics) startRequest() {
+	m.requests.Add(1)
+	m.inFlight.Add(1)
+}
+
+// endRequest records a request completing after d.
+func (m *Metrics) endRequest(d time.Duration, failed bool) {
+	m.inFlight.Add(-1)
+	if failed {
+		m.failures.Add(1)
+	}
+	m.totalLatency.Add(int64(d))
+	i := 0
+	for i < len(latencyBounds) && d > latencyBounds[i] {
+		i++
+	}
+	m.buckets[i].Add(1)
+}
+
+// dropRequest records a request abandoned before any response arrived.
+func (m *Metrics) dropRequest() {
+	m.inFlight.Add(-1)
+}
+
+// AddRestart records a worker process being torn down and replaced.
+func (m *Metrics) AddRestart() {
+	m.restarts.Add(1)
+}
+
+// MetricsSnapshot is a point-in-time copy of the counters, shaped for JSON
+// (and therefore expvar) output. Latency buckets are keyed by their upper
+// bound, with "+inf" catching everything slower.
+type MetricsSnapshot struct {
+	Requests       int64            `json:"requests"`
+	InFlight       int64            `json:"in_flight"`
+	Failures       int64            `json:"failures"`
+	Restarts       int64            `json:"restarts"`
+	MeanLatencyMS  float64          `json:"mean_latency_ms"`
+	LatencyBuckets map[string]int64 `json:"latency_buckets"`
+}
+
+// Snapshot returns a consistent-enough copy of the counters for reporting.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	s := MetricsSnapshot{
+		Requests:       m.requests.Load(),
+		InFlight:       m.inFlight.Load(),
+		Failures:       m.failures.Load(),
+		Restarts:       m.restarts.Load(),
+		LatencyBuckets: make(map[string]int64, len(m.buckets)),
+	}
+	var completed int64
+	for i := range m.buckets {
+		n := m.buckets[i].Load()
+		completed += n
+		s.LatencyBuckets[bucketLabel(i)] = n
+	}
+	if completed > 0 {
+		s.MeanLatencyMS = float64(m.totalLatency.Load()) / float64(completed) / float64(time.Millisecond)
+	}
+	return s
+}
+
+// bucketLabel names histogram bucket i by its upper bound.
+func bucketLabel(i int) string {
+	if i == len(latencyBounds) {
+		return "+inf"
+	}
+	return latencyBounds[i].String()
+}
+
+// WritePrometheus writes the counters in Prometheus text exposition format,
+// for daemons serving a /metrics endpoint. The latency histogram comes out
+// cumulatively as japaya_eval_latency_seconds, so the usual
+// histogram_quantile queries yield percentiles.
+func (m *Metrics) WritePrometheus(w io.Writer) {
+	fmt.Fprintf(w, "# HELP japaya_worker_requests_total Requests sent to the python worker.\n")
+	fmt.Fprintf(w, "# TYPE japaya_worker_requests_total counter\n")
+	fmt.Fprintf(w, "japaya_worker_requests_total %d\n", m.requests.Load())
+	fmt.Fprintf(w, "# HELP japaya_worker_failures_total Worker requests that completed with an error.\n")
+	fmt.Fprintf(w, "# TYPE japaya_worker_failures_total counter\n")
+	fmt.Fprintf(w, "japaya_worker_failures_total %d\n", m.failures.Load())
+	fmt.Fprintf(w, "# HELP japaya_worker_restarts_total Worker processes torn down and replaced.\n")
+	fmt.Fprintf(w, "# TYPE japaya_worker_restarts_total counter\n")
+	fmt.Fprintf(w, "japaya_worker_restarts_total %d\n", m.restarts.Load())
+	fmt.Fprintf(w, "# HELP japaya_worker_in_flight Worker requests awaiting a response.\n")
+	fmt.Fprintf(w, "# TYPE japaya_worker_in_flight gauge\n")
+	fmt.Fprintf(w, "japaya_worker_in_flight %d\n", m.inFlight.Load())
+
+	fmt.Fprintf(w, "# HELP japaya_eval_latency_seconds Evaluation latency of worker requests.\n")
+	fmt.Fprintf(w, "# TYPE japaya_eval_latency_seconds histogram\n")
+	var cum int64
+	for i := range m.buckets {
+		cum += m.buckets[i].Load()
+		le := "+Inf"
+		if i < len(latencyBounds) {
+			le = strconv.FormatFloat(latencyBounds[i].Seconds(), 'g', -1, 64)
+		}
+		fmt.Fprintf(w, "japaya_eval_latency_seconds_bucket{le=%q} %d\n", le, cum)
+	}
+	fmt.Fprintf(w, "japaya_eval_latency_seconds_sum %g\n", float64(m.totalLatency.Load())/float64(time.Second))
+	fmt.Fprintf(w, "japaya_eval_latency_seconds_count %d\n", cum)
+}
+
+// Publish registers the metrics in the process-wide expvar registry under
+// name, so daemons serving the standard /debug/vars endpoint expose them.
+func (m *Metrics) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any { return m.Snapshot() }))
+}
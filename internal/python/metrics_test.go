@@ -0,0 +1,90 @@
+package python
+
+import (
+	"context"
+	"expvar"
+	"strings"
+	"testing"
+)
+
+func TestMetrics_CountsRequestsFailuresAndLatency(t *testing.T) {
+	p := mustStart(t)
+	ctx := context.Background()
+
+	if _, err := p.Eval(ctx, "stmt", []byte(`"ok"`)); err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	if _, err := p.Eval(ctx, "stmt", []byte("1/0")); err == nil {
+		t.Fatalf("expected failing eval")
+	}
+
+	s := p.Metrics().Snapshot()
+	if s.Requests != 2 {
+		t.Errorf("Requests = %d, want 2", s.Requests)
+	}
+	if s.InFlight != 0 {
+		t.Errorf("InFlight = %d, want 0", s.InFlight)
+	}
+	if s.Failures != 1 {
+		t.Errorf("Failures = %d, want 1", s.Failures)
+	}
+
+	var completed int64
+	for _, n := range s.LatencyBuckets {
+		completed += n
+	}
+	if completed != 2 {
+		t.Errorf("latency buckets count %d observations, want 2", completed)
+	}
+	if s.MeanLatencyMS <= 0 {
+		t.Errorf("MeanLatencyMS = %v, want > 0", s.MeanLatencyMS)
+	}
+}
+
+func TestMetrics_Publish(t *testing.T) {
+	var m Metrics
+	m.startRequest()
+	m.AddRestart()
+
+	m.Publish("japaya_test_metrics")
+	v := expvar.Get("japaya_test_metrics")
+	if v == nil {
+		t.Fatalf("metrics not published")
+	}
+	got := v.String()
+	if !strings.Contains(got, `"requests":1`) || !strings.Contains(got, `"restarts":1`) {
+		t.Errorf("published metrics = %s", got)
+	}
+}
+
+func TestMetrics_WritePrometheus(t *testing.T) {
+	p := mustStart(t)
+
+	ctx := context.Background()
+	if _, err := p.Eval(ctx, "stmt", []byte("1+1")); err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	p.Metrics().AddRestart()
+
+	var buf strings.Builder
+	p.Metrics().WritePrometheus(&buf)
+	out := buf.String()
+
+	for _, want := range []string{
+		"japaya_worker_requests_total 1",
+		"japaya_worker_restarts_total 1",
+		"japaya_worker_in_flight 0",
+		"# TYPE japaya_eval_latency_seconds histogram",
+		`japaya_eval_latency_seconds_bucket{le="+Inf"} 1`,
+		"japaya_eval_latency_seconds_count 1",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("prometheus output missing %q:\n%s", want, out)
+		}
+	}
+
+	// Buckets are cumulative, so the slowest bound matches +Inf.
+	if !strings.Contains(out, `japaya_eval_latency_seconds_bucket{le="10"} 1`) {
+		t.Errorf("expected the 10s bucket to include the one completed request:\n%s", out)
+	}
+}
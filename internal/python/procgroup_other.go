@@ -0,0 +1,20 @@
+//go:build !unix
+
+package python
+
+import "os/exec"
+
+// setProcessGroup is a no-op here: process groups are a unix notion. The
+// Windows equivalent would be a job object, which needs more plumbing than
+// os/exec exposes directly.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills just the worker; its children are left to the OS.
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+}
+
+// killForkedWorker is a no-op here: the fork engine never starts off unix.
+func killForkedWorker(pid int) {}
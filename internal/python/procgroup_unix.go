@@ -0,0 +1,33 @@
+//go:build unix
+
+package python
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup puts the worker in its own process group, so subprocesses
+// spawned by snippets can be terminated along with it.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup terminates the worker's whole process group, taking any
+// snippet-spawned subprocesses with it. Harmless if the group is already
+// gone.
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// killForkedWorker terminates a fork-server child's process group by pid.
+// The child called setsid, so the group is its own.
+func killForkedWorker(pid int) {
+	if pid <= 0 {
+		return
+	}
+	_ = syscall.Kill(-pid, syscall.SIGKILL)
+}
@@ -3,13 +3,203 @@ import sys
 import json
 import io
 import os
+import base64
+import builtins
+import gzip
+import threading
 import traceback
+import importlib
 import importlib.util
-from contextlib import redirect_stdout, redirect_stderr
-from typing import Tuple
+import posixpath
+import signal
+import socket
+import struct
+from contextlib import contextmanager
+from typing import Set, Tuple
 
 PRELUDE = {}
 
+# Macros registered by snippets via japaya.macro("name"); invoked from Java
+# regions with @name(key=value, ...) call syntax.
+MACROS = {}
+
+
+def _int_env(*keys):
+    for key in keys:
+        val = os.environ.get(key, "").strip()
+        if not val:
+            continue
+        try:
+            return int(val)
+        except ValueError:
+            continue
+    return None
+
+
+class _SkipFile(Exception):
+    """Raised by japaya.skip to abandon the current file without failing."""
+
+
+class _TypedResult:
+    """A typed evaluation result. Statements that return one (built via
+    japaya.binary / japaya.files) travel back in the response's structured
+    `result` field instead of the plain `out` string, so binary payloads and
+    multi-file outputs don't have to squeeze through text."""
+
+    def __init__(self, kind, text="", data=b"", files=None):
+        self.kind = kind
+        self.text = text
+        self.data = data
+        self.files = files or []
+
+    def to_wire(self):
+        wire = {"type": self.kind}
+        if self.kind == "text":
+            wire["text"] = self.text
+        elif self.kind == "bytes":
+            wire["data"] = base64.b64encode(self.data).decode("ascii")
+        else:  # files
+            wire["files"] = [
+                {"path": path, "data": base64.b64encode(data).decode("ascii")}
+                for path, data in self.files
+            ]
+        return wire
+
+
+def _as_bytes(value, what):
+    if isinstance(value, (bytes, bytearray)):
+        return bytes(value)
+    if isinstance(value, str):
+        return value.encode("utf-8")
+    raise TypeError(f"{what} must be bytes or str, not {type(value).__name__}")
+
+
+class _JapayaAPI:
+    """The `japaya` helper object visible to every snippet."""
+
+    @staticmethod
+    def binary(data):
+        """Wrap raw bytes as a typed result, returned as-is to the caller
+        instead of being stringified."""
+        return _TypedResult("bytes", data=_as_bytes(data, "binary data"))
+
+    @staticmethod
+    def slot(name, text):
+        """Append text to a named output slot, spliced wherever the template
+        places its ```!slot name``` marker — e.g. a region generating a field
+        can register the import it needs at the top of the file."""
+        if not isinstance(name, str) or not name:
+            raise TypeError("slot name must be a non-empty string")
+        if not isinstance(text, str):
+            raise TypeError("slot text must be a string")
+        bucket = getattr(_SLOT_TRACKING, "bucket", None)
+        if bucket is None:
+            raise RuntimeError("japaya.slot called outside an evaluation")
+        bucket.append((name, text))
+
+    @staticmethod
+    def skip(reason=""):
+        """Skip generating the current file entirely. Translation of the file
+        stops, nothing is written, and the skip (with its reason) is recorded
+        in the run's accounting — the hook for feature-flagged classes that
+        some configurations simply don't have."""
+        raise _SkipFile(reason if isinstance(reason, str) else str(reason))
+
+    @staticmethod
+    def require_import(fqn):
+        """Require a java import in the generated file. The translator
+        inserts missing import statements, sorted and deduplicated, into the
+        output's import block."""
+        if not isinstance(fqn, str) or not fqn:
+            raise TypeError("import name must be a non-empty string")
+        bucket = getattr(_REQUIRE_TRACKING, "bucket", None)
+        if bucket is None:
+            raise RuntimeError("japaya.require_import called outside an evaluation")
+        bucket.add(fqn)
+
+    @staticmethod
+    def set_output(path):
+        """Override where the current file's generated output is written,
+        relative to the output root — for templates that compute their
+        package or location from data instead of directory layout."""
+        if not isinstance(path, str) or not path:
+            raise TypeError("output path must be a non-empty string")
+        norm = posixpath.normpath(path.replace("\\", "/"))
+        if posixpath.isabs(norm) or norm.startswith(".."):
+            raise ValueError(f"output path {path!r} escapes the output root")
+        bucket = getattr(_OUTPUT_TRACKING, "bucket", None)
+        if bucket is None:
+            raise RuntimeError("japaya.set_output called outside an evaluation")
+        bucket[:] = [norm]
+
+    @staticmethod
+    def emit_resource(path, content):
+        """Emit a named resource file alongside the generated Java. The path
+        must stay relative; the build writes it under its resources root."""
+        if not isinstance(path, str) or not path:
+            raise TypeError("resource path must be a non-empty string")
+        norm = posixpath.normpath(path.replace("\\", "/"))
+        if posixpath.isabs(norm) or norm.startswith(".."):
+            raise ValueError(f"resource path {path!r} escapes the resources root")
+        bucket = getattr(_RESOURCE_TRACKING, "bucket", None)
+        if bucket is None:
+            raise RuntimeError("japaya.emit_resource called outside an evaluation")
+        bucket.append((norm, _as_bytes(content, f"content of {path!r}")))
+
+    @staticmethod
+    def files(mapping):
+        """Wrap a {path: content} mapping as a multi-file typed result.
+        Contents may be bytes or str; entries are sorted by path."""
+        if not isinstance(mapping, dict):
+            raise TypeError("japaya.files wants a {path: content} dict")
+        entries = [(path, _as_bytes(content, f"content of {path!r}"))
+                   for path, content in sorted(mapping.items())]
+        return _TypedResult("files", files=entries)
+
+    @staticmethod
+    def macro(name):
+        def register(fn):
+            MACROS[name] = fn
+            return fn
+        return register
+
+    @property
+    def epoch(self):
+        """Unix timestamp for reproducible builds (-seed/SOURCE_DATE_EPOCH),
+        or None when the build doesn't pin one."""
+        return _int_env("JAPAYA_EPOCH", "SOURCE_DATE_EPOCH")
+
+    @property
+    def seed(self):
+        """Deterministic seed from -seed, or None. Snippets that use
+        randomness should call random.seed(japaya.seed)."""
+        return _int_env("JAPAYA_SEED")
+
+    @property
+    def package(self):
+        """Package name inferred from the current file's path relative to the
+        configured source root (-package-root), or None when inference is off
+        or the file sits at the root (default package)."""
+        return os.environ.get("JAPAYA_PACKAGE") or None
+
+    @property
+    def tmpdir(self):
+        """Path of the run's managed scratch directory, or None. It is
+        removed when the run ends, so intermediate files (protoc output,
+        downloaded schemas) never leak into the tree."""
+        return os.environ.get("JAPAYA_TMPDIR") or None
+
+
+JAPAYA_API = _JapayaAPI()
+
+# Persistent namespace for "repl" requests. Lazily seeded from the prelude on
+# first use so the prelude has been loaded by then.
+REPL_NS = {}
+
+# Namespaces for "session" requests, keyed by session ID. The Go side decides
+# what a session spans (one per file, one per run); "session_end" discards one.
+SESSIONS = {}
+
 def load_prelude(py_dir: str) -> dict:
     init_path = os.path.join(py_dir, "__init__.py")
     if not os.path.isfile(init_path):
@@ -31,18 +221,230 @@ def load_prelude(py_dir: str) -> dict:
         out[name] = val
     return out
 
-def run_stmt(code: str) -> str:
+class _ThreadLocalStream:
+    """
+    Routes writes to a per-thread buffer when one is set, falling back to the
+    real stream otherwise. Requests run on their own threads, so each can
+    capture its snippet's output without racing the others.
+    """
+
+    def __init__(self, fallback):
+        self._fallback = fallback
+        self._local = threading.local()
+
+    def swap(self, target):
+        prev = getattr(self._local, "target", None)
+        self._local.target = target
+        return prev
+
+    def _current(self):
+        return getattr(self._local, "target", None) or self._fallback
+
+    def write(self, s):
+        return self._current().write(s)
+
+    def flush(self):
+        return self._current().flush()
+
+
+STDOUT = _ThreadLocalStream(sys.stdout)
+STDERR = _ThreadLocalStream(sys.stderr)
+
+
+@contextmanager
+def capture_output(buf_out, buf_err):
+    """Capture this thread's stdout/stderr into the given buffers."""
+    prev_out = STDOUT.swap(buf_out)
+    prev_err = STDERR.swap(buf_err)
+    try:
+        yield
+    finally:
+        STDOUT.swap(prev_out)
+        STDERR.swap(prev_err)
+
+
+# A single process-wide __import__ hook routes to a thread-local set, so
+# concurrent requests don't see each other's imports.
+_REAL_IMPORT = builtins.__import__
+_IMPORT_TRACKING = threading.local()
+
+
+def _tracking_import(name, globals=None, locals=None, fromlist=(), level=0):
+    found = getattr(_IMPORT_TRACKING, "found", None)
+    if found is not None:
+        found.add(name.partition(".")[0])
+    return _REAL_IMPORT(name, globals, locals, fromlist, level)
+
+
+builtins.__import__ = _tracking_import
+
+
+# Resources emitted via japaya.emit_resource land in a thread-local bucket,
+# so concurrent requests keep their resources separate.
+_RESOURCE_TRACKING = threading.local()
+
+
+@contextmanager
+def collect_resources(bucket):
+    """Collect (path, bytes) resources emitted by this thread while the body
+    runs; they travel back in the response's `resources` field."""
+    _RESOURCE_TRACKING.bucket = bucket
+    try:
+        yield
+    finally:
+        _RESOURCE_TRACKING.bucket = None
+
+
+# Slot appends from japaya.slot use the same thread-local scheme.
+_SLOT_TRACKING = threading.local()
+
+# Imports required via japaya.require_import, likewise thread-local.
+_REQUIRE_TRACKING = threading.local()
+
+# The output-path override from japaya.set_output, likewise thread-local.
+# The bucket is a one-slot list; the last call per evaluation wins.
+_OUTPUT_TRACKING = threading.local()
+
+
+@contextmanager
+def collect_output(bucket):
+    """Expose the output-path override slot to this thread while the body
+    runs; a set value travels back in the response's `output_path` field."""
+    _OUTPUT_TRACKING.bucket = bucket
+    try:
+        yield
+    finally:
+        _OUTPUT_TRACKING.bucket = None
+
+
+@contextmanager
+def collect_requires(bucket):
+    """Collect the java imports required by this thread while the body runs;
+    they travel back in the response's `requires` field."""
+    _REQUIRE_TRACKING.bucket = bucket
+    try:
+        yield
+    finally:
+        _REQUIRE_TRACKING.bucket = None
+
+
+@contextmanager
+def collect_slots(bucket):
+    """Collect (name, text) slot appends emitted by this thread while the
+    body runs; they travel back in the response's `slots` field."""
+    _SLOT_TRACKING.bucket = bucket
+    try:
+        yield
+    finally:
+        _SLOT_TRACKING.bucket = None
+
+
+@contextmanager
+def track_imports(found: Set[str]):
     """
-    Evaluate `code` as a Python expression and return its string form.
+    Record the top-level name of every module imported by this thread while
+    the body runs.
+
+    The hook sees every `import x` / `from x import y` statement, even for
+    modules already cached in sys.modules, so repeated evaluations keep
+    reporting the helpers they depend on.
+    """
+    _IMPORT_TRACKING.found = found
+    try:
+        yield
+    finally:
+        _IMPORT_TRACKING.found = None
+
+
+# Payloads above this size are gzip-compressed on the wire (matching the Go
+# side's threshold for requests).
+COMPRESS_THRESHOLD = 32 * 1024
+
+
+# Serializes requests that carry a scope: cwd and environ are process-global,
+# so scoped requests can't safely overlap. Unscoped requests run freely.
+SCOPE_LOCK = threading.Lock()
+
+
+@contextmanager
+def snippet_scope(cwd, env):
+    """
+    Run a request under a per-request working directory and env additions,
+    restoring both afterwards so one file's scope never leaks into the next.
+    The Go side points cwd at the input file's directory so snippets'
+    relative open() calls resolve next to the template.
+    """
+    if not cwd and not env:
+        yield
+        return
+
+    with SCOPE_LOCK:
+        prev_cwd = os.getcwd() if cwd else None
+        prev_env = {}
+        if cwd:
+            try:
+                os.chdir(cwd)
+            except OSError:
+                # The host path may not exist in this environment (e.g. a
+                # containerized worker); run from the current directory.
+                prev_cwd = None
+        if env:
+            for key, val in env.items():
+                prev_env[key] = os.environ.get(key)
+                os.environ[key] = val
+        try:
+            yield
+        finally:
+            for key, val in prev_env.items():
+                if val is None:
+                    os.environ.pop(key, None)
+                else:
+                    os.environ[key] = val
+            if prev_cwd is not None:
+                os.chdir(prev_cwd)
+
+
+def invalidate_modules(modules) -> None:
+    """
+    Drop the given top-level modules (and their submodules) from sys.modules
+    so the next import re-reads them from disk. Used by watch mode when a
+    python-dir helper changes.
+    """
+    for mod in modules:
+        for cached in list(sys.modules):
+            if cached == mod or cached.startswith(mod + "."):
+                del sys.modules[cached]
+
+
+def run_stmt(code: str):
+    """
+    Evaluate `code` as a Python expression and return its string form, or
+    the _TypedResult itself when the expression built one.
 
     Examples:
       code: '"int x = 3;"'        -> out: 'int x = 3;'
       code: 'f"int x = {2+1};"'   -> out: 'int x = 3;'
     """
-    globs = {"__builtins__": __builtins__}
+    try:
+        compiled = compile(code, "<japaya-statement>", "eval")
+    except SyntaxError as e:
+        # If the same code compiles as statements, the region holds
+        # statements where an expression belongs; say so instead of
+        # surfacing a bare SyntaxError traceback.
+        try:
+            compile(code, "<japaya-statement>", "exec")
+        except SyntaxError:
+            raise e from None  # genuinely malformed; the original error stands
+        raise ValueError(
+            "statement region contains python statements, not an expression; "
+            "use a ```block``` region and print() the output"
+        ) from None
+    globs = {"__builtins__": __builtins__, "japaya": JAPAYA_API}
     globs.update(PRELUDE)
     locs = {}
-    result = eval(code, globs, locs)
+    result = eval(compiled, globs, locs)
+    if isinstance(result, _TypedResult):
+        return result
     return "" if result is None else str(result)
 
 
@@ -55,72 +457,338 @@ def run_block(code: str) -> Tuple[str, str]:
       print("int x = 3;")
       print("int y = 4;")
     """
-    globs = {"__builtins__": __builtins__}
+    globs = {"__builtins__": __builtins__, "japaya": JAPAYA_API}
     globs.update(PRELUDE)
     locs = {}
     buf_out = io.StringIO()
     buf_err = io.StringIO()
 
     # Capture stdout/stderr from the snippet itself so protocol isn't corrupted.
-    with redirect_stdout(buf_out), redirect_stderr(buf_err):
-        exec(code, globs, locs)
+    # Compiling under a recognizable filename lets error replies report the
+    # line within the block (see _snippet_line).
+    with capture_output(buf_out, buf_err):
+        exec(compile(code, "<japaya-block>", "exec"), globs, locs)
 
     # NOTE: We return stdout only as OUT for block. Stderr is returned separately.
     return buf_out.getvalue(), buf_err.getvalue()
 
 
+def run_repl(code: str) -> Tuple[str, str]:
+    """
+    Evaluate `code` in a persistent namespace shared across repl requests.
+
+    Expressions have their repr printed (like the standard Python REPL);
+    anything else is exec'd. Names defined in one request are visible in
+    later ones.
+    """
+    if not REPL_NS:
+        REPL_NS["__builtins__"] = __builtins__
+        REPL_NS["japaya"] = JAPAYA_API
+        REPL_NS.update(PRELUDE)
+
+    buf_out = io.StringIO()
+    buf_err = io.StringIO()
+
+    with capture_output(buf_out, buf_err):
+        try:
+            compiled = compile(code, "<repl>", "eval")
+        except SyntaxError:
+            exec(code, REPL_NS)
+        else:
+            result = eval(compiled, REPL_NS)
+            if result is not None:
+                buf_out.write(repr(result) + "\n")
+
+    return buf_out.getvalue(), buf_err.getvalue()
+
+
+def run_session(session_id: str, code: str) -> Tuple[str, str]:
+    """
+    Execute `code` as statements in the namespace tied to session_id,
+    returning captured stdout like a block. Names defined by one request are
+    visible to later requests carrying the same session ID.
+    """
+    ns = SESSIONS.get(session_id)
+    if ns is None:
+        ns = {"__builtins__": __builtins__, "japaya": JAPAYA_API}
+        ns.update(PRELUDE)
+        SESSIONS[session_id] = ns
+
+    buf_out = io.StringIO()
+    buf_err = io.StringIO()
+
+    with capture_output(buf_out, buf_err):
+        exec(code, ns)
+
+    return buf_out.getvalue(), buf_err.getvalue()
+
+
+def _resources_wire(resources):
+    return [{"path": path, "data": base64.b64encode(data).decode("ascii")}
+            for path, data in resources]
+
+
+def _slots_wire(slots):
+    return [{"name": name, "text": text} for name, text in slots]
+
+
 def handle_request(req: dict) -> dict:
     stdout_buf = io.StringIO()
     stderr_buf = io.StringIO()
+    imports: Set[str] = set()
+    resources = []
+    slots = []
+    requires: Set[str] = set()
+    output = []
 
     try:
         kind = req.get("kind")
         code = req.get("code")
+        code_gz = req.get("code_gz")
+        if code_gz:
+            # Large payloads travel gzip-compressed and base64-framed.
+            code = gzip.decompress(base64.b64decode(code_gz)).decode("utf-8")
+        scope_cwd = req.get("cwd") or ""
+        scope_env = req.get("env") or {}
+
+        if kind == "invalidate":
+            modules = req.get("modules")
+            if not isinstance(modules, list):
+                raise TypeError("modules must be a list of strings")
+            invalidate_modules(modules)
+            return {"ok": True, "out": "", "stdout": "", "stderr": ""}
 
-        if kind not in ("stmt", "block"):
-            raise ValueError("kind must be 'stmt' or 'block'")
+        if kind == "macro":
+            name = req.get("name")
+            if not isinstance(name, str):
+                raise TypeError("name must be a string")
+            fn = MACROS.get(name)
+            if fn is None:
+                # Not an error: the translator leaves unrecognized @calls
+                # alone, since Java is full of real annotations.
+                return {"ok": True, "out": "", "stdout": "", "stderr": "",
+                        "unknown": True}
+            args = req.get("args") or {}
+            with capture_output(stdout_buf, stderr_buf):
+                with track_imports(imports), snippet_scope(scope_cwd, scope_env):
+                    result = fn(**args)
+            # A macro may either return its expansion or print it.
+            out = str(result) if result is not None else stdout_buf.getvalue()
+            return {
+                "ok": True,
+                "out": out,
+                "stdout": "",
+                "stderr": stderr_buf.getvalue(),
+                "imports": sorted(imports),
+            }
+
+        if kind == "module":
+            name = req.get("name")
+            if not isinstance(name, str) or not name:
+                raise TypeError("name must be a non-empty string")
+            with capture_output(stdout_buf, stderr_buf):
+                with track_imports(imports):
+                    mod = importlib.import_module(name)
+            doc_lines = (mod.__doc__ or "").strip().splitlines()
+            return {
+                "ok": True,
+                "out": "",
+                "stdout": "",
+                "stderr": stderr_buf.getvalue(),
+                "path": getattr(mod, "__file__", "") or "",
+                "doc": doc_lines[0] if doc_lines else "",
+                "exports": sorted(n for n in vars(mod) if not n.startswith("_")),
+                "imports": sorted(imports),
+            }
+
+        if kind == "session_end":
+            session = req.get("session")
+            if not isinstance(session, str) or not session:
+                raise TypeError("session must be a non-empty string")
+            SESSIONS.pop(session, None)
+            return {"ok": True, "out": "", "stdout": "", "stderr": ""}
+
+        if kind not in ("stmt", "block", "repl", "session"):
+            raise ValueError("kind must be 'stmt', 'block', 'repl' or 'session'")
         if not isinstance(code, str):
             raise TypeError("code must be a string")
 
-        if kind == "stmt":
-            # For stmt: capture any incidental output, but 'out' is the eval result.
-            with redirect_stdout(stdout_buf), redirect_stderr(stderr_buf):
-                out = run_stmt(code)
+        if kind == "session":
+            session = req.get("session")
+            if not isinstance(session, str) or not session:
+                raise TypeError("session must be a non-empty string")
+            with track_imports(imports), snippet_scope(scope_cwd, scope_env):
+                out, snippet_stderr = run_session(session, code)
             return {
                 "ok": True,
                 "out": out,
+                "stdout": "",
+                "stderr": snippet_stderr,
+                "imports": sorted(imports),
+            }
+
+        if kind == "stmt":
+            # For stmt: capture any incidental output, but 'out' is the eval result.
+            with capture_output(stdout_buf, stderr_buf):
+                with track_imports(imports), snippet_scope(scope_cwd, scope_env):
+                    with collect_resources(resources), collect_slots(slots), \
+                            collect_requires(requires), collect_output(output):
+                        out = run_stmt(code)
+            resp = {
+                "ok": True,
+                "out": "",
                 "stdout": stdout_buf.getvalue(),
                 "stderr": stderr_buf.getvalue(),
+                "imports": sorted(imports),
+                "resources": _resources_wire(resources),
+                "slots": _slots_wire(slots),
+                "requires": sorted(requires),
+                "output_path": output[0] if output else "",
+            }
+            if isinstance(out, _TypedResult):
+                resp["result"] = out.to_wire()
+            else:
+                resp["out"] = out
+            return resp
+
+        if kind == "repl":
+            with track_imports(imports), snippet_scope(scope_cwd, scope_env):
+                out, snippet_stderr = run_repl(code)
+            return {
+                "ok": True,
+                "out": out,
+                "stdout": "",
+                "stderr": snippet_stderr,
+                "imports": sorted(imports),
             }
 
         # kind == "block"
         # For block: run exec and return stdout as out.
         # We still separately expose stderr for debugging.
-        out, snippet_stderr = run_block(code)
+        with track_imports(imports), snippet_scope(scope_cwd, scope_env):
+            with collect_resources(resources), collect_slots(slots), \
+                    collect_requires(requires), collect_output(output):
+                out, snippet_stderr = run_block(code)
         return {
             "ok": True,
             "out": out,
             "stdout": "",                 # optional; keep empty since 'out' is stdout
             "stderr": snippet_stderr,     # stderr from snippet execution
+            "imports": sorted(imports),
+            "resources": _resources_wire(resources),
+            "slots": _slots_wire(slots),
+            "requires": sorted(requires),
+            "output_path": output[0] if output else "",
         }
 
+    except _SkipFile as e:
+        # Not a failure: the snippet asked for the whole file to be skipped.
+        return {
+            "ok": True,
+            "out": "",
+            "stdout": stdout_buf.getvalue(),
+            "stderr": stderr_buf.getvalue(),
+            "skip": True,
+            "skip_reason": str(e),
+        }
     except Exception as e:
         # Include traceback in stderr to help debugging.
         stderr_buf.write(traceback.format_exc())
         return {
             "ok": False,
             "err": str(e),
+            "line": _snippet_line(e),
             "stdout": stdout_buf.getvalue(),
             "stderr": stderr_buf.getvalue(),
         }
 
 
+def _snippet_line(e: BaseException) -> int:
+    """
+    1-based line within the snippet where the error occurred, or 0 when
+    unknown. The deepest traceback frame running snippet code wins, so an
+    error raised inside a helper still points at the snippet line that
+    called it.
+    """
+    if isinstance(e, SyntaxError) and (e.filename or "").startswith("<japaya-"):
+        return e.lineno or 0
+    line = 0
+    tb = e.__traceback__
+    while tb is not None:
+        if tb.tb_frame.f_code.co_filename.startswith("<japaya-"):
+            line = tb.tb_lineno
+        tb = tb.tb_next
+    return line
+
+
+
+def install_fs_guard(spec: dict) -> None:
+    """Best-effort filesystem confinement: replace builtins.open with one
+    that checks paths against the JAPAYA_SANDBOX spec. Reads are allowed
+    under the spec's read roots (and the write root); writes only under the
+    write root. OS-level sandboxing (bwrap) is the real fence where
+    available; this stops honest mistakes everywhere else."""
+    real_open = builtins.open
+    read_roots = [os.path.realpath(p) for p in spec.get("read", [])]
+    write_root = os.path.realpath(spec["write"]) if spec.get("write") else None
+
+    def allowed(path, roots):
+        try:
+            rp = os.path.realpath(os.path.join(os.getcwd(), os.fspath(path)))
+        except TypeError:
+            return True  # unusual path-likes fall through to real open
+        return any(rp == r or rp.startswith(r + os.sep) for r in roots)
+
+    def guarded_open(file, mode="r", *args, **kwargs):
+        if isinstance(file, int):  # already-open descriptors pass
+            return real_open(file, mode, *args, **kwargs)
+        if any(c in mode for c in "wax+"):
+            roots = [write_root] if write_root else []
+            if not allowed(file, roots):
+                raise PermissionError(
+                    f"japaya sandbox: write access denied: {file!r}")
+        else:
+            roots = read_roots + ([write_root] if write_root else [])
+            if not allowed(file, roots):
+                raise PermissionError(
+                    f"japaya sandbox: read access denied: {file!r}")
+        return real_open(file, mode, *args, **kwargs)
+
+    builtins.open = guarded_open
+
+
+def open_protocol_out():
+    """
+    Return the stream responses are written to. When the Go side passes a
+    dedicated pipe via JAPAYA_PROTO_FD, use it so snippets writing to the
+    real stdout (native extensions, os.write(1, ...)) can't corrupt protocol
+    framing; otherwise fall back to the classic stdout protocol.
+    """
+    fd = int(os.environ.get("JAPAYA_PROTO_FD", "1"))
+    if fd == 1:
+        return sys.stdout
+    return os.fdopen(fd, "w")
+
+
 def main() -> None:
     global PRELUDE
 
-    # Load in the init file, if present
+    proto_out = open_protocol_out()
+    write_lock = threading.Lock()
+
+    sandbox = os.environ.get("JAPAYA_SANDBOX", "").strip()
+    if sandbox:
+        install_fs_guard(json.loads(sandbox))
+
+    # Snippet output is captured per-thread from here on; anything written
+    # outside a capture falls through to the real streams.
+    sys.stdout = STDOUT
+    sys.stderr = STDERR
+
+    # Load in the init file, if present. Workers forked from a fork server
+    # inherit the parent's already-loaded prelude and skip this.
     py_dir = os.environ.get("JAPAYA_PY_DIR", "").strip()
-    if py_dir:
+    if py_dir and not PRELUDE:
         try:
             PRELUDE = load_prelude(py_dir)
         except Exception:
@@ -128,7 +796,28 @@ def main() -> None:
             sys.stderr.flush()
             raise
 
-    # JSON-lines protocol: one request per line, one response per line.
+    def reply(resp: dict, req_id) -> None:
+        resp["id"] = req_id
+        with write_lock:
+            proto_out.write(json.dumps(resp) + "\n")
+            proto_out.flush()
+
+    def serve(req: dict) -> None:
+        resp = handle_request(req)
+        out = resp.get("out", "")
+        if req.get("accept_gz") and len(out) > COMPRESS_THRESHOLD:
+            # Mirror code_gz: large outputs shrink a lot when they're
+            # generated tables, and the caller asked for it.
+            resp["out_gz"] = base64.b64encode(
+                gzip.compress(out.encode("utf-8"))).decode("ascii")
+            resp["out"] = ""
+        reply(resp, req.get("id", 0))
+
+    # JSON-lines protocol: one request per line, responses tagged with the
+    # request ID and possibly out of order. Each request runs on its own
+    # thread; the GIL serializes CPU, but I/O-bound snippets overlap.
+    threads = []
+    clean_exit = False
     for raw in sys.stdin:
         line = raw.strip()
         if not line:
@@ -137,20 +826,106 @@ def main() -> None:
         try:
             req = json.loads(line)
         except Exception as e:
-            resp = {
+            reply({
                 "ok": False,
                 "err": f"invalid JSON request: {e}",
                 "stdout": "",
                 "stderr": traceback.format_exc(),
-            }
-            sys.stdout.write(json.dumps(resp) + "\n")
-            sys.stdout.flush()
+            }, 0)
             continue
 
-        resp = handle_request(req)
-        sys.stdout.write(json.dumps(resp) + "\n")
-        sys.stdout.flush()
+        if req.get("kind") == "exit":
+            # Graceful shutdown: drain in-flight requests below, then leave
+            # via os._exit so snippet-spawned non-daemon threads can't keep
+            # the process alive through interpreter teardown.
+            reply({"ok": True, "out": "", "err": "",
+                   "stdout": "", "stderr": ""}, req.get("id", 0))
+            clean_exit = True
+            break
+
+        th = threading.Thread(target=serve, args=(req,), daemon=True)
+        th.start()
+        threads = [t for t in threads if t.is_alive()]
+        threads.append(th)
+
+    # stdin closed or exit requested: let in-flight requests finish first.
+    for th in threads:
+        th.join()
+    if clean_exit:
+        os._exit(0)
+
+
+def _recv_fds(sock, nfds):
+    """Receive one message and up to nfds file descriptors over sock."""
+    import array
+    fds = array.array("i")
+    msg, ancdata, _flags, _addr = sock.recvmsg(1, socket.CMSG_LEN(nfds * fds.itemsize))
+    for level, typ, data in ancdata:
+        if level == socket.SOL_SOCKET and typ == socket.SCM_RIGHTS:
+            fds.frombytes(data[: len(data) - (len(data) % fds.itemsize)])
+    return msg, list(fds)
+
+
+def fork_server_main() -> None:
+    """
+    Fork-server mode (POSIX only): import the prelude and any JAPAYA_PRELOAD
+    modules once, then fork a child per worker request from the Go side.
+    Children inherit the parent's loaded modules, so each worker start skips
+    the import cost of heavy helper libraries. The Go side sends each
+    request's stdin and protocol fds over the control socket (SCM_RIGHTS)
+    and reads back the child's pid; closing the socket shuts the server
+    down.
+    """
+    global PRELUDE
+
+    py_dir = os.environ.get("JAPAYA_PY_DIR", "").strip()
+    if py_dir:
+        try:
+            PRELUDE = load_prelude(py_dir)
+        except Exception:
+            sys.stderr.write(traceback.format_exc())
+            sys.stderr.flush()
+            raise
+    for mod in os.environ.get("JAPAYA_PRELOAD", "").split(","):
+        mod = mod.strip()
+        if not mod:
+            continue
+        try:
+            importlib.import_module(mod)
+        except Exception:
+            # A bad preload costs the optimization, not the run; the snippet
+            # that really needs the module will surface the import error.
+            sys.stderr.write(traceback.format_exc())
+            sys.stderr.flush()
+
+    # Children are fire-and-forget from the server's side; the kernel reaps.
+    signal.signal(signal.SIGCHLD, signal.SIG_IGN)
+
+    sock = socket.socket(fileno=int(os.environ["JAPAYA_FORK_FD"]))
+    while True:
+        msg, fds = _recv_fds(sock, 2)
+        if not msg:
+            break
+        stdin_fd, proto_fd = fds
+        pid = os.fork()
+        if pid == 0:
+            sock.close()
+            # Own session, so the Go side can kill the worker and anything
+            # its snippets spawned as one group.
+            os.setsid()
+            os.dup2(stdin_fd, 0)
+            os.close(stdin_fd)
+            sys.stdin = os.fdopen(0, "r")
+            os.environ["JAPAYA_PROTO_FD"] = str(proto_fd)
+            main()
+            os._exit(0)
+        os.close(stdin_fd)
+        os.close(proto_fd)
+        sock.sendall(struct.pack("<i", pid))
 
 
 if __name__ == "__main__":
-    main()
+    if os.environ.get("JAPAYA_FORK_SERVER") == "1":
+        fork_server_main()
+    else:
+        main()
@@ -0,0 +1,42 @@
+package python
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// pycachePrefix picks the persistent bytecode cache directory for python-dir
+// modules. An explicit PYTHONPYCACHEPREFIX in the environment wins; otherwise
+// the cache lives under the user cache dir, so it survives CI containers that
+// wipe the workspace's __pycache__ directories every run. CPython mirrors
+// absolute source paths under the prefix, so one shared prefix serves any
+// number of python-dirs without collisions. Returns "" when no sensible
+// location exists, which disables the cache.
+func pycachePrefix() string {
+	if prefix := os.Getenv("PYTHONPYCACHEPREFIX"); prefix != "" {
+		return prefix
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(base, "japaya", "pycache")
+}
+
+// precompilePythonDir byte-compiles pythonDir into the cache prefix before
+// the worker starts, so even the first import of each helper module hits
+// warm bytecode. compileall skips up-to-date files, so repeat runs are
+// cheap. Hash-based invalidation means an edited helper is recompiled even
+// when the edit preserves the file's size and mtime second, which matters
+// for watch-mode invalidation. Best effort: a helper that fails to compile
+// surfaces as an import error at eval time, not at startup.
+func precompilePythonDir(pythonCmd, pythonDir, prefix string) {
+	if err := os.MkdirAll(prefix, 0o755); err != nil {
+		return
+	}
+	cmd := exec.Command(pythonCmd, "-m", "compileall", "-q",
+		"--invalidation-mode", "checked-hash", pythonDir)
+	cmd.Env = append(os.Environ(), "PYTHONPYCACHEPREFIX="+prefix)
+	_ = cmd.Run()
+}
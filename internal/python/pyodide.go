@@ -0,0 +1,31 @@
+package python
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/justinottesen/japaya/internal/core"
+)
+
+// PyodideEvaluator is a planned evaluator backend that runs snippets inside a
+// Pyodide (CPython compiled to WebAssembly) interpreter embedded in the
+// japaya process, removing the need for a host python installation.
+//
+// The implementation is blocked on vendoring a Go WASM runtime (e.g. wazero)
+// and a Pyodide distribution; until then the constructor reports the backend
+// as unavailable so callers can fall back to the subprocess worker.
+type PyodideEvaluator struct{}
+
+// ErrPyodideUnavailable is returned while the backend is unimplemented.
+var ErrPyodideUnavailable = fmt.Errorf("pyodide backend unavailable: requires a bundled WASM runtime and Pyodide distribution")
+
+func NewPyodideEvaluator() (*PyodideEvaluator, error) {
+	return nil, ErrPyodideUnavailable
+}
+
+func (e *PyodideEvaluator) Close() error { return nil }
+
+// Eval satisfies core.PythonEvaluator.
+func (e *PyodideEvaluator) Eval(ctx context.Context, t core.RegionType, code []byte) ([]byte, error) {
+	return nil, ErrPyodideUnavailable
+}
@@ -6,53 +6,268 @@ import (
 	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
+
+	"github.com/justinottesen/japaya/internal/core"
 )
 
 //go:embed py/worker.py
 var embeddedWorkerPy []byte
 
+// ProtocolVersion is the version of the JSON-lines protocol spoken between
+// the Go side and py/worker.py. Bump when the request/response shape changes.
+//
+// v2: responses report the modules imported during each evaluation, and a
+// new "invalidate" request kind drops cached modules from sys.modules.
+//
+// v3: a "macro" request kind invokes functions snippets registered via
+// japaya.macro(name); unknown names come back flagged rather than failing.
+//
+// v4: evaluation requests may carry a working directory and env additions
+// that the worker applies around the evaluation and restores afterwards.
+//
+// v5: a "session" request kind executes a block in a namespace that persists
+// across requests sharing a session ID; "session_end" discards one.
+//
+// v6: a "module" request kind imports a named module from the python-dir and
+// reports its file path, docstring summary and public names, so helper
+// libraries can be validated up front rather than failing at first use.
+//
+// v7: requests carry an ID that responses echo, and the worker runs each
+// request on its own thread, so multiple evaluations can be in flight at
+// once and responses may arrive out of order. The GIL still serializes CPU,
+// but I/O-bound snippets overlap.
+//
+// v8: requests advertise accept_gz and may carry gzip-compressed code in
+// code_gz; responses to such requests may compress large outputs into
+// out_gz. Only gzip for now — zstd needs a third-party codec — but the
+// field naming leaves room.
+//
+// v9: an "exit" request kind asks the worker to drain in-flight requests
+// and leave via os._exit, so snippet-spawned non-daemon threads can't keep
+// the process alive past shutdown.
+//
+// v10: responses may carry a structured result (text, bytes or a list of
+// files, built via japaya.binary / japaya.files), the resources snippets
+// emitted via japaya.emit_resource, and named-slot appends from japaya.slot,
+// instead of squeezing everything through the single out string.
+//
+// v11: responses list the java imports snippets required via
+// japaya.require_import, so the translator can insert the missing ones into
+// the generated file's import block.
+const ProtocolVersion = 11
+
+const (
+	// defaultMaxResponseSize bounds how many bytes a single protocol
+	// response may occupy in memory. Snippets that legitimately produce more
+	// output can raise the limit with SetMaxResponseSize.
+	defaultMaxResponseSize = 64 << 20
+
+	// responseBufferSize is the protocol reader's initial buffer, sized
+	// generously so legitimately large outputs don't thrash reallocations.
+	responseBufferSize = 1 << 20
+)
+
 // PythonWorker is a long-lived Python worker process that evaluates snippets in an
 // isolated namespace per request. This isolation will leak modules if they are
-// mutable, however variables and functions used in blocks will not be leaked
+// mutable, however variables and functions used in blocks will not be leaked.
+//
+// Requests are multiplexed: any number of evaluations may be in flight at
+// once, matched to their responses by ID, so concurrent callers don't queue
+// behind each other's round trips.
 type PythonWorker struct {
 	cmd       *exec.Cmd
+	pid       int // child pid when forked from a fork server; cmd is nil then
 	stdin     io.WriteCloser
-	stdout    *bufio.Reader
-	workerDir string // temp path so we can clean up
+	protoFile *os.File // read side of the dedicated protocol pipe, if one is used
+	workerDir string   // temp path so we can clean up
+
+	sendMu sync.Mutex // serializes request writes to stdin
+
+	nextID atomic.Uint64 // request ID generator
+
+	// Largest response line readLoop will buffer; see SetMaxResponseSize.
+	maxResponseSize atomic.Int64
 
 	mu sync.Mutex
 
+	// Completion callbacks for in-flight requests, keyed by request ID.
+	// Guarded by mu; readLoop invokes them as responses arrive.
+	pending map[uint64]func(callResult)
+
+	// Top-level modules the worker reported importing since the last
+	// TakeImports call. Guarded by mu.
+	imports map[string]bool
+
+	// Resources snippets emitted via japaya.emit_resource since the last
+	// TakeResources call. Guarded by mu.
+	resources []ResultFile
+
+	// Named-slot appends from japaya.slot since the last TakeSlots call.
+	// Guarded by mu.
+	slots []SlotAppend
+
+	// Java imports snippets required via japaya.require_import since the last
+	// TakeRequiredImports call. Guarded by mu.
+	requires map[string]bool
+
+	// Output-path override from japaya.set_output since the last
+	// TakeOutputPath call; the last call wins. Guarded by mu.
+	outputPath string
+
+	// Scope attached to every evaluation request until changed: the working
+	// directory snippets run in and extra env vars they see. Guarded by mu.
+	scopeCwd string
+	scopeEnv map[string]string
+
 	closeOnce  sync.Once
 	closeError error
 
 	closing atomic.Bool
+
+	// Activity counters for monitoring; see Metrics.
+	metrics Metrics
+
+	// Debug trace of protocol traffic; see SetTrace. Guarded by traceMu.
+	traceMu  sync.Mutex
+	traceW   io.Writer
+	traceMax int
+
+	// Crash forensics: the last few request summaries (guarded by mu), the
+	// tail of the worker's stderr, and the exit status harvested by the
+	// waiter goroutine (valid once exited is closed).
+	recent     []string
+	stderrTail tailBuffer
+	exited     chan struct{}
+	waitErr    error
+}
+
+const (
+	// forensicsRequests is how many recent request summaries crash reports
+	// include.
+	forensicsRequests = 5
+
+	// stderrTailSize is how much worker stderr crash reports retain.
+	stderrTailSize = 4 << 10
+)
+
+// tailBuffer keeps the last stderrTailSize bytes written to it, so a crash
+// report can include what the worker said on the way down.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if n := len(t.buf) - stderrTailSize; n > 0 {
+		t.buf = append([]byte(nil), t.buf[n:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) tail() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
+
+// callResult pairs a response with a transport-level error for the caller
+// waiting on it.
+type callResult struct {
+	resp pythonResponse
+	err  error
+}
+
+// Result carries the outcome of one asynchronous evaluation.
+type Result struct {
+	Out []byte
+	Err error
+}
+
+// TypedResult is the structured payload of one evaluation. Plain string
+// output is a "text" result; snippets build "bytes" and "files" results via
+// japaya.binary and japaya.files, so binary and multi-file outputs don't
+// have to squeeze through the single out string.
+type TypedResult struct {
+	Type  string       `json:"type"` // "text", "bytes" or "files"
+	Text  string       `json:"text,omitempty"`
+	Data  []byte       `json:"data,omitempty"` // base64-framed on the wire
+	Files []ResultFile `json:"files,omitempty"`
+}
+
+// ResultFile is one entry of a "files" result.
+type ResultFile struct {
+	Path string `json:"path"`
+	Data []byte `json:"data"` // base64-framed on the wire
+}
+
+// SlotAppend is one japaya.slot call: text destined for the named output
+// slot, flushed at the template's marker for that slot.
+type SlotAppend struct {
+	Name string `json:"name"`
+	Text string `json:"text"`
 }
 
 type pythonRequest struct {
-	Kind string `json:"kind"`
-	Code string `json:"code"`
+	ID      uint64            `json:"id"`
+	Kind    string            `json:"kind"`
+	Code    string            `json:"code,omitempty"`
+	Modules []string          `json:"modules,omitempty"` // for kind "invalidate"
+	Name    string            `json:"name,omitempty"`    // for kinds "macro" and "module"
+	Args    map[string]string `json:"args,omitempty"`    // for kind "macro"
+	Cwd     string            `json:"cwd,omitempty"`     // working directory applied around the evaluation
+	Env     map[string]string `json:"env,omitempty"`     // env additions applied around the evaluation
+	Session string            `json:"session,omitempty"` // for kinds "session" and "session_end"
+
+	CodeGz   []byte `json:"code_gz,omitempty"`   // gzip-compressed Code, used for large payloads
+	AcceptGz bool   `json:"accept_gz,omitempty"` // the sender understands out_gz responses
 }
 
 type pythonResponse struct {
-	OK     bool   `json:"ok"`
-	Out    string `json:"out,omitempty"`
-	Err    string `json:"err,omitempty"`
-	Stdout string `json:"stdout,omitempty"`
-	Stderr string `json:"stderr,omitempty"`
+	ID      uint64   `json:"id"`
+	OK      bool     `json:"ok"`
+	Out     string   `json:"out,omitempty"`
+	Err     string   `json:"err,omitempty"`
+	Line    int      `json:"line,omitempty"` // 1-based line within the snippet where the error occurred
+	Stdout  string   `json:"stdout,omitempty"`
+	Stderr  string   `json:"stderr,omitempty"`
+	Imports []string `json:"imports,omitempty"` // top-level modules imported during evaluation
+	Unknown bool     `json:"unknown,omitempty"` // macro name not registered
+	OutGz   []byte   `json:"out_gz,omitempty"`  // gzip-compressed Out, used for large outputs
+	Path    string   `json:"path,omitempty"`    // file a module was loaded from (kind "module")
+	Doc     string   `json:"doc,omitempty"`     // first docstring line (kind "module")
+	Exports []string `json:"exports,omitempty"` // public top-level names (kind "module")
+
+	Result *TypedResult `json:"result,omitempty"` // structured payload, when the snippet built one
+
+	Resources []ResultFile `json:"resources,omitempty"` // files emitted via japaya.emit_resource
+	Slots     []SlotAppend `json:"slots,omitempty"`     // named-slot appends from japaya.slot
+	Requires  []string     `json:"requires,omitempty"`  // java imports required via japaya.require_import
+
+	Skip       bool   `json:"skip,omitempty"`        // the snippet called japaya.skip
+	SkipReason string `json:"skip_reason,omitempty"` // its reason, if it gave one
+
+	OutputPath string `json:"output_path,omitempty"` // override from japaya.set_output
 }
 
 type PythonError struct {
 	Kind   string
 	ErrMsg string
+	Line   int // 1-based line within the snippet, 0 when unknown
 	Stdout string
 	Stderr string
 }
@@ -65,6 +280,11 @@ func (e *PythonError) Error() string {
 	return msg
 }
 
+// SnippetLine reports the 1-based line within the snippet where the error
+// occurred, or 0 when the worker couldn't tell. Core uses it to point
+// errors in multi-line blocks at the absolute file line.
+func (e *PythonError) SnippetLine() int { return e.Line }
+
 // Get the python executable command based on the OS
 func defaultPythonCmd() string {
 	if runtime.GOOS == "windows" {
@@ -74,6 +294,12 @@ func defaultPythonCmd() string {
 }
 
 func StartPythonWorker(pythonCmd string, pythonDir string) (*PythonWorker, error) {
+	return StartSandboxedPythonWorker(pythonCmd, pythonDir, nil)
+}
+
+// StartSandboxedPythonWorker is StartPythonWorker with optional filesystem
+// confinement; see SandboxConfig. sb may be nil for an unrestricted worker.
+func StartSandboxedPythonWorker(pythonCmd string, pythonDir string, sb *SandboxConfig) (*PythonWorker, error) {
 	// Load with defaults if not provided
 	if pythonCmd == "" {
 		pythonCmd = defaultPythonCmd()
@@ -92,72 +318,350 @@ func StartPythonWorker(pythonCmd string, pythonDir string) (*PythonWorker, error
 		return nil, err
 	}
 
-	// Create and setup the command
-	cmd := exec.Command(pythonCmd, "-u", workerPath)
+	// Create and setup the command. The worker gets its own process group so
+	// subprocesses spawned by snippets die with it (see killProcessGroup).
+	name, args := sandboxCommand(pythonCmd, []string{"-u", workerPath}, sb, []string{tmpDir, pythonDir})
+	cmd := exec.Command(name, args...)
+	setProcessGroup(cmd)
+	env := os.Environ()
+	if sb != nil {
+		// Belt and braces: the in-worker open() guard applies even when
+		// bwrap confines the mounts.
+		env = append(env, "JAPAYA_SANDBOX="+sandboxEnv(sb, pythonDir))
+	}
 
 	// Add the python dir
-	if pythonDir != "" {
-		// Preserve any existing PYTHONPATH and prepend ours.
-		env := os.Environ()
-		const key = "PYTHONPATH="
-
-		var had bool
-		for i := range env {
-			if strings.HasPrefix(env[i], key) {
-				had = true
-				// Prepend our dir so it wins.
-				env[i] = key + pythonDir + string(os.PathListSeparator) + strings.TrimPrefix(env[i], key)
-				break
-			}
-		}
-		if !had {
-			env = append(env, key+pythonDir)
-		}
-		cmd.Env = env
-
-		// Add an environment variable for the dir as well
-		cmd.Env = append(cmd.Env, "JAPAYA_PY_DIR="+pythonDir)
-	}
+	env = pythonDirEnv(env, pythonCmd, pythonDir, sb != nil)
 
-	// Get stdin and stdout pipes
+	// Get stdin pipe for requests
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		_ = os.RemoveAll(tmpDir)
 		return nil, err
 	}
-	stdout, err := cmd.StdoutPipe()
+
+	// Responses travel on a dedicated pipe (fd 3 in the child) where
+	// supported, so snippets writing to the real stdout — native extensions,
+	// os.write(1, ...) — can't corrupt protocol framing. The worker's fd 1
+	// then just passes through to our stderr. Windows has no ExtraFiles, so
+	// it keeps the classic stdout protocol.
+	var protoReader io.Reader
+	var protoFile *os.File
+	if runtime.GOOS == "windows" {
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			_ = os.RemoveAll(tmpDir)
+			return nil, err
+		}
+		protoReader = stdout
+	} else {
+		r, w, err := os.Pipe()
+		if err != nil {
+			_ = os.RemoveAll(tmpDir)
+			return nil, err
+		}
+		cmd.ExtraFiles = []*os.File{w} // becomes fd 3 in the child
+		env = append(env, "JAPAYA_PROTO_FD=3")
+		cmd.Stdout = os.Stderr
+		protoReader = r
+		protoFile = r
+		defer w.Close() // the child holds its own copy after Start
+	}
+	cmd.Env = env
+
+	return launchWorker(cmd, stdin, protoReader, protoFile, tmpDir)
+}
+
+// pythonDirEnv appends the PYTHONPATH, JAPAYA_PY_DIR and persistent
+// bytecode-cache variables for pythonDir onto env; a no-op when pythonDir
+// is empty.
+func pythonDirEnv(env []string, pythonCmd, pythonDir string, sandboxed bool) []string {
+	if pythonDir == "" {
+		return env
+	}
+
+	// Preserve any existing PYTHONPATH and prepend ours.
+	const key = "PYTHONPATH="
+
+	var had bool
+	for i := range env {
+		if strings.HasPrefix(env[i], key) {
+			had = true
+			// Prepend our dir so it wins.
+			env[i] = key + pythonDir + string(os.PathListSeparator) + strings.TrimPrefix(env[i], key)
+			break
+		}
+	}
+	if !had {
+		env = append(env, key+pythonDir)
+	}
+
+	// Add an environment variable for the dir as well
+	env = append(env, "JAPAYA_PY_DIR="+pythonDir)
+
+	// Cache helper-module bytecode somewhere persistent. Skipped for
+	// sandboxed workers, whose mounts wouldn't allow the writes anyway.
+	if prefix := pycachePrefix(); prefix != "" && !sandboxed {
+		precompilePythonDir(pythonCmd, pythonDir, prefix)
+		env = append(env, "PYTHONPYCACHEPREFIX="+prefix)
+	}
+	return env
+}
+
+// launchWorker starts cmd and wires up the protocol plumbing shared by the
+// subprocess and docker engines: the stderr copier, the exit-status waiter
+// and the response read loop.
+func launchWorker(cmd *exec.Cmd, stdin io.WriteCloser, protoReader io.Reader, protoFile *os.File, tmpDir string) (*PythonWorker, error) {
+	p := &PythonWorker{
+		cmd:       cmd,
+		stdin:     stdin,
+		protoFile: protoFile,
+		workerDir: tmpDir,
+		pending:   make(map[uint64]func(callResult)),
+		exited:    make(chan struct{}),
+	}
+	p.maxResponseSize.Store(defaultMaxResponseSize)
+
+	// Anything the worker writes to its real stderr — prelude tracebacks,
+	// native extensions dying — passes through, with a tail retained for
+	// crash reports. A private pipe with our own copier, rather than handing
+	// exec the writer: exec's Wait would otherwise block until
+	// snippet-spawned children that inherited stderr exit too.
+	stderrR, stderrW, err := os.Pipe()
 	if err != nil {
+		if protoFile != nil {
+			_ = protoFile.Close()
+		}
 		_ = os.RemoveAll(tmpDir)
 		return nil, err
 	}
+	cmd.Stderr = stderrW
 
-	// Start the process
 	if err := cmd.Start(); err != nil {
+		_ = stderrR.Close()
+		_ = stderrW.Close()
+		if protoFile != nil {
+			_ = protoFile.Close()
+		}
 		_ = os.RemoveAll(tmpDir)
 		return nil, err
 	}
+	_ = stderrW.Close() // the child holds its own copy after Start
+	go func() {
+		_, _ = io.Copy(io.MultiWriter(os.Stderr, &p.stderrTail), stderrR)
+		_ = stderrR.Close()
+	}()
 
-	// Construct the python object
-	p := &PythonWorker{
-		cmd:       cmd,
-		stdin:     stdin,
-		stdout:    bufio.NewReader(stdout),
-		workerDir: tmpDir,
+	// A single waiter goroutine harvests the exit status so both Close and
+	// crash reports can consult it without racing over cmd.Wait. When the
+	// protocol rides on StdoutPipe (Windows, docker), Wait would tear the
+	// pipe down while readLoop still drains it, so Close waits directly
+	// there instead.
+	if protoFile != nil {
+		go func() {
+			p.waitErr = cmd.Wait()
+			close(p.exited)
+		}()
 	}
 
+	go p.readLoop(bufio.NewReaderSize(protoReader, responseBufferSize))
+
 	return p, nil
 }
 
-// Closes stdin and waits for the python process to exit
+// readLoop dispatches protocol responses to their waiting callers by request
+// ID. Runs until the protocol stream closes, then fails anything still
+// pending. Responses nobody is waiting for (abandoned by a cancelled
+// context) are dropped.
+func (p *PythonWorker) readLoop(r *bufio.Reader) {
+	for {
+		line, err := readResponseLine(r, &p.maxResponseSize)
+		if err != nil {
+			p.failPending(p.crashError(fmt.Errorf("failed reading from python worker: %w", err)))
+			return
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var resp pythonResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			// Framing is gone; there is no telling which request this
+			// belonged to, so everything in flight fails.
+			s := string(line)
+			if len(s) > 200 {
+				s = s[:200] + "..."
+			}
+			p.failPending(p.crashError(fmt.Errorf("invalid python response JSON: %w (line=%q)", err, s)))
+			return
+		}
+
+		var decompressErr error
+		if len(resp.OutGz) > 0 {
+			if out, err := gunzipBytes(resp.OutGz); err != nil {
+				decompressErr = fmt.Errorf("decompress python response: %w", err)
+			} else {
+				resp.Out = string(out)
+				resp.OutGz = nil
+			}
+		}
+
+		p.mu.Lock()
+		deliver := p.pending[resp.ID]
+		delete(p.pending, resp.ID)
+		p.mu.Unlock()
+		if deliver != nil {
+			if decompressErr != nil {
+				deliver(callResult{err: decompressErr})
+			} else {
+				deliver(callResult{resp: resp})
+			}
+		}
+	}
+}
+
+// readResponseLine reads one protocol line, failing once it exceeds the
+// limit rather than buffering an arbitrarily large response in memory. The
+// limit is loaded at check time so SetMaxResponseSize applies immediately.
+func readResponseLine(r *bufio.Reader, limit *atomic.Int64) ([]byte, error) {
+	var line []byte
+	for {
+		frag, err := r.ReadSlice('\n')
+		line = append(line, frag...)
+		if max := int(limit.Load()); len(line) > max {
+			return nil, fmt.Errorf("response exceeds %d bytes; raise the limit with SetMaxResponseSize if the output is legitimate", max)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		return line, err
+	}
+}
+
+// crashError augments a transport error with forensics — the worker's exit
+// status, the tail of its stderr, and the last few requests sent — so a
+// segfaulting native extension leaves more behind than a bare "EOF".
+func (p *PythonWorker) crashError(err error) error {
+	var b strings.Builder
+	b.WriteString(err.Error())
+
+	select {
+	case <-p.exited:
+		if p.waitErr != nil {
+			fmt.Fprintf(&b, "; worker exited: %v", p.waitErr)
+		} else {
+			b.WriteString("; worker exited cleanly")
+		}
+	case <-time.After(time.Second):
+		b.WriteString("; worker still running")
+	}
+
+	if tail := strings.TrimSpace(p.stderrTail.tail()); tail != "" {
+		fmt.Fprintf(&b, "\nworker stderr tail:\n%s", tail)
+	}
+
+	p.mu.Lock()
+	recent := append([]string(nil), p.recent...)
+	p.mu.Unlock()
+	if len(recent) > 0 {
+		fmt.Fprintf(&b, "\nlast requests sent:\n%s", strings.Join(recent, "\n"))
+	}
+
+	return errors.New(b.String())
+}
+
+// requestSummary renders one request for crash reports.
+func requestSummary(req *pythonRequest) string {
+	code := strings.ReplaceAll(req.Code, "\n", `\n`)
+	if len(code) > 120 {
+		code = code[:120] + "..."
+	}
+	if code == "" && len(req.CodeGz) > 0 {
+		code = fmt.Sprintf("(gzip, %d bytes)", len(req.CodeGz))
+	}
+	if req.Kind == "macro" || req.Kind == "module" {
+		return fmt.Sprintf("#%d %s %s", req.ID, req.Kind, req.Name)
+	}
+	return fmt.Sprintf("#%d %s %s", req.ID, req.Kind, code)
+}
+
+// failPending delivers err to every in-flight request.
+func (p *PythonWorker) failPending(err error) {
+	p.mu.Lock()
+	pending := p.pending
+	p.pending = make(map[uint64]func(callResult))
+	p.mu.Unlock()
+	for _, deliver := range pending {
+		deliver(callResult{err: err})
+	}
+}
+
+// defaultCloseTimeout is how long Close lets the worker drain before
+// killing it.
+const defaultCloseTimeout = 5 * time.Second
+
+// killGroup terminates the worker's whole process group, whether the worker
+// is a subprocess we exec'ed or a child forked from a fork server.
+func (p *PythonWorker) killGroup() {
+	if p.cmd == nil {
+		killForkedWorker(p.pid)
+		return
+	}
+	killProcessGroup(p.cmd)
+}
+
+// Close shuts the worker down with a default kill deadline; see Shutdown.
 func (p *PythonWorker) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultCloseTimeout)
+	defer cancel()
+	return p.Shutdown(ctx)
+}
+
+// Shutdown stops the worker: it sends the protocol "exit" request and closes
+// stdin, letting the worker drain in-flight requests and leave cleanly. If
+// the process is still running when ctx ends — a snippet may have blocked a
+// request thread forever — it is killed. Safe to call more than once; later
+// calls return the first call's result.
+func (p *PythonWorker) Shutdown(ctx context.Context) error {
 	p.closeOnce.Do(func() {
 		p.closing.Store(true)
 
-		p.mu.Lock()
-		defer p.mu.Unlock()
-
+		// Best-effort clean exit, then stdin EOF as a backstop: either way
+		// the worker drains and the protocol stream closes, unblocking
+		// readLoop.
+		p.sendMu.Lock()
+		if line, err := json.Marshal(&pythonRequest{ID: p.nextID.Add(1), Kind: "exit"}); err == nil {
+			_, _ = p.stdin.Write(append(line, '\n'))
+		}
 		_ = p.stdin.Close()
-		p.closeError = p.cmd.Wait()
+		p.sendMu.Unlock()
+
+		if p.protoFile != nil {
+			select {
+			case <-p.exited:
+				p.closeError = p.waitErr
+			case <-ctx.Done():
+				p.killGroup()
+				<-p.exited
+				p.closeError = fmt.Errorf("python worker did not exit before the deadline; killed: %w", ctx.Err())
+			}
+			_ = p.protoFile.Close()
+		} else {
+			done := make(chan error, 1)
+			go func() { done <- p.cmd.Wait() }()
+			select {
+			case err := <-done:
+				p.closeError = err
+			case <-ctx.Done():
+				p.killGroup()
+				<-done
+				p.closeError = fmt.Errorf("python worker did not exit before the deadline; killed: %w", ctx.Err())
+			}
+		}
+		// Even after a clean exit, snippet-spawned subprocesses may outlive
+		// the worker; sweep the whole group so they don't leak past the run.
+		p.killGroup()
 		_ = os.RemoveAll(p.workerDir)
 	})
 
@@ -168,7 +672,9 @@ func (p *PythonWorker) IsClosed() bool {
 	return p.closing.Load()
 }
 
-// Evaluate some python code
+// Evaluate some python code. Safe for concurrent use: requests are
+// multiplexed over the worker, so callers don't queue behind each other's
+// round trips.
 func (p *PythonWorker) Eval(ctx context.Context, kind string, code []byte) ([]byte, error) {
 	// Check if python evaluator is running
 	if p.IsClosed() {
@@ -179,82 +685,580 @@ func (p *PythonWorker) Eval(ctx context.Context, kind string, code []byte) ([]by
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	if kind != "stmt" && kind != "block" {
-		return nil, fmt.Errorf("invalid kind %q (expected stmt|block)", kind)
+	if kind != "stmt" && kind != "block" && kind != "repl" {
+		return nil, fmt.Errorf("invalid kind %q (expected stmt|block|repl)", kind)
 	}
 
-	// Grab mutex
 	p.mu.Lock()
-	defer p.mu.Unlock()
+	req := pythonRequest{Kind: kind, Code: string(code), Cwd: p.scopeCwd, Env: p.scopeEnv}
+	p.mu.Unlock()
 
-	// Check again under the lock if we closed the worker
+	resp, err := p.call(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember which helper modules the snippet pulled in; TakeImports drains
+	// this for dependency tracking.
+	p.mergeImports(resp.Imports)
+	p.mergeResources(resp.Resources)
+	p.mergeSlots(resp.Slots)
+	p.mergeRequires(resp.Requires)
+	p.mergeOutputPath(resp.OutputPath)
+
+	if resp.Skip {
+		return nil, &core.FileSkippedError{Reason: resp.SkipReason}
+	}
+
+	// Typed results that still fit a byte slice are unwrapped here; callers
+	// that want the structure itself use EvalTyped.
+	if r := resp.Result; r != nil {
+		switch r.Type {
+		case "text":
+			return []byte(r.Text), nil
+		case "bytes":
+			return r.Data, nil
+		default:
+			return nil, fmt.Errorf("snippet returned a %q result with %d file(s); receive it with EvalTyped", r.Type, len(r.Files))
+		}
+	}
+	return []byte(resp.Out), nil
+}
+
+// EvalTyped evaluates code like Eval but hands back the worker's structured
+// result. Plain string output arrives as a "text" result, so callers can
+// treat every evaluation uniformly.
+func (p *PythonWorker) EvalTyped(ctx context.Context, kind string, code []byte) (*TypedResult, error) {
 	if p.IsClosed() {
 		return nil, fmt.Errorf("python worker is closed")
 	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if kind != "stmt" && kind != "block" && kind != "repl" {
+		return nil, fmt.Errorf("invalid kind %q (expected stmt|block|repl)", kind)
+	}
 
-	// Check for cancellation
-	//
-	// TODO: This is not scalable. We only check for context cancellation once we
-	// have the mutex, when getting the mutex is likely to be the bottleneck when
-	// we have scaled sufficiently to need cancellations
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	default:
+	p.mu.Lock()
+	req := pythonRequest{Kind: kind, Code: string(code), Cwd: p.scopeCwd, Env: p.scopeEnv}
+	p.mu.Unlock()
+
+	resp, err := p.call(ctx, req)
+	if err != nil {
+		return nil, err
 	}
+	p.mergeImports(resp.Imports)
+	p.mergeResources(resp.Resources)
+	p.mergeSlots(resp.Slots)
+	p.mergeRequires(resp.Requires)
+	p.mergeOutputPath(resp.OutputPath)
 
-	// Actually evaluate
-	return p.evalOne(kind, code)
+	if resp.Skip {
+		return nil, &core.FileSkippedError{Reason: resp.SkipReason}
+	}
+
+	if resp.Result != nil {
+		return resp.Result, nil
+	}
+	return &TypedResult{Type: "text", Text: resp.Out}, nil
 }
 
-// Evaluate a single python snippet
-//
-// NOTE: This should be run under the mutex
-func (p *PythonWorker) evalOne(kind string, code []byte) ([]byte, error) {
-	// Create a python request from the provided code
-	req := pythonRequest{
-		Kind: kind,
-		Code: string(code),
+// SetSnippetScope sets the working directory and extra environment variables
+// applied around every subsequent evaluation. The worker chdirs into cwd for
+// the duration of each request and restores its previous state afterwards,
+// so one file's scope never leaks into the next. An empty cwd leaves the
+// worker's own directory alone.
+func (p *PythonWorker) SetSnippetScope(cwd string, env map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.scopeCwd = cwd
+	p.scopeEnv = env
+}
+
+// EvalAsync queues an evaluation and returns a buffered channel that
+// receives its Result exactly once. The request is in flight immediately, so
+// callers can queue many snippets and collect results later without tying up
+// a goroutine per region. The context is only checked before the request is
+// sent; a caller that stops waiting can simply drop the channel, and the
+// response is discarded when it arrives.
+func (p *PythonWorker) EvalAsync(ctx context.Context, kind string, code []byte) <-chan Result {
+	ch := make(chan Result, 1)
+
+	if p.IsClosed() {
+		ch <- Result{Err: fmt.Errorf("python worker is closed")}
+		return ch
 	}
-	line, err := json.Marshal(req)
+	if kind != "stmt" && kind != "block" && kind != "repl" {
+		ch <- Result{Err: fmt.Errorf("invalid kind %q (expected stmt|block|repl)", kind)}
+		return ch
+	}
+	if ctx != nil {
+		if err := ctx.Err(); err != nil {
+			ch <- Result{Err: err}
+			return ch
+		}
+	}
+
+	p.mu.Lock()
+	req := pythonRequest{Kind: kind, Code: string(code), Cwd: p.scopeCwd, Env: p.scopeEnv}
+	p.mu.Unlock()
+
+	err := p.start(&req, func(r callResult) {
+		if r.err != nil {
+			ch <- Result{Err: r.err}
+			return
+		}
+		resp := r.resp
+		if !resp.OK {
+			ch <- Result{Err: &PythonError{
+				Kind:   req.Kind,
+				ErrMsg: resp.Err,
+				Line:   resp.Line,
+				Stdout: strings.ReplaceAll(resp.Stdout, "\r\n", "\n"),
+				Stderr: strings.ReplaceAll(resp.Stderr, "\r\n", "\n"),
+			}}
+			return
+		}
+		p.mergeImports(resp.Imports)
+		ch <- Result{Out: []byte(resp.Out)}
+	})
 	if err != nil {
-		return nil, err
+		ch <- Result{Err: err}
 	}
-	line = append(line, '\n')
+	return ch
+}
 
-	// Send the code to the python process
-	if _, err := p.stdin.Write(line); err != nil {
-		return nil, fmt.Errorf("failed writing to python worker: %w", err)
+// TakeImports drains and returns the sorted set of top-level modules the
+// worker reported importing since the last call. Callers that want per-file
+// attribution should drain after each file.
+func (p *PythonWorker) TakeImports() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.imports) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(p.imports))
+	for mod := range p.imports {
+		out = append(out, mod)
+	}
+	p.imports = nil
+	sort.Strings(out)
+	return out
+}
+
+// Invalidate drops the given top-level modules from the worker's module
+// cache so the next import re-reads them from disk.
+func (p *PythonWorker) Invalidate(ctx context.Context, modules []string) error {
+	if p.IsClosed() {
+		return fmt.Errorf("python worker is closed")
+	}
+	if len(modules) == 0 {
+		return nil
+	}
+
+	_, err := p.call(ctx, pythonRequest{Kind: "invalidate", Modules: modules})
+	return err
+}
+
+// EvalMacro invokes a macro the worker registered via japaya.macro(name).
+// known is false when no such macro exists, so the caller can leave the
+// source text untouched rather than fail on ordinary Java annotations.
+func (p *PythonWorker) EvalMacro(ctx context.Context, name string, args map[string]string) (string, bool, error) {
+	if p.IsClosed() {
+		return "", false, fmt.Errorf("python worker is closed")
+	}
+
+	p.mu.Lock()
+	req := pythonRequest{Kind: "macro", Name: name, Args: args, Cwd: p.scopeCwd, Env: p.scopeEnv}
+	p.mu.Unlock()
+
+	resp, err := p.call(ctx, req)
+	if err != nil {
+		return "", false, err
+	}
+	if resp.Unknown {
+		return "", false, nil
+	}
+	p.mergeImports(resp.Imports)
+	return resp.Out, true, nil
+}
+
+// ModuleInfo describes a helper module imported from the python-dir.
+type ModuleInfo struct {
+	Path    string   // file the module was loaded from, if known
+	Doc     string   // first line of the module docstring, if any
+	Exports []string // public top-level names, sorted
+}
+
+// ExecModule imports a named module from the python-dir and reports what it
+// exports. A failed import surfaces here with its traceback, so callers can
+// validate helper libraries up front instead of at first snippet use.
+func (p *PythonWorker) ExecModule(ctx context.Context, name string) (ModuleInfo, error) {
+	var info ModuleInfo
+	if p.IsClosed() {
+		return info, fmt.Errorf("python worker is closed")
+	}
+	if name == "" {
+		return info, fmt.Errorf("empty module name")
+	}
+
+	resp, err := p.call(ctx, pythonRequest{Kind: "module", Name: name})
+	if err != nil {
+		return info, fmt.Errorf("import module %q: %w", name, err)
+	}
+	info.Path = resp.Path
+	info.Doc = resp.Doc
+	info.Exports = resp.Exports
+	return info, nil
+}
+
+// EvalSession executes code like a block, but in a namespace that persists
+// across calls sharing the same session ID. The caller decides what a
+// session spans (typically one per file or per run); EndSession discards it.
+func (p *PythonWorker) EvalSession(ctx context.Context, session string, code []byte) ([]byte, error) {
+	if p.IsClosed() {
+		return nil, fmt.Errorf("python worker is closed")
+	}
+	if session == "" {
+		return nil, fmt.Errorf("empty session ID")
 	}
 
-	// Read the response
-	respLine, err := p.stdout.ReadBytes('\n')
+	p.mu.Lock()
+	req := pythonRequest{Kind: "session", Session: session, Code: string(code), Cwd: p.scopeCwd, Env: p.scopeEnv}
+	p.mu.Unlock()
+
+	resp, err := p.call(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed reading from python worker: %w", err)
+		return nil, err
+	}
+	p.mergeImports(resp.Imports)
+	return []byte(resp.Out), nil
+}
+
+// EndSession discards a session's namespace so a later request with the same
+// ID starts fresh. Ending a session that was never used is not an error.
+func (p *PythonWorker) EndSession(ctx context.Context, session string) error {
+	if p.IsClosed() {
+		return fmt.Errorf("python worker is closed")
+	}
+	if session == "" {
+		return fmt.Errorf("empty session ID")
+	}
+
+	_, err := p.call(ctx, pythonRequest{Kind: "session_end", Session: session})
+	return err
+}
+
+// mergeImports records modules a response reported importing; TakeImports
+// drains them for dependency tracking.
+func (p *PythonWorker) mergeImports(mods []string) {
+	if len(mods) == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.imports == nil {
+		p.imports = make(map[string]bool)
+	}
+	for _, mod := range mods {
+		p.imports[mod] = true
+	}
+}
+
+// mergeResources records files a response reported emitting; TakeResources
+// drains them so the build can write them under its resources root.
+func (p *PythonWorker) mergeResources(resources []ResultFile) {
+	if len(resources) == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resources = append(p.resources, resources...)
+}
+
+// TakeResources drains and returns the resources snippets emitted since the
+// last call, in emission order. Callers that want per-file attribution
+// should drain after each file.
+func (p *PythonWorker) TakeResources() []ResultFile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := p.resources
+	p.resources = nil
+	return out
+}
+
+// mergeSlots records named-slot appends a response reported; TakeSlots
+// drains them so the translator can flush them at the file's markers.
+func (p *PythonWorker) mergeSlots(slots []SlotAppend) {
+	if len(slots) == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.slots = append(p.slots, slots...)
+}
+
+// TakeSlots drains and returns the named-slot appends accumulated since the
+// last call, in emission order. Callers that want per-file attribution
+// should drain after each file.
+func (p *PythonWorker) TakeSlots() []SlotAppend {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := p.slots
+	p.slots = nil
+	return out
+}
+
+// mergeOutputPath records an output-path override a response reported;
+// TakeOutputPath drains it so the translator can redirect the file's output.
+// Later overrides win, matching the last japaya.set_output call in the file.
+func (p *PythonWorker) mergeOutputPath(path string) {
+	if path == "" {
+		return
 	}
-	respLine = bytes.TrimSpace(respLine)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.outputPath = path
+}
 
-	// Process the response
+// TakeOutputPath drains and returns the output-path override from the
+// evaluations since the last call, or "" when no snippet set one. Callers
+// that want per-file attribution should drain after each file.
+func (p *PythonWorker) TakeOutputPath() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := p.outputPath
+	p.outputPath = ""
+	return out
+}
+
+// mergeRequires records java imports a response reported requiring;
+// TakeRequiredImports drains them so the translator can insert them into the
+// generated file's import block.
+func (p *PythonWorker) mergeRequires(fqns []string) {
+	if len(fqns) == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.requires == nil {
+		p.requires = make(map[string]bool)
+	}
+	for _, fqn := range fqns {
+		p.requires[fqn] = true
+	}
+}
+
+// TakeRequiredImports drains and returns the sorted set of java imports
+// snippets required via japaya.require_import since the last call. Callers
+// that want per-file attribution should drain after each file.
+func (p *PythonWorker) TakeRequiredImports() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.requires) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(p.requires))
+	for fqn := range p.requires {
+		out = append(out, fqn)
+	}
+	p.requires = nil
+	sort.Strings(out)
+	return out
+}
+
+// call sends one request and waits for its response, which may arrive out of
+// order relative to other in-flight requests. A cancelled context abandons
+// the request: the worker keeps evaluating it, but readLoop drops the
+// eventual response and the worker stays usable.
+func (p *PythonWorker) call(ctx context.Context, req pythonRequest) (pythonResponse, error) {
 	var resp pythonResponse
-	if err := json.Unmarshal(respLine, &resp); err != nil {
-		s := string(respLine)
-		if len(s) > 200 {
-			s = s[:200] + "..."
+
+	if p.IsClosed() {
+		return resp, fmt.Errorf("python worker is closed")
+	}
+	if ctx != nil {
+		if err := ctx.Err(); err != nil {
+			return resp, err
+		}
+	}
+
+	ch := make(chan callResult, 1)
+	if err := p.start(&req, func(r callResult) { ch <- r }); err != nil {
+		return resp, err
+	}
+
+	// Wait for readLoop to deliver the matching response
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return resp, r.err
 		}
-		return nil, fmt.Errorf("invalid python response JSON: %w (line=%q)", err, s)
+		resp = r.resp
+	case <-done:
+		p.forget(req.ID)
+		return resp, fmt.Errorf("python evaluation interrupted: %w", ctx.Err())
 	}
 
 	// Return error info (if applicable)
 	if !resp.OK {
 		resp.Stdout = strings.ReplaceAll(resp.Stdout, "\r\n", "\n")
 		resp.Stderr = strings.ReplaceAll(resp.Stderr, "\r\n", "\n")
-		return nil, &PythonError{
-			Kind:   kind,
+		return resp, &PythonError{
+			Kind:   req.Kind,
 			ErrMsg: resp.Err,
+			Line:   resp.Line,
 			Stdout: resp.Stdout,
 			Stderr: resp.Stderr,
 		}
 	}
 
-	return []byte(resp.Out), nil
+	return resp, nil
+}
+
+// start registers a completion callback for a request and sends it,
+// assigning the request ID. On send failure the callback is unregistered and
+// never invoked. deliver runs on the readLoop goroutine, so it must not
+// block.
+func (p *PythonWorker) start(req *pythonRequest, deliver func(callResult)) error {
+	req.ID = p.nextID.Add(1)
+	req.AcceptGz = true
+	if len(req.Code) > compressionThreshold {
+		req.CodeGz = gzipBytes([]byte(req.Code))
+		req.Code = ""
+	}
+	started := time.Now()
+	wrapped := func(r callResult) {
+		d := time.Since(started)
+		p.metrics.endRequest(d, r.err != nil || !r.resp.OK)
+		if p.traceEnabled() {
+			p.traceResponse(req.ID, r, d)
+		}
+		deliver(r)
+	}
+	if p.traceEnabled() {
+		p.tracef("> #%d %s code=%q", req.ID, req.Kind, p.traceSnippet(req.Code))
+	}
+	p.metrics.startRequest()
+	p.mu.Lock()
+	p.pending[req.ID] = wrapped
+	p.recent = append(p.recent, requestSummary(req))
+	if len(p.recent) > forensicsRequests {
+		p.recent = p.recent[1:]
+	}
+	p.mu.Unlock()
+
+	line, err := json.Marshal(req)
+	if err != nil {
+		p.forget(req.ID)
+		return err
+	}
+	line = append(line, '\n')
+
+	// Send the code to the python process
+	p.sendMu.Lock()
+	_, err = p.stdin.Write(line)
+	p.sendMu.Unlock()
+	if err != nil {
+		p.forget(req.ID)
+		return fmt.Errorf("failed writing to python worker: %w", err)
+	}
+	return nil
+}
+
+// forget drops a pending entry whose response will never be consumed.
+func (p *PythonWorker) forget(id uint64) {
+	p.mu.Lock()
+	_, ok := p.pending[id]
+	delete(p.pending, id)
+	p.mu.Unlock()
+	if ok {
+		p.metrics.dropRequest()
+	}
+}
+
+// defaultTraceSnippet is how many bytes of a snippet or output trace lines
+// keep when no truncation limit is configured.
+const defaultTraceSnippet = 200
+
+// SetTrace starts appending a timestamped line per protocol request and
+// response to w, with snippets and outputs truncated to maxSnippet bytes
+// (<= 0 uses a default), so protocol issues can be reproduced and reported.
+// Pass a nil writer to stop tracing.
+func (p *PythonWorker) SetTrace(w io.Writer, maxSnippet int) {
+	if maxSnippet <= 0 {
+		maxSnippet = defaultTraceSnippet
+	}
+	p.traceMu.Lock()
+	p.traceW = w
+	p.traceMax = maxSnippet
+	p.traceMu.Unlock()
+}
+
+// traceEnabled reports whether tracing is on, so callers can skip building
+// trace strings when it isn't.
+func (p *PythonWorker) traceEnabled() bool {
+	p.traceMu.Lock()
+	defer p.traceMu.Unlock()
+	return p.traceW != nil
+}
+
+// tracef appends one timestamped line to the trace, if tracing is on.
+func (p *PythonWorker) tracef(format string, args ...any) {
+	p.traceMu.Lock()
+	defer p.traceMu.Unlock()
+	if p.traceW == nil {
+		return
+	}
+	fmt.Fprintf(p.traceW, "%s ", time.Now().Format("2006-01-02T15:04:05.000Z07:00"))
+	fmt.Fprintf(p.traceW, format, args...)
+	fmt.Fprintln(p.traceW)
+}
+
+// traceSnippet flattens and truncates snippet text for one trace line.
+func (p *PythonWorker) traceSnippet(s string) string {
+	p.traceMu.Lock()
+	max := p.traceMax
+	p.traceMu.Unlock()
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if len(s) > max {
+		s = s[:max] + "..."
+	}
+	return s
+}
+
+// traceResponse records one completed request in the trace.
+func (p *PythonWorker) traceResponse(id uint64, r callResult, d time.Duration) {
+	switch {
+	case r.err != nil:
+		p.tracef("< #%d transport error (%s): %v", id, d, r.err)
+	case !r.resp.OK:
+		p.tracef("< #%d fail (%s): %s", id, d, p.traceSnippet(r.resp.Err))
+	default:
+		p.tracef("< #%d ok (%s) out=%q", id, d, p.traceSnippet(r.resp.Out))
+	}
+}
+
+// SetMaxResponseSize bounds how many bytes a single worker response may
+// occupy in memory; n <= 0 restores the default. An oversized response fails
+// every request in flight and abandons the protocol stream, since its
+// framing can no longer be trusted; the region being evaluated shows up in
+// the resulting TranslationError as usual.
+func (p *PythonWorker) SetMaxResponseSize(n int) {
+	if n <= 0 {
+		n = defaultMaxResponseSize
+	}
+	p.maxResponseSize.Store(int64(n))
+}
+
+// Metrics exposes the worker's activity counters; see Metrics.Publish for
+// expvar integration.
+func (p *PythonWorker) Metrics() *Metrics {
+	return &p.metrics
 }
@@ -1,16 +1,23 @@
 package python
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/justinottesen/japaya/internal/core"
 )
 
 func pickPythonCmd(t *testing.T) string {
@@ -278,3 +285,1034 @@ func TestConcurrentEval_SerializesAndWorks(t *testing.T) {
 		t.Fatalf("concurrent eval error: %v", err)
 	}
 }
+
+func TestEval_ReplPersistsNamespace(t *testing.T) {
+	p := mustStart(t)
+
+	if _, err := p.Eval(context.Background(), "repl", []byte("x = 40")); err != nil {
+		t.Fatalf("Eval repl error: %v", err)
+	}
+
+	out, err := p.Eval(context.Background(), "repl", []byte("x + 2"))
+	if err != nil {
+		t.Fatalf("Eval repl error: %v", err)
+	}
+	if string(out) != "42\n" {
+		t.Fatalf("unexpected out: %q", string(out))
+	}
+}
+
+func TestTakeImports_ReportsAndDrains(t *testing.T) {
+	p := mustStart(t)
+
+	if _, err := p.Eval(context.Background(), "block", []byte("import json\nprint(json.dumps({}))\n")); err != nil {
+		t.Fatalf("Eval block error: %v", err)
+	}
+
+	imports := p.TakeImports()
+	found := false
+	for _, mod := range imports {
+		if mod == "json" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected imports to include %q, got %v", "json", imports)
+	}
+
+	// The set drains: a second call without intervening evals reports nothing.
+	if again := p.TakeImports(); again != nil {
+		t.Fatalf("expected drained imports, got %v", again)
+	}
+
+	// Re-importing an already-cached module is still reported.
+	if _, err := p.Eval(context.Background(), "block", []byte("import json\n")); err != nil {
+		t.Fatalf("Eval block error: %v", err)
+	}
+	if imports := p.TakeImports(); len(imports) == 0 {
+		t.Fatalf("expected cached import to still be reported")
+	}
+}
+
+func TestInvalidate_ReloadsEditedModule(t *testing.T) {
+	dir := t.TempDir()
+	helper := filepath.Join(dir, "helper.py")
+	if err := os.WriteFile(helper, []byte("VALUE = 1\n"), 0o644); err != nil {
+		t.Fatalf("write helper: %v", err)
+	}
+
+	cmd := pickPythonCmd(t)
+	p, err := StartPythonWorker(cmd, dir)
+	if err != nil {
+		t.Fatalf("StartPythonWorker error: %v", err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+
+	out, err := p.Eval(context.Background(), "stmt", []byte("__import__('helper').VALUE"))
+	if err != nil {
+		t.Fatalf("Eval stmt error: %v", err)
+	}
+	if string(out) != "1" {
+		t.Fatalf("unexpected out: %q", string(out))
+	}
+
+	// Edit the helper; without invalidation the worker keeps the cached copy.
+	if err := os.WriteFile(helper, []byte("VALUE = 2\n"), 0o644); err != nil {
+		t.Fatalf("rewrite helper: %v", err)
+	}
+	out, err = p.Eval(context.Background(), "stmt", []byte("__import__('helper').VALUE"))
+	if err != nil {
+		t.Fatalf("Eval stmt error: %v", err)
+	}
+	if string(out) != "1" {
+		t.Fatalf("expected cached value before invalidation, got %q", string(out))
+	}
+
+	if err := p.Invalidate(context.Background(), []string{"helper"}); err != nil {
+		t.Fatalf("Invalidate error: %v", err)
+	}
+	out, err = p.Eval(context.Background(), "stmt", []byte("__import__('helper').VALUE"))
+	if err != nil {
+		t.Fatalf("Eval stmt error: %v", err)
+	}
+	if string(out) != "2" {
+		t.Fatalf("expected reloaded value after invalidation, got %q", string(out))
+	}
+}
+
+func TestEvalMacro_RegisteredAndUnknown(t *testing.T) {
+	p := mustStart(t)
+
+	register := "@japaya.macro(\"getter\")\ndef getter(name, type):\n" +
+		"    print(f\"{type} get_{name}() {{ return {name}; }}\")\n"
+	if _, err := p.Eval(context.Background(), "block", []byte(register)); err != nil {
+		t.Fatalf("Eval block error: %v", err)
+	}
+
+	out, known, err := p.EvalMacro(context.Background(), "getter", map[string]string{
+		"name": "foo", "type": "int",
+	})
+	if err != nil {
+		t.Fatalf("EvalMacro error: %v", err)
+	}
+	if !known {
+		t.Fatalf("expected macro to be known after registration")
+	}
+	if out != "int get_foo() { return foo; }\n" {
+		t.Fatalf("unexpected expansion: %q", out)
+	}
+
+	if _, known, err := p.EvalMacro(context.Background(), "nope", nil); err != nil || known {
+		t.Fatalf("EvalMacro(nope) = known=%v err=%v, want unknown and no error", known, err)
+	}
+}
+
+func TestProtocol_SurvivesRawStdoutWrites(t *testing.T) {
+	p := mustStart(t)
+
+	// os.write(1, ...) bypasses sys.stdout redirection entirely; with the
+	// protocol on a dedicated pipe it must not corrupt framing.
+	code := "import os\nos.write(1, b'raw stdout junk\\n')\nprint('kept')\n"
+	out, err := p.Eval(context.Background(), "block", []byte(code))
+	if err != nil {
+		t.Fatalf("Eval block error: %v", err)
+	}
+	if string(out) != "kept\n" {
+		t.Fatalf("unexpected out: %q", string(out))
+	}
+
+	// The worker still answers the next request.
+	out, err = p.Eval(context.Background(), "stmt", []byte("'ok'"))
+	if err != nil {
+		t.Fatalf("Eval stmt error: %v", err)
+	}
+	if string(out) != "ok" {
+		t.Fatalf("unexpected out: %q", string(out))
+	}
+}
+
+func TestSnippetScope_CwdAndEnv(t *testing.T) {
+	p := mustStart(t)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "scope.txt"), []byte("from template dir\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	p.SetSnippetScope(dir, map[string]string{"JAPAYA_SCOPE_TEST": "on"})
+	out, err := p.Eval(context.Background(), "block", []byte(
+		"import os\n"+
+			"print(open(\"scope.txt\").read().strip())\n"+
+			"print(os.environ[\"JAPAYA_SCOPE_TEST\"])\n"))
+	if err != nil {
+		t.Fatalf("Eval block error: %v", err)
+	}
+	if string(out) != "from template dir\non\n" {
+		t.Fatalf("unexpected out: %q", string(out))
+	}
+
+	// Clearing the scope restores the worker's own cwd and environment.
+	p.SetSnippetScope("", nil)
+	out, err = p.Eval(context.Background(), "block", []byte(
+		"import os\n"+
+			"print(os.environ.get(\"JAPAYA_SCOPE_TEST\", \"unset\"))\n"+
+			"print(os.path.exists(\"scope.txt\"))\n"))
+	if err != nil {
+		t.Fatalf("Eval block error: %v", err)
+	}
+	if string(out) != "unset\nFalse\n" {
+		t.Fatalf("scope leaked after clearing: %q", string(out))
+	}
+}
+
+func TestEvalSession_NamespacePerSessionID(t *testing.T) {
+	p := mustStart(t)
+	ctx := context.Background()
+
+	if _, err := p.EvalSession(ctx, "a", []byte("x = 41")); err != nil {
+		t.Fatalf("EvalSession define error: %v", err)
+	}
+	out, err := p.EvalSession(ctx, "a", []byte("print(x + 1)"))
+	if err != nil {
+		t.Fatalf("EvalSession use error: %v", err)
+	}
+	if string(out) != "42\n" {
+		t.Fatalf("unexpected out: %q", string(out))
+	}
+
+	// Other sessions don't see session a's names.
+	if _, err := p.EvalSession(ctx, "b", []byte("print(x)")); err == nil {
+		t.Fatalf("expected NameError from session b, got none")
+	}
+
+	// Ending a session discards its namespace.
+	if err := p.EndSession(ctx, "a"); err != nil {
+		t.Fatalf("EndSession error: %v", err)
+	}
+	if _, err := p.EvalSession(ctx, "a", []byte("print(x)")); err == nil {
+		t.Fatalf("expected NameError after EndSession, got none")
+	}
+}
+
+func TestExecModule_ReportsExportsAndImportErrors(t *testing.T) {
+	dir := t.TempDir()
+	helper := `"""Helpers for the exec-module test."""
+
+def greet(name):
+    return f"hello {name}"
+
+VERSION = 1
+
+def _private():
+    pass
+`
+	if err := os.WriteFile(filepath.Join(dir, "helpers.py"), []byte(helper), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	p, err := StartPythonWorker(pickPythonCmd(t), dir)
+	if err != nil {
+		t.Fatalf("StartPythonWorker error: %v", err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+
+	info, err := p.ExecModule(context.Background(), "helpers")
+	if err != nil {
+		t.Fatalf("ExecModule error: %v", err)
+	}
+	if info.Doc != "Helpers for the exec-module test." {
+		t.Errorf("Doc = %q", info.Doc)
+	}
+	if !strings.HasSuffix(info.Path, "helpers.py") {
+		t.Errorf("Path = %q, want the helper file", info.Path)
+	}
+	got := strings.Join(info.Exports, ",")
+	if !strings.Contains(got, "greet") || !strings.Contains(got, "VERSION") {
+		t.Errorf("Exports = %v, want greet and VERSION", info.Exports)
+	}
+	if strings.Contains(got, "_private") {
+		t.Errorf("Exports = %v, private names should be excluded", info.Exports)
+	}
+
+	_, err = p.ExecModule(context.Background(), "no_such_helper")
+	if err == nil {
+		t.Fatalf("expected import error for missing module")
+	}
+	if !strings.Contains(err.Error(), "no_such_helper") {
+		t.Errorf("error %q should name the module", err)
+	}
+}
+
+func TestConcurrentEval_IOBoundSnippetsOverlap(t *testing.T) {
+	p := mustStart(t)
+
+	// Start a slow snippet, then a fast one; with multiplexing the fast one
+	// comes back while the slow one is still sleeping.
+	slowDone := make(chan error, 1)
+	go func() {
+		_, err := p.Eval(context.Background(), "block", []byte("import time\ntime.sleep(2)\n"))
+		slowDone <- err
+	}()
+
+	// Give the slow request a moment to get in flight.
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	out, err := p.Eval(context.Background(), "stmt", []byte(`"fast"`))
+	if err != nil {
+		t.Fatalf("fast eval error: %v", err)
+	}
+	if string(out) != "fast" {
+		t.Fatalf("unexpected out: %q", string(out))
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("fast eval took %v; requests are not overlapping", d)
+	}
+
+	if err := <-slowDone; err != nil {
+		t.Fatalf("slow eval error: %v", err)
+	}
+}
+
+func TestEvalAsync_QueuesManyAndCollects(t *testing.T) {
+	p := mustStart(t)
+	ctx := context.Background()
+
+	const n = 10
+	chans := make([]<-chan Result, n)
+	for i := range n {
+		chans[i] = p.EvalAsync(ctx, "stmt", []byte(`f"val=`+strconv.Itoa(i)+`"`))
+	}
+	for i, ch := range chans {
+		r := <-ch
+		if r.Err != nil {
+			t.Fatalf("async eval %d error: %v", i, r.Err)
+		}
+		if string(r.Out) != "val="+strconv.Itoa(i) {
+			t.Fatalf("async eval %d out = %q", i, r.Out)
+		}
+	}
+}
+
+func TestEvalAsync_DeliversErrors(t *testing.T) {
+	p := mustStart(t)
+
+	r := <-p.EvalAsync(context.Background(), "nope", []byte("1"))
+	if r.Err == nil || !strings.Contains(r.Err.Error(), "invalid kind") {
+		t.Fatalf("expected invalid kind error, got %v", r.Err)
+	}
+
+	r = <-p.EvalAsync(context.Background(), "stmt", []byte("1/0"))
+	var pe *PythonError
+	if !errors.As(r.Err, &pe) {
+		t.Fatalf("expected *PythonError, got %T: %v", r.Err, r.Err)
+	}
+	if !strings.Contains(pe.ErrMsg, "division by zero") {
+		t.Fatalf("unexpected error message: %q", pe.ErrMsg)
+	}
+}
+
+func TestSetMaxResponseSize_RejectsOversizedOutput(t *testing.T) {
+	p := mustStart(t)
+
+	// Large-but-legal output passes under the default limit.
+	out, err := p.Eval(context.Background(), "stmt", []byte(`"y" * (2 << 20)`))
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	if len(out) != 2<<20 {
+		t.Fatalf("len(out) = %d", len(out))
+	}
+
+	p.SetMaxResponseSize(1024)
+	_, err = p.Eval(context.Background(), "stmt", []byte(`"x" * 10000`))
+	if err == nil {
+		t.Fatalf("expected oversized-response error")
+	}
+	if !strings.Contains(err.Error(), "exceeds 1024 bytes") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCompressedPayloads_RoundTripLargePayloads(t *testing.T) {
+	p := mustStart(t)
+	ctx := context.Background()
+
+	// Code above the compression threshold travels as code_gz.
+	big := strings.Repeat("a", 40000)
+	out, err := p.Eval(ctx, "stmt", []byte(`"`+big+`"[:5]`))
+	if err != nil {
+		t.Fatalf("Eval large-code error: %v", err)
+	}
+	if string(out) != "aaaaa" {
+		t.Fatalf("unexpected out: %q", string(out))
+	}
+
+	// Output above the threshold comes back as out_gz.
+	out, err = p.Eval(ctx, "stmt", []byte(`"xy" * 50000`))
+	if err != nil {
+		t.Fatalf("Eval large-output error: %v", err)
+	}
+	if len(out) != 100000 || string(out[:4]) != "xyxy" {
+		t.Fatalf("unexpected out: len=%d head=%q", len(out), out[:4])
+	}
+}
+
+func TestCrashForensics_ReportStatusStderrAndRequests(t *testing.T) {
+	p := mustStart(t)
+
+	_, err := p.Eval(context.Background(), "block", []byte(
+		"import os\n"+
+			"os.write(2, b\"native extension went boom\\n\")\n"+
+			"os._exit(3)\n"))
+	if err == nil {
+		t.Fatalf("expected error from dying worker")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		"worker exited: exit status 3",
+		"native extension went boom",
+		"last requests sent:",
+		"block",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error missing %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestSetTrace_RecordsRequestsAndResponses(t *testing.T) {
+	p := mustStart(t)
+
+	var buf strings.Builder
+	p.SetTrace(&buf, 10)
+
+	out, err := p.Eval(context.Background(), "stmt", []byte(`"0123456789abcdef"`))
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	if string(out) != "0123456789abcdef" {
+		t.Fatalf("unexpected out: %q", string(out))
+	}
+
+	trace := buf.String()
+	if !strings.Contains(trace, "> #") || !strings.Contains(trace, "< #") {
+		t.Fatalf("trace missing request/response lines:\n%s", trace)
+	}
+	if !strings.Contains(trace, `"0123456789...`) {
+		t.Fatalf("trace not truncated at 10 bytes:\n%s", trace)
+	}
+
+	// A nil writer turns tracing back off.
+	p.SetTrace(nil, 0)
+	mark := buf.Len()
+	if _, err := p.Eval(context.Background(), "stmt", []byte(`"x"`)); err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	if buf.Len() != mark {
+		t.Fatalf("trace grew after being disabled")
+	}
+}
+
+func mustStartEvaluator(t *testing.T) *Evaluator {
+	t.Helper()
+
+	e, err := NewEvaluator(pickPythonCmd(t), "")
+	if err != nil {
+		t.Fatalf("NewEvaluator error: %v", err)
+	}
+	t.Cleanup(func() { _ = e.Close() })
+	return e
+}
+
+// crashOnceBlock crashes the worker the first time it runs and prints
+// "recovered" on any later attempt, keyed off a marker file.
+func crashOnceBlock(t *testing.T) []byte {
+	t.Helper()
+
+	marker := filepath.Join(t.TempDir(), "crashed")
+	return []byte(fmt.Sprintf(`import os
+if not os.path.exists(%q):
+    open(%q, "w").close()
+    os._exit(3)
+print("recovered")`, marker, marker))
+}
+
+func TestRetryPolicy_RestartsWorkerAfterCrash(t *testing.T) {
+	e := mustStartEvaluator(t)
+	e.SetRetryPolicy(1, 10*time.Millisecond)
+
+	out, err := e.Eval(context.Background(), core.RegionTypePythonBlock, crashOnceBlock(t))
+	if err != nil {
+		t.Fatalf("Eval should succeed on the retry, got: %v", err)
+	}
+	if !strings.Contains(string(out), "recovered") {
+		t.Errorf("retried output = %q, want it to contain %q", string(out), "recovered")
+	}
+	if got := e.Metrics().Snapshot().Restarts; got != 1 {
+		t.Errorf("restarts = %d, want 1", got)
+	}
+}
+
+func TestRetryPolicy_DoesNotRetryPythonExceptions(t *testing.T) {
+	e := mustStartEvaluator(t)
+	e.SetRetryPolicy(3, time.Millisecond)
+
+	_, err := e.Eval(context.Background(), core.RegionTypePythonBlock, []byte(`raise ValueError("nope")`))
+	if err == nil {
+		t.Fatal("expected the exception to surface")
+	}
+	var perr *PythonError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected *PythonError, got %T: %v", err, err)
+	}
+	if got := e.Metrics().Snapshot().Restarts; got != 0 {
+		t.Errorf("restarts = %d, want 0 for a python exception", got)
+	}
+}
+
+func TestRetryPolicy_GivesUpAfterConfiguredRetries(t *testing.T) {
+	e := mustStartEvaluator(t)
+	e.SetRetryPolicy(1, time.Millisecond)
+
+	_, err := e.Eval(context.Background(), core.RegionTypePythonBlock, []byte("import os\nos._exit(3)"))
+	if err == nil {
+		t.Fatal("expected an error once retries are exhausted")
+	}
+	if !strings.Contains(err.Error(), "worker exited") {
+		t.Errorf("error = %v, want the transport failure surfaced", err)
+	}
+	if got := e.Metrics().Snapshot().Restarts; got != 1 {
+		t.Errorf("restarts = %d, want 1", got)
+	}
+}
+
+func TestRetryPolicy_DefaultIsNoRetries(t *testing.T) {
+	e := mustStartEvaluator(t)
+
+	if _, err := e.Eval(context.Background(), core.RegionTypePythonBlock, []byte("import os\nos._exit(3)")); err == nil {
+		t.Fatal("expected the crash to surface without a retry policy")
+	}
+	if got := e.Metrics().Snapshot().Restarts; got != 0 {
+		t.Errorf("restarts = %d, want 0 by default", got)
+	}
+}
+
+func TestShutdown_CleanExitDespiteNonDaemonThread(t *testing.T) {
+	p := mustStart(t)
+
+	// A non-daemon thread would keep interpreter teardown waiting forever
+	// under plain stdin-EOF shutdown.
+	code := []byte(`import threading, time
+threading.Thread(target=lambda: time.sleep(60)).start()
+print("spawned")`)
+	if _, err := p.Eval(context.Background(), "block", code); err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+
+	start := time.Now()
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("Close took %v; the exit request should bypass thread teardown", d)
+	}
+}
+
+func TestShutdown_KillsWorkerPastDeadline(t *testing.T) {
+	p := mustStart(t)
+
+	// Leave a request in flight so the worker's drain blocks.
+	ch := p.EvalAsync(context.Background(), "block", []byte("import time\ntime.sleep(60)"))
+	time.Sleep(200 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := p.Shutdown(ctx)
+	if err == nil {
+		t.Fatal("expected Shutdown to report the missed deadline")
+	}
+	if !strings.Contains(err.Error(), "killed") {
+		t.Errorf("Shutdown error = %v, want the kill mentioned", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Shutdown took %v despite the deadline", d)
+	}
+
+	// The abandoned request fails rather than hanging.
+	select {
+	case r := <-ch:
+		if r.Err == nil {
+			t.Error("in-flight request should fail once the worker is killed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("in-flight request never completed after the kill")
+	}
+
+	// Later calls return the first result.
+	if err2 := p.Close(); err2 == nil || err2.Error() != err.Error() {
+		t.Errorf("Close after Shutdown = %v, want the original %v", err2, err)
+	}
+}
+
+func TestClose_ReapsSnippetSpawnedSubprocesses(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process-group cleanup is unix-only")
+	}
+	p := mustStart(t)
+
+	// Spawn a long-lived child from a snippet and capture its pid.
+	code := []byte(`import subprocess, sys
+child = subprocess.Popen([sys.executable, "-c", "import time; time.sleep(60)"])
+print(child.pid)`)
+	out, err := p.Eval(context.Background(), "block", code)
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		t.Fatalf("unexpected pid output %q: %v", string(out), err)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	// Signal 0 probes existence; the group kill is synchronous but give the
+	// OS a moment to reap.
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		err := syscall.Kill(pid, 0)
+		if err == syscall.ESRCH {
+			return // child is gone
+		}
+		if time.Now().After(deadline) {
+			_ = syscall.Kill(pid, 9)
+			t.Fatalf("child %d still alive after Close (kill(0) = %v)", pid, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestSandbox_GuardsSnippetFileAccess(t *testing.T) {
+	readable := t.TempDir()
+	if err := os.WriteFile(filepath.Join(readable, "data.txt"), []byte("visible"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	secretDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(secretDir, "secret.txt"), []byte("hidden"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	scratch := t.TempDir()
+
+	p, err := StartSandboxedPythonWorker(pickPythonCmd(t), "", &SandboxConfig{
+		ReadDirs: []string{readable},
+		WriteDir: scratch,
+	})
+	if err != nil {
+		t.Fatalf("StartSandboxedPythonWorker error: %v", err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+	ctx := context.Background()
+
+	// Reads under a read root and writes under the scratch dir work.
+	out, err := p.Eval(ctx, "block", []byte(fmt.Sprintf(
+		"print(open(%q).read())", filepath.Join(readable, "data.txt"))))
+	if err != nil {
+		t.Fatalf("read under a read root failed: %v", err)
+	}
+	if !strings.Contains(string(out), "visible") {
+		t.Errorf("read output = %q, want the file contents", string(out))
+	}
+	if _, err := p.Eval(ctx, "block", []byte(fmt.Sprintf(
+		"open(%q, 'w').write('ok')", filepath.Join(scratch, "out.txt")))); err != nil {
+		t.Fatalf("write under the scratch dir failed: %v", err)
+	}
+
+	// Reads outside the roots and writes outside the scratch dir fail.
+	if _, err := p.Eval(ctx, "block", []byte(fmt.Sprintf(
+		"open(%q).read()", filepath.Join(secretDir, "secret.txt")))); err == nil {
+		t.Error("expected a read outside the read roots to fail")
+	}
+	if _, err := p.Eval(ctx, "block", []byte(fmt.Sprintf(
+		"open(%q, 'w').write('leak')", filepath.Join(secretDir, "leak.txt")))); err == nil {
+		t.Error("expected a write outside the scratch dir to fail")
+	}
+}
+
+func TestSandbox_NilConfigIsUnrestricted(t *testing.T) {
+	p := mustStart(t)
+
+	path := filepath.Join(t.TempDir(), "free.txt")
+	if _, err := p.Eval(context.Background(), "block", []byte(fmt.Sprintf(
+		"open(%q, 'w').write('fine')", path))); err != nil {
+		t.Fatalf("unsandboxed write failed: %v", err)
+	}
+}
+
+func TestJapayaAPI_EpochAndSeedFromScopeEnv(t *testing.T) {
+	t.Parallel()
+
+	p := mustStart(t)
+	p.SetSnippetScope("", map[string]string{"JAPAYA_EPOCH": "946684800", "JAPAYA_SEED": "7"})
+
+	out, err := p.Eval(context.Background(), "stmt", []byte(`f"{japaya.epoch}/{japaya.seed}"`))
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if string(out) != "946684800/7" {
+		t.Fatalf("japaya.epoch/seed = %q, want %q", out, "946684800/7")
+	}
+
+	// Outside the scope the knobs read as unset.
+	p.SetSnippetScope("", map[string]string{"SOURCE_DATE_EPOCH": ""})
+	out, err = p.Eval(context.Background(), "stmt", []byte(`f"{japaya.seed}"`))
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if string(out) != "None" {
+		t.Fatalf("japaya.seed = %q, want None", out)
+	}
+}
+
+func TestPycache_PrecompilesPythonDirIntoPrefix(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("PYTHONPYCACHEPREFIX", cacheDir)
+
+	pyDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(pyDir, "helper.py"),
+		[]byte("def shout(s):\n    return s.upper()\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := StartPythonWorker(pickPythonCmd(t), pyDir)
+	if err != nil {
+		t.Fatalf("StartPythonWorker: %v", err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+
+	// compileall at startup should have populated the cache prefix.
+	var pycs int
+	err = filepath.WalkDir(cacheDir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && strings.HasSuffix(path, ".pyc") {
+			pycs++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("walk cache dir: %v", err)
+	}
+	if pycs == 0 {
+		t.Errorf("no .pyc files under %q after startup", cacheDir)
+	}
+
+	// The helper still imports normally with the cache prefix in place.
+	out, err := p.Eval(context.Background(), "stmt", []byte(`__import__("helper").shout("ok")`))
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if string(out) != "OK" {
+		t.Fatalf("helper.shout = %q, want %q", out, "OK")
+	}
+}
+
+func TestJapayaAPI_PackageFromScopeEnv(t *testing.T) {
+	t.Parallel()
+
+	p := mustStart(t)
+	p.SetSnippetScope("", map[string]string{"JAPAYA_PACKAGE": "com.example"})
+
+	out, err := p.Eval(context.Background(), "stmt", []byte(`japaya.package`))
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if string(out) != "com.example" {
+		t.Fatalf("japaya.package = %q, want %q", out, "com.example")
+	}
+
+	// Without inference configured the knob reads as unset.
+	p.SetSnippetScope("", map[string]string{"JAPAYA_SEED": ""})
+	out, err = p.Eval(context.Background(), "stmt", []byte(`japaya.package is None`))
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if string(out) != "True" {
+		t.Fatalf("japaya.package = %q, want None", out)
+	}
+}
+
+func TestJapayaAPI_TmpdirFromScopeEnv(t *testing.T) {
+	t.Parallel()
+
+	p := mustStart(t)
+	dir := t.TempDir()
+	p.SetSnippetScope("", map[string]string{"JAPAYA_TMPDIR": dir})
+
+	out, err := p.Eval(context.Background(), "stmt", []byte(`japaya.tmpdir`))
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if string(out) != dir {
+		t.Fatalf("japaya.tmpdir = %q, want %q", out, dir)
+	}
+
+	// The directory is writable from snippets.
+	if _, err := p.Eval(context.Background(), "block", []byte(
+		"import os\nopen(os.path.join(japaya.tmpdir, 'scratch.txt'), 'w').write('ok')")); err != nil {
+		t.Fatalf("write into japaya.tmpdir: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "scratch.txt")); err != nil {
+		t.Fatalf("scratch file: %v", err)
+	}
+
+	// Outside the scope the knob reads as unset.
+	p.SetSnippetScope("", map[string]string{"JAPAYA_SEED": ""})
+	out, err = p.Eval(context.Background(), "stmt", []byte(`f"{japaya.tmpdir}"`))
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if string(out) != "None" {
+		t.Fatalf("japaya.tmpdir = %q, want None", out)
+	}
+}
+
+func TestEvalTyped_BytesAndFilesResults(t *testing.T) {
+	t.Parallel()
+
+	p := mustStart(t)
+	ctx := context.Background()
+
+	// A plain expression arrives as a text result.
+	res, err := p.EvalTyped(ctx, "stmt", []byte(`"hi"`))
+	if err != nil {
+		t.Fatalf("EvalTyped: %v", err)
+	}
+	if res.Type != "text" || res.Text != "hi" {
+		t.Fatalf("typed result = %+v, want text \"hi\"", res)
+	}
+
+	// japaya.binary round-trips raw bytes, NULs included.
+	res, err = p.EvalTyped(ctx, "stmt", []byte(`japaya.binary(b"\x00\x01\xff")`))
+	if err != nil {
+		t.Fatalf("EvalTyped binary: %v", err)
+	}
+	if res.Type != "bytes" || !bytes.Equal(res.Data, []byte{0x00, 0x01, 0xff}) {
+		t.Fatalf("typed result = %+v, want the raw bytes back", res)
+	}
+
+	// japaya.files carries a path-sorted list of named payloads.
+	res, err = p.EvalTyped(ctx, "stmt", []byte(`japaya.files({"b.bin": b"\x02", "a.txt": "A"})`))
+	if err != nil {
+		t.Fatalf("EvalTyped files: %v", err)
+	}
+	if res.Type != "files" || len(res.Files) != 2 {
+		t.Fatalf("typed result = %+v, want two files", res)
+	}
+	if res.Files[0].Path != "a.txt" || string(res.Files[0].Data) != "A" ||
+		res.Files[1].Path != "b.bin" || !bytes.Equal(res.Files[1].Data, []byte{0x02}) {
+		t.Fatalf("files = %+v, want a.txt then b.bin", res.Files)
+	}
+}
+
+func TestEval_UnwrapsTypedResults(t *testing.T) {
+	t.Parallel()
+
+	p := mustStart(t)
+	ctx := context.Background()
+
+	// Bytes results flow through the classic Eval path untouched.
+	out, err := p.Eval(ctx, "stmt", []byte(`japaya.binary(b"\x00raw")`))
+	if err != nil {
+		t.Fatalf("Eval binary: %v", err)
+	}
+	if !bytes.Equal(out, []byte("\x00raw")) {
+		t.Fatalf("out = %q, want the raw bytes", out)
+	}
+
+	// Multi-file results don't fit a byte slice; Eval says so.
+	if _, err := p.Eval(ctx, "stmt", []byte(`japaya.files({"a": "A"})`)); err == nil ||
+		!strings.Contains(err.Error(), "EvalTyped") {
+		t.Fatalf("err = %v, want a pointer at EvalTyped", err)
+	}
+}
+
+func TestEmitResource_RoundTripsThroughWorker(t *testing.T) {
+	t.Parallel()
+
+	p := mustStart(t)
+	ctx := context.Background()
+
+	out, err := p.Eval(ctx, "block", []byte(
+		"japaya.emit_resource('i18n/messages.properties', 'greeting=hi\\n')\nprint('done', end='')"))
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if string(out) != "done" {
+		t.Fatalf("out = %q, want spliced output unaffected", out)
+	}
+
+	resources := p.TakeResources()
+	if len(resources) != 1 {
+		t.Fatalf("resources = %+v, want one entry", resources)
+	}
+	if resources[0].Path != "i18n/messages.properties" || string(resources[0].Data) != "greeting=hi\n" {
+		t.Fatalf("resource = %+v", resources[0])
+	}
+	if got := p.TakeResources(); got != nil {
+		t.Fatalf("second TakeResources = %+v, want drained", got)
+	}
+
+	// Escaping paths are rejected in the worker, before anything is queued.
+	if _, err := p.Eval(ctx, "block", []byte(
+		"japaya.emit_resource('../evil.txt', 'x')")); err == nil ||
+		!strings.Contains(err.Error(), "escapes the resources root") {
+		t.Fatalf("err = %v, want a path escape rejection", err)
+	}
+}
+
+func TestSlot_RoundTripsThroughWorker(t *testing.T) {
+	t.Parallel()
+
+	p := mustStart(t)
+	ctx := context.Background()
+
+	if _, err := p.Eval(ctx, "stmt", []byte(
+		`(japaya.slot("imports", "import java.util.List;"), "ok")[1]`)); err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if _, err := p.Eval(ctx, "block", []byte(
+		"japaya.slot('imports', 'import java.util.Map;')")); err != nil {
+		t.Fatalf("Eval block: %v", err)
+	}
+
+	slots := p.TakeSlots()
+	if len(slots) != 2 {
+		t.Fatalf("slots = %+v, want two appends", slots)
+	}
+	if slots[0].Name != "imports" || slots[0].Text != "import java.util.List;" ||
+		slots[1].Text != "import java.util.Map;" {
+		t.Fatalf("slots = %+v, want the appends in emission order", slots)
+	}
+	if got := p.TakeSlots(); got != nil {
+		t.Fatalf("second TakeSlots = %+v, want drained", got)
+	}
+}
+
+func TestSkip_SurfacesAsFileSkippedError(t *testing.T) {
+	t.Parallel()
+
+	p := mustStart(t)
+	ctx := context.Background()
+
+	_, err := p.Eval(ctx, "block", []byte(`japaya.skip("feature off")`))
+	var skip *core.FileSkippedError
+	if !errors.As(err, &skip) || skip.Reason != "feature off" {
+		t.Fatalf("err = %v, want FileSkippedError with the snippet's reason", err)
+	}
+
+	// The worker stays usable after a skip.
+	out, err := p.Eval(ctx, "stmt", []byte("1 + 1"))
+	if err != nil || string(out) != "2" {
+		t.Fatalf("Eval after skip = (%q, %v), want 2", out, err)
+	}
+}
+
+func TestSetOutput_RoundTripsThroughWorker(t *testing.T) {
+	t.Parallel()
+
+	p := mustStart(t)
+	ctx := context.Background()
+
+	if _, err := p.Eval(ctx, "block", []byte(
+		"japaya.set_output('com/example/v1/Foo.java')\n"+
+			"japaya.set_output('com/example/v2/Foo.java')")); err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if got := p.TakeOutputPath(); got != "com/example/v2/Foo.java" {
+		t.Fatalf("TakeOutputPath = %q, want the last override", got)
+	}
+	if got := p.TakeOutputPath(); got != "" {
+		t.Fatalf("second TakeOutputPath = %q, want drained", got)
+	}
+
+	if _, err := p.Eval(ctx, "stmt", []byte(`japaya.set_output("../escape.java")`)); err == nil {
+		t.Fatal("escaping output path should fail")
+	}
+}
+
+func TestRequireImport_RoundTripsThroughWorker(t *testing.T) {
+	t.Parallel()
+
+	p := mustStart(t)
+	ctx := context.Background()
+
+	if _, err := p.Eval(ctx, "block", []byte(
+		"japaya.require_import('java.util.Map')\n"+
+			"japaya.require_import('java.util.List')\n"+
+			"japaya.require_import('java.util.List')")); err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+
+	got := p.TakeRequiredImports()
+	want := []string{"java.util.List", "java.util.Map"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("TakeRequiredImports = %v, want %v", got, want)
+	}
+	if got := p.TakeRequiredImports(); got != nil {
+		t.Fatalf("second TakeRequiredImports = %v, want drained", got)
+	}
+
+	if _, err := p.Eval(ctx, "stmt", []byte(`japaya.require_import("")`)); err == nil {
+		t.Fatal("empty import name should fail")
+	}
+}
+
+func TestStmt_StatementsProduceTargetedError(t *testing.T) {
+	t.Parallel()
+	p := mustStart(t)
+
+	_, err := p.Eval(context.Background(), "stmt", []byte("x = 1"))
+	if err == nil {
+		t.Fatal("want an error for statements in a statement region")
+	}
+	if !strings.Contains(err.Error(), "use a ```block``` region") {
+		t.Errorf("err = %v, want a pointer at block regions", err)
+	}
+
+	// Genuinely malformed code still reads as a syntax error.
+	_, err = p.Eval(context.Background(), "stmt", []byte("1 +"))
+	if err == nil {
+		t.Fatal("want an error for malformed code")
+	}
+	if !strings.Contains(err.Error(), "invalid syntax") {
+		t.Errorf("err = %v, want the original syntax error", err)
+	}
+}
+
+func TestBlock_ErrorReportsSnippetLine(t *testing.T) {
+	t.Parallel()
+	p := mustStart(t)
+
+	_, err := p.Eval(context.Background(), "block", []byte("x = 1\n1/0\nprint(x)\n"))
+	if err == nil {
+		t.Fatal("want an error for a failing block")
+	}
+	var pe *PythonError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected *PythonError, got %T: %v", err, err)
+	}
+	if pe.SnippetLine() != 2 {
+		t.Errorf("SnippetLine() = %d, want 2", pe.SnippetLine())
+	}
+
+	// Errors raised inside helpers still point at the snippet line that
+	// called them.
+	_, err = p.Eval(context.Background(), "block", []byte("def f():\n    raise ValueError('boom')\nf()\n"))
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected *PythonError, got %T: %v", err, err)
+	}
+	if pe.SnippetLine() != 2 {
+		t.Errorf("SnippetLine() = %d, want the deepest snippet frame (2)", pe.SnippetLine())
+	}
+}
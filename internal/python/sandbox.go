@@ -0,0 +1,88 @@
+package python
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// SandboxConfig confines the worker's filesystem access: snippets may read
+// only under ReadDirs (plus the python-dir and the interpreter's own files)
+// and write only under WriteDir. Enforcement is OS-level where available —
+// bwrap on Linux puts the worker in a mount namespace with read-only binds —
+// and falls back to guarding builtins.open inside the worker everywhere
+// else. The fallback stops honest mistakes, not determined snippets.
+type SandboxConfig struct {
+	ReadDirs []string // directory trees snippets may read
+	WriteDir string   // the only tree snippets may write; "" denies all writes
+}
+
+// sandboxSpec is the JSON handed to the worker's open() guard via
+// JAPAYA_SANDBOX.
+type sandboxSpec struct {
+	Read  []string `json:"read"`
+	Write string   `json:"write,omitempty"`
+}
+
+// sandboxEnv renders the guard spec for the worker. pythonDir rides along so
+// helper modules stay importable.
+func sandboxEnv(sb *SandboxConfig, pythonDir string) string {
+	spec := sandboxSpec{Read: append([]string(nil), sb.ReadDirs...), Write: sb.WriteDir}
+	if pythonDir != "" {
+		spec.Read = append(spec.Read, pythonDir)
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// sandboxCommand wraps the worker invocation in bwrap when OS-level
+// confinement is available, binding the system prefixes and the configured
+// directories and nothing else. roDirs are extra read-only binds the worker
+// itself needs (its temp dir, the python-dir). When bwrap isn't available
+// the command runs unwrapped and the in-worker guard is the only
+// enforcement.
+func sandboxCommand(pythonCmd string, args []string, sb *SandboxConfig, roDirs []string) (string, []string) {
+	if sb == nil || runtime.GOOS != "linux" {
+		return pythonCmd, args
+	}
+	bwrap, err := exec.LookPath("bwrap")
+	if err != nil {
+		return pythonCmd, args
+	}
+
+	bargs := []string{"--die-with-parent", "--proc", "/proc", "--dev", "/dev", "--tmpfs", "/tmp"}
+	for _, d := range []string{"/usr", "/lib", "/lib64", "/bin", "/sbin", "/etc"} {
+		if _, err := os.Stat(d); err == nil {
+			bargs = append(bargs, "--ro-bind", d, d)
+		}
+	}
+	// The interpreter may live outside the system prefixes (pyenv, venvs);
+	// bind its installation too.
+	if py, err := exec.LookPath(pythonCmd); err == nil {
+		if resolved, err := filepath.EvalSymlinks(py); err == nil {
+			prefix := filepath.Dir(filepath.Dir(resolved))
+			bargs = append(bargs, "--ro-bind", prefix, prefix)
+		}
+	}
+	for _, d := range roDirs {
+		if d != "" {
+			bargs = append(bargs, "--ro-bind", d, d)
+		}
+	}
+	for _, d := range sb.ReadDirs {
+		if d != "" {
+			bargs = append(bargs, "--ro-bind", d, d)
+		}
+	}
+	if sb.WriteDir != "" {
+		bargs = append(bargs, "--bind", sb.WriteDir, sb.WriteDir)
+	}
+	bargs = append(bargs, "--")
+	bargs = append(bargs, pythonCmd)
+	return bwrap, append(bargs, args...)
+}
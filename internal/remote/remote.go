@@ -0,0 +1,189 @@
+// Package remote runs python evaluation on another host: a Server wraps a
+// local evaluator and speaks a JSON-lines protocol over TCP — usually with
+// mutual TLS — and a Client implements core.PythonEvaluator against it.
+// Locked-down build agents that forbid local interpreters can point japaya
+// at a managed evaluator service this way.
+package remote
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/justinottesen/japaya/internal/core"
+)
+
+// request and response are one JSON object per line each, mirroring the
+// local worker protocol's shape.
+type request struct {
+	ID   uint64 `json:"id"`
+	Kind string `json:"kind"` // "stmt" | "block"
+	Code string `json:"code"`
+}
+
+type response struct {
+	ID  uint64 `json:"id"`
+	OK  bool   `json:"ok"`
+	Out string `json:"out,omitempty"`
+	Err string `json:"err,omitempty"`
+}
+
+// MTLSConfig builds a TLS config for mutual authentication: both sides
+// present certificates signed by the CA in caFile. Pass server=true on the
+// serving side, which then rejects clients without a valid certificate.
+func MTLSConfig(certFile, keyFile, caFile string, server bool) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load key pair: %w", err)
+	}
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %q", caFile)
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if server {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = pool
+	} else {
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// Server evaluates requests from remote clients against a local evaluator.
+type Server struct {
+	py core.PythonEvaluator
+}
+
+func NewServer(py core.PythonEvaluator) *Server {
+	return &Server{py: py}
+}
+
+// Serve accepts connections until ln is closed, returning the accept error.
+// Each connection's requests are evaluated in order; connections are served
+// concurrently but share the one evaluator.
+func (s *Server) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		var req request
+		if err := dec.Decode(&req); err != nil {
+			return // disconnect or framing loss; the client will redial
+		}
+		resp := response{ID: req.ID}
+		if rt, ok := kindToRegion(req.Kind); !ok {
+			resp.Err = fmt.Sprintf("invalid kind %q (expected stmt|block)", req.Kind)
+		} else if out, err := s.py.Eval(context.Background(), rt, []byte(req.Code)); err != nil {
+			resp.Err = err.Error()
+		} else {
+			resp.OK = true
+			resp.Out = string(out)
+		}
+		if err := enc.Encode(&resp); err != nil {
+			return
+		}
+	}
+}
+
+func kindToRegion(kind string) (core.RegionType, bool) {
+	switch kind {
+	case "stmt":
+		return core.RegionTypePythonStatement, true
+	case "block":
+		return core.RegionTypePythonBlock, true
+	default:
+		return 0, false
+	}
+}
+
+// Client is a core.PythonEvaluator that forwards evaluations to a remote
+// Server over one connection. Calls are serialized on the connection, so
+// it has no use for the local worker's multiplexing.
+type Client struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	enc    *json.Encoder
+	dec    *json.Decoder
+	nextID uint64
+}
+
+// Dial connects to a remote evaluator. cfg is the client half of
+// MTLSConfig; nil dials plain TCP, for tests and trusted networks only.
+func Dial(addr string, cfg *tls.Config) (*Client, error) {
+	var conn net.Conn
+	var err error
+	if cfg != nil {
+		conn, err = tls.Dial("tcp", addr, cfg)
+	} else {
+		conn, err = net.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dial remote evaluator: %w", err)
+	}
+	return &Client{conn: conn, enc: json.NewEncoder(conn), dec: json.NewDecoder(conn)}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Eval satisfies core.PythonEvaluator. ctx deadlines become connection
+// deadlines, so a hung server fails the evaluation rather than the build.
+func (c *Client) Eval(ctx context.Context, t core.RegionType, code []byte) ([]byte, error) {
+	var kind string
+	switch t {
+	case core.RegionTypePythonStatement:
+		kind = "stmt"
+	case core.RegionTypePythonBlock:
+		kind = "block"
+	default:
+		return nil, fmt.Errorf("remote evaluator received non-python region type: %v", t)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Time{}
+	}
+	_ = c.conn.SetDeadline(deadline)
+
+	c.nextID++
+	req := request{ID: c.nextID, Kind: kind, Code: string(code)}
+	if err := c.enc.Encode(&req); err != nil {
+		return nil, fmt.Errorf("send to remote evaluator: %w", err)
+	}
+	var resp response
+	if err := c.dec.Decode(&resp); err != nil {
+		return nil, fmt.Errorf("read from remote evaluator: %w", err)
+	}
+	if resp.ID != req.ID {
+		return nil, fmt.Errorf("remote evaluator answered request %d, expected %d", resp.ID, req.ID)
+	}
+	if !resp.OK {
+		return nil, fmt.Errorf("remote python eval failed: %s", resp.Err)
+	}
+	return []byte(resp.Out), nil
+}
@@ -0,0 +1,234 @@
+package remote
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justinottesen/japaya/internal/core"
+)
+
+// fakeEval is a stand-in for the python evaluator: it uppercases statements
+// and fails code containing "boom".
+type fakeEval struct{}
+
+func (fakeEval) Eval(_ context.Context, t core.RegionType, code []byte) ([]byte, error) {
+	if strings.Contains(string(code), "boom") {
+		return nil, errors.New("NameError: name 'boom' is not defined")
+	}
+	if t == core.RegionTypePythonBlock {
+		return []byte("block:" + string(code)), nil
+	}
+	return []byte(strings.ToUpper(string(code))), nil
+}
+
+// serve starts a Server for fakeEval on ln and stops it with the test.
+func serve(t *testing.T, ln net.Listener) {
+	t.Helper()
+	srv := NewServer(fakeEval{})
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = ln.Close() })
+}
+
+func TestClientServer_PlainTCPRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	serve(t, ln)
+
+	c, err := Dial(ln.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	out, err := c.Eval(ctx, core.RegionTypePythonStatement, []byte("hello"))
+	if err != nil {
+		t.Fatalf("Eval stmt: %v", err)
+	}
+	if string(out) != "HELLO" {
+		t.Fatalf("stmt output = %q, want %q", out, "HELLO")
+	}
+
+	out, err = c.Eval(ctx, core.RegionTypePythonBlock, []byte("x = 1"))
+	if err != nil {
+		t.Fatalf("Eval block: %v", err)
+	}
+	if string(out) != "block:x = 1" {
+		t.Fatalf("block output = %q, want %q", out, "block:x = 1")
+	}
+
+	if _, err := c.Eval(ctx, core.RegionTypePythonStatement, []byte("boom")); err == nil {
+		t.Fatal("expected remote evaluation error")
+	} else if !strings.Contains(err.Error(), "NameError") {
+		t.Fatalf("error %q should carry the remote message", err)
+	}
+}
+
+func TestClientServer_MTLSRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	caFile, serverCert, serverKey, clientCert, clientKey := writeTestPKI(t)
+
+	serverCfg, err := MTLSConfig(serverCert, serverKey, caFile, true)
+	if err != nil {
+		t.Fatalf("server MTLSConfig: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	serve(t, tls.NewListener(ln, serverCfg))
+
+	clientCfg, err := MTLSConfig(clientCert, clientKey, caFile, false)
+	if err != nil {
+		t.Fatalf("client MTLSConfig: %v", err)
+	}
+	c, err := Dial(ln.Addr().String(), clientCfg)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+
+	out, err := c.Eval(context.Background(), core.RegionTypePythonStatement, []byte("secure"))
+	if err != nil {
+		t.Fatalf("Eval over mTLS: %v", err)
+	}
+	if string(out) != "SECURE" {
+		t.Fatalf("output = %q, want %q", out, "SECURE")
+	}
+}
+
+func TestClientServer_RejectsClientWithoutCertificate(t *testing.T) {
+	t.Parallel()
+
+	caFile, serverCert, serverKey, _, _ := writeTestPKI(t)
+
+	serverCfg, err := MTLSConfig(serverCert, serverKey, caFile, true)
+	if err != nil {
+		t.Fatalf("server MTLSConfig: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	serve(t, tls.NewListener(ln, serverCfg))
+
+	// Trusts the server but presents no certificate of its own.
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		t.Fatalf("read CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caPEM)
+	c, err := Dial(ln.Addr().String(), &tls.Config{RootCAs: pool})
+	if err == nil {
+		// The handshake may only fail on first use.
+		defer c.Close()
+		if _, err = c.Eval(context.Background(), core.RegionTypePythonStatement, []byte("x")); err == nil {
+			t.Fatal("expected the server to reject a client without a certificate")
+		}
+	}
+}
+
+func TestMTLSConfig_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, serverCert, serverKey, _, _ := writeTestPKI(t)
+	if _, err := MTLSConfig("missing.pem", serverKey, serverCert, true); err == nil {
+		t.Fatal("expected an error for a missing certificate file")
+	}
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MTLSConfig(serverCert, serverKey, empty, true); err == nil {
+		t.Fatal("expected an error for a CA file with no certificates")
+	}
+}
+
+// writeTestPKI generates a throwaway CA plus server and client leaf
+// certificates under a temp dir and returns their PEM file paths.
+func writeTestPKI(t *testing.T) (caFile, serverCert, serverKey, clientCert, clientKey string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	caPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate CA key: %v", err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "japaya test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caPriv.PublicKey, caPriv)
+	if err != nil {
+		t.Fatalf("create CA cert: %v", err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("parse CA cert: %v", err)
+	}
+	caFile = writePEM(t, dir, "ca.pem", "CERTIFICATE", caDER)
+
+	leaf := func(name string, serial int64, usage x509.ExtKeyUsage, ips []net.IP) (string, string) {
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("generate %s key: %v", name, err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "japaya test " + name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  ips,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &priv.PublicKey, caPriv)
+		if err != nil {
+			t.Fatalf("create %s cert: %v", name, err)
+		}
+		keyDER, err := x509.MarshalECPrivateKey(priv)
+		if err != nil {
+			t.Fatalf("marshal %s key: %v", name, err)
+		}
+		return writePEM(t, dir, name+".pem", "CERTIFICATE", der),
+			writePEM(t, dir, name+"-key.pem", "EC PRIVATE KEY", keyDER)
+	}
+
+	serverCert, serverKey = leaf("server", 2, x509.ExtKeyUsageServerAuth, []net.IP{net.IPv4(127, 0, 0, 1)})
+	clientCert, clientKey = leaf("client", 3, x509.ExtKeyUsageClientAuth, nil)
+	return caFile, serverCert, serverKey, clientCert, clientKey
+}
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
@@ -0,0 +1,50 @@
+// Package shell implements core.ShellEvaluator by running `sh!` region
+// contents through the system shell and splicing their stdout.
+//
+// This executes arbitrary commands from the input file, so the CLI only wires
+// it up when explicitly enabled (-allow-shell).
+package shell
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Evaluator runs commands via `sh -c` (`cmd /c` on Windows).
+type Evaluator struct{}
+
+func NewEvaluator() *Evaluator {
+	return &Evaluator{}
+}
+
+// Eval satisfies core.ShellEvaluator. A trailing newline on the command's
+// stdout is stripped, matching the usual $(...) substitution behavior.
+func (e *Evaluator) Eval(ctx context.Context, command []byte) ([]byte, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/c", string(command))
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", string(command))
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := fmt.Sprintf("shell command failed: %v", err)
+		if s := strings.TrimSpace(stderr.String()); s != "" {
+			msg += ": " + s
+		}
+		return nil, fmt.Errorf("%s", msg)
+	}
+
+	out := stdout.Bytes()
+	out = bytes.TrimSuffix(out, []byte("\n"))
+	return out, nil
+}
@@ -0,0 +1,40 @@
+package shell
+
+import (
+	"context"
+	"runtime"
+	"testing"
+)
+
+func TestEval_SplicesStdout(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses POSIX shell syntax")
+	}
+
+	e := NewEvaluator()
+
+	out, err := e.Eval(context.Background(), []byte("echo hello"))
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	// Trailing newline is stripped.
+	if string(out) != "hello" {
+		t.Fatalf("unexpected out: %q", string(out))
+	}
+}
+
+func TestEval_FailingCommandReportsStderr(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses POSIX shell syntax")
+	}
+
+	e := NewEvaluator()
+
+	if _, err := e.Eval(context.Background(), []byte("echo oops >&2; exit 3")); err == nil {
+		t.Fatalf("expected error for failing command")
+	}
+}
@@ -0,0 +1,65 @@
+// Package version exposes build metadata for the japaya binary.
+//
+// Version, Commit and Date may be stamped at build time:
+//
+//	go build -ldflags "-X github.com/justinottesen/japaya/internal/version.Version=v1.2.3"
+//
+// When they are not stamped we fall back to what the Go toolchain recorded in
+// the binary's build info (module version, vcs.revision).
+package version
+
+import "runtime/debug"
+
+var (
+	Version = "dev" // The module version (ldflags or build info)
+	Commit  = ""    // The git commit the binary was built from
+	Date    = ""    // The build date, if stamped
+)
+
+// resolve fills unstamped fields from the binary's build info.
+func resolve() (version, commit, date string) {
+	version, commit, date = Version, Commit, Date
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+
+	if version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		version = info.Main.Version
+	}
+	if commit == "" {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				commit = s.Value
+			}
+		}
+	}
+	if date == "" {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.time" {
+				date = s.Value
+			}
+		}
+	}
+	return
+}
+
+// String returns a single-line description like "japaya dev (abc1234)",
+// suitable for generated-file headers and manifests.
+func String() string {
+	version, commit, _ := resolve()
+	s := "japaya " + version
+	if commit != "" {
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		s += " (" + commit + ")"
+	}
+	return s
+}
+
+// Fields returns the resolved version, commit and build date.
+func Fields() (version, commit, date string) {
+	return resolve()
+}
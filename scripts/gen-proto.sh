@@ -0,0 +1,14 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+SCRIPT_DIR="$(cd -- "$(dirname -- "${BASH_SOURCE[0]}")" && pwd)"
+source "$SCRIPT_DIR/lib/repo.sh"
+
+ROOT_DIR="$(repo_root)"
+
+# Requires protoc plus protoc-gen-go and protoc-gen-go-grpc on PATH.
+protoc \
+  --proto_path="$ROOT_DIR/proto" \
+  --go_out="$ROOT_DIR/gen" --go_opt=paths=source_relative \
+  --go-grpc_out="$ROOT_DIR/gen" --go-grpc_opt=paths=source_relative \
+  "$ROOT_DIR/proto/japaya/v1/translator.proto"